@@ -116,18 +116,18 @@ func UpdateOrInsertPolicyYamlToDB(kyvernoPolicies []kyvernov1.PolicyInterface, l
 	}
 }
 
-// GetAdmissionControllerPolicy returns admission controller policies
-func GetAdmissionControllerPolicy(namespace, clusterName, labels, policyType string) []kyvernov1.Policy {
+// GetAdmissionControllerPolicy returns a page of the admission controller policies and the token of the next page
+func GetAdmissionControllerPolicy(namespace, clusterName, labels, policyType string, page types.PageRequest) ([]kyvernov1.Policy, string) {
 	var kyvernoPolicies []kyvernov1.Policy
 	filterOptions := types.PolicyFilter{
 		Cluster:   clusterName,
 		Namespace: namespace,
 		Labels:    libs.LabelMapFromString(labels),
 	}
-	policyYamls, err := libs.GetPolicyYamls(CfgDB, policyType, filterOptions)
+	policyYamls, next, err := libs.GetPolicyYamlsPage(CfgDB, policyType, filterOptions, page)
 	if err != nil {
 		log.Error().Msgf("fetching policy yaml from DB failed err=%v", err.Error())
-		return nil
+		return nil, ""
 	}
 	for _, policyYaml := range policyYamls {
 		var kyvernoPolicy kyvernov1.Policy
@@ -138,7 +138,7 @@ func GetAdmissionControllerPolicy(namespace, clusterName, labels, policyType str
 		}
 		kyvernoPolicies = append(kyvernoPolicies, kyvernoPolicy)
 	}
-	return kyvernoPolicies
+	return kyvernoPolicies, next
 }
 
 // ConvertPoliciesToWorkerResponse converts kyverno policies to worker response
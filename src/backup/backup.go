@@ -0,0 +1,515 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	cfg "github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	logger "github.com/accuknox/auto-policy-discovery/src/logging"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/rs/zerolog"
+)
+
+var log *zerolog.Logger
+
+func init() {
+	log = logger.GetInstance()
+}
+
+// ============ //
+// == Backup == //
+// ============ //
+
+// The discovery state is archived as a gzipped tar of a manifest, the config, and a file of
+// json lines for each of the tables. The records go through the store of the database driver,
+// so an archive exported from a driver is imported into any other.
+
+// ArchiveVersion is the version of the layout of the archives
+const ArchiveVersion = 1
+
+// the files of an archive
+const (
+	ManifestFile        = "manifest.json"
+	ConfigFile          = "config.json"
+	NetworkPoliciesFile = "network_policies.jsonl"
+	SystemPoliciesFile  = "system_policies.jsonl"
+	FileSetsFile        = "workload_process_filesets.jsonl"
+	PolicyYamlsFile     = "policy_yamls.jsonl"
+	SummariesFile       = "system_summaries.jsonl"
+	RollupsFile         = "system_summary_rollups.jsonl"
+)
+
+// policyYamlTypes are the types of the policy yamls of an archive
+var policyYamlTypes = []string{
+	types.PolicyTypeNetwork,
+	types.PolicyTypeSystem,
+	types.PolicyTypeAdmissionController,
+	types.PolicyTypeAdmissionControllerGeneric,
+	types.PolicyTypePolicyGap,
+}
+
+// ErrInvalidArchive is returned for an archive not starting with its manifest, or of records not decoded
+var ErrInvalidArchive = errors.New("invalid discovery state archive")
+
+// ErrArchiveTooNew is returned for an archive of a newer layout than this engine
+var ErrArchiveTooNew = errors.New("discovery state archive is newer than the discovery engine")
+
+// Manifest describes an archive, the counts are the records of each file
+type Manifest struct {
+	Version     int              `json:"version"`
+	CreatedTime int64            `json:"created_time"`
+	DBDriver    string           `json:"db_driver"`
+	Counts      map[string]int64 `json:"counts"`
+}
+
+// FileSet is a workload process file set with the name of its policy
+type FileSet struct {
+	types.WorkloadProcessFileSet
+	PolicyName string   `json:"policy_name"`
+	FileSet    []string `json:"fileset"`
+}
+
+// ============ //
+// == Export == //
+// ============ //
+
+// section is a file of an archive being written
+type section struct {
+	name  string
+	buf   bytes.Buffer
+	count int64
+}
+
+func (s *section) add(record interface{}) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.buf.Write(b)
+	s.buf.WriteByte('\n')
+	s.count++
+	return nil
+}
+
+// Export writes the archive of the discovery state in the database of the configuration
+func Export(w io.Writer, cfgDB types.ConfigDB) (Manifest, error) {
+	manifest := Manifest{
+		Version:     ArchiveVersion,
+		CreatedTime: time.Now().UTC().Unix(),
+		DBDriver:    cfgDB.DBDriver,
+		Counts:      map[string]int64{},
+	}
+
+	store, err := libs.GetStore(cfgDB)
+	if err != nil {
+		return manifest, err
+	}
+
+	sections := []*section{
+		{name: NetworkPoliciesFile},
+		{name: SystemPoliciesFile},
+		{name: FileSetsFile},
+		{name: PolicyYamlsFile},
+		{name: SummariesFile},
+		{name: RollupsFile},
+	}
+	exports := []func(store libs.Store, s *section) error{
+		exportNetworkPolicies,
+		exportSystemPolicies,
+		exportFileSets,
+		exportPolicyYamls,
+		exportSummaries,
+		exportRollups,
+	}
+	for i, s := range sections {
+		if err := exports[i](store, s); err != nil {
+			return manifest, fmt.Errorf("%s: %w", s.name, err)
+		}
+		manifest.Counts[s.name] = s.count
+	}
+
+	// the database of the target keeps its own connection
+	config := cfg.CurrentCfg
+	config.ConfigDB = types.ConfigDB{}
+	configJSON, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Counts[ConfigFile] = 1
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	// the manifest comes first so an import checks it before reading the records
+	if err := writeFile(tw, ManifestFile, manifestJSON, manifest.CreatedTime); err != nil {
+		return manifest, err
+	}
+	if err := writeFile(tw, ConfigFile, configJSON, manifest.CreatedTime); err != nil {
+		return manifest, err
+	}
+	for _, s := range sections {
+		if err := writeFile(tw, s.name, s.buf.Bytes(), manifest.CreatedTime); err != nil {
+			return manifest, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gw.Close()
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime int64) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Unix(modTime, 0),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func exportNetworkPolicies(store libs.Store, s *section) error {
+	page := types.PageRequest{Size: libs.DefaultPageSize}
+	for {
+		policies, next, err := store.GetNetworkPolicies(types.NetworkPolicyFilter{}, page)
+		if err != nil {
+			return err
+		}
+		for _, policy := range policies {
+			if err := s.add(policy); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		page.Token = next
+	}
+}
+
+func exportSystemPolicies(store libs.Store, s *section) error {
+	page := types.PageRequest{Size: libs.DefaultPageSize}
+	for {
+		policies, next, err := store.GetSystemPolicies("", "", page)
+		if err != nil {
+			return err
+		}
+		for _, policy := range policies {
+			if err := s.add(policy); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		page.Token = next
+	}
+}
+
+func exportFileSets(store libs.Store, s *section) error {
+	fileSets, policyNames, err := store.GetWorkloadProcessFileSet(types.WorkloadProcessFileSet{})
+	if err != nil {
+		return err
+	}
+
+	for wpfs, fs := range fileSets {
+		if err := s.add(FileSet{WorkloadProcessFileSet: wpfs, PolicyName: policyNames[wpfs], FileSet: fs}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportPolicyYamls(store libs.Store, s *section) error {
+	for _, policyType := range policyYamlTypes {
+		page := types.PageRequest{Size: libs.DefaultPageSize}
+		for {
+			policies, next, err := store.GetPolicyYamls(policyType, types.PolicyFilter{}, page)
+			if err != nil {
+				return err
+			}
+			for _, policy := range policies {
+				if err := s.add(policy); err != nil {
+					return err
+				}
+			}
+			if next == "" {
+				break
+			}
+			page.Token = next
+		}
+	}
+	return nil
+}
+
+func exportSummaries(store libs.Store, s *section) error {
+	summaries, err := store.GetSystemSummary(types.SystemSummary{})
+	if err != nil {
+		return err
+	}
+
+	for _, summary := range summaries {
+		if err := s.add(summary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportRollups(store libs.Store, s *section) error {
+	rollups, err := store.GetSystemSummaryRollups(types.SystemSummary{})
+	if err != nil {
+		return err
+	}
+
+	for _, rollup := range rollups {
+		if err := s.add(rollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ============ //
+// == Import == //
+// ============ //
+
+// Import reads an archive into the database of the configuration and returns its manifest with
+// the counts of the records imported. The policies and the file sets are inserted, the policy
+// yamls replace the ones of the same names and the counts of the summaries and their rollups add
+// up to the counts of the same ones. With replace the policies, the file sets, the policy yamls
+// and the summaries already in the database are cleared first, once the whole archive is validated.
+// The discovery settings of the config of the archive are applied to the running engine, see importConfig.
+func Import(r io.Reader, cfgDB types.ConfigDB, replace bool) (Manifest, error) {
+	store, err := libs.GetStore(cfgDB)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	if replace {
+		// the whole archive is validated before the database is cleared, a corrupt archive
+		// leaves the database as it is; the archive is spooled to be read twice
+		spool, err := os.CreateTemp("", "discovery-state-*.tar.gz")
+		if err != nil {
+			return Manifest{}, err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		if _, err := io.Copy(spool, r); err != nil {
+			return Manifest{}, err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return Manifest{}, err
+		}
+
+		validates := map[string]func(dec *json.Decoder) (int64, error){
+			ConfigFile:          validateRecords[types.Configuration],
+			NetworkPoliciesFile: validateRecords[types.KnoxNetworkPolicy],
+			SystemPoliciesFile:  validateRecords[types.KnoxSystemPolicy],
+			FileSetsFile:        validateRecords[FileSet],
+			PolicyYamlsFile:     validateRecords[types.PolicyYaml],
+			SummariesFile:       validateRecords[types.SystemSummary],
+			RollupsFile:         validateRecords[types.SystemSummaryRollup],
+		}
+		if manifest, err := readArchive(spool, validates); err != nil {
+			return manifest, err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return Manifest{}, err
+		}
+		r = spool
+
+		clears := []func() error{store.ClearDBTables, store.ClearPolicyYamlDBTable, store.ClearSystemSummaryDBTables}
+		for _, clear := range clears {
+			if err := clear(); err != nil {
+				return Manifest{}, err
+			}
+		}
+	}
+
+	imports := map[string]func(dec *json.Decoder) (int64, error){}
+	for name, importFile := range map[string]func(store libs.Store, dec *json.Decoder) (int64, error){
+		ConfigFile:          importConfig,
+		NetworkPoliciesFile: importNetworkPolicies,
+		SystemPoliciesFile:  importSystemPolicies,
+		FileSetsFile:        importFileSets,
+		PolicyYamlsFile:     importPolicyYamls,
+		SummariesFile:       importSummaries,
+		RollupsFile:         importRollups,
+	} {
+		importFile := importFile
+		imports[name] = func(dec *json.Decoder) (int64, error) { return importFile(store, dec) }
+	}
+
+	return readArchive(r, imports)
+}
+
+// readArchive reads the manifest of an archive, then each of its files of the handlers, and returns
+// the manifest with the counts of the records of the files
+func readArchive(r io.Reader, handlers map[string]func(dec *json.Decoder) (int64, error)) (Manifest, error) {
+	manifest := Manifest{}
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != ManifestFile {
+		return manifest, ErrInvalidArchive
+	}
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if manifest.Version > ArchiveVersion {
+		return manifest, fmt.Errorf("%w: version %d, supported version %d", ErrArchiveTooNew, manifest.Version, ArchiveVersion)
+	}
+
+	counts := map[string]int64{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		handle, ok := handlers[header.Name]
+		if !ok {
+			log.Warn().Msgf("skipping %s of the discovery state archive", header.Name)
+			continue
+		}
+
+		count, err := handle(json.NewDecoder(bufio.NewReader(tr)))
+		counts[header.Name] = count
+		if err != nil {
+			manifest.Counts = counts
+			return manifest, fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+
+	manifest.Counts = counts
+	return manifest, nil
+}
+
+// validateRecords decodes the records of a file without importing them
+func validateRecords[T any](dec *json.Decoder) (int64, error) {
+	count, err := decodeBatches(dec, func(records []T) error { return nil })
+	if err != nil {
+		return count, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	return count, nil
+}
+
+// decodeBatches decodes the records of a file by batches of the default page size
+func decodeBatches[T any](dec *json.Decoder, batch func(records []T) error) (int64, error) {
+	var count int64
+	records := []T{}
+
+	for {
+		var record T
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		records = append(records, record)
+		if len(records) == libs.DefaultPageSize {
+			if err := batch(records); err != nil {
+				return count, err
+			}
+			count += int64(len(records))
+			records = []T{}
+		}
+	}
+
+	if len(records) > 0 {
+		if err := batch(records); err != nil {
+			return count, err
+		}
+		count += int64(len(records))
+	}
+	return count, nil
+}
+
+// importConfig applies the discovery settings of the config of the archive until the restart of
+// the engine, the engine keeps the database, the relays, the identity of its cluster and the rest
+// of the config of its environment. The settings missing from the archive are kept as well.
+func importConfig(_ libs.Store, dec *json.Decoder) (int64, error) {
+	archived := cfg.CurrentCfg
+	if err := dec.Decode(&archived); err != nil {
+		return 0, err
+	}
+
+	cfg.CurrentCfg.ConfigNetPolicy = archived.ConfigNetPolicy
+	cfg.CurrentCfg.ConfigSysPolicy = archived.ConfigSysPolicy
+	cfg.CurrentCfg.ConfigAdmissionControllerPolicy = archived.ConfigAdmissionControllerPolicy
+	cfg.CurrentCfg.ConfigRecommendPolicy = archived.ConfigRecommendPolicy
+	cfg.CurrentCfg.ConfigPathAggregation = archived.ConfigPathAggregation
+	return 1, nil
+}
+
+func importNetworkPolicies(store libs.Store, dec *json.Decoder) (int64, error) {
+	return decodeBatches(dec, store.InsertNetworkPolicies)
+}
+
+func importSystemPolicies(store libs.Store, dec *json.Decoder) (int64, error) {
+	return decodeBatches(dec, store.InsertSystemPolicies)
+}
+
+func importFileSets(store libs.Store, dec *json.Decoder) (int64, error) {
+	return decodeBatches(dec, func(fileSets []FileSet) error {
+		for _, fs := range fileSets {
+			if err := store.InsertWorkloadProcessFileSet(fs.WorkloadProcessFileSet, fs.PolicyName, fs.FileSet); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func importPolicyYamls(store libs.Store, dec *json.Decoder) (int64, error) {
+	return decodeBatches(dec, store.UpdateOrInsertPolicyYamls)
+}
+
+func importSummaries(store libs.Store, dec *json.Decoder) (int64, error) {
+	return decodeBatches(dec, func(summaries []types.SystemSummary) error {
+		summaryMap := map[types.SystemSummary]types.SysSummaryTimeCount{}
+		for _, summary := range summaries {
+			timeCount := types.SysSummaryTimeCount{Count: summary.Count, UpdatedTime: summary.UpdatedTime}
+			summary.Count = 0
+			summary.UpdatedTime = 0
+			summaryMap[summary] = timeCount
+		}
+		return store.UpsertSystemSummary(summaryMap)
+	})
+}
+
+func importRollups(store libs.Store, dec *json.Decoder) (int64, error) {
+	return decodeBatches(dec, store.UpsertSystemSummaryRollups)
+}
@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"path/filepath"
+	"testing"
+
+	cfg "github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestStore returns the store of the databases of dir, the observability db included
+func newTestStore(t *testing.T, dir string) (types.ConfigDB, libs.Store) {
+	prevObsDBName := cfg.CurrentCfg.ConfigObservability.DBName
+	t.Cleanup(func() { cfg.CurrentCfg.ConfigObservability.DBName = prevObsDBName })
+	cfg.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+
+	cfgDB := types.ConfigDB{DBDriver: "sqlite3", SQLiteDBPath: filepath.Join(dir, "knox.db")}
+
+	store, err := libs.GetStore(cfgDB)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, store.Migrate()) {
+		t.FailNow()
+	}
+	return cfgDB, store
+}
+
+func TestExportImport(t *testing.T) {
+	defer libs.CloseDBs()
+	prevCfg := cfg.CurrentCfg
+	defer func() { cfg.CurrentCfg = prevCfg }()
+
+	// the source
+	srcCfg, src := newTestStore(t, t.TempDir())
+	cfg.CurrentCfg.ConfigNetPolicy.CronJobTimeInterval = "@every 5m"
+	cfg.CurrentCfg.ConfigSysPolicy.CronJobTimeInterval = "@every 7m"
+
+	assert.NoError(t, src.InsertNetworkPolicies([]types.KnoxNetworkPolicy{{
+		APIVersion: "v1",
+		Kind:       "KnoxNetworkPolicy",
+		Metadata:   map[string]string{"name": "egress-a", "cluster_name": "default", "namespace": "ns-a", "status": "latest"},
+		Spec:       types.Spec{Selector: types.Selector{MatchLabels: map[string]string{"app": "a"}}},
+	}}))
+	assert.NoError(t, src.InsertSystemPolicies([]types.KnoxSystemPolicy{{
+		APIVersion: "v1",
+		Kind:       "KubeArmorPolicy",
+		Metadata:   map[string]string{"name": "sys-a", "clusterName": "default", "namespace": "ns-a", "status": "latest"},
+	}}))
+	wpfs := types.WorkloadProcessFileSet{ClusterName: "default", Namespace: "ns-a", ContainerName: "c", Labels: "app=a", SetType: "file"}
+	assert.NoError(t, src.InsertWorkloadProcessFileSet(wpfs, "autopol-file-a", []string{"/etc/hosts", "/etc/passwd"}))
+	assert.NoError(t, src.UpdateOrInsertPolicyYamls([]types.PolicyYaml{
+		{Type: types.PolicyTypeSystem, Kind: "KubeArmorPolicy", Name: "sys-a", Namespace: "ns-a", Cluster: "default", Yaml: []byte("kind: KubeArmorPolicy")},
+	}))
+	summary := types.SystemSummary{ClusterName: "default", NamespaceName: "ns-a", PodName: "pod-a", Operation: "File", Source: "/bin/sh", Destination: "/etc/hosts"}
+	assert.NoError(t, src.UpsertSystemSummary(map[types.SystemSummary]types.SysSummaryTimeCount{summary: {Count: 3, UpdatedTime: 100}}))
+	rollup := types.SystemSummaryRollup{Granularity: libs.RollupHourly, BucketTime: 3600, Count: 7,
+		Summary: types.SystemSummary{ClusterName: "default", NamespaceName: "ns-a", Operation: "File", Source: "/bin/sh", Destination: "/etc/passwd"}}
+	assert.NoError(t, src.UpsertSystemSummaryRollups([]types.SystemSummaryRollup{rollup}))
+
+	archive := bytes.Buffer{}
+	manifest, err := Export(&archive, srcCfg)
+	assert.NoError(t, err)
+	assert.Equal(t, ArchiveVersion, manifest.Version)
+	assert.Equal(t, map[string]int64{
+		NetworkPoliciesFile: 1,
+		SystemPoliciesFile:  1,
+		FileSetsFile:        1,
+		PolicyYamlsFile:     1,
+		SummariesFile:       1,
+		RollupsFile:         1,
+		ConfigFile:          1,
+	}, manifest.Counts)
+	exported := archive.Bytes()
+
+	// the target, of its own config
+	dstCfg, dst := newTestStore(t, t.TempDir())
+	dstObsDBName := cfg.CurrentCfg.ConfigObservability.DBName
+	cfg.CurrentCfg.ConfigNetPolicy.CronJobTimeInterval = "@every 1h"
+	cfg.CurrentCfg.ConfigSysPolicy.CronJobTimeInterval = "@every 1h"
+
+	imported, err := Import(bytes.NewReader(exported), dstCfg, false)
+	assert.NoError(t, err)
+	assert.Equal(t, manifest.Counts, imported.Counts)
+
+	// the discovery settings of the archive are applied, the target keeps its databases
+	assert.Equal(t, "@every 5m", cfg.CurrentCfg.ConfigNetPolicy.CronJobTimeInterval)
+	assert.Equal(t, "@every 7m", cfg.CurrentCfg.ConfigSysPolicy.CronJobTimeInterval)
+	assert.Equal(t, dstObsDBName, cfg.CurrentCfg.ConfigObservability.DBName)
+
+	networkPolicies, _, err := dst.GetNetworkPolicies(types.NetworkPolicyFilter{Selector: map[string]string{"app": "a"}}, types.PageRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, networkPolicies, 1) {
+		assert.Equal(t, "egress-a", networkPolicies[0].Metadata["name"])
+	}
+
+	fileSets, policyNames, err := dst.GetWorkloadProcessFileSet(types.WorkloadProcessFileSet{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/etc/hosts", "/etc/passwd"}, fileSets[wpfs])
+	assert.Equal(t, "autopol-file-a", policyNames[wpfs])
+
+	summaries, err := dst.GetSystemSummary(types.SystemSummary{})
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, int32(3), summaries[0].Count)
+	}
+
+	rollups, err := dst.GetSystemSummaryRollups(types.SystemSummary{})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.SystemSummaryRollup{rollup}, rollups)
+
+	// replacing imports neither duplicate the policies nor add up the counts of the summaries
+	for i := 0; i < 2; i++ {
+		_, err = Import(bytes.NewReader(exported), dstCfg, true)
+		assert.NoError(t, err)
+	}
+	systemPolicies, _, err := dst.GetSystemPolicies("", "", types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, systemPolicies, 1)
+
+	policyYamls, _, err := dst.GetPolicyYamls(types.PolicyTypeSystem, types.PolicyFilter{}, types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, policyYamls, 1)
+
+	summaries, err = dst.GetSystemSummary(types.SystemSummary{})
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, int32(3), summaries[0].Count)
+	}
+
+	rollups, err = dst.GetSystemSummaryRollups(types.SystemSummary{})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.SystemSummaryRollup{rollup}, rollups)
+
+	// a corrupt archive is refused before the database is cleared
+	corrupt := bytes.Buffer{}
+	gw := gzip.NewWriter(&corrupt)
+	tw := tar.NewWriter(gw)
+	assert.NoError(t, writeFile(tw, ManifestFile, []byte(`{"version": 1}`), 0))
+	assert.NoError(t, writeFile(tw, NetworkPoliciesFile, []byte(`{"apiVersion": "v1"}`+"\n"+`{"apiVersion": `), 0))
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+
+	_, err = Import(&corrupt, dstCfg, true)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	systemPolicies, _, err = dst.GetSystemPolicies("", "", types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, systemPolicies, 1)
+	summaries, err = dst.GetSystemSummary(types.SystemSummary{})
+	assert.NoError(t, err)
+	assert.Len(t, summaries, 1)
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	defer libs.CloseDBs()
+	dir := t.TempDir()
+	cfgDB, _ := newTestStore(t, dir)
+
+	_, err := Import(bytes.NewReader([]byte("not an archive")), cfgDB, false)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	archive := bytes.Buffer{}
+	gw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gw)
+	assert.NoError(t, writeFile(tw, ManifestFile, []byte(`{"version": 2}`), 0))
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+
+	_, err = Import(&archive, cfgDB, false)
+	assert.ErrorIs(t, err, ErrArchiveTooNew)
+}
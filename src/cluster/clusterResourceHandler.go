@@ -37,6 +37,37 @@ func GetPods(clusterName string) []types.Pod {
 	return pods
 }
 
+// GetNodes returns the nodes of the cluster along with their labels
+func GetNodes(clusterName string) []types.Node {
+	results := []types.Node{}
+
+	if config.GetCfgClusterInfoFrom() != "k8sclient" {
+		// node labels are only available from the k8s api
+		return results
+	}
+
+	nodeList, err := GetNodesFromK8sClient()
+	if err != nil {
+		return results
+	}
+
+	for _, node := range nodeList.Items {
+		n := types.Node{
+			NodeName: node.Name,
+			Labels:   []string{},
+		}
+
+		for k, v := range node.Labels {
+			n.Labels = append(n.Labels, k+"="+v)
+		}
+		sort.Strings(n.Labels)
+
+		results = append(results, n)
+	}
+
+	return results
+}
+
 func GetAllClusterResources(cluster string) ([]string, []types.Service, []types.Endpoint, []types.Pod, error) {
 	clusterMgmt := config.GetCfgClusterInfoFrom()
 
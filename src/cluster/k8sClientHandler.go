@@ -92,12 +92,16 @@ func GetPodsFromK8sClient() []types.Pod {
 		return results
 	}
 
+	// key: namespace/job - val: owner cronjob, fetched only if a pod is owned by a job
+	var jobOwners map[string]string
+
 	for _, pod := range pods.Items {
 		group := types.Pod{
-			Namespace: pod.Namespace,
-			PodName:   pod.Name,
-			Labels:    []string{},
-			PodIP:     pod.Status.PodIP,
+			Namespace:      pod.Namespace,
+			PodName:        pod.Name,
+			Labels:         []string{},
+			PodIP:          pod.Status.PodIP,
+			InitContainers: []string{},
 		}
 
 		for k, v := range pod.Labels {
@@ -110,12 +114,63 @@ func GetPodsFromK8sClient() []types.Pod {
 		}
 		sort.Strings(group.Labels)
 
+		for _, container := range pod.Spec.InitContainers {
+			group.InitContainers = append(group.InitContainers, container.Name)
+		}
+
+		if jobOwners == nil && isOwnedByJob(pod) {
+			jobOwners = getJobOwnersFromK8sClient(client)
+		}
+		group.OwnerKind, group.OwnerName = getPodOwner(pod, jobOwners)
+
 		results = append(results, group)
 	}
 
 	return results
 }
 
+func isOwnedByJob(pod v1.Pod) bool {
+	owner := metav1.GetControllerOf(&pod)
+	return owner != nil && owner.Kind == "Job"
+}
+
+// getJobOwnersFromK8sClient returns the owner cronjob of each job
+func getJobOwnersFromK8sClient(client kubernetes.Interface) map[string]string {
+	results := map[string]string{}
+
+	jobs, err := client.BatchV1().Jobs("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		Logr.Error().Msg(err.Error())
+		return results
+	}
+
+	for _, job := range jobs.Items {
+		owner := metav1.GetControllerOf(&job)
+		if owner != nil && owner.Kind == "CronJob" {
+			results[job.Namespace+"/"+job.Name] = owner.Name
+		}
+	}
+
+	return results
+}
+
+// getPodOwner returns the kind and name of the workload controlling the pod,
+// the pods of a job created by a cronjob are reported as owned by the cronjob
+func getPodOwner(pod v1.Pod, jobOwners map[string]string) (string, string) {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return "", ""
+	}
+
+	if owner.Kind == "Job" {
+		if cronJob, ok := jobOwners[pod.Namespace+"/"+owner.Name]; ok {
+			return "CronJob", cronJob
+		}
+	}
+
+	return owner.Kind, owner.Name
+}
+
 func SetAnnotationsToPodsInNamespaceK8s(namespace string, annotation map[string]string) error {
 	client := ConnectK8sClient()
 	if client == nil {
@@ -481,6 +536,9 @@ func GetStatefulSetsFromK8sClient() []types.Deployment {
 func GetNodesFromK8sClient() (*v1.NodeList, error) {
 
 	client := ConnectK8sClient()
+	if client == nil {
+		return &v1.NodeList{}, errors.New("failed to create k8s client")
+	}
 	nodeList, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		Logr.Error().Msg(err.Error())
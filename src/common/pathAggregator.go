@@ -1,6 +1,7 @@
 package common
 
 import (
+	"fmt"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -19,10 +20,153 @@ var WildPaths []string
 
 const Threshold = 3
 
+// DefaultAlwaysCollapse is used when no always-collapse prefixes are configured
+var DefaultAlwaysCollapse = []string{"/tmp/"}
+
 func init() {
 	WildPaths = []string{WildPathDigit, WildPathChar}
 }
 
+// ============================ //
+// == Path Aggregation Rules == //
+// ============================ //
+
+// pathNormalizer rewrites volatile path segments (e.g., /proc/<pid>)
+type pathNormalizer struct {
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// pathAggregationRules decides when a path node is collapsed into a directory
+type pathAggregationRules struct {
+	threshold        int
+	prefixThresholds []types.PathPrefixThreshold
+	neverCollapse    []string
+	alwaysCollapse   []string
+	normalizers      []pathNormalizer
+}
+
+var aggRules = defaultPathAggregationRules()
+
+func defaultPathAggregationRules() *pathAggregationRules {
+	return &pathAggregationRules{
+		threshold:      Threshold,
+		alwaysCollapse: DefaultAlwaysCollapse,
+	}
+}
+
+// normalizeDirPrefix converts "/tmp", "/tmp/" and "/tmp/*" into "/tmp/"
+func normalizeDirPrefix(prefix string) string {
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "*")
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+	return prefix
+}
+
+// SetPathAggregationConfig compiles the path aggregation rules used by
+// AggregatePaths, MergeAndAggregatePaths and AggregatePathsExt.
+// It should be called once the configuration is loaded, before discovery starts.
+func SetPathAggregationConfig(cfg types.ConfigPathAggregation) error {
+	rules := defaultPathAggregationRules()
+
+	if cfg.Threshold > 0 {
+		rules.threshold = cfg.Threshold
+	}
+
+	for _, pt := range cfg.PrefixThresholds {
+		if pt.Prefix == "" {
+			continue
+		}
+		rules.prefixThresholds = append(rules.prefixThresholds, types.PathPrefixThreshold{
+			Prefix:    normalizeDirPrefix(pt.Prefix),
+			Threshold: pt.Threshold,
+		})
+	}
+
+	for _, prefix := range cfg.NeverCollapse {
+		if prefix != "" {
+			rules.neverCollapse = append(rules.neverCollapse, normalizeDirPrefix(prefix))
+		}
+	}
+
+	if len(cfg.AlwaysCollapse) > 0 {
+		rules.alwaysCollapse = []string{}
+		for _, prefix := range cfg.AlwaysCollapse {
+			if prefix != "" {
+				rules.alwaysCollapse = append(rules.alwaysCollapse, normalizeDirPrefix(prefix))
+			}
+		}
+	}
+
+	for _, n := range cfg.Normalizers {
+		r, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid path normalizer pattern %q: %v", n.Pattern, err)
+		}
+		rules.normalizers = append(rules.normalizers, pathNormalizer{
+			regex:       r,
+			replacement: n.Replacement,
+		})
+	}
+
+	aggRules = rules
+	return nil
+}
+
+// NormalizePath applies the configured normalizers to the given path
+func NormalizePath(path string) string {
+	for _, n := range aggRules.normalizers {
+		path = n.regex.ReplaceAllString(path, n.replacement)
+	}
+	return path
+}
+
+// longestPrefix returns the longest prefix in the list that matches the dir
+func longestPrefix(prefixes []string, dir string) string {
+	longest := ""
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(dir, prefix) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+	return longest
+}
+
+func (r *pathAggregationRules) thresholdOf(dir string) int {
+	threshold := r.threshold
+	longest := ""
+	for _, pt := range r.prefixThresholds {
+		if strings.HasPrefix(dir, pt.Prefix) && len(pt.Prefix) > len(longest) && pt.Threshold > 0 {
+			longest = pt.Prefix
+			threshold = pt.Threshold
+		}
+	}
+	return threshold
+}
+
+// shouldCollapse checks if the node at the given path should become a matchDirectory
+func (r *pathAggregationRules) shouldCollapse(path string, numChildNodes int) bool {
+	dir := path + "/"
+	never := longestPrefix(r.neverCollapse, dir)
+
+	// the more specific rule wins if a path is in both always and never lists
+	for _, prefix := range r.alwaysCollapse {
+		if prefix == dir && len(prefix) >= len(never) {
+			return true
+		}
+	}
+
+	if never != "" {
+		return false
+	}
+
+	return numChildNodes > r.thresholdOf(dir)
+}
+
 // ============================ //
 // == PathNode and functions == //
 // ============================ //
@@ -97,29 +241,33 @@ func (n *Node) insert(paths []string) {
 	}
 }
 
-func (n *Node) aggregateChildNodes() {
+func (n *Node) aggregateChildNodes(rules *pathAggregationRules, parentPath string) {
+	fullPath := parentPath + n.path
+
 	// depth first search
 	for _, childNode := range n.childNodes {
-		childNode.aggregateChildNodes()
+		childNode.aggregateChildNodes(rules, fullPath)
 	}
 
-	// #child nodes > threshold --> aggreagte it, and make matchDirectories
-	if len(n.childNodes) > Threshold {
+	// #child nodes > threshold (or always collapse) --> aggreagte it, and make matchDirectories
+	if rules.shouldCollapse(fullPath, len(n.childNodes)) {
 		n.childNodes = nil
 		n.touchCount = 1 // reset touch count
 		n.isDir = true
 	}
 }
 
-func (n *Node) makeChildNodeToDir() {
+func (n *Node) makeChildNodeToDir(rules *pathAggregationRules, parentPath string) {
+	fullPath := parentPath + n.path
+
 	// depth first search
 	for _, childNode := range n.childNodes {
-		childNode.makeChildNodeToDir()
+		childNode.makeChildNodeToDir(rules, fullPath)
 	}
 
 	// #child nodes > threshold --> aggreagte it, and make matchDirectories
 	if len(n.childNodes) == 0 {
-		n.touchCount = Threshold + 1 // reset touch count
+		n.touchCount = rules.thresholdOf(fullPath+"/") + 1 // reset touch count
 		n.isDir = true
 	}
 }
@@ -153,6 +301,8 @@ func buildPathTree(treeMap map[string]*Node, paths []string) {
 
 	// iterate paths
 	for _, path := range paths {
+		path = NormalizePath(path)
+
 		if path == "/" { // rootpath
 			continue
 		}
@@ -167,17 +317,12 @@ func buildPathTree(treeMap map[string]*Node, paths []string) {
 
 		rootPath := tokenizedPaths[0]
 
-		if rootPath == "/tmp" {
-			tokenizedPaths = pattern.FindAllString("/tmp/", -1)
-		}
-
 		if rootNode, ok := treeMap[rootPath]; !ok {
 			newRoot := &Node{
 				depth:      0,
 				path:       rootPath,
 				touchCount: 1,
 				childNodes: []*Node{},
-				isDir:      rootPath == "/tmp",
 			}
 
 			newRoot.insert(tokenizedPaths[1:])
@@ -217,6 +362,7 @@ func mergeFileInDir(paths []string) (map[string]bool, []string) {
 }
 
 func AggregatePaths(paths []string) []SysPath {
+	rules := aggRules
 	treeMap := map[string]*Node{}
 
 	// step 1: build path tree
@@ -237,7 +383,7 @@ func AggregatePaths(paths []string) []SysPath {
 
 	// step 2: aggregate path
 	for _, root := range treeMap {
-		root.aggregateChildNodes()
+		root.aggregateChildNodes(rules, "")
 	}
 
 	// for root, childs := range treeMap {
@@ -272,6 +418,7 @@ func AggregatePaths(paths []string) []SysPath {
 // ========================================= //
 
 func MergeAndAggregatePaths(dirs []string, paths []string) []SysPath {
+	rules := aggRules
 	treeMap := map[string]*Node{}
 
 	// step 1: build path tree from matchDirectories
@@ -285,7 +432,7 @@ func MergeAndAggregatePaths(dirs []string, paths []string) []SysPath {
 	// ...
 	buildPathTree(treeMap, dirs)
 	for _, root := range treeMap {
-		root.makeChildNodeToDir()
+		root.makeChildNodeToDir(rules, "")
 	}
 
 	// step 2: append matchPaths to the path tree
@@ -293,7 +440,7 @@ func MergeAndAggregatePaths(dirs []string, paths []string) []SysPath {
 
 	// step 3: aggregate new paths/directories
 	for _, root := range treeMap {
-		root.aggregateChildNodes()
+		root.aggregateChildNodes(rules, "")
 	}
 
 	// step 4: generate tree -> path string
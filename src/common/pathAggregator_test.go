@@ -3,6 +3,7 @@ package common
 import (
 	"testing"
 
+	types "github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -158,3 +159,118 @@ func TestAggregatePathsExt_4(t *testing.T) {
 
 	assert.Equal(t, len(results), 1)
 }
+
+// ============================= //
+// == Path Aggregation Config == //
+// ============================= //
+
+func TestAggregatePaths_AlwaysCollapseTmp(t *testing.T) {
+	paths := []string{"/tmp/a", "/tmp/b/c"}
+
+	results := AggregatePaths(paths)
+
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Path, "/tmp/")
+	assert.True(t, results[0].IsDir)
+}
+
+func TestAggregatePaths_NeverCollapse(t *testing.T) {
+	err := SetPathAggregationConfig(types.ConfigPathAggregation{
+		NeverCollapse: []string{"/etc"},
+	})
+	assert.NoError(t, err)
+	defer func() { _ = SetPathAggregationConfig(types.ConfigPathAggregation{}) }()
+
+	paths := []string{"/etc/passwd", "/etc/group", "/etc/hosts", "/etc/hostname", "/etc/resolv.conf"}
+
+	results := AggregatePaths(paths)
+
+	assert.Equal(t, len(results), 5)
+	for _, r := range results {
+		assert.False(t, r.IsDir)
+	}
+}
+
+func TestAggregatePaths_PrefixThreshold(t *testing.T) {
+	err := SetPathAggregationConfig(types.ConfigPathAggregation{
+		PrefixThresholds: []types.PathPrefixThreshold{
+			{Prefix: "/usr/lib/", Threshold: 10},
+			{Prefix: "/var/", Threshold: 1},
+		},
+	})
+	assert.NoError(t, err)
+	defer func() { _ = SetPathAggregationConfig(types.ConfigPathAggregation{}) }()
+
+	results := AggregatePaths([]string{
+		"/usr/lib/python2.7/UserDict.py",
+		"/usr/lib/python2.7/UserDict.pyo",
+		"/usr/lib/python2.7/UserDict.3",
+		"/usr/lib/python2.7/UserDict.4",
+	})
+	assert.Equal(t, len(results), 4)
+
+	results = AggregatePaths([]string{"/var/log/a", "/var/log/b"})
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Path, "/var/log/")
+}
+
+func TestAggregatePaths_AlwaysCollapse(t *testing.T) {
+	err := SetPathAggregationConfig(types.ConfigPathAggregation{
+		AlwaysCollapse: []string{"/var/cache/*"},
+	})
+	assert.NoError(t, err)
+	defer func() { _ = SetPathAggregationConfig(types.ConfigPathAggregation{}) }()
+
+	results := AggregatePaths([]string{"/var/cache/apt/pkgcache.bin"})
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Path, "/var/cache/")
+	assert.True(t, results[0].IsDir)
+
+	// /tmp is not collapsed once always-collapse is overridden
+	results = AggregatePaths([]string{"/tmp/a"})
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Path, "/tmp/a")
+}
+
+func TestAggregatePaths_Normalizers(t *testing.T) {
+	err := SetPathAggregationConfig(types.ConfigPathAggregation{
+		Normalizers: []types.PathNormalizer{
+			{Pattern: "^/proc/[0-9]+/", Replacement: "/proc/self/"},
+		},
+	})
+	assert.NoError(t, err)
+	defer func() { _ = SetPathAggregationConfig(types.ConfigPathAggregation{}) }()
+
+	results := AggregatePaths([]string{"/proc/1/status", "/proc/22/status", "/proc/333/status"})
+
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Path, "/proc/self/status")
+	assert.Equal(t, NormalizePath("/proc/4444/cmdline"), "/proc/self/cmdline")
+}
+
+func TestSetPathAggregationConfig_InvalidPattern(t *testing.T) {
+	err := SetPathAggregationConfig(types.ConfigPathAggregation{
+		Normalizers: []types.PathNormalizer{{Pattern: "(", Replacement: ""}},
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, aggRules.threshold, Threshold)
+}
+
+func TestMakeChildNodeToDir_Rules(t *testing.T) {
+	rules := &pathAggregationRules{
+		threshold:        3,
+		prefixThresholds: []types.PathPrefixThreshold{{Prefix: "/var/log/", Threshold: 10}},
+	}
+
+	treeMap := map[string]*Node{}
+	buildPathTree(treeMap, []string{"/var/log/app", "/usr/lib"})
+	for _, root := range treeMap {
+		root.makeChildNodeToDir(rules, "")
+	}
+
+	// the touch counts are reset to the threshold of the given rules, not the global ones
+	assert.Equal(t, 11, treeMap["/var"].childNodes[0].childNodes[0].touchCount)
+	assert.Equal(t, 4, treeMap["/usr"].childNodes[0].touchCount)
+	assert.True(t, treeMap["/usr"].childNodes[0].isDir)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	types "github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/spf13/viper"
@@ -41,6 +42,15 @@ var NetworkPlugIn string
 var IgnoringNetworkNamespaces []string
 var HTTPUrlThreshold int
 
+// retentionTables are the tables of the purge-old-db-entries.retention keys
+var retentionTables = map[string]string{
+	"network-logs":             "network_logs",
+	"system-logs":              "system_logs",
+	"workload-process-fileset": "workload_process_fileset",
+	"system-summary":           "system_summary",
+	"system-summary-rollup":    "system_summary_rollup",
+}
+
 func init() {
 	IgnoringNetworkNamespaces = []string{"kube-system"}
 	HTTPUrlThreshold = 5
@@ -72,6 +82,21 @@ func LoadConfigDB() types.ConfigDB {
 	*/
 	cfgDB.DBPort = viper.GetString("database.port")
 
+	cfgDB.MaxOpenConns = viper.GetInt("database.max-open-conns")
+	cfgDB.MaxIdleConns = viper.GetInt("database.max-idle-conns")
+	cfgDB.ConnMaxLifetime = viper.GetInt("database.conn-max-lifetime")
+
+	cfgDB.LogStore = viper.GetString("database.log-store")
+	cfgDB.LogStorePath = viper.GetString("database.log-store-path")
+	cfgDB.LogSegmentDuration = int64(viper.GetDuration("database.log-segment-duration").Seconds())
+
+	cfgDB.Encryption = types.ConfigEncryption{
+		Enable:    viper.GetBool("database.encryption.enable"),
+		KeyDir:    viper.GetString("database.encryption.key-dir"),
+		Secret:    viper.GetString("database.encryption.secret"),
+		ActiveKey: viper.GetString("database.encryption.active-key"),
+	}
+
 	return cfgDB
 }
 
@@ -90,6 +115,8 @@ func LoadConfigCiliumHubble() types.ConfigCiliumHubble {
 	*/
 
 	cfgHubble.HubblePort = viper.GetString("cilium-hubble.port")
+	cfgHubble.Endpoints = getConfigRelayEndpoints("cilium-hubble.endpoints")
+	cfgHubble.FanIn = viper.GetBool("cilium-hubble.fan-in")
 
 	return cfgHubble
 }
@@ -107,10 +134,72 @@ func LoadConfigKubeArmor() types.ConfigKubeArmorRelay {
 	*/
 
 	cfgKubeArmor.KubeArmorRelayPort = viper.GetString("kubearmor.port")
+	cfgKubeArmor.Endpoints = getConfigRelayEndpoints("kubearmor.endpoints")
+	cfgKubeArmor.FanIn = viper.GetBool("kubearmor.fan-in")
 
 	return cfgKubeArmor
 }
 
+func getConfigRelayEndpoints(key string) []types.RelayEndpoint {
+	endpoints := []types.RelayEndpoint{}
+
+	// endpoints are a list of objects, so they are decoded using the key names from the config file
+	relays := []struct {
+		Cluster string `mapstructure:"cluster"`
+		URL     string `mapstructure:"url"`
+		Port    string `mapstructure:"port"`
+	}{}
+	_ = viper.UnmarshalKey(key, &relays)
+	for _, r := range relays {
+		if r.URL == "" {
+			continue
+		}
+		endpoints = append(endpoints, types.RelayEndpoint{
+			ClusterName: r.Cluster,
+			URL:         r.URL,
+			Port:        r.Port,
+		})
+	}
+
+	return endpoints
+}
+
+func LoadConfigPathAggregation() types.ConfigPathAggregation {
+	cfgPathAgg := types.ConfigPathAggregation{}
+
+	cfgPathAgg.Threshold = viper.GetInt("path-aggregation.threshold")
+	cfgPathAgg.NeverCollapse = viper.GetStringSlice("path-aggregation.never-collapse")
+	cfgPathAgg.AlwaysCollapse = viper.GetStringSlice("path-aggregation.always-collapse")
+
+	// prefix thresholds and normalizers are lists of objects,
+	// so they are decoded using the key names from the config file
+	prefixThresholds := []struct {
+		Prefix    string `mapstructure:"prefix"`
+		Threshold int    `mapstructure:"threshold"`
+	}{}
+	_ = viper.UnmarshalKey("path-aggregation.prefix-thresholds", &prefixThresholds)
+	for _, pt := range prefixThresholds {
+		cfgPathAgg.PrefixThresholds = append(cfgPathAgg.PrefixThresholds, types.PathPrefixThreshold{
+			Prefix:    pt.Prefix,
+			Threshold: pt.Threshold,
+		})
+	}
+
+	normalizers := []struct {
+		Pattern     string `mapstructure:"pattern"`
+		Replacement string `mapstructure:"replacement"`
+	}{}
+	_ = viper.UnmarshalKey("path-aggregation.normalizers", &normalizers)
+	for _, n := range normalizers {
+		cfgPathAgg.Normalizers = append(cfgPathAgg.Normalizers, types.PathNormalizer{
+			Pattern:     n.Pattern,
+			Replacement: n.Replacement,
+		})
+	}
+
+	return cfgPathAgg
+}
+
 func LoadConfigFromFile() {
 	CurrentCfg = types.Configuration{}
 
@@ -137,9 +226,13 @@ func LoadConfigFromFile() {
 		NetworkLogLimit:  viper.GetInt("application.network.network-log-limit"),
 		NetworkLogFrom:   viper.GetString("application.network.network-log-from"),
 		NetworkLogFile:   viper.GetString("application.network.network-log-file"),
+		EnvoyReceiver:    viper.GetString("application.network.envoy-receiver"),
 		NetworkPolicyTo:  viper.GetString("application.network.network-policy-to"),
 		NetworkPolicyDir: viper.GetString("application.network.network-policy-dir"),
 
+		PolicyGapMaxAge:   viper.GetInt("application.network.policy-gap-max-age"),
+		PolicyGapMaxCount: viper.GetInt("application.network.policy-gap-max-count"),
+
 		NetPolicyTypes:     3,
 		NetPolicyRuleTypes: 1023,
 		NetPolicyCIDRBits:  32,
@@ -154,6 +247,7 @@ func LoadConfigFromFile() {
 	}
 
 	CurrentCfg.ConfigNetPolicy.NsFilter, CurrentCfg.ConfigNetPolicy.NsNotFilter = getConfigNsFilter("application.network.namespace-filter")
+	CurrentCfg.ConfigNetPolicy.VerdictFilter, CurrentCfg.ConfigNetPolicy.VerdictNotFilter = getConfigVerdictFilter("application.network.verdict-filter")
 
 	// load system policy discovery
 	CurrentCfg.ConfigSysPolicy = types.ConfigSystemPolicy{
@@ -178,6 +272,13 @@ func LoadConfigFromFile() {
 
 	CurrentCfg.ConfigSysPolicy.NsFilter, CurrentCfg.ConfigSysPolicy.NsNotFilter = getConfigNsFilter("application.system.namespace-filter")
 	CurrentCfg.ConfigSysPolicy.FromSourceFilter = viper.GetStringSlice("application.system.fromsource-filter")
+	CurrentCfg.ConfigSysPolicy.HostPolicy = viper.GetBool("application.system.host-policy")
+	CurrentCfg.ConfigSysPolicy.HostNodeLabelKeys = viper.GetStringSlice("application.system.host-node-label-keys")
+	CurrentCfg.ConfigSysPolicy.ContainerPolicy = viper.GetBool("application.system.container-policy")
+	CurrentCfg.ConfigSysPolicy.InitContainerMode = viper.GetString("application.system.init-container-mode")
+	CurrentCfg.ConfigSysPolicy.JobMode = viper.GetString("application.system.job-mode")
+	CurrentCfg.ConfigSysPolicy.AnomalyDetection = viper.GetBool("application.system.anomaly-detection")
+	CurrentCfg.ConfigSysPolicy.StabilisationPeriod = viper.GetString("application.system.stabilisation-period")
 
 	CurrentCfg.ConfigAdmissionControllerPolicy.NsFilter, CurrentCfg.ConfigAdmissionControllerPolicy.NsNotFilter = getConfigNsFilter("application.admission-controller.namespace-filter")
 	CurrentCfg.ConfigAdmissionControllerPolicy.GenericPolicyList = viper.GetStringSlice("application.admission-controller.generic-policy-list")
@@ -207,6 +308,18 @@ func LoadConfigFromFile() {
 		Enable:              viper.GetBool("purge-old-db-entries.enable"),
 		CronJobTimeInterval: "@every " + viper.GetString("purge-old-db-entries.cron-job-time-interval"),
 		DBName:              viper.GetStringSlice("purge-old-db-entries.dbname"),
+		Retention:           map[string]types.ConfigRetention{},
+		Downsampling: types.ConfigDownsampling{
+			Enable:      viper.GetBool("purge-old-db-entries.downsampling.enable"),
+			HourlyAfter: int64(viper.GetDuration("purge-old-db-entries.downsampling.hourly-after").Seconds()),
+			DailyAfter:  int64(viper.GetDuration("purge-old-db-entries.downsampling.daily-after").Seconds()),
+		},
+	}
+	for key, table := range retentionTables {
+		CurrentCfg.ConfigPurgeOldDBEntries.Retention[table] = types.ConfigRetention{
+			MaxAge:  int64(viper.GetDuration("purge-old-db-entries.retention." + key + ".max-age").Seconds()),
+			MaxRows: viper.GetInt64("purge-old-db-entries.retention." + key + ".max-rows"),
+		}
 	}
 
 	// recommend policy configurations
@@ -219,6 +332,20 @@ func LoadConfigFromFile() {
 		RecommendTemplateVersion:           viper.GetString("recommend.template-version"),
 	}
 
+	// load file/process path aggregation rules
+	CurrentCfg.ConfigPathAggregation = LoadConfigPathAggregation()
+
+	// leader election of the discovery workers
+	CurrentCfg.ConfigLeaderElection = types.ConfigLeaderElection{
+		Enable:        viper.GetBool("leader-election.enable"),
+		LeaseName:     viper.GetString("leader-election.lease-name"),
+		Namespace:     viper.GetString("leader-election.namespace"),
+		Identity:      viper.GetString("leader-election.identity"),
+		LeaseDuration: int64(viper.GetDuration("leader-election.lease-duration").Seconds()),
+		RenewDeadline: int64(viper.GetDuration("leader-election.renew-deadline").Seconds()),
+		RetryPeriod:   int64(viper.GetDuration("leader-election.retry-period").Seconds()),
+	}
+
 	// load database
 	CurrentCfg.ConfigDB = LoadConfigDB()
 
@@ -306,6 +433,18 @@ func GetCfgNetworkLogFile() string {
 	return CurrentCfg.ConfigNetPolicy.NetworkLogFile
 }
 
+func GetCfgEnvoyReceiver() string {
+	return CurrentCfg.ConfigNetPolicy.EnvoyReceiver
+}
+
+func GetCfgPolicyGapMaxAge() int {
+	return CurrentCfg.ConfigNetPolicy.PolicyGapMaxAge
+}
+
+func GetCfgPolicyGapMaxCount() int {
+	return CurrentCfg.ConfigNetPolicy.PolicyGapMaxCount
+}
+
 func GetCfgCiliumHubble() types.ConfigCiliumHubble {
 	return CurrentCfg.ConfigCiliumHubble
 }
@@ -420,6 +559,34 @@ func GetCfgSystemFileFromSource() bool {
 	return CurrentCfg.ConfigSysPolicy.FileFromSource
 }
 
+func GetCfgSystemHostPolicy() bool {
+	return CurrentCfg.ConfigSysPolicy.HostPolicy
+}
+
+func GetCfgSystemHostNodeLabelKeys() []string {
+	return CurrentCfg.ConfigSysPolicy.HostNodeLabelKeys
+}
+
+func GetCfgSystemContainerPolicy() bool {
+	return CurrentCfg.ConfigSysPolicy.ContainerPolicy
+}
+
+func GetCfgSystemInitContainerMode() string {
+	return CurrentCfg.ConfigSysPolicy.InitContainerMode
+}
+
+func GetCfgSystemJobMode() string {
+	return CurrentCfg.ConfigSysPolicy.JobMode
+}
+
+func GetCfgSystemAnomalyDetection() bool {
+	return CurrentCfg.ConfigSysPolicy.AnomalyDetection
+}
+
+func GetCfgSystemStabilisationPeriod() string {
+	return CurrentCfg.ConfigSysPolicy.StabilisationPeriod
+}
+
 // ============================= //
 // == Get Cluster Config Info == //
 // ============================= //
@@ -481,6 +648,21 @@ func getConfigNsFilter(config string) ([]string, []string) {
 	return ns, notNs
 }
 
+// ============================ //
+// == Extract Verdict Filter == //
+// ============================ //
+
+func getConfigVerdictFilter(config string) ([]string, []string) {
+	verdicts, notVerdicts := getConfigNsFilter(config)
+	for i := range verdicts {
+		verdicts[i] = strings.ToUpper(verdicts[i])
+	}
+	for i := range notVerdicts {
+		notVerdicts[i] = strings.ToUpper(notVerdicts[i])
+	}
+	return verdicts, notVerdicts
+}
+
 // ========================== //
 // == Get Publisher Config == //
 // ========================== //
@@ -509,6 +691,18 @@ func GetCfgPurgeOldDBEntriesDBName() []string {
 	return CurrentCfg.ConfigPurgeOldDBEntries.DBName
 }
 
+func GetCfgPurgeOldDBEntries() types.ConfigPurgeOldDBEntries {
+	return CurrentCfg.ConfigPurgeOldDBEntries
+}
+
+// ===================== //
+// == Leader Election == //
+// ===================== //
+
+func GetCfgLeaderElection() types.ConfigLeaderElection {
+	return CurrentCfg.ConfigLeaderElection
+}
+
 // ============================ //
 // == Get Recommend Config Info == //
 // ============================ //
@@ -537,6 +731,14 @@ func GetCfgRecommendTemplateVersion() string {
 	return CurrentCfg.ConfigRecommendPolicy.RecommendTemplateVersion
 }
 
+// ====================================== //
+// == Get Path Aggregation Config Info == //
+// ====================================== //
+
+func GetCfgPathAggregation() types.ConfigPathAggregation {
+	return CurrentCfg.ConfigPathAggregation
+}
+
 // ======================================= //
 // == Get Discovered Policy Config Info == //
 // ======================================= //
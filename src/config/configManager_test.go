@@ -81,6 +81,17 @@ cilium-hubble:
 kubearmor:
   url: 10.4.41.240
   port: 8079
+
+path-aggregation:
+  threshold: 5
+  prefix-thresholds:
+    - prefix: "/usr/lib/"
+      threshold: 10
+  never-collapse:
+    - "/etc/"
+  normalizers:
+    - pattern: "^/proc/[0-9]+/"
+      replacement: "/proc/self/"
 `)
 
 func initMockYaml() {
@@ -119,6 +130,21 @@ func TestLoadConfigKubeArmor(t *testing.T) {
 	assert.NotEmpty(t, cfg.KubeArmorRelayPort, "KubeArmor relay Port should not be empty")
 }
 
+func TestLoadConfigPathAggregation(t *testing.T) {
+	initMockYaml()
+
+	cfg := LoadConfigPathAggregation()
+
+	assert.Equal(t, 5, cfg.Threshold)
+	assert.Equal(t, []string{"/etc/"}, cfg.NeverCollapse)
+	assert.Empty(t, cfg.AlwaysCollapse)
+	assert.Len(t, cfg.PrefixThresholds, 1)
+	assert.Equal(t, "/usr/lib/", cfg.PrefixThresholds[0].Prefix)
+	assert.Equal(t, 10, cfg.PrefixThresholds[0].Threshold)
+	assert.Len(t, cfg.Normalizers, 1)
+	assert.Equal(t, "/proc/self/", cfg.Normalizers[0].Replacement)
+}
+
 func TestLoadDefaultConfig(t *testing.T) {
 	initMockYaml()
 
@@ -3,26 +3,21 @@ package feedconsumer
 import (
 	"encoding/json"
 	"errors"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog"
 
 	"github.com/spf13/viper"
 
 	cfg "github.com/accuknox/auto-policy-discovery/src/config"
-	"github.com/accuknox/auto-policy-discovery/src/libs"
 	logger "github.com/accuknox/auto-policy-discovery/src/logging"
-	"github.com/accuknox/auto-policy-discovery/src/plugin"
 	types "github.com/accuknox/auto-policy-discovery/src/types"
-	cilium "github.com/cilium/cilium/api/v1/flow"
-	pb "github.com/kubearmor/KubeArmor/protobuf"
-	"google.golang.org/protobuf/types/known/timestamppb"
-	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 const ( // status
@@ -33,6 +28,8 @@ const ( // status
 const (
 	DRIVER_KAFKA  = "kafka"
 	DRIVER_PULSAR = "pulsar"
+	DRIVER_NATS   = "nats"
+	DRIVER_REDIS  = "redis"
 )
 
 const (
@@ -52,7 +49,7 @@ var ConsumerMutex sync.Mutex
 var waitG sync.WaitGroup
 var stopChan chan struct{}
 
-var pulsarReceiver chan pulsar.ConsumerMessage
+var handedOffEvents *dedupWindow
 
 var log *zerolog.Logger
 
@@ -65,7 +62,7 @@ func init() {
 
 	consumers = []*KnoxFeedConsumer{}
 
-	pulsarReceiver = make(chan pulsar.ConsumerMessage, 100)
+	handedOffEvents = newDedupWindow(DefaultDedupWindowSize)
 }
 
 // ======================== //
@@ -77,29 +74,68 @@ type KnoxFeedConsumer struct {
 	driver         string
 	kafkaConfig    kafka.ConfigMap
 	pulsarConfig   pulsar.ClientOptions
+	natsConfig     natsConfig
+	redisConfig    redisConfig
 	ciliumTopic    string
 	kubearmorTopic string
 	consumerGroup  string
 	messageOffset  string
 	eventsBuffer   int
+	flushInterval  time.Duration
+	maxQueueSize   int
+	paused         bool
+
+	deadLetterSink      string
+	lastDeadLetterRetry time.Time
+
+	lastNetLogFlush time.Time
+	lastSyslogFlush time.Time
 
 	netLogEvents      []types.NetworkLogEvent
+	netLogPending     []*pendingMsg
 	netLogEventsCount int
 
 	syslogEvents      []types.SystemLogEvent
+	syslogPending     []*pendingMsg
 	syslogEventsCount int
+
+	pending []*pendingMsg
+	ack     func(msgs []*Message)
 }
 
 func (cfc *KnoxFeedConsumer) setupConfig() {
 	cfc.driver = viper.GetString("feed-consumer.driver")
 	servers := viper.GetStringSlice("feed-consumer.servers")
 
-	cfc.consumerGroup = viper.GetString("feed-consumer.consumer-group") + "-" + libs.RandSeq(15)
+	// the group is shared by the restarts and the replicas, so the committed offsets are resumed
+	cfc.consumerGroup = viper.GetString("feed-consumer.consumer-group")
 	cfc.ciliumTopic = viper.GetString("feed-consumer.topic.cilium")
 	cfc.kubearmorTopic = viper.GetString("feed-consumer.topic.kubearmor")
 
 	cfc.messageOffset = viper.GetString("feed-consumer.message-offset")
 	cfc.eventsBuffer = viper.GetInt("feed-consumer.event-buffer-size")
+	cfc.maxQueueSize = viper.GetInt("feed-consumer.max-queue-size")
+	if cfc.maxQueueSize > 0 && cfc.maxQueueSize < cfc.eventsBuffer {
+		log.Warn().Msgf("feed-consumer max-queue-size [%d] is less than event-buffer-size, using %d",
+			cfc.maxQueueSize, cfc.eventsBuffer)
+		cfc.maxQueueSize = cfc.eventsBuffer
+	}
+	atomic.StoreInt64(&metrics.queueCapacity, int64(cfc.maxQueueSize))
+
+	flushInterval, err := time.ParseDuration(viper.GetString("feed-consumer.flush-interval"))
+	if err != nil || flushInterval < 0 {
+		log.Error().Msgf("invalid feed-consumer flush-interval, using %s", DefaultFlushInterval)
+		flushInterval = DefaultFlushInterval
+	}
+	cfc.flushInterval = flushInterval
+	cfc.lastNetLogFlush = time.Now()
+	cfc.lastSyslogFlush = time.Now()
+
+	cfc.deadLetterSink = viper.GetString("feed-consumer.dead-letter.sink")
+	if cfc.deadLetterSink != DEAD_LETTER_TABLE && cfc.deadLetterSink != DEAD_LETTER_NONE {
+		log.Error().Msgf("invalid feed-consumer dead-letter sink [%s], using %s", cfc.deadLetterSink, DEAD_LETTER_TABLE)
+		cfc.deadLetterSink = DEAD_LETTER_TABLE
+	}
 
 	cfc.netLogEvents = make([]types.NetworkLogEvent, 0, cfc.eventsBuffer)
 	cfc.syslogEvents = make([]types.SystemLogEvent, 0, cfc.eventsBuffer)
@@ -114,13 +150,13 @@ func (cfc *KnoxFeedConsumer) setupConfig() {
 
 	if cfc.driver == DRIVER_KAFKA {
 		cfc.kafkaConfig = kafka.ConfigMap{
-			"enable.auto.commit":      true,
-			"auto.commit.interval.ms": 1000,
-			"bootstrap.servers":       strings.Join(servers, ","),
-			"broker.address.family":   viper.GetString("feed-consumer.kafka.server-address-family"),
-			"group.id":                cfc.consumerGroup,
-			"session.timeout.ms":      viper.GetString("feed-consumer.kafka.session-timeout"),
-			"auto.offset.reset":       cfc.messageOffset,
+			// offsets are committed once the events are handed off
+			"enable.auto.commit":    false,
+			"bootstrap.servers":     strings.Join(servers, ","),
+			"broker.address.family": viper.GetString("feed-consumer.kafka.server-address-family"),
+			"group.id":              cfc.consumerGroup,
+			"session.timeout.ms":    viper.GetString("feed-consumer.kafka.session-timeout"),
+			"auto.offset.reset":     cfc.messageOffset,
 		}
 
 		// Set up TLS encryption/authentication configs
@@ -154,49 +190,57 @@ func (cfc *KnoxFeedConsumer) setupConfig() {
 		} else {
 			cfc.pulsarConfig.URL = "pulsar://" + strings.Join(servers, ",")
 		}
+	} else if cfc.driver == DRIVER_NATS {
+		cfc.natsConfig.URL = strings.Join(servers, ",")
+		if encryptEnabled {
+			cfc.natsConfig.Options = append(cfc.natsConfig.Options, nats.RootCAs(caCertPath))
+			if authEnabled {
+				cfc.natsConfig.Options = append(cfc.natsConfig.Options, nats.ClientCert(certPath, keyPath))
+			}
+		}
+	} else if cfc.driver == DRIVER_REDIS {
+		if len(servers) > 0 {
+			cfc.redisConfig.Options.Addr = servers[0]
+		}
+		cfc.redisConfig.Options.Password = viper.GetString("feed-consumer.redis.password")
+		cfc.redisConfig.Options.DB = viper.GetInt("feed-consumer.redis.db")
+		cfc.redisConfig.PayloadField = viper.GetString("feed-consumer.redis.payload-field")
+		if encryptEnabled {
+			tlsConfig, err := getTLSConfig(caCertPath, authEnabled, certPath, keyPath)
+			if err != nil {
+				log.Error().Msg(err.Error())
+			}
+			cfc.redisConfig.Options.TLSConfig = tlsConfig
+		}
 	} else {
-		log.Error().Msg("Invalid feed-consumer driver. Supported drivers are 'kafka' and 'pulsar'.")
+		log.Error().Msg("Invalid feed-consumer driver. Supported drivers are 'kafka', 'pulsar', 'nats' and 'redis'.")
 	}
 }
 
-func (cfc *KnoxFeedConsumer) HandlePollEvent(ev interface{}) bool {
-	var topic string
-	var msg []byte
-
-	switch e := ev.(type) {
-	case pulsar.Message:
-		topic = e.Topic()
-		msg = e.Payload()
-	case *kafka.Message:
-		topic = *e.TopicPartition.Topic
-		msg = e.Value
-	case kafka.Error:
-		// Errors should generally be considered
-		// informational, the client will try to
-		// automatically recover.
-		// But in this example we choose to terminate
-		// the application if all brokers are down.
-		log.Error().Msgf("Error: %v: %v\n", e.Code(), e)
-		if e.Code() == kafka.ErrAllBrokersDown {
-			return false
-		}
-	default:
-		log.Debug().Msgf("Ignored %v\n", e)
+// HandleMessage passes the message to the pipeline of its topic,
+// the messages which cannot be parsed are dead-lettered
+func (cfc *KnoxFeedConsumer) HandleMessage(msg *Message) {
+	pending := cfc.track(msg)
+
+	err := cfc.processMessage(msg.Topic, msg.Payload, pending)
+	if errors.Is(err, errUnknownTopic) {
+		log.Info().Msgf("Received message from unknown topic %s\n", msg.Topic)
+		pending.done = true
+	} else if err != nil {
+		log.Error().Msg(err.Error())
+		cfc.deadLetter(msg, err, pending)
 	}
 
+	cfc.ackDone()
+}
+
+func (cfc *KnoxFeedConsumer) processMessage(topic string, message []byte, pending *pendingMsg) error {
 	if topic == cfc.ciliumTopic {
-		if err := cfc.processNetworkLogMessage(msg); err != nil {
-			log.Error().Msg(err.Error())
-		}
+		return cfc.processNetworkLogMessage(message, pending)
 	} else if topic == cfc.kubearmorTopic {
-		if err := cfc.processSystemLogMessage(msg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if topic != "" {
-		log.Info().Msgf("Received message from unknown topic %s\n", topic)
+		return cfc.processSystemLogMessage(message, pending)
 	}
-
-	return true
+	return errUnknownTopic
 }
 
 func (cfc *KnoxFeedConsumer) getSubscriptionTopics() []string {
@@ -213,87 +257,33 @@ func (cfc *KnoxFeedConsumer) getSubscriptionTopics() []string {
 }
 
 func (cfc *KnoxFeedConsumer) startConsumer() {
-	if cfc.driver == DRIVER_KAFKA {
-		cfc.startConsumerKafka()
-	} else if cfc.driver == DRIVER_PULSAR {
-		cfc.startConsumerPulsar()
-	}
-}
-
-func (cfc *KnoxFeedConsumer) startConsumerKafka() {
 	defer waitG.Done()
 
-	c, err := kafka.NewConsumer(&cfc.kafkaConfig)
+	driver, err := cfc.newDriver()
 	if err != nil {
-		log.Error().Msgf("Failed to create consumer: %s", err)
+		log.Error().Msg(err.Error())
 		return
 	}
-	log.Debug().Msgf("Created Consumer %v", c)
 
 	subTopics := cfc.getSubscriptionTopics()
-	err = c.SubscribeTopics(subTopics, nil)
-	if err != nil {
+	if err := driver.Connect(subTopics); err != nil {
 		log.Error().Msgf("Failed to subscribe topics: %s", err)
 		return
 	}
 
-	log.Info().Msgf("Starting consumer %d, topics: %v", cfc.id, subTopics)
+	log.Info().Msgf("Starting consumer %d, driver: %s, topics: %v", cfc.id, cfc.driver, subTopics)
 
-	run := true
-	for run {
-		select {
-		case <-stopChan:
-			log.Info().Msgf("Got a signal to terminate the consumer %d", cfc.id)
-			run = false
-
-		default:
-			ev := c.Poll(100)
-			if ev == nil {
-				continue
-			}
-			run = cfc.HandlePollEvent(ev)
-		}
-	}
+	cfc.run(driver)
 
 	log.Info().Msgf("Closing consumer %d", cfc.id)
-	if err := c.Close(); err != nil {
+	if err := driver.Close(); err != nil {
 		log.Error().Msg(err.Error())
 	}
 }
 
-func (cfc *KnoxFeedConsumer) startConsumerPulsar() {
-	defer waitG.Done()
-
-	c, err := pulsar.NewClient(cfc.pulsarConfig)
-	if err != nil {
-		log.Error().Msgf("Failed to create pulsar client: %s", err)
-		return
-	}
-	defer c.Close()
-
-	log.Debug().Msgf("Created pulsar client %v", c)
-
-	subTopics := cfc.getSubscriptionTopics()
-
-	subOffset := pulsar.SubscriptionPositionLatest
-	if cfc.messageOffset == MSG_OFFSET_EARLIEST {
-		subOffset = pulsar.SubscriptionPositionEarliest
-	}
-
-	sub, err := c.Subscribe(pulsar.ConsumerOptions{
-		Topics:                      subTopics,
-		SubscriptionName:            cfc.consumerGroup,
-		Type:                        pulsar.Shared,
-		SubscriptionInitialPosition: subOffset,
-		MessageChannel:              pulsarReceiver,
-	})
-	if err != nil {
-		log.Error().Msgf("Failed to subscribe topics: %s", err)
-		return
-	}
-	defer sub.Close()
-
-	log.Info().Msgf("Starting consumer %d, topics: %v", cfc.id, subTopics)
+// run consumes the messages of the driver until the consumers are stopped
+func (cfc *KnoxFeedConsumer) run(driver Driver) {
+	cfc.ack = driver.Ack
 
 	run := true
 	for run {
@@ -303,16 +293,46 @@ func (cfc *KnoxFeedConsumer) startConsumerPulsar() {
 			run = false
 
 		default:
-			ev := <-pulsarReceiver
-			_ = sub.Ack(ev)
-			run = cfc.HandlePollEvent(ev.Message)
+			cfc.flushIfDue()
+			cfc.retryDeadLetters()
+			cfc.applyBackpressure(driver)
+
+			msg, err := driver.Poll(flushTick)
+			if err != nil {
+				log.Error().Msgf("Consumer %d failed: %s", cfc.id, err)
+				run = false
+				continue
+			}
+			if msg == nil {
+				continue
+			}
+			cfc.HandleMessage(msg)
 		}
 	}
 
-	log.Info().Msgf("Closing consumer %d", cfc.id)
+	cfc.stopFlush()
 }
 
-func (cfc *KnoxFeedConsumer) processNetworkLogMessage(message []byte) error {
+// stopFlush hands off what is left in the buffers before the consumer is closed
+func (cfc *KnoxFeedConsumer) stopFlush() {
+	netLogsFlushed := cfc.flushNetworkLogs()
+	syslogsFlushed := cfc.flushSystemLogs()
+	cfc.ackDone()
+
+	// the events left behind are not acknowledged, they will be redelivered
+	if !netLogsFlushed || !syslogsFlushed {
+		log.Warn().Msgf("Hand-off queue is full, leaving buffered events of consumer %d for redelivery (network=%d system=%d)",
+			cfc.id, cfc.netLogEventsCount, cfc.syslogEventsCount)
+		atomic.AddInt64(&metrics.networkLogsBuffered, -int64(cfc.netLogEventsCount))
+		atomic.AddInt64(&metrics.systemLogsBuffered, -int64(cfc.syslogEventsCount))
+	}
+	if cfc.paused {
+		atomic.AddInt32(&metrics.pausedConsumers, -1)
+		cfc.paused = false
+	}
+}
+
+func (cfc *KnoxFeedConsumer) processNetworkLogMessage(message []byte, pending *pendingMsg) error {
 	event := types.NetworkLogEvent{}
 	var eventMap map[string]json.RawMessage
 	if err := json.Unmarshal(message, &eventMap); err != nil {
@@ -342,51 +362,22 @@ func (cfc *KnoxFeedConsumer) processNetworkLogMessage(message []byte) error {
 
 	// add cluster_name to the event
 	event.ClusterName = clusterNameStr
-	cfc.netLogEvents = append(cfc.netLogEvents, event)
-	cfc.netLogEventsCount++
 
-	if cfc.netLogEventsCount == cfc.eventsBuffer {
-		if len(cfc.netLogEvents) > 0 {
-			for _, netLog := range cfc.netLogEvents {
-				time, _ := strconv.ParseInt(netLog.Time, 10, 64)
-				flow := &cilium.Flow{
-					TrafficDirection: cilium.TrafficDirection(plugin.TrafficDirection[netLog.TrafficDirection]),
-					PolicyMatchType:  uint32(netLog.PolicyMatchType),
-					DropReason:       uint32(netLog.DropReason),
-					Verdict:          cilium.Verdict(plugin.Verdict[netLog.Verdict]),
-					Time: &timestamppb.Timestamp{
-						Seconds: time,
-					},
-					EventType:   &cilium.CiliumEventType{},
-					Source:      &cilium.Endpoint{},
-					Destination: &cilium.Endpoint{},
-					IP:          &cilium.IP{},
-					L4:          &cilium.Layer4{},
-					L7:          &cilium.Layer7{},
-					IsReply:     &wrapperspb.BoolValue{Value: netLog.Reply},
-				}
+	// skip the redelivered events which were already handed off
+	pending.key = getNetworkLogEventKey(event)
+	if handedOffEvents.Seen(pending.key) {
+		atomic.AddUint64(&metrics.redeliveredEvents, 1)
+		pending.done = true
+		return nil
+	}
 
-				// _ = is to ignore the return value
-				_ = plugin.GetFlowData(netLog.EventType, flow.EventType)
-				_ = plugin.GetFlowData(netLog.Source, flow.Source)
-				_ = plugin.GetFlowData(netLog.Destination, flow.Destination)
-				_ = plugin.GetFlowData(netLog.IP, flow.IP)
-				_ = plugin.GetFlowData(netLog.L4, flow.L4)
-				_ = plugin.GetFlowData(netLog.L7, flow.L7)
-
-				knoxFlow, valid := plugin.ConvertCiliumFlowToKnoxNetworkLog(flow)
-				if valid {
-					knoxFlow.ClusterName = netLog.ClusterName
-					plugin.CiliumFlowsFCMutex.Lock()
-					plugin.CiliumFlowsFC = append(plugin.CiliumFlowsFC, &knoxFlow)
-					plugin.CiliumFlowsFCMutex.Unlock()
-				}
-			}
-			cfc.netLogEvents = nil
-			cfc.netLogEvents = make([]types.NetworkLogEvent, 0, cfc.eventsBuffer)
-		}
+	cfc.netLogEvents = append(cfc.netLogEvents, event)
+	cfc.netLogPending = append(cfc.netLogPending, pending)
+	cfc.netLogEventsCount++
+	atomic.AddInt64(&metrics.networkLogsBuffered, 1)
 
-		cfc.netLogEventsCount = 0
+	if cfc.netLogEventsCount >= cfc.eventsBuffer {
+		cfc.flushNetworkLogs()
 	}
 
 	return nil
@@ -394,7 +385,7 @@ func (cfc *KnoxFeedConsumer) processNetworkLogMessage(message []byte) error {
 
 // == //
 
-func (cfc *KnoxFeedConsumer) processSystemLogMessage(message []byte) error {
+func (cfc *KnoxFeedConsumer) processSystemLogMessage(message []byte, pending *pendingMsg) error {
 	syslogEvent := types.SystemLogEvent{}
 
 	err := json.Unmarshal(message, &syslogEvent)
@@ -403,39 +394,21 @@ func (cfc *KnoxFeedConsumer) processSystemLogMessage(message []byte) error {
 		return err
 	}
 
+	// skip the redelivered events which were already handed off
+	pending.key = getSystemLogEventKey(syslogEvent)
+	if handedOffEvents.Seen(pending.key) {
+		atomic.AddUint64(&metrics.redeliveredEvents, 1)
+		pending.done = true
+		return nil
+	}
+
 	cfc.syslogEvents = append(cfc.syslogEvents, syslogEvent)
+	cfc.syslogPending = append(cfc.syslogPending, pending)
 	cfc.syslogEventsCount++
+	atomic.AddInt64(&metrics.systemLogsBuffered, 1)
 
-	if cfc.syslogEventsCount == cfc.eventsBuffer {
-		if len(cfc.syslogEvents) > 0 {
-			for _, syslog := range cfc.syslogEvents {
-				log := pb.Alert{
-					ClusterName:   syslog.ClusterName,
-					HostName:      syslog.HostName,
-					NamespaceName: syslog.NamespaceName,
-					ContainerName: syslog.ContainerName,
-					PodName:       syslog.PodName,
-					Source:        syslog.Source,
-					Operation:     syslog.Operation,
-					Resource:      syslog.Resource,
-					Data:          syslog.Data,
-					Result:        syslog.Result,
-				}
-
-				knoxLog, err := plugin.ConvertKubeArmorLogToKnoxSystemLog(&log)
-				if err != nil {
-					continue
-				}
-				knoxLog.ClusterName = syslog.Clustername
-				plugin.KubeArmorFCLogsMutex.Lock()
-				plugin.KubeArmorFCLogs = append(plugin.KubeArmorFCLogs, &knoxLog)
-				plugin.KubeArmorFCLogsMutex.Unlock()
-			}
-			cfc.syslogEvents = nil
-			cfc.syslogEvents = make([]types.SystemLogEvent, 0, cfc.eventsBuffer)
-		}
-
-		cfc.syslogEventsCount = 0
+	if cfc.syslogEventsCount >= cfc.eventsBuffer {
+		cfc.flushSystemLogs()
 	}
 
 	return nil
@@ -452,6 +425,13 @@ func StartConsumer() {
 
 	numOfConsumers = viper.GetInt("feed-consumer.number-of-consumers")
 
+	dedupWindowSize := viper.GetInt("feed-consumer.dedup-window-size")
+	if dedupWindowSize > 0 && dedupWindowSize != len(handedOffEvents.ring) {
+		handedOffEvents = newDedupWindow(dedupWindowSize)
+	}
+
+	stopChan = make(chan struct{})
+
 	n := 0
 	log.Info().Msgf("%d Knox feed consumer(s) started", numOfConsumers)
 
@@ -462,12 +442,11 @@ func StartConsumer() {
 
 		c.setupConfig()
 		consumers = append(consumers, c)
-		go c.startConsumer()
 		waitG.Add(1)
+		go c.startConsumer()
 		n++
 	}
 
-	stopChan = make(chan struct{})
 	Status = STATUS_RUNNING
 
 	log.Info().Msg("Knox feed consumer(s) started")
@@ -1,8 +1,12 @@
 package feedconsumer
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/accuknox/auto-policy-discovery/src/plugin"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -89,7 +93,7 @@ func TestProcessMessage(t *testing.T) {
 	dataBytes := []byte(cilium)
 	consumer := &KnoxFeedConsumer{}
 
-	err := consumer.processNetworkLogMessage(dataBytes)
+	err := consumer.processNetworkLogMessage(dataBytes, consumer.track(nil))
 	assert.NoError(t, err)
 }
 
@@ -119,6 +123,87 @@ func TestProcessSystemLogMessage(t *testing.T) {
 	dataBytes := []byte(kubearmor)
 	consumer := &KnoxFeedConsumer{}
 
-	err := consumer.processSystemLogMessage(dataBytes)
+	err := consumer.processSystemLogMessage(dataBytes, consumer.track(nil))
 	assert.NoError(t, err)
 }
+
+func getTestSystemLogMessage(pid int) []byte {
+	return []byte(fmt.Sprintf(`{
+		"ClusterName":"accuknox-dev",
+		"NamespaceName":"default",
+		"PodName":"recommendationservice-cb98b57c-6255h",
+		"ContainerName":"server",
+		"Operation":"File",
+		"Source":"/usr/local/bin/python",
+		"Resource":"/etc/hosts",
+		"Data":"flags=O_RDONLY",
+		"Result":"Passed",
+		"UpdatedTime":"2021-03-23T12:01:28.661031Z",
+		"PID":%d,
+		"cluster_name":"accuknox-dev"
+	 }`, pid))
+}
+
+func resetHandOff() {
+	plugin.KubeArmorFCLogsMutex.Lock()
+	plugin.KubeArmorFCLogs = []*types.KnoxSystemLog{}
+	plugin.KubeArmorFCLogsMutex.Unlock()
+
+	handedOffEvents = newDedupWindow(DefaultDedupWindowSize)
+}
+
+func TestFlushSystemLogsOnInterval(t *testing.T) {
+	resetHandOff()
+	defer resetHandOff()
+
+	consumer := &KnoxFeedConsumer{
+		eventsBuffer:    10,
+		flushInterval:   time.Minute,
+		lastSyslogFlush: time.Now(),
+	}
+
+	assert.NoError(t, consumer.processSystemLogMessage(getTestSystemLogMessage(1), consumer.track(nil)))
+
+	// buffer is not full and the interval has not elapsed
+	consumer.flushIfDue()
+	assert.Len(t, plugin.KubeArmorFCLogs, 0)
+	assert.Equal(t, 1, consumer.syslogEventsCount)
+
+	consumer.lastSyslogFlush = time.Now().Add(-2 * time.Minute)
+	consumer.flushIfDue()
+	assert.Len(t, plugin.KubeArmorFCLogs, 1)
+	assert.Equal(t, 0, consumer.syslogEventsCount)
+}
+
+func TestFlushSystemLogsBackpressure(t *testing.T) {
+	resetHandOff()
+	defer resetHandOff()
+
+	consumer := &KnoxFeedConsumer{
+		eventsBuffer:  2,
+		maxQueueSize:  3,
+		flushInterval: time.Minute,
+	}
+
+	// the first buffer is handed off, the second one only partially
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, consumer.processSystemLogMessage(getTestSystemLogMessage(i), consumer.track(nil)))
+	}
+	assert.Len(t, plugin.KubeArmorFCLogs, 3)
+	assert.Equal(t, 1, consumer.syslogEventsCount)
+	assert.False(t, consumer.isBackpressured())
+
+	// the buffer is full and the queue is full
+	assert.NoError(t, consumer.processSystemLogMessage(getTestSystemLogMessage(4), consumer.track(nil)))
+	assert.Equal(t, 2, consumer.syslogEventsCount)
+	assert.True(t, consumer.updatePauseState())
+	assert.True(t, consumer.paused)
+
+	// the discovery drains the queue
+	resetHandOff()
+	consumer.flushIfDue()
+	assert.Len(t, plugin.KubeArmorFCLogs, 2)
+	assert.True(t, consumer.updatePauseState())
+	assert.False(t, consumer.paused)
+	assert.Equal(t, int32(0), GetMetrics().PausedConsumers)
+}
@@ -0,0 +1,133 @@
+package feedconsumer
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+
+	cfg "github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+const ( // dead letter sinks
+	DEAD_LETTER_TABLE = "table"
+	DEAD_LETTER_NONE  = "none"
+)
+
+var errUnknownTopic = errors.New("unknown topic")
+
+// ================= //
+// == Dead Letter == //
+// ================= //
+
+// deadLetter keeps the message which could not be parsed. The message is only done, and so
+// acknowledged, once the dead letter is written; the failed writes are retried on the flushes
+func (cfc *KnoxFeedConsumer) deadLetter(msg *Message, reason error, pending *pendingMsg) {
+	if cfc.deadLetterSink == DEAD_LETTER_NONE {
+		pending.done = true
+		return
+	}
+
+	pending.deadLetter = &types.DeadLetter{
+		Driver:      cfc.driver,
+		Topic:       msg.Topic,
+		Partition:   msg.Partition,
+		Offset:      msg.Offset,
+		MessageID:   msg.ID,
+		Payload:     msg.Payload,
+		Reason:      reason.Error(),
+		CreatedTime: time.Now().UTC().Unix(),
+	}
+	cfc.writeDeadLetter(pending)
+}
+
+func (cfc *KnoxFeedConsumer) writeDeadLetter(pending *pendingMsg) bool {
+	if err := libs.InsertDeadLetter(cfg.GetCfgDB(), *pending.deadLetter); err != nil {
+		log.Error().Msgf("Failed to write the dead letter of %s [partition=%d offset=%d], retrying: %s",
+			pending.deadLetter.Topic, pending.deadLetter.Partition, pending.deadLetter.Offset, err)
+		return false
+	}
+
+	atomic.AddUint64(&metrics.deadLetters, 1)
+	pending.deadLetter = nil
+	pending.done = true
+	return true
+}
+
+// retryDeadLetters writes the dead letters again, in the arrival order
+func (cfc *KnoxFeedConsumer) retryDeadLetters() {
+	if time.Since(cfc.lastDeadLetterRetry) < cfc.flushInterval {
+		return
+	}
+	cfc.lastDeadLetterRetry = time.Now()
+
+	for _, pending := range cfc.pending {
+		if pending.deadLetter != nil && !cfc.writeDeadLetter(pending) {
+			return
+		}
+	}
+	cfc.ackDone()
+}
+
+// ListDeadLetters returns the dead letters kept in the table
+func ListDeadLetters(filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	return libs.GetDeadLetters(cfg.GetCfgDB(), filter)
+}
+
+// ReplayDeadLetters passes the dead letters to the pipelines again. The ones handed off
+// are removed from the table, the ones still failing are returned with the new reason.
+func ReplayDeadLetters(filter types.DeadLetterFilter) (int, []types.DeadLetter, error) {
+	deadLetters, err := ListDeadLetters(filter)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	cfc := &KnoxFeedConsumer{
+		ciliumTopic:     viper.GetString("feed-consumer.topic.cilium"),
+		kubearmorTopic:  viper.GetString("feed-consumer.topic.kubearmor"),
+		eventsBuffer:    len(deadLetters) + 1, // flushed at once below
+		maxQueueSize:    viper.GetInt("feed-consumer.max-queue-size"),
+		lastNetLogFlush: time.Now(),
+		lastSyslogFlush: time.Now(),
+	}
+
+	pendings := make([]*pendingMsg, len(deadLetters))
+	failed := []types.DeadLetter{}
+
+	for i := range deadLetters {
+		pendings[i] = &pendingMsg{}
+		if err := cfc.processMessage(deadLetters[i].Topic, deadLetters[i].Payload, pendings[i]); err != nil {
+			deadLetters[i].Reason = err.Error()
+			failed = append(failed, deadLetters[i])
+			pendings[i] = nil
+		}
+	}
+
+	cfc.stopFlush()
+
+	replayed := []int64{}
+	for i, pending := range pendings {
+		if pending == nil {
+			continue
+		}
+		if !pending.done {
+			deadLetters[i].Reason = "hand-off queue is full"
+			failed = append(failed, deadLetters[i])
+			continue
+		}
+		replayed = append(replayed, deadLetters[i].ID)
+	}
+
+	if err := libs.DeleteDeadLetters(cfg.GetCfgDB(), replayed); err != nil {
+		return len(replayed), failed, err
+	}
+
+	if len(deadLetters) > 0 {
+		log.Info().Msgf("Replayed %d dead letter(s), %d failed", len(replayed), len(failed))
+	}
+
+	return len(replayed), failed, nil
+}
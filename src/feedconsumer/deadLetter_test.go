@@ -0,0 +1,131 @@
+package feedconsumer
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	"github.com/accuknox/auto-policy-discovery/src/plugin"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+func TestDeadLetterWriteFailure(t *testing.T) {
+	prevCfg := cfg.CurrentCfg
+	defer func() { cfg.CurrentCfg = prevCfg }()
+	dir := t.TempDir()
+	cfg.CurrentCfg.ConfigDB = types.ConfigDB{
+		DBDriver:     "sqlite3",
+		SQLiteDBPath: filepath.Join(dir, "dlq.db"),
+	}
+	cfg.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+	assert.NoError(t, libs.MigrateDB(cfg.GetCfgDB()))
+
+	// the dead letters cannot be written
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "dlq.db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	_, err = db.Exec("ALTER TABLE " + libs.TableDeadLetter_TableName + " RENAME TO dead_letter_moved")
+	assert.NoError(t, err)
+
+	driver := &memDriver{}
+	consumer := &KnoxFeedConsumer{
+		driver:         DRIVER_KAFKA,
+		ciliumTopic:    "cilium",
+		eventsBuffer:   10,
+		deadLetterSink: DEAD_LETTER_TABLE,
+		ack:            driver.Ack,
+	}
+
+	// the message is not acked until its dead letter is written
+	consumer.HandleMessage(&Message{Topic: "cilium", Payload: []byte(`{"cluster_name":"default"}`), Partition: 2, Offset: 7})
+	assert.Equal(t, 0, driver.ackedCount())
+	consumer.retryDeadLetters()
+	assert.Equal(t, 0, driver.ackedCount())
+
+	_, err = db.Exec("ALTER TABLE dead_letter_moved RENAME TO " + libs.TableDeadLetter_TableName)
+	assert.NoError(t, err)
+	consumer.lastDeadLetterRetry = time.Time{}
+	consumer.retryDeadLetters()
+	assert.Equal(t, 1, driver.ackedCount())
+
+	deadLetters, err := ListDeadLetters(types.DeadLetterFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, "cilium", deadLetters[0].Topic)
+		assert.Equal(t, int32(2), deadLetters[0].Partition)
+		assert.Equal(t, int64(7), deadLetters[0].Offset)
+		assert.Equal(t, `{"cluster_name":"default"}`, string(deadLetters[0].Payload))
+		assert.Equal(t, "Unable to parse feed-consumer message", deadLetters[0].Reason)
+	}
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	resetHandOff()
+	defer resetHandOff()
+
+	prevCfg := cfg.CurrentCfg
+	defer func() { cfg.CurrentCfg = prevCfg }()
+	dir := t.TempDir()
+	cfg.CurrentCfg.ConfigDB = types.ConfigDB{
+		DBDriver:     "sqlite3",
+		SQLiteDBPath: filepath.Join(dir, "dlq.db"),
+	}
+	cfg.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+	assert.NoError(t, libs.MigrateDB(cfg.GetCfgDB()))
+
+	viper.Set("feed-consumer.topic.kubearmor", "kubearmor")
+	defer viper.Set("feed-consumer.topic.kubearmor", nil)
+
+	// malformed messages are written to the table
+	driver := &memDriver{}
+	consumer := &KnoxFeedConsumer{
+		driver:         DRIVER_KAFKA,
+		kubearmorTopic: "kubearmor",
+		eventsBuffer:   10,
+		deadLetterSink: DEAD_LETTER_TABLE,
+		ack:            driver.Ack,
+	}
+	consumer.HandleMessage(&Message{Topic: "kubearmor", Payload: []byte("malformed"), Offset: 1})
+	consumer.HandleMessage(&Message{Topic: "kubearmor", Payload: []byte("malformed"), Offset: 2})
+	assert.Equal(t, 2, driver.ackedCount())
+
+	deadLetters, err := ListDeadLetters(types.DeadLetterFilter{Topic: "kubearmor"})
+	assert.NoError(t, err)
+	if !assert.Len(t, deadLetters, 2) {
+		return
+	}
+	assert.Equal(t, int64(1), deadLetters[0].Offset)
+	assert.Equal(t, "malformed", string(deadLetters[0].Payload))
+
+	// the first one is fixed, the second one still fails
+	fixed := deadLetters[0]
+	fixed.Payload = getTestSystemLogMessage(1)
+	assert.NoError(t, libs.DeleteDeadLetters(cfg.GetCfgDB(), []int64{fixed.ID}))
+	assert.NoError(t, libs.InsertDeadLetter(cfg.GetCfgDB(), fixed))
+
+	replayed, failed, err := ReplayDeadLetters(types.DeadLetterFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, int64(2), failed[0].Offset)
+		assert.NotEmpty(t, failed[0].Reason)
+	}
+
+	deadLetters, err = ListDeadLetters(types.DeadLetterFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, int64(2), deadLetters[0].Offset)
+	}
+
+	plugin.KubeArmorFCLogsMutex.Lock()
+	defer plugin.KubeArmorFCLogsMutex.Unlock()
+	assert.Len(t, plugin.KubeArmorFCLogs, 1)
+}
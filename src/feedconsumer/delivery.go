@@ -0,0 +1,177 @@
+package feedconsumer
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// DefaultDedupWindowSize is the number of handed-off events remembered to detect redeliveries
+const DefaultDedupWindowSize = 100000
+
+// ====================== //
+// == Pending Messages == //
+// ====================== //
+
+// pendingMsg is a consumed message which is not acknowledged yet
+type pendingMsg struct {
+	msg        *Message
+	key        uint64            // event fingerprint, 0 if unknown
+	deadLetter *types.DeadLetter // not written yet
+	done       bool
+}
+
+// track records the message in the arrival order, messages are only
+// acknowledged once all the messages received before them are done
+func (cfc *KnoxFeedConsumer) track(msg *Message) *pendingMsg {
+	pending := &pendingMsg{msg: msg}
+	cfc.pending = append(cfc.pending, pending)
+	return pending
+}
+
+// ackDone acknowledges the messages that are done, in the arrival order
+func (cfc *KnoxFeedConsumer) ackDone() {
+	n := 0
+	for n < len(cfc.pending) && cfc.pending[n].done {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+
+	msgs := make([]*Message, 0, n)
+	for _, pending := range cfc.pending[:n] {
+		if pending.msg != nil {
+			msgs = append(msgs, pending.msg)
+		}
+	}
+	cfc.pending = append([]*pendingMsg{}, cfc.pending[n:]...)
+
+	if cfc.ack != nil && len(msgs) > 0 {
+		cfc.ack(msgs)
+	}
+}
+
+// markDone marks the messages done and remembers their events as handed off
+func markDone(pendings []*pendingMsg) {
+	for _, pending := range pendings {
+		pending.done = true
+		handedOffEvents.Add(pending.key)
+	}
+}
+
+// ================== //
+// == Redeliveries == //
+// ================== //
+
+// dedupWindow remembers the last N event fingerprints
+type dedupWindow struct {
+	mutex sync.Mutex
+	keys  map[uint64]struct{}
+	ring  []uint64
+	next  int
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	if size <= 0 {
+		size = DefaultDedupWindowSize
+	}
+	return &dedupWindow{
+		keys: make(map[uint64]struct{}, size),
+		ring: make([]uint64, size),
+	}
+}
+
+// Seen checks if the fingerprint is in the window
+func (w *dedupWindow) Seen(key uint64) bool {
+	if key == 0 {
+		return false
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	_, ok := w.keys[key]
+	return ok
+}
+
+// Add puts the fingerprint in the window, evicting the oldest one
+func (w *dedupWindow) Add(key uint64) {
+	if key == 0 {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.keys[key]; ok {
+		return
+	}
+
+	if old := w.ring[w.next]; old != 0 {
+		delete(w.keys, old)
+	}
+	w.ring[w.next] = key
+	w.keys[key] = struct{}{}
+	w.next = (w.next + 1) % len(w.ring)
+}
+
+func fingerprint(fields ...[]byte) uint64 {
+	h := fnv.New64a()
+	for _, field := range fields {
+		_, _ = h.Write(field)
+		_, _ = h.Write([]byte{0})
+	}
+
+	key := h.Sum64()
+	if key == 0 {
+		key = 1 // 0 is reserved for unknown
+	}
+	return key
+}
+
+// getNetworkLogEventKey identifies a flow by its time, node and endpoints
+func getNetworkLogEventKey(event types.NetworkLogEvent) uint64 {
+	if event.Time == "" {
+		return 0
+	}
+
+	return fingerprint(
+		[]byte(event.ClusterName),
+		[]byte(event.NodeName),
+		[]byte(event.Time),
+		[]byte(event.Verdict),
+		[]byte(event.TrafficDirection),
+		[]byte(strconv.FormatBool(event.Reply)),
+		event.Source,
+		event.Destination,
+		event.IP,
+		event.L4,
+		event.L7,
+	)
+}
+
+// getSystemLogEventKey identifies a system log by its time, host, process and resource
+func getSystemLogEventKey(event types.SystemLogEvent) uint64 {
+	if event.UpdatedTime == "" && event.Time == "" && event.Timestamp == 0 {
+		return 0
+	}
+
+	return fingerprint(
+		[]byte(event.Clustername),
+		[]byte(event.HostName),
+		[]byte(event.UpdatedTime),
+		[]byte(event.Time),
+		[]byte(strconv.Itoa(event.Timestamp)),
+		[]byte(event.ContainerID),
+		[]byte(strconv.Itoa(event.HostPID)),
+		[]byte(strconv.Itoa(event.PID)),
+		[]byte(event.Operation),
+		[]byte(event.Source),
+		[]byte(event.Resource),
+		[]byte(event.Data),
+		[]byte(event.Result),
+	)
+}
@@ -0,0 +1,73 @@
+package feedconsumer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAckDoneInArrivalOrder(t *testing.T) {
+	acked := []*Message{}
+	consumer := &KnoxFeedConsumer{
+		ack: func(msgs []*Message) {
+			acked = append(acked, msgs...)
+		},
+	}
+
+	firstMsg := &Message{ID: "first"}
+	secondMsg := &Message{ID: "second"}
+	first := consumer.track(firstMsg)
+	second := consumer.track(secondMsg)
+
+	// the second message cannot be acked before the first one
+	second.done = true
+	consumer.ackDone()
+	assert.Empty(t, acked)
+
+	first.done = true
+	consumer.ackDone()
+	assert.Equal(t, []*Message{firstMsg, secondMsg}, acked)
+	assert.Empty(t, consumer.pending)
+}
+
+func TestSkipRedeliveredSystemLogs(t *testing.T) {
+	resetHandOff()
+	defer resetHandOff()
+
+	acked := 0
+	consumer := &KnoxFeedConsumer{
+		eventsBuffer: 1,
+		ack: func(msgs []*Message) {
+			acked += len(msgs)
+		},
+	}
+
+	assert.NoError(t, consumer.processSystemLogMessage(getTestSystemLogMessage(1), consumer.track(&Message{})))
+	consumer.ackDone()
+	assert.Equal(t, 1, acked)
+
+	// the same event is redelivered
+	redelivered := GetMetrics().RedeliveredEvents
+	assert.NoError(t, consumer.processSystemLogMessage(getTestSystemLogMessage(1), consumer.track(&Message{})))
+	consumer.ackDone()
+	assert.Equal(t, 2, acked)
+	assert.Equal(t, redelivered+1, GetMetrics().RedeliveredEvents)
+	assert.Equal(t, 0, consumer.syslogEventsCount)
+}
+
+func TestDedupWindowEviction(t *testing.T) {
+	window := newDedupWindow(2)
+
+	window.Add(1)
+	window.Add(2)
+	assert.True(t, window.Seen(1))
+
+	window.Add(3)
+	assert.False(t, window.Seen(1))
+	assert.True(t, window.Seen(2))
+	assert.True(t, window.Seen(3))
+
+	// unknown fingerprints are never deduplicated
+	window.Add(0)
+	assert.False(t, window.Seen(0))
+}
@@ -0,0 +1,59 @@
+package feedconsumer
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBrokerDown is returned by Poll when the consumer cannot recover
+var ErrBrokerDown = errors.New("all brokers are down")
+
+// ============ //
+// == Driver == //
+// ============ //
+
+// Message is a message consumed from a topic, independent of the driver
+type Message struct {
+	Topic     string
+	Payload   []byte
+	Partition int32  // kafka partition
+	Offset    int64  // kafka offset, nats stream sequence
+	ID        string // redis stream entry id
+
+	raw interface{} // driver specific message used for the ack
+}
+
+// Driver is a message broker the feed events are consumed from
+type Driver interface {
+	// Connect connects to the broker and subscribes to the topics
+	Connect(topics []string) error
+
+	// Poll waits up to timeout for the next message, it returns nil if there is none.
+	// Paused drivers keep the connection alive but do not return messages.
+	Poll(timeout time.Duration) (*Message, error)
+
+	// Pause stops fetching messages from the broker until Resume is called
+	Pause() error
+	Resume() error
+
+	// Ack acknowledges the messages, they are passed in the arrival order
+	Ack(msgs []*Message)
+
+	Close() error
+}
+
+// newDriver returns the driver configured for the feed consumer
+func (cfc *KnoxFeedConsumer) newDriver() (Driver, error) {
+	switch cfc.driver {
+	case DRIVER_KAFKA:
+		return newKafkaDriver(cfc.kafkaConfig), nil
+	case DRIVER_PULSAR:
+		return newPulsarDriver(cfc.pulsarConfig, cfc.consumerGroup, cfc.messageOffset), nil
+	case DRIVER_NATS:
+		return newNatsDriver(cfc.natsConfig, cfc.consumerGroup, cfc.messageOffset), nil
+	case DRIVER_REDIS:
+		return newRedisDriver(cfc.redisConfig, cfc.consumerGroup, cfc.messageOffset), nil
+	}
+
+	return nil, errors.New("invalid feed-consumer driver " + cfc.driver)
+}
@@ -0,0 +1,145 @@
+package feedconsumer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// ================== //
+// == Kafka Driver == //
+// ================== //
+
+type kafkaDriver struct {
+	config   kafka.ConfigMap
+	consumer *kafka.Consumer
+	paused   bool
+}
+
+func newKafkaDriver(config kafka.ConfigMap) *kafkaDriver {
+	return &kafkaDriver{config: config}
+}
+
+func (d *kafkaDriver) Connect(topics []string) error {
+	c, err := kafka.NewConsumer(&d.config)
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("Created Consumer %v", c)
+
+	if err := c.SubscribeTopics(topics, nil); err != nil {
+		_ = c.Close()
+		return err
+	}
+
+	d.consumer = c
+	return nil
+}
+
+func (d *kafkaDriver) Poll(timeout time.Duration) (*Message, error) {
+	// partitions may be reassigned while paused, so keep pausing the current assignment
+	if d.paused {
+		if err := d.setPaused(true); err != nil {
+			log.Error().Msgf("Failed to pause the assigned partitions: %s", err)
+		}
+	}
+
+	ev := d.consumer.Poll(int(timeout.Milliseconds()))
+
+	switch e := ev.(type) {
+	case *kafka.Message:
+		msg := &Message{
+			Payload:   e.Value,
+			Partition: e.TopicPartition.Partition,
+			Offset:    int64(e.TopicPartition.Offset),
+			raw:       e,
+		}
+		if e.TopicPartition.Topic != nil {
+			msg.Topic = *e.TopicPartition.Topic
+		}
+		return msg, nil
+	case kafka.Error:
+		// Errors should generally be considered
+		// informational, the client will try to
+		// automatically recover.
+		// But we choose to terminate the consumer
+		// if all brokers are down.
+		log.Error().Msgf("Error: %v: %v\n", e.Code(), e)
+		if e.Code() == kafka.ErrAllBrokersDown {
+			return nil, ErrBrokerDown
+		}
+	case nil:
+	default:
+		log.Debug().Msgf("Ignored %v\n", e)
+	}
+
+	return nil, nil
+}
+
+func (d *kafkaDriver) setPaused(paused bool) error {
+	partitions, err := d.consumer.Assignment()
+	if err != nil || len(partitions) == 0 {
+		return err
+	}
+
+	if paused {
+		return d.consumer.Pause(partitions)
+	}
+	return d.consumer.Resume(partitions)
+}
+
+func (d *kafkaDriver) Pause() error {
+	d.paused = true
+	return d.setPaused(true)
+}
+
+func (d *kafkaDriver) Resume() error {
+	d.paused = false
+	return d.setPaused(false)
+}
+
+// Ack commits the offset next to the last message of each partition
+func (d *kafkaDriver) Ack(msgs []*Message) {
+	offsets := map[string]kafka.TopicPartition{}
+	keys := []string{}
+
+	for _, msg := range msgs {
+		m, ok := msg.raw.(*kafka.Message)
+		if !ok || m.TopicPartition.Topic == nil {
+			continue
+		}
+
+		key := *m.TopicPartition.Topic + "/" + strconv.Itoa(int(m.TopicPartition.Partition))
+		tp, exists := offsets[key]
+		if !exists {
+			keys = append(keys, key)
+		} else if tp.Offset > m.TopicPartition.Offset {
+			continue
+		}
+
+		tp = m.TopicPartition
+		tp.Offset = m.TopicPartition.Offset + 1
+		offsets[key] = tp
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	tps := make([]kafka.TopicPartition, 0, len(keys))
+	for _, key := range keys {
+		tps = append(tps, offsets[key])
+	}
+
+	if _, err := d.consumer.CommitOffsets(tps); err != nil {
+		log.Error().Msgf("Failed to commit offsets %v: %s", tps, err)
+	}
+}
+
+func (d *kafkaDriver) Close() error {
+	if d.consumer == nil {
+		return nil
+	}
+	return d.consumer.Close()
+}
@@ -0,0 +1,157 @@
+package feedconsumer
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsFetchBatch is the number of messages pulled from a subject at once
+const natsFetchBatch = 100
+
+// natsAckWait is the time after which a message not acked is redelivered
+const natsAckWait = 30 * time.Second
+
+// =========================== //
+// == NATS JetStream Driver == //
+// =========================== //
+
+type natsDriver struct {
+	config        natsConfig
+	durable       string
+	messageOffset string
+	ackWait       time.Duration
+
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subs    []*nats.Subscription
+	next    int // round robin over the subscriptions
+	pending []*nats.Msg
+	paused  bool
+}
+
+type natsConfig struct {
+	URL     string
+	Options []nats.Option
+}
+
+func newNatsDriver(config natsConfig, durable, messageOffset string) *natsDriver {
+	return &natsDriver{
+		config: config,
+		// durable names cannot contain '.', '*' or '>'
+		durable:       strings.NewReplacer(".", "-", "*", "-", ">", "-").Replace(durable),
+		messageOffset: messageOffset,
+		ackWait:       natsAckWait,
+	}
+}
+
+func (d *natsDriver) Connect(topics []string) error {
+	nc, err := nats.Connect(d.config.URL, d.config.Options...)
+	if err != nil {
+		return err
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return err
+	}
+
+	deliver := nats.DeliverNew()
+	if d.messageOffset == MSG_OFFSET_EARLIEST {
+		deliver = nats.DeliverAll()
+	}
+
+	for i, topic := range topics {
+		// one durable pull consumer per subject
+		durable := d.durable
+		if len(topics) > 1 {
+			durable = durable + "-" + strconv.Itoa(i)
+		}
+
+		sub, err := js.PullSubscribe(topic, durable, deliver, nats.AckExplicit(), nats.AckWait(d.ackWait))
+		if err != nil {
+			nc.Close()
+			return err
+		}
+		d.subs = append(d.subs, sub)
+	}
+
+	d.conn = nc
+	d.js = js
+	return nil
+}
+
+func (d *natsDriver) Poll(timeout time.Duration) (*Message, error) {
+	if len(d.pending) == 0 {
+		if d.paused || len(d.subs) == 0 {
+			time.Sleep(timeout)
+			return nil, nil
+		}
+
+		sub := d.subs[d.next]
+		d.next = (d.next + 1) % len(d.subs)
+
+		msgs, err := sub.Fetch(natsFetchBatch, nats.MaxWait(timeout))
+		if err != nil && !errors.Is(err, nats.ErrTimeout) {
+			if d.conn.IsClosed() {
+				return nil, ErrBrokerDown
+			}
+			log.Error().Msgf("Failed to fetch messages from %s: %s", sub.Subject, err)
+			return nil, nil
+		}
+		d.pending = msgs
+	}
+
+	if len(d.pending) == 0 {
+		return nil, nil
+	}
+
+	m := d.pending[0]
+	d.pending = d.pending[1:]
+
+	msg := &Message{
+		Topic:   m.Subject,
+		Payload: m.Data,
+		raw:     m,
+	}
+	if meta, err := m.Metadata(); err == nil {
+		msg.Offset = int64(meta.Sequence.Stream)
+	}
+
+	return msg, nil
+}
+
+// Pause stops fetching, the messages already fetched are still returned
+func (d *natsDriver) Pause() error {
+	d.paused = true
+	return nil
+}
+
+func (d *natsDriver) Resume() error {
+	d.paused = false
+	return nil
+}
+
+// Ack acks the messages and waits for the server to confirm, the messages are committed once it returns
+func (d *natsDriver) Ack(msgs []*Message) {
+	for _, msg := range msgs {
+		m, ok := msg.raw.(*nats.Msg)
+		if !ok {
+			continue
+		}
+		if err := m.AckSync(); err != nil {
+			log.Error().Msgf("Failed to ack message %s/%d: %s", msg.Topic, msg.Offset, err)
+		}
+	}
+}
+
+func (d *natsDriver) Close() error {
+	if d.conn != nil {
+		d.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package feedconsumer
+
+import (
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// =================== //
+// == Pulsar Driver == //
+// =================== //
+
+type pulsarDriver struct {
+	config           pulsar.ClientOptions
+	subscriptionName string
+	messageOffset    string
+
+	client   pulsar.Client
+	sub      pulsar.Consumer
+	receiver chan pulsar.ConsumerMessage
+	paused   bool
+}
+
+func newPulsarDriver(config pulsar.ClientOptions, subscriptionName, messageOffset string) *pulsarDriver {
+	return &pulsarDriver{
+		config:           config,
+		subscriptionName: subscriptionName,
+		messageOffset:    messageOffset,
+		receiver:         make(chan pulsar.ConsumerMessage, 100),
+	}
+}
+
+func (d *pulsarDriver) Connect(topics []string) error {
+	c, err := pulsar.NewClient(d.config)
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("Created pulsar client %v", c)
+
+	subOffset := pulsar.SubscriptionPositionLatest
+	if d.messageOffset == MSG_OFFSET_EARLIEST {
+		subOffset = pulsar.SubscriptionPositionEarliest
+	}
+
+	sub, err := c.Subscribe(pulsar.ConsumerOptions{
+		Topics:                      topics,
+		SubscriptionName:            d.subscriptionName,
+		Type:                        pulsar.Shared,
+		SubscriptionInitialPosition: subOffset,
+		MessageChannel:              d.receiver,
+	})
+	if err != nil {
+		c.Close()
+		return err
+	}
+
+	d.client = c
+	d.sub = sub
+	return nil
+}
+
+// Poll stops receiving while paused, the pulsar client stops
+// the flow of messages once its receiver queue fills up
+func (d *pulsarDriver) Poll(timeout time.Duration) (*Message, error) {
+	if d.paused {
+		time.Sleep(timeout)
+		return nil, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case ev := <-d.receiver:
+		return &Message{
+			Topic:   ev.Topic(),
+			Payload: ev.Payload(),
+			raw:     ev.Message,
+		}, nil
+	case <-timer.C:
+		return nil, nil
+	}
+}
+
+func (d *pulsarDriver) Pause() error {
+	d.paused = true
+	return nil
+}
+
+func (d *pulsarDriver) Resume() error {
+	d.paused = false
+	return nil
+}
+
+// Ack acknowledges the messages individually
+func (d *pulsarDriver) Ack(msgs []*Message) {
+	for _, msg := range msgs {
+		m, ok := msg.raw.(pulsar.Message)
+		if !ok {
+			continue
+		}
+		if err := d.sub.Ack(m); err != nil {
+			log.Error().Msgf("Failed to ack message %v: %s", m.ID(), err)
+		}
+	}
+}
+
+func (d *pulsarDriver) Close() error {
+	if d.sub != nil {
+		d.sub.Close()
+	}
+	if d.client != nil {
+		d.client.Close()
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package feedconsumer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisReadCount is the number of entries read from the streams at once
+const redisReadCount = 100
+
+// redisClaimIdle is the idle time after which the entries pending on another consumer,
+// e.g., one that crashed before acking them, are claimed
+const redisClaimIdle = time.Minute
+
+// ========================== //
+// == Redis Streams Driver == //
+// ========================== //
+
+type redisDriver struct {
+	config        redisConfig
+	group         string
+	consumer      string
+	messageOffset string
+	claimIdle     time.Duration
+
+	client    *redis.Client
+	streams   []string
+	pending   []*Message
+	paused    bool
+	recovered bool              // the entries pending on this consumer were read again
+	recoverAt map[string]string // the last pending entry read again, by stream
+	lastClaim time.Time         // the entries idle on the other consumers were last claimed
+}
+
+type redisConfig struct {
+	Options      redis.Options
+	PayloadField string // field of the stream entry holding the event
+}
+
+func newRedisDriver(config redisConfig, group, messageOffset string) *redisDriver {
+	// the consumers of the group are the replicas, a restarted pod keeps its name
+	consumer, err := os.Hostname()
+	if err != nil || consumer == "" {
+		consumer = group
+	}
+
+	return &redisDriver{
+		config:        config,
+		group:         group,
+		consumer:      consumer,
+		messageOffset: messageOffset,
+		claimIdle:     redisClaimIdle,
+	}
+}
+
+func (d *redisDriver) Connect(topics []string) error {
+	client := redis.NewClient(&d.config.Options)
+	ctx := context.Background()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return err
+	}
+
+	start := "$"
+	if d.messageOffset == MSG_OFFSET_EARLIEST {
+		start = "0"
+	}
+
+	for _, stream := range topics {
+		err := client.XGroupCreateMkStream(ctx, stream, d.group, start).Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			_ = client.Close()
+			return err
+		}
+	}
+
+	d.client = client
+	d.streams = topics
+	d.recovered = false
+	d.recoverAt = map[string]string{}
+	d.lastClaim = time.Now()
+	return nil
+}
+
+func (d *redisDriver) Poll(timeout time.Duration) (*Message, error) {
+	if len(d.pending) == 0 {
+		if d.paused || len(d.streams) == 0 {
+			time.Sleep(timeout)
+			return nil, nil
+		}
+		if err := d.read(timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(d.pending) == 0 {
+		return nil, nil
+	}
+
+	msg := d.pending[0]
+	d.pending = d.pending[1:]
+	return msg, nil
+}
+
+// read returns the entries pending on this consumer first, e.g., after a crash, then the
+// entries idle on the other consumers of the group, then the new entries of the streams
+func (d *redisDriver) read(timeout time.Duration) error {
+	if !d.recovered {
+		ids := make([]string, 0, len(d.streams))
+		for _, stream := range d.streams {
+			id := d.recoverAt[stream]
+			if id == "" {
+				id = "0"
+			}
+			ids = append(ids, id)
+		}
+		// the pending entries are returned at once
+		if err := d.readGroup(ids, -1); err != nil || len(d.pending) > 0 {
+			return err
+		}
+	}
+
+	if time.Since(d.lastClaim) >= d.claimIdle {
+		d.lastClaim = time.Now()
+		if err := d.claim(); err != nil || len(d.pending) > 0 {
+			return err
+		}
+	}
+
+	ids := make([]string, 0, len(d.streams))
+	for range d.streams {
+		ids = append(ids, ">")
+	}
+	return d.readGroup(ids, timeout)
+}
+
+// readGroup reads the entries after the ids of the streams, ">" being the new entries
+func (d *redisDriver) readGroup(ids []string, timeout time.Duration) error {
+	streams := make([]string, 0, 2*len(d.streams))
+	streams = append(streams, d.streams...)
+	streams = append(streams, ids...)
+
+	res, err := d.client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    d.group,
+		Consumer: d.consumer,
+		Streams:  streams,
+		Count:    redisReadCount,
+		Block:    timeout,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		d.recovered = true
+		return nil
+	} else if errors.Is(err, redis.ErrClosed) {
+		return ErrBrokerDown
+	} else if err != nil {
+		log.Error().Msgf("Failed to read from streams %v: %s", d.streams, err)
+		return nil
+	}
+
+	read := 0
+	for _, stream := range res {
+		d.appendEntries(stream.Stream, stream.Messages)
+		read += len(stream.Messages)
+
+		if !d.recovered && len(stream.Messages) > 0 {
+			d.recoverAt[stream.Stream] = stream.Messages[len(stream.Messages)-1].ID
+		}
+	}
+	if read == 0 {
+		d.recovered = true
+	}
+
+	return nil
+}
+
+// claim moves the entries idle on the other consumers of the group to this consumer
+func (d *redisDriver) claim() error {
+	for _, stream := range d.streams {
+		entries, _, err := d.client.XAutoClaim(context.Background(), &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    d.group,
+			Consumer: d.consumer,
+			MinIdle:  d.claimIdle,
+			Start:    "0-0",
+			Count:    redisReadCount,
+		}).Result()
+		if errors.Is(err, redis.ErrClosed) {
+			return ErrBrokerDown
+		} else if err != nil {
+			log.Error().Msgf("Failed to claim the pending entries of stream %s: %s", stream, err)
+			continue
+		}
+
+		if len(entries) > 0 {
+			log.Info().Msgf("Claimed %d pending entries of stream %s", len(entries), stream)
+		}
+		d.appendEntries(stream, entries)
+	}
+
+	return nil
+}
+
+func (d *redisDriver) appendEntries(stream string, entries []redis.XMessage) {
+	for _, entry := range entries {
+		msg := &Message{
+			Topic: stream,
+			ID:    entry.ID,
+		}
+		if payload, ok := entry.Values[d.config.PayloadField].(string); ok {
+			msg.Payload = []byte(payload)
+		}
+		d.pending = append(d.pending, msg)
+	}
+}
+
+func (d *redisDriver) Pause() error {
+	d.paused = true
+	return nil
+}
+
+func (d *redisDriver) Resume() error {
+	d.paused = false
+	return nil
+}
+
+func (d *redisDriver) Ack(msgs []*Message) {
+	ids := map[string][]string{}
+	for _, msg := range msgs {
+		ids[msg.Topic] = append(ids[msg.Topic], msg.ID)
+	}
+
+	for stream, streamIDs := range ids {
+		if err := d.client.XAck(context.Background(), stream, d.group, streamIDs...).Err(); err != nil {
+			log.Error().Msgf("Failed to ack entries of stream %s: %s", stream, err)
+		}
+	}
+}
+
+func (d *redisDriver) Close() error {
+	if d.client == nil {
+		return nil
+	}
+	return d.client.Close()
+}
+
+func getTLSConfig(caCertPath string, authEnabled bool, certPath, keyPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	caCert, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return tlsConfig, err
+	}
+	tlsConfig.RootCAs = x509.NewCertPool()
+	if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+		return tlsConfig, errors.New("failed to parse the ca-cert " + caCertPath)
+	}
+
+	if authEnabled {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return tlsConfig, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
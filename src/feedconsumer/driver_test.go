@@ -0,0 +1,313 @@
+package feedconsumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/accuknox/auto-policy-discovery/src/plugin"
+)
+
+// memDriver is an in-process driver serving the queued messages
+type memDriver struct {
+	mutex  sync.Mutex
+	queue  []*Message
+	acked  []*Message
+	paused bool
+}
+
+func (d *memDriver) Connect(topics []string) error {
+	return nil
+}
+
+func (d *memDriver) Poll(timeout time.Duration) (*Message, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.paused || len(d.queue) == 0 {
+		return nil, nil
+	}
+	msg := d.queue[0]
+	d.queue = d.queue[1:]
+	return msg, nil
+}
+
+func (d *memDriver) Pause() error {
+	d.paused = true
+	return nil
+}
+
+func (d *memDriver) Resume() error {
+	d.paused = false
+	return nil
+}
+
+func (d *memDriver) Ack(msgs []*Message) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.acked = append(d.acked, msgs...)
+}
+
+func (d *memDriver) Close() error {
+	return nil
+}
+
+func (d *memDriver) ackedCount() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return len(d.acked)
+}
+
+func TestRunConsumerWithDriver(t *testing.T) {
+	resetHandOff()
+	defer resetHandOff()
+
+	driver := &memDriver{
+		queue: []*Message{
+			{Topic: "kubearmor", Payload: getTestSystemLogMessage(1)},
+			{Topic: "kubearmor", Payload: []byte("malformed")},
+			{Topic: "unknown", Payload: getTestSystemLogMessage(2)},
+			{Topic: "kubearmor", Payload: getTestSystemLogMessage(3)},
+		},
+	}
+
+	consumer := &KnoxFeedConsumer{
+		kubearmorTopic:  "kubearmor",
+		eventsBuffer:    10,
+		flushInterval:   time.Millisecond,
+		lastSyslogFlush: time.Now(),
+		deadLetterSink:  DEAD_LETTER_NONE,
+	}
+
+	stopChan = make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		consumer.run(driver)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return driver.ackedCount() == 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(stopChan)
+	<-done
+
+	plugin.KubeArmorFCLogsMutex.Lock()
+	defer plugin.KubeArmorFCLogsMutex.Unlock()
+	assert.Len(t, plugin.KubeArmorFCLogs, 2)
+}
+
+func TestRedisDriver(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	driver := newRedisDriver(redisConfig{
+		Options:      redis.Options{Addr: s.Addr()},
+		PayloadField: "data",
+	}, "knoxautopolicy", MSG_OFFSET_EARLIEST)
+	assert.NoError(t, driver.Connect([]string{"kubearmor"}))
+	defer driver.Close()
+
+	// the consumer group already exists on reconnect
+	assert.NoError(t, driver.Connect([]string{"kubearmor"}))
+
+	ctx := context.Background()
+	id, err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "kubearmor",
+		Values: map[string]interface{}{"data": string(getTestSystemLogMessage(1))},
+	}).Result()
+	assert.NoError(t, err)
+
+	msg, err := driver.Poll(100 * time.Millisecond)
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, "kubearmor", msg.Topic)
+		assert.Equal(t, id, msg.ID)
+		assert.Equal(t, getTestSystemLogMessage(1), msg.Payload)
+	}
+
+	pending, err := client.XPending(ctx, "kubearmor", "knoxautopolicy").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pending.Count)
+
+	driver.Ack([]*Message{msg})
+
+	pending, err = client.XPending(ctx, "kubearmor", "knoxautopolicy").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+
+	// paused drivers do not read
+	assert.NoError(t, driver.Pause())
+	client.XAdd(ctx, &redis.XAddArgs{Stream: "kubearmor", Values: map[string]interface{}{"data": "{}"}})
+	msg, err = driver.Poll(10 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestRedisDriverPending(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	config := redisConfig{Options: redis.Options{Addr: s.Addr()}, PayloadField: "data"}
+	ctx := context.Background()
+
+	// the consumer crashes before acking its entry
+	crashed := newRedisDriver(config, "knoxautopolicy", MSG_OFFSET_EARLIEST)
+	assert.NoError(t, crashed.Connect([]string{"kubearmor"}))
+	id, err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "kubearmor",
+		Values: map[string]interface{}{"data": string(getTestSystemLogMessage(1))},
+	}).Result()
+	assert.NoError(t, err)
+
+	msg, err := crashed.Poll(100 * time.Millisecond)
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, id, msg.ID)
+	}
+	crashed.Close()
+
+	// the restarted consumer reads its pending entry again, once
+	restarted := newRedisDriver(config, "knoxautopolicy", MSG_OFFSET_EARLIEST)
+	assert.NoError(t, restarted.Connect([]string{"kubearmor"}))
+	defer restarted.Close()
+
+	msg, err = restarted.Poll(100 * time.Millisecond)
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, id, msg.ID)
+		assert.Equal(t, getTestSystemLogMessage(1), msg.Payload)
+	}
+	msg, err = restarted.Poll(10 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	// another replica claims the entry once it is idle
+	other := newRedisDriver(config, "knoxautopolicy", MSG_OFFSET_EARLIEST)
+	other.consumer = "other-replica"
+	assert.NoError(t, other.Connect([]string{"kubearmor"}))
+	defer other.Close()
+
+	other.lastClaim = time.Time{}
+	s.SetTime(time.Now().Add(2 * redisClaimIdle))
+
+	msg, err = other.Poll(10 * time.Millisecond)
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, id, msg.ID)
+	}
+	other.Ack([]*Message{msg})
+
+	pending, err := client.XPending(ctx, "kubearmor", "knoxautopolicy").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+
+	// the group is stable, no group is left behind by the restarts
+	groups, err := client.XInfoGroups(ctx, "kubearmor").Result()
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+}
+
+func runNatsServer(t *testing.T) *natsserver.Server {
+	s, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats-server is not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func TestNatsDriver(t *testing.T) {
+	s := runNatsServer(t)
+
+	nc, err := nats.Connect(s.ClientURL())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "KUBEARMOR", Subjects: []string{"kubearmor"}})
+	assert.NoError(t, err)
+
+	newDriver := func() *natsDriver {
+		driver := newNatsDriver(natsConfig{URL: s.ClientURL()}, "knoxautopolicy.group", MSG_OFFSET_EARLIEST)
+		driver.ackWait = 500 * time.Millisecond
+		assert.NoError(t, driver.Connect([]string{"kubearmor"}))
+		return driver
+	}
+
+	_, err = js.Publish("kubearmor", getTestSystemLogMessage(1))
+	assert.NoError(t, err)
+
+	// the consumer crashes before acking the message
+	crashed := newDriver()
+	msg, err := crashed.Poll(time.Second)
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, "kubearmor", msg.Topic)
+		assert.Equal(t, int64(1), msg.Offset)
+		assert.Equal(t, getTestSystemLogMessage(1), msg.Payload)
+	}
+	crashed.Close()
+
+	// the message is redelivered to the durable consumer once the ack wait expires
+	driver := newDriver()
+	defer driver.Close()
+
+	assert.Eventually(t, func() bool {
+		msg, err = driver.Poll(100 * time.Millisecond)
+		return err == nil && msg != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	if !assert.NotNil(t, msg) {
+		return
+	}
+	assert.Equal(t, int64(1), msg.Offset)
+	if meta, err := msg.raw.(*nats.Msg).Metadata(); assert.NoError(t, err) {
+		assert.Equal(t, uint64(2), meta.NumDelivered)
+	}
+	driver.Ack([]*Message{msg})
+
+	// the ack is committed, the message is not delivered again
+	info, err := js.ConsumerInfo("KUBEARMOR", "knoxautopolicy-group")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, info.NumAckPending)
+		assert.Equal(t, uint64(1), info.AckFloor.Stream)
+	}
+
+	time.Sleep(time.Second)
+	msg, err = driver.Poll(100 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	// the next message is delivered after the committed one
+	_, err = js.Publish("kubearmor", getTestSystemLogMessage(2))
+	assert.NoError(t, err)
+	msg, err = driver.Poll(time.Second)
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, int64(2), msg.Offset)
+	}
+}
@@ -0,0 +1,326 @@
+package feedconsumer
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/plugin"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+	cilium "github.com/cilium/cilium/api/v1/flow"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// DefaultFlushInterval is used when the configured flush interval is missing or invalid
+const DefaultFlushInterval = 10 * time.Second
+
+// flushTick is how often idle consumers check the flush interval and the queue space
+const flushTick = 100 * time.Millisecond
+
+// ============= //
+// == Metrics == //
+// ============= //
+
+// ConsumerMetrics is a snapshot of the feed consumer buffers and hand-off queues
+type ConsumerMetrics struct {
+	NetworkLogsBuffered int64 // events read from the broker, not yet handed off
+	SystemLogsBuffered  int64
+	NetworkLogsQueued   int64 // logs handed off, waiting for the discovery
+	SystemLogsQueued    int64
+	QueueCapacity       int64 // 0 means unbounded
+
+	PausedConsumers    int32
+	NetworkLogsFlushed uint64
+	SystemLogsFlushed  uint64
+	BackpressurePauses uint64
+	RedeliveredEvents  uint64 // skipped, already handed off
+	DeadLetters        uint64 // messages which could not be parsed
+}
+
+var metrics struct {
+	networkLogsBuffered int64
+	systemLogsBuffered  int64
+	queueCapacity       int64
+	pausedConsumers     int32
+	networkLogsFlushed  uint64
+	systemLogsFlushed   uint64
+	backpressurePauses  uint64
+	redeliveredEvents   uint64
+	deadLetters         uint64
+}
+
+// GetMetrics returns the current buffer depths and flush counters
+func GetMetrics() ConsumerMetrics {
+	plugin.CiliumFlowsFCMutex.Lock()
+	networkLogsQueued := len(plugin.CiliumFlowsFC)
+	plugin.CiliumFlowsFCMutex.Unlock()
+
+	plugin.KubeArmorFCLogsMutex.Lock()
+	systemLogsQueued := len(plugin.KubeArmorFCLogs)
+	plugin.KubeArmorFCLogsMutex.Unlock()
+
+	return ConsumerMetrics{
+		NetworkLogsBuffered: atomic.LoadInt64(&metrics.networkLogsBuffered),
+		SystemLogsBuffered:  atomic.LoadInt64(&metrics.systemLogsBuffered),
+		NetworkLogsQueued:   int64(networkLogsQueued),
+		SystemLogsQueued:    int64(systemLogsQueued),
+		QueueCapacity:       atomic.LoadInt64(&metrics.queueCapacity),
+		PausedConsumers:     atomic.LoadInt32(&metrics.pausedConsumers),
+		NetworkLogsFlushed:  atomic.LoadUint64(&metrics.networkLogsFlushed),
+		SystemLogsFlushed:   atomic.LoadUint64(&metrics.systemLogsFlushed),
+		BackpressurePauses:  atomic.LoadUint64(&metrics.backpressurePauses),
+		RedeliveredEvents:   atomic.LoadUint64(&metrics.redeliveredEvents),
+		DeadLetters:         atomic.LoadUint64(&metrics.deadLetters),
+	}
+}
+
+// ================ //
+// == Conversion == //
+// ================ //
+
+func convertNetworkLogEvent(netLog types.NetworkLogEvent) (*types.KnoxNetworkLog, bool) {
+	time, _ := strconv.ParseInt(netLog.Time, 10, 64)
+	flow := &cilium.Flow{
+		TrafficDirection: cilium.TrafficDirection(plugin.TrafficDirection[netLog.TrafficDirection]),
+		PolicyMatchType:  uint32(netLog.PolicyMatchType),
+		DropReason:       uint32(netLog.DropReason),
+		DropReasonDesc:   cilium.DropReason(netLog.DropReason),
+		Verdict:          cilium.Verdict(plugin.Verdict[netLog.Verdict]),
+		Time: &timestamppb.Timestamp{
+			Seconds: time,
+		},
+		EventType:   &cilium.CiliumEventType{},
+		Source:      &cilium.Endpoint{},
+		Destination: &cilium.Endpoint{},
+		IP:          &cilium.IP{},
+		L4:          &cilium.Layer4{},
+		L7:          &cilium.Layer7{},
+		IsReply:     &wrapperspb.BoolValue{Value: netLog.Reply},
+	}
+
+	// _ = is to ignore the return value
+	_ = plugin.GetFlowData(netLog.EventType, flow.EventType)
+	_ = plugin.GetFlowData(netLog.Source, flow.Source)
+	_ = plugin.GetFlowData(netLog.Destination, flow.Destination)
+	_ = plugin.GetFlowData(netLog.IP, flow.IP)
+	_ = plugin.GetFlowData(netLog.L4, flow.L4)
+	_ = plugin.GetFlowData(netLog.L7, flow.L7)
+
+	knoxFlow, valid := plugin.ConvertCiliumFlowToKnoxNetworkLog(flow)
+	if !valid {
+		return nil, false
+	}
+	knoxFlow.ClusterName = netLog.ClusterName
+
+	return &knoxFlow, true
+}
+
+func convertSystemLogEvent(syslog types.SystemLogEvent) (*types.KnoxSystemLog, bool) {
+	log := pb.Alert{
+		ClusterName:   syslog.ClusterName,
+		HostName:      syslog.HostName,
+		NamespaceName: syslog.NamespaceName,
+		ContainerName: syslog.ContainerName,
+		PodName:       syslog.PodName,
+		Source:        syslog.Source,
+		Operation:     syslog.Operation,
+		Resource:      syslog.Resource,
+		Data:          syslog.Data,
+		Result:        syslog.Result,
+	}
+
+	knoxLog, err := plugin.ConvertKubeArmorLogToKnoxSystemLog(&log)
+	if err != nil {
+		return nil, false
+	}
+	knoxLog.ClusterName = syslog.Clustername
+
+	return &knoxLog, true
+}
+
+// ============== //
+// == Flushing == //
+// ============== //
+
+// queueSpace returns the number of logs that can still be handed off, -1 if unbounded
+func (cfc *KnoxFeedConsumer) queueSpace(queued int) int {
+	if cfc.maxQueueSize <= 0 {
+		return -1
+	}
+	if queued >= cfc.maxQueueSize {
+		return 0
+	}
+	return cfc.maxQueueSize - queued
+}
+
+func (cfc *KnoxFeedConsumer) isFlushDue(count int, lastFlush time.Time) bool {
+	if count == 0 {
+		return false
+	}
+	if count >= cfc.eventsBuffer {
+		return true
+	}
+	return cfc.flushInterval > 0 && time.Since(lastFlush) >= cfc.flushInterval
+}
+
+// flushNetworkLogs hands off the buffered network log events to the discovery,
+// as many as the queue can take. It returns false if some events are left behind.
+func (cfc *KnoxFeedConsumer) flushNetworkLogs() bool {
+	cfc.lastNetLogFlush = time.Now()
+	if len(cfc.netLogEvents) == 0 {
+		return true
+	}
+
+	plugin.CiliumFlowsFCMutex.Lock()
+	space := cfc.queueSpace(len(plugin.CiliumFlowsFC))
+	plugin.CiliumFlowsFCMutex.Unlock()
+	if space == 0 {
+		return false
+	}
+
+	knoxFlows := make([]*types.KnoxNetworkLog, len(cfc.netLogEvents))
+	for i, netLog := range cfc.netLogEvents {
+		knoxFlows[i], _ = convertNetworkLogEvent(netLog)
+	}
+
+	// re-check the space, other consumers may have handed off in the meantime
+	handedOff := 0
+	plugin.CiliumFlowsFCMutex.Lock()
+	space = cfc.queueSpace(len(plugin.CiliumFlowsFC))
+	for _, knoxFlow := range knoxFlows {
+		if knoxFlow != nil {
+			if space == 0 {
+				break
+			}
+			plugin.CiliumFlowsFC = append(plugin.CiliumFlowsFC, knoxFlow)
+			space--
+		}
+		handedOff++
+	}
+	plugin.CiliumFlowsFCMutex.Unlock()
+
+	markDone(cfc.netLogPending[:handedOff])
+	cfc.netLogPending = append([]*pendingMsg{}, cfc.netLogPending[handedOff:]...)
+
+	remaining := make([]types.NetworkLogEvent, 0, cfc.eventsBuffer)
+	remaining = append(remaining, cfc.netLogEvents[handedOff:]...)
+	cfc.netLogEvents = remaining
+	cfc.netLogEventsCount = len(cfc.netLogEvents)
+	cfc.ackDone()
+
+	atomic.AddInt64(&metrics.networkLogsBuffered, -int64(handedOff))
+	atomic.AddUint64(&metrics.networkLogsFlushed, uint64(handedOff))
+
+	return cfc.netLogEventsCount == 0
+}
+
+// flushSystemLogs hands off the buffered system log events to the discovery,
+// as many as the queue can take. It returns false if some events are left behind.
+func (cfc *KnoxFeedConsumer) flushSystemLogs() bool {
+	cfc.lastSyslogFlush = time.Now()
+	if len(cfc.syslogEvents) == 0 {
+		return true
+	}
+
+	plugin.KubeArmorFCLogsMutex.Lock()
+	space := cfc.queueSpace(len(plugin.KubeArmorFCLogs))
+	plugin.KubeArmorFCLogsMutex.Unlock()
+	if space == 0 {
+		return false
+	}
+
+	knoxLogs := make([]*types.KnoxSystemLog, len(cfc.syslogEvents))
+	for i, syslog := range cfc.syslogEvents {
+		knoxLogs[i], _ = convertSystemLogEvent(syslog)
+	}
+
+	// re-check the space, other consumers may have handed off in the meantime
+	handedOff := 0
+	plugin.KubeArmorFCLogsMutex.Lock()
+	space = cfc.queueSpace(len(plugin.KubeArmorFCLogs))
+	for _, knoxLog := range knoxLogs {
+		if knoxLog != nil {
+			if space == 0 {
+				break
+			}
+			plugin.KubeArmorFCLogs = append(plugin.KubeArmorFCLogs, knoxLog)
+			space--
+		}
+		handedOff++
+	}
+	plugin.KubeArmorFCLogsMutex.Unlock()
+
+	markDone(cfc.syslogPending[:handedOff])
+	cfc.syslogPending = append([]*pendingMsg{}, cfc.syslogPending[handedOff:]...)
+
+	remaining := make([]types.SystemLogEvent, 0, cfc.eventsBuffer)
+	remaining = append(remaining, cfc.syslogEvents[handedOff:]...)
+	cfc.syslogEvents = remaining
+	cfc.syslogEventsCount = len(cfc.syslogEvents)
+	cfc.ackDone()
+
+	atomic.AddInt64(&metrics.systemLogsBuffered, -int64(handedOff))
+	atomic.AddUint64(&metrics.systemLogsFlushed, uint64(handedOff))
+
+	return cfc.syslogEventsCount == 0
+}
+
+// flushIfDue flushes the buffers which are full or older than the flush interval
+func (cfc *KnoxFeedConsumer) flushIfDue() {
+	if cfc.isFlushDue(cfc.netLogEventsCount, cfc.lastNetLogFlush) {
+		cfc.flushNetworkLogs()
+	}
+	if cfc.isFlushDue(cfc.syslogEventsCount, cfc.lastSyslogFlush) {
+		cfc.flushSystemLogs()
+	}
+}
+
+// ================== //
+// == Backpressure == //
+// ================== //
+
+// isBackpressured checks if a buffer is full because the hand-off queue is full
+func (cfc *KnoxFeedConsumer) isBackpressured() bool {
+	return cfc.netLogEventsCount >= cfc.eventsBuffer && cfc.netLogEventsCount > 0 ||
+		cfc.syslogEventsCount >= cfc.eventsBuffer && cfc.syslogEventsCount > 0
+}
+
+// updatePauseState records the pause/resume transitions, it returns true if the state changed
+func (cfc *KnoxFeedConsumer) updatePauseState() bool {
+	backpressured := cfc.isBackpressured()
+	if backpressured == cfc.paused {
+		return false
+	}
+
+	cfc.paused = backpressured
+	if cfc.paused {
+		atomic.AddInt32(&metrics.pausedConsumers, 1)
+		atomic.AddUint64(&metrics.backpressurePauses, 1)
+		log.Warn().Msgf("Hand-off queue is full, pausing consumer %d (buffered network=%d system=%d)",
+			cfc.id, cfc.netLogEventsCount, cfc.syslogEventsCount)
+	} else {
+		atomic.AddInt32(&metrics.pausedConsumers, -1)
+		log.Info().Msgf("Resuming consumer %d", cfc.id)
+	}
+
+	return true
+}
+
+// applyBackpressure pauses the driver while the hand-off queue is full
+func (cfc *KnoxFeedConsumer) applyBackpressure(driver Driver) {
+	if !cfc.updatePauseState() {
+		return
+	}
+
+	var err error
+	if cfc.paused {
+		err = driver.Pause()
+	} else {
+		err = driver.Resume()
+	}
+	if err != nil {
+		log.Error().Msgf("Failed to pause/resume consumer %d: %s", cfc.id, err)
+	}
+}
@@ -0,0 +1,192 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	logger "github.com/accuknox/auto-policy-discovery/src/logging"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/rs/zerolog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+var log *zerolog.Logger
+
+func init() {
+	log = logger.GetInstance()
+}
+
+// ===================== //
+// == Leader Election == //
+// ===================== //
+
+// The replicas of the discovery engine campaign for a coordination/v1 Lease. The elected
+// replica runs the discovery workers, which write the policies, while every replica serves
+// the gRPC APIs. A replica losing the lease stops its workers and campaigns again.
+
+// ErrNoK8sClient is returned for the leader election without a k8s client
+var ErrNoK8sClient = errors.New("leader election requires a k8s client")
+
+// Workers are the jobs of the leader, started when it is elected and stopped when it loses the lease
+type Workers struct {
+	Start func()
+	Stop  func()
+}
+
+// Elector campaigns for the lease of the discovery workers
+type Elector struct {
+	client   kubernetes.Interface
+	cfg      types.ConfigLeaderElection
+	identity string
+	workers  Workers
+
+	// serializes the start and the stop of the workers
+	workersLock sync.Mutex
+
+	stateLock sync.RWMutex
+	leading   bool
+	leader    string
+}
+
+// NewElector returns the elector of the replica, identified by its hostname by default
+func NewElector(client kubernetes.Interface, cfg types.ConfigLeaderElection, workers Workers) (*Elector, error) {
+	if client == nil {
+		return nil, ErrNoK8sClient
+	}
+
+	identity := cfg.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		identity = hostname
+	}
+
+	e := &Elector{
+		client:   client,
+		cfg:      cfg,
+		identity: identity,
+		workers:  workers,
+	}
+
+	// check the lease durations before campaigning
+	if _, err := e.newLeaderElector(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Elector) newLeaderElector() (*leaderelection.LeaderElector, error) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      e.cfg.LeaseName,
+			Namespace: e.cfg.Namespace,
+		},
+		Client: e.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: e.identity,
+		},
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            e.cfg.LeaseName,
+		LeaseDuration:   time.Duration(e.cfg.LeaseDuration) * time.Second,
+		RenewDeadline:   time.Duration(e.cfg.RenewDeadline) * time.Second,
+		RetryPeriod:     time.Duration(e.cfg.RetryPeriod) * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: e.startWorkers,
+			OnStoppedLeading: e.stopWorkers,
+			OnNewLeader:      e.setLeader,
+		},
+	})
+}
+
+// Run campaigns for the lease until ctx is done, then the lease is released
+func (e *Elector) Run(ctx context.Context) error {
+	log.Info().Msgf("%s campaigns for the lease %s/%s", e.identity, e.cfg.Namespace, e.cfg.LeaseName)
+
+	for {
+		elector, err := e.newLeaderElector()
+		if err != nil {
+			return err
+		}
+
+		// returns when the lease is lost, or else ctx is done
+		elector.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			log.Info().Msgf("%s lost the lease %s/%s, campaigning again", e.identity, e.cfg.Namespace, e.cfg.LeaseName)
+		}
+	}
+}
+
+// IsLeader tells if the workers run on this replica
+func (e *Elector) IsLeader() bool {
+	e.stateLock.RLock()
+	defer e.stateLock.RUnlock()
+
+	return e.leading
+}
+
+// Leader returns the identity of the last observed leader
+func (e *Elector) Leader() string {
+	e.stateLock.RLock()
+	defer e.stateLock.RUnlock()
+
+	return e.leader
+}
+
+func (e *Elector) setLeader(identity string) {
+	e.stateLock.Lock()
+	e.leader = identity
+	e.stateLock.Unlock()
+
+	log.Info().Msgf("%s is the leader of %s/%s", identity, e.cfg.Namespace, e.cfg.LeaseName)
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.stateLock.Lock()
+	defer e.stateLock.Unlock()
+
+	e.leading = leading
+}
+
+func (e *Elector) startWorkers(ctx context.Context) {
+	e.workersLock.Lock()
+	defer e.workersLock.Unlock()
+
+	// the lease can be lost before the workers are started
+	if ctx.Err() != nil {
+		return
+	}
+
+	log.Info().Msgf("%s is elected, starting the discovery workers", e.identity)
+	e.setLeading(true)
+	e.workers.Start()
+}
+
+func (e *Elector) stopWorkers() {
+	e.workersLock.Lock()
+	defer e.workersLock.Unlock()
+
+	// also called when a follower stops campaigning
+	if !e.IsLeader() {
+		return
+	}
+
+	log.Info().Msgf("%s is no longer the leader, stopping the discovery workers", e.identity)
+	e.workers.Stop()
+	e.setLeading(false)
+}
@@ -0,0 +1,94 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type testWorkers struct {
+	started atomic.Int32
+	stopped atomic.Int32
+}
+
+func (w *testWorkers) workers() Workers {
+	return Workers{
+		Start: func() { w.started.Add(1) },
+		Stop:  func() { w.stopped.Add(1) },
+	}
+}
+
+func newTestElector(t *testing.T, client *fake.Clientset, identity string, w *testWorkers) *Elector {
+	cfg := types.ConfigLeaderElection{
+		Enable:        true,
+		LeaseName:     "discovery-engine",
+		Namespace:     "accuknox-agents",
+		Identity:      identity,
+		LeaseDuration: 3,
+		RenewDeadline: 2,
+		RetryPeriod:   1,
+	}
+
+	e, err := NewElector(client, cfg, w.workers())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return e
+}
+
+func TestNewElector(t *testing.T) {
+	_, err := NewElector(nil, types.ConfigLeaderElection{}, Workers{})
+	assert.ErrorIs(t, err, ErrNoK8sClient)
+
+	// the lease is renewed before it expires
+	_, err = NewElector(fake.NewSimpleClientset(), types.ConfigLeaderElection{
+		LeaseName: "discovery-engine", Namespace: "accuknox-agents", Identity: "replica-1",
+		LeaseDuration: 2, RenewDeadline: 3, RetryPeriod: 1,
+	}, Workers{})
+	assert.Error(t, err)
+}
+
+func TestElectorFailover(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	// the first replica is elected
+	w1 := &testWorkers{}
+	e1 := newTestElector(t, client, "replica-1", w1)
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	done1 := make(chan error)
+	go func() { done1 <- e1.Run(ctx1) }()
+
+	assert.Eventually(t, e1.IsLeader, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, int32(1), w1.started.Load())
+
+	// the second replica only follows while the lease is renewed
+	w2 := &testWorkers{}
+	e2 := newTestElector(t, client, "replica-2", w2)
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done2 := make(chan error)
+	go func() { done2 <- e2.Run(ctx2) }()
+
+	assert.Eventually(t, func() bool { return e2.Leader() == "replica-1" }, 10*time.Second, 100*time.Millisecond)
+	time.Sleep(4 * time.Second)
+	assert.False(t, e2.IsLeader())
+	assert.Zero(t, w2.started.Load())
+
+	// the lease is released on shutdown, the workers fail over to the second replica
+	cancel1()
+	assert.NoError(t, <-done1)
+	assert.False(t, e1.IsLeader())
+	assert.Equal(t, int32(1), w1.stopped.Load())
+
+	assert.Eventually(t, e2.IsLeader, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, int32(1), w2.started.Load())
+	assert.Equal(t, "replica-2", e2.Leader())
+
+	cancel2()
+	assert.NoError(t, <-done2)
+	assert.Equal(t, int32(1), w2.stopped.Load())
+}
@@ -0,0 +1,149 @@
+package libs
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"google.golang.org/grpc"
+
+	dpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/discovery"
+)
+
+var anomalySeverityLevel = map[string]int{
+	types.AnomalySeverityLow:      1,
+	types.AnomalySeverityMedium:   2,
+	types.AnomalySeverityHigh:     3,
+	types.AnomalySeverityCritical: 4,
+}
+
+// AnomalyConsumer stores filter information provided in v1.Discovery.GetAnomaly RPC request
+type AnomalyConsumer struct {
+	Filter types.AnomalyFilter
+	Events chan *types.SystemAnomaly
+}
+
+func NewAnomalyConsumer(req *dpb.GetAnomalyRequest) *AnomalyConsumer {
+	return &AnomalyConsumer{
+		Filter: types.AnomalyFilter{
+			Cluster:   req.GetCluster(),
+			Namespace: req.GetNamespace(),
+			Labels:    LabelMapFromLabelArray(req.GetLabel()),
+			Severity:  strings.ToLower(req.GetSeverity()),
+		},
+		Events: make(chan *types.SystemAnomaly, 64),
+	}
+}
+
+// AnomalyStore is used for support v1.Discovery.GetAnomaly RPC requests
+type AnomalyStore struct {
+	Consumers map[*AnomalyConsumer]struct{}
+	Mutex     sync.Mutex
+}
+
+// AddConsumer adds a new AnomalyConsumer to the store
+func (as *AnomalyStore) AddConsumer(c *AnomalyConsumer) {
+	as.Mutex.Lock()
+	defer as.Mutex.Unlock()
+
+	as.Consumers[c] = struct{}{}
+}
+
+// RemoveConsumer removes an AnomalyConsumer from the store
+func (as *AnomalyStore) RemoveConsumer(c *AnomalyConsumer) {
+	as.Mutex.Lock()
+	defer as.Mutex.Unlock()
+
+	delete(as.Consumers, c)
+}
+
+// Publish pushes the anomaly to the matching consumer's channels
+func (as *AnomalyStore) Publish(anomaly *types.SystemAnomaly) {
+	as.Mutex.Lock()
+	defer as.Mutex.Unlock()
+
+	for consumer := range as.Consumers {
+		if MatchAnomaly(anomaly, consumer.Filter) {
+			log.Info().Msgf("Publishing %s anomaly %s", anomaly.Severity, anomaly.Resource)
+			consumer.Events <- anomaly
+		}
+	}
+}
+
+func FilterAnomalies(anomalies []types.SystemAnomaly, filter types.AnomalyFilter) []types.SystemAnomaly {
+	result := []types.SystemAnomaly{}
+
+	for i := range anomalies {
+		if MatchAnomaly(&anomalies[i], filter) {
+			result = append(result, anomalies[i])
+		}
+	}
+
+	return result
+}
+
+func MatchAnomaly(a *types.SystemAnomaly, filter types.AnomalyFilter) bool {
+	if filter.Cluster != "" && filter.Cluster != a.ClusterName {
+		return false
+	}
+
+	if filter.Namespace != "" && filter.Namespace != a.Namespace {
+		return false
+	}
+
+	if len(filter.Labels) != 0 &&
+		!IsLabelMapSubset(LabelMapFromLabelArray(strings.Split(a.Labels, ",")), filter.Labels) {
+		return false
+	}
+
+	if filter.Severity != "" && anomalySeverityLevel[a.Severity] < anomalySeverityLevel[filter.Severity] {
+		return false
+	}
+
+	return true
+}
+
+func convertAnomalyToGrpcResponse(a *types.SystemAnomaly) *dpb.GetAnomalyResponse {
+	return &dpb.GetAnomalyResponse{
+		Cluster:       a.ClusterName,
+		Namespace:     a.Namespace,
+		ContainerName: a.ContainerName,
+		Label:         strings.Split(a.Labels, ","),
+		FromSource:    a.FromSource,
+		Operation:     a.Operation,
+		Resource:      a.Resource,
+		Severity:      a.Severity,
+		Count:         a.Count,
+		FirstSeen:     a.FirstSeen,
+		LastSeen:      a.LastSeen,
+	}
+}
+
+func SendAnomalyInGrpcStream(stream grpc.ServerStream, anomaly *types.SystemAnomaly) error {
+	resp := convertAnomalyToGrpcResponse(anomaly)
+	err := stream.SendMsg(resp)
+	if err != nil {
+		log.Error().Msgf("sending anomaly in grpc stream failed err=%v", err.Error())
+		return err
+	}
+	return nil
+}
+
+func RelayAnomalyEventToGrpcStream(stream grpc.ServerStream, consumer *AnomalyConsumer) error {
+	for {
+		select {
+		case <-stream.Context().Done():
+			// client disconnected
+			return nil
+		case anomaly, ok := <-consumer.Events:
+			if !ok {
+				// channel closed and all items are consumed
+				return nil
+			}
+			err := SendAnomalyInGrpcStream(stream, anomaly)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
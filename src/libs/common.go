@@ -103,6 +103,8 @@ func SetDefaultConfig() {
 	viper.SetDefault("application.network.network-policy-to", "db|file")
 	viper.SetDefault("application.network.network-policy-dir", "./")
 	viper.SetDefault("application.network.skip-cert-verification", true)
+	viper.SetDefault("application.network.policy-gap-max-age", 86400)
+	viper.SetDefault("application.network.policy-gap-max-count", 10000)
 
 	// Application->System config
 	viper.SetDefault("application.system.operation-mode", 1)
@@ -125,6 +127,16 @@ func SetDefaultConfig() {
 	viper.SetDefault("database.host", "127.0.0.1")
 	viper.SetDefault("database.port", "3306")
 	viper.SetDefault("database.sqlite-db-path", "./accuknox.db")
+	viper.SetDefault("database.max-open-conns", 20)
+	viper.SetDefault("database.max-idle-conns", 5)
+	viper.SetDefault("database.conn-max-lifetime", 300)
+	viper.SetDefault("database.log-store", LogStoreSQL)
+	viper.SetDefault("database.log-store-path", "./logs")
+	viper.SetDefault("database.log-segment-duration", "1h")
+	viper.SetDefault("database.encryption.enable", false)
+	viper.SetDefault("database.encryption.key-dir", "")
+	viper.SetDefault("database.encryption.secret", "")
+	viper.SetDefault("database.encryption.active-key", "")
 	viper.SetDefault("database.table-network-policy", "network_policy")
 	viper.SetDefault("database.table-system-policy", "system_policy")
 
@@ -142,12 +154,37 @@ func SetDefaultConfig() {
 	// feed-consumer config
 	viper.SetDefault("feed-consumer.number-of-consumers", "1")
 	viper.SetDefault("feed-consumer.event-buffer-size", "50")
+	viper.SetDefault("feed-consumer.flush-interval", "0h0m10s")
+	viper.SetDefault("feed-consumer.max-queue-size", "100000")
+	viper.SetDefault("feed-consumer.dedup-window-size", "100000")
+	viper.SetDefault("feed-consumer.dead-letter.sink", "table")
 	viper.SetDefault("feed-consumer.consumer-group", "knoxautopolicy")
 	viper.SetDefault("feed-consumer.message-offset", "latest")
 	viper.SetDefault("feed-consumer.kafka.server-address-family", "v4")
 	viper.SetDefault("feed-consumer.kafka.session-timeout", "6000")
 	viper.SetDefault("feed-consumer.pulsar.connection-timeout", "10")
 	viper.SetDefault("feed-consumer.pulsar.operation-timeout", "30")
+	viper.SetDefault("feed-consumer.redis.db", "0")
+	viper.SetDefault("feed-consumer.redis.payload-field", "data")
+
+	// purge old db entries config, a zero max-age or max-rows keeps the rows
+	viper.SetDefault("purge-old-db-entries.retention.network-logs.max-age", "168h0m00s")
+	viper.SetDefault("purge-old-db-entries.retention.system-logs.max-age", "168h0m00s")
+	viper.SetDefault("purge-old-db-entries.retention.workload-process-fileset.max-age", "0s")
+	viper.SetDefault("purge-old-db-entries.retention.system-summary.max-age", "24h0m00s")
+	viper.SetDefault("purge-old-db-entries.retention.system-summary-rollup.max-age", "720h0m00s")
+	viper.SetDefault("purge-old-db-entries.downsampling.enable", false)
+	viper.SetDefault("purge-old-db-entries.downsampling.hourly-after", "1h0m00s")
+	viper.SetDefault("purge-old-db-entries.downsampling.daily-after", "24h0m00s")
+
+	// leader election config
+	viper.SetDefault("leader-election.enable", false)
+	viper.SetDefault("leader-election.lease-name", "discovery-engine")
+	viper.SetDefault("leader-election.namespace", "accuknox-agents")
+	viper.SetDefault("leader-election.identity", "")
+	viper.SetDefault("leader-election.lease-duration", "15s")
+	viper.SetDefault("leader-election.renew-deadline", "10s")
+	viper.SetDefault("leader-election.retry-period", "2s")
 
 	// recommend config
 	viper.SetDefault("recommend.cron-job-time-interval", "1h0m00s")
@@ -216,6 +253,18 @@ func pprofInit() {
 	}()
 }
 
+// MigrateOnly is set by -migrate, to run the db migrations and exit
+var MigrateOnly bool
+
+// ExportPath is set by -export, to export the discovery state to the archive and exit
+var ExportPath string
+
+// ImportPath is set by -import, to import the discovery state from the archive and exit
+var ImportPath string
+
+// ImportReplace is set by -import-replace, to clear the policies before the import
+var ImportReplace bool
+
 /* configuration file values are final values */
 func CheckCommandLineConfig() {
 	var cmdlineCfg cfgArray
@@ -223,6 +272,10 @@ func CheckCommandLineConfig() {
 	pprofFlag := flag.Bool("pprof", false, "enable pprof")
 	version1 := flag.Bool("ver", false, "print version and exit")
 	version2 := flag.Bool("version", false, "print version and exit")
+	flag.BoolVar(&MigrateOnly, "migrate", false, "run the db migrations and exit")
+	flag.StringVar(&ExportPath, "export", "", "export the discovery state to the archive file and exit")
+	flag.StringVar(&ImportPath, "import", "", "import the discovery state from the archive file and exit")
+	flag.BoolVar(&ImportReplace, "import-replace", false, "clear the policies and the file sets before the import")
 	flag.Var(&cmdlineCfg, "cfg", "Configuration key=val")
 
 	configFilePath := flag.String("config-path", "conf/", "conf/")
@@ -736,6 +789,13 @@ func HashSystemSummary(summary *types.SystemSummary) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// HashSelector hashes the matchLabels of a selector, whatever the order of the labels
+func HashSelector(matchLabels map[string]string) string {
+	h := sha256.New()
+	h.Write([]byte(LabelMapToString(matchLabels)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Removes the label associated to the key specified and returns the final label
 func RemoveFieldFromLabel(srcLabel, keyLabel string) string {
 	labels := strings.Split(srcLabel, ",")
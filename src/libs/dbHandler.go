@@ -2,7 +2,6 @@ package libs
 
 import (
 	"database/sql"
-	"errors"
 	"sort"
 	"strings"
 
@@ -26,70 +25,40 @@ var LastFlowID int64 = 0
 func GetNetworkPolicies(cfg types.ConfigDB, cluster, namespace, status, nwtype, rule string) []types.KnoxNetworkPolicy {
 	results := []types.KnoxNetworkPolicy{}
 
-	if cfg.DBDriver == "mysql" {
-		docs, err := GetNetworkPoliciesFromMySQL(cfg, cluster, namespace, status, nwtype, rule)
-		if err != nil {
-			return results
-		}
-		results = docs
-	} else if cfg.DBDriver == "sqlite3" {
-		docs, err := GetNetworkPoliciesFromSQLite(cfg, cluster, namespace, status)
-		if err != nil {
-			return results
-		}
-		results = docs
+	filter := types.NetworkPolicyFilter{Cluster: cluster, Namespace: namespace, Status: status, Type: nwtype, Rule: rule}
+	docs, _, err := GetNetworkPoliciesPage(cfg, filter, types.PageRequest{})
+	if err != nil {
+		return results
 	}
 
-	return results
+	return docs
 }
 
-func GetNetworkPoliciesBySelector(cfg types.ConfigDB, cluster, namespace, status string, selector map[string]string) ([]types.KnoxNetworkPolicy, error) {
-	results := []types.KnoxNetworkPolicy{}
-
-	if cfg.DBDriver == "mysql" {
-		docs, err := GetNetworkPoliciesFromMySQL(cfg, cluster, namespace, status, "", "")
-		if err != nil {
-			return nil, err
-		}
-		results = docs
-	} else if cfg.DBDriver == "sqlite3" {
-		docs, err := GetNetworkPoliciesFromSQLite(cfg, cluster, namespace, status)
-		if err != nil {
-			return nil, err
-		}
-		results = docs
-	} else {
-		return results, nil
-	}
-
-	filtered := []types.KnoxNetworkPolicy{}
-	for _, policy := range results {
-		matched := true
-		for k, v := range selector {
-			val := policy.Spec.Selector.MatchLabels[k]
-			if val != v {
-				matched = false
-				break
-			}
-		}
-
-		if matched {
-			filtered = append(filtered, policy)
-		}
+// GetNetworkPoliciesPage returns a page of the network policies of the filter and the token of the next page
+func GetNetworkPoliciesPage(cfg types.ConfigDB, filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []types.KnoxNetworkPolicy{}, "", err
 	}
 
-	return filtered, nil
+	return store.GetNetworkPolicies(filter, page)
+}
+
+// GetNetworkPoliciesBySelector returns a page of the network policies of exactly the selector,
+// looked up by the hash of the selector
+func GetNetworkPoliciesBySelector(cfg types.ConfigDB, cluster, namespace, status string, selector map[string]string, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
+	filter := types.NetworkPolicyFilter{Cluster: cluster, Namespace: namespace, Status: status, Selector: selector}
+	return GetNetworkPoliciesPage(cfg, filter, page)
 }
 
 func UpdateOutdatedNetworkPolicy(cfg types.ConfigDB, outdatedPolicy string, latestPolicy string) {
-	if cfg.DBDriver == "mysql" {
-		if err := UpdateOutdatedNetworkPolicyFromMySQL(cfg, outdatedPolicy, latestPolicy); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := UpdateOutdatedNetworkPolicyFromSQLite(cfg, outdatedPolicy, latestPolicy); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
+	}
+
+	if err := store.UpdateOutdatedNetworkPolicy(outdatedPolicy, latestPolicy); err != nil {
+		log.Error().Msg(err.Error())
 	}
 }
 
@@ -100,28 +69,25 @@ func UpdateNetworkPolicies(cfg types.ConfigDB, policies []types.KnoxNetworkPolic
 }
 
 func UpdateNetworkPolicy(cfg types.ConfigDB, policy types.KnoxNetworkPolicy) {
-	if cfg.DBDriver == "mysql" {
-		if err := UpdateNetworkPolicyToMySQL(cfg, policy); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := UpdateNetworkPolicyToSQLite(cfg, policy); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
+	}
+
+	if err := store.UpdateNetworkPolicy(policy); err != nil {
+		log.Error().Msg(err.Error())
 	}
 }
 
 func InsertNetworkPolicies(cfg types.ConfigDB, policies []types.KnoxNetworkPolicy) {
-	if cfg.DBDriver == "mysql" {
-		if err := InsertNetworkPoliciesToMySQL(cfg, policies); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := InsertNetworkPoliciesToSQLite(cfg, policies); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
 	}
 
+	if err := store.InsertNetworkPolicies(policies); err != nil {
+		log.Error().Msg(err.Error())
+	}
 }
 
 // ================ //
@@ -143,95 +109,150 @@ var LastSysAlertID int64 = 0
 // =================== //
 
 func UpdateOutdatedSystemPolicy(cfg types.ConfigDB, outdatedPolicy string, latestPolicy string) {
-	if cfg.DBDriver == "mysql" {
-		if err := UpdateOutdatedSystemPolicyFromMySQL(cfg, outdatedPolicy, latestPolicy); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := UpdateOutdatedSystemPolicyFromSQLite(cfg, outdatedPolicy, latestPolicy); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
+	}
+
+	if err := store.UpdateOutdatedSystemPolicy(outdatedPolicy, latestPolicy); err != nil {
+		log.Error().Msg(err.Error())
 	}
 }
 
 func GetSystemPolicies(cfg types.ConfigDB, namespace, status string) []types.KnoxSystemPolicy {
 	results := []types.KnoxSystemPolicy{}
 
-	if cfg.DBDriver == "mysql" {
-		docs, err := GetSystemPoliciesFromMySQL(cfg, namespace, status)
-		if err != nil {
-			return results
-		}
-		results = docs
-	} else if cfg.DBDriver == "sqlite3" {
-		docs, err := GetSystemPoliciesFromSQLite(cfg, namespace, status)
-		if err != nil {
-			return results
-		}
-		results = docs
+	docs, _, err := GetSystemPoliciesPage(cfg, namespace, status, types.PageRequest{})
+	if err != nil {
+		return results
 	}
 
-	return results
+	return docs
+}
+
+// GetSystemPoliciesPage returns a page of the system policies and the token of the next page
+func GetSystemPoliciesPage(cfg types.ConfigDB, namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []types.KnoxSystemPolicy{}, "", err
+	}
+
+	return store.GetSystemPolicies(namespace, status, page)
 }
 
 func InsertSystemPolicies(cfg types.ConfigDB, policies []types.KnoxSystemPolicy) {
-	if cfg.DBDriver == "mysql" {
-		if err := InsertSystemPoliciesToMySQL(cfg, policies); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := InsertSystemPoliciesToSQLite(cfg, policies); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
+	}
+
+	if err := store.InsertSystemPolicies(policies); err != nil {
+		log.Error().Msg(err.Error())
 	}
 }
 
 func UpdateSystemPolicy(cfg types.ConfigDB, policy types.KnoxSystemPolicy) {
-	if cfg.DBDriver == "mysql" {
-		if err := UpdateSystemPolicyToMySQL(cfg, policy); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := UpdateSystemPolicyToSQLite(cfg, policy); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
 	}
 
+	if err := store.UpdateSystemPolicy(policy); err != nil {
+		log.Error().Msg(err.Error())
+	}
 }
 
 func GetWorkloadProcessFileSet(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error) {
-	if cfg.DBDriver == "mysql" {
-		res, pnMap, err := GetWorkloadProcessFileSetMySQL(cfg, wpfs)
-		if err != nil {
-			log.Error().Msg(err.Error())
-		}
-		return res, pnMap, err
-	} else if cfg.DBDriver == "sqlite3" {
-		res, pnMap, err := GetWorkloadProcessFileSetSQLite(cfg, wpfs)
-		if err != nil {
-			log.Error().Msg(err.Error())
-		}
-		return res, pnMap, err
+	store, err := GetStore(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, pnMap, err := store.GetWorkloadProcessFileSet(wpfs)
+	if err != nil {
+		log.Error().Msg(err.Error())
 	}
-	return nil, nil, errors.New("no db driver")
+	return res, pnMap, err
 }
 
 func InsertWorkloadProcessFileSet(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, fs []string) error {
-	if cfg.DBDriver == "mysql" {
-		return InsertWorkloadProcessFileSetMySQL(cfg, wpfs, fs)
-	} else if cfg.DBDriver == "sqlite3" {
-		return InsertWorkloadProcessFileSetSQLite(cfg, wpfs, fs)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
 	}
-	return errors.New("no db driver")
+	return store.InsertWorkloadProcessFileSet(wpfs, "", fs)
+}
+
+func UpdateWorkloadProcessFileSet(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, fs []string) error {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
+	}
+	return store.UpdateWorkloadProcessFileSet(wpfs, fs)
 }
 
 func ClearWPFSDb(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, duration int64) error {
-	if cfg.DBDriver == "mysql" {
-		return ClearWPFSDbMySQL(cfg, wpfs, duration)
-	} else if cfg.DBDriver == "sqlite3" {
-		return ClearWPFSDbSQLite(cfg, wpfs, duration)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
+	}
+	return store.ClearWPFS(wpfs, duration)
+}
+
+func GetWorkloadProcessFileSetCreatedTime(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet) (int64, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return 0, err
+	}
+	return store.GetWorkloadProcessFileSetCreatedTime(wpfs)
+}
+
+// ==================== //
+// == System Anomaly == //
+// ==================== //
+
+func UpsertSystemAnomaly(cfg types.ConfigDB, anomaly types.SystemAnomaly) (bool, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return false, err
+	}
+	return store.UpsertSystemAnomaly(anomaly)
+}
+
+func GetSystemAnomalies(cfg types.ConfigDB, filter types.AnomalyFilter) ([]types.SystemAnomaly, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetSystemAnomalies(filter)
+}
+
+// ================= //
+// == Dead Letter == //
+// ================= //
+
+func InsertDeadLetter(cfg types.ConfigDB, deadLetter types.DeadLetter) error {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
+	}
+	return store.InsertDeadLetter(deadLetter)
+}
+
+func GetDeadLetters(cfg types.ConfigDB, filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetDeadLetters(filter)
+}
+
+func DeleteDeadLetters(cfg types.ConfigDB, ids []int64) error {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
 	}
-	return errors.New("no db driver")
+	return store.DeleteDeadLetters(ids)
 }
 
 // =========== //
@@ -239,206 +260,383 @@ func ClearWPFSDb(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, duration
 // =========== //
 
 func ClearDBTables(cfg types.ConfigDB) {
-	if cfg.DBDriver == "mysql" {
-		if err := ClearDBTablesMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := ClearDBTablesSQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
+	}
+
+	if err := store.ClearDBTables(); err != nil {
+		log.Error().Msg(err.Error())
 	}
 }
 
 func ClearNetworkDBTable(cfg types.ConfigDB) {
-	if cfg.DBDriver == "mysql" {
-		if err := ClearNetworkDBTableMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return
+	}
+
+	if err := store.ClearNetworkDBTable(); err != nil {
+		log.Error().Msg(err.Error())
 	}
 }
 
-func CreateTablesIfNotExist(cfg types.ConfigDB) {
-	if cfg.DBDriver == "mysql" {
-		if err := CreateTableNetworkPolicyMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableSystemPolicyMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableWorkLoadProcessFileSetMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableSystemLogsMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableNetworkLogsMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreatePolicyTableMySQL(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		if err := CreateTableNetworkPolicySQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableSystemPolicySQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableWorkLoadProcessFileSetSQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableSystemLogsSQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateTableNetworkLogsSQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreatePolicyTableSQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
-		if err := CreateSystemSummaryTableSQLite(cfg); err != nil {
-			log.Error().Msg(err.Error())
-		}
+// MigrateDB creates the tables or brings them up to date,
+// it fails with ErrSchemaTooNew for a database migrated by a newer discovery engine
+func MigrateDB(cfg types.ConfigDB) error {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
 	}
+
+	return store.Migrate()
 }
 
 // =================== //
 // == Observability == //
 // =================== //
 func UpdateOrInsertKubearmorLogs(cfg types.ConfigDB, kubearmorLogMap map[types.KubeArmorLog]int) error {
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		err = UpdateOrInsertKubearmorLogsMySQL(cfg, kubearmorLogMap)
-	} else if cfg.DBDriver == "sqlite3" {
-		err = UpdateOrInsertKubearmorLogsSQLite(cfg, kubearmorLogMap)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
+	}
+	return store.UpdateOrInsertKubearmorLogs(kubearmorLogMap)
+}
+
+// updateOrInsertKubearmorLogsSQL adds the counts of the logs to the existing ones or inserts the new ones,
+// the statements are prepared once for the batch
+func updateOrInsertKubearmorLogsSQL(db sqlDB, tableName string, kubearmorLogMap map[types.KubeArmorLog]int) error {
+	queryString := `cluster_name = ? and namespace_name = ? and pod_name = ? and container_name = ? and operation = ? and labels = ? 
+					and data = ? and category = ? and action = ? and result = ? and source = ? and resource = ?`
+
+	updateStmt, err := db.Prepare("UPDATE " + tableName + " SET total=total+?, updated_time=? WHERE " + queryString)
+	if err != nil {
+		return err
+	}
+	defer updateStmt.Close()
+
+	insertQueryString := `(cluster_name,namespace_name,pod_name,container_name,operation,labels,data,category,action,
+		updated_time,result,total,source,resource) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+
+	insertStmt, err := db.Prepare("INSERT INTO " + tableName + insertQueryString)
+	if err != nil {
+		return err
+	}
+	defer insertStmt.Close()
+
+	for kubearmorlog, count := range kubearmorLogMap {
+		updatedTime := ConvertStrToUnixTime("now")
+
+		result, err := updateStmt.Exec(
+			count,
+			updatedTime,
+			kubearmorlog.ClusterName,
+			kubearmorlog.NamespaceName,
+			kubearmorlog.PodName,
+			kubearmorlog.ContainerName,
+			kubearmorlog.Operation,
+			kubearmorlog.Labels,
+			kubearmorlog.Data,
+			kubearmorlog.Category,
+			kubearmorlog.Action,
+			kubearmorlog.Result,
+			kubearmorlog.Source,
+			kubearmorlog.Resource,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected > 0 {
+			continue
+		}
+
+		if _, err := insertStmt.Exec(
+			kubearmorlog.ClusterName,
+			kubearmorlog.NamespaceName,
+			kubearmorlog.PodName,
+			kubearmorlog.ContainerName,
+			kubearmorlog.Operation,
+			kubearmorlog.Labels,
+			kubearmorlog.Data,
+			kubearmorlog.Category,
+			kubearmorlog.Action,
+			updatedTime,
+			kubearmorlog.Result,
+			count,
+			kubearmorlog.Source,
+			kubearmorlog.Resource,
+		); err != nil {
+			return err
+		}
 	}
-	return err
+
+	return nil
 }
 
 func GetKubearmorLogs(cfg types.ConfigDB, filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
-	kubearmorLog := []types.KubeArmorLog{}
-	totalCount := []uint32{}
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		kubearmorLog, totalCount, err = GetSystemLogsMySQL(cfg, filterLog)
-	} else if cfg.DBDriver == "sqlite3" {
-		kubearmorLog, totalCount, err = GetSystemLogsSQLite(cfg, filterLog)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []types.KubeArmorLog{}, []uint32{}, err
 	}
-	return kubearmorLog, totalCount, err
+	return store.GetKubearmorLogs(filterLog)
 }
 
 func UpdateOrInsertCiliumLogs(cfg types.ConfigDB, ciliumLogs []types.CiliumLog) error {
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		err = UpdateOrInsertCiliumLogsMySQL(cfg, ciliumLogs)
-	} else if cfg.DBDriver == "sqlite3" {
-		err = UpdateOrInsertCiliumLogsSQLite(cfg, ciliumLogs)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
 	}
-	return err
+	return store.UpdateOrInsertCiliumLogs(ciliumLogs)
+}
+
+// getCiliumLogTotal returns the number of flows a cilium log stands for, one if it is not compacted
+func getCiliumLogTotal(ciliumLog types.CiliumLog) int64 {
+	if ciliumLog.Total > 0 {
+		return ciliumLog.Total
+	}
+	return 1
+}
+
+// updateOrInsertCiliumLogsSQL adds the totals of the logs to the existing ones or inserts the new ones,
+// the statements are prepared once for the batch
+func updateOrInsertCiliumLogsSQL(db sqlDB, tableName string, ciliumLogs []types.CiliumLog) error {
+	queryString := `verdict = ? and ip_source = ? and ip_destination = ? and ip_version = ? and ip_encrypted = ? and l4_tcp_source_port = ? and 
+					l4_tcp_destination_port = ? and l4_udp_source_port = ? and l4_udp_destination_port = ? and l4_icmpv4_type = ? and 
+					l4_icmpv4_code = ? and l4_icmpv6_type = ? and l4_icmpv6_code = ? and source_namespace = ? and source_labels = ? and 
+					source_pod_name = ? and destination_namespace = ? and destination_labels = ? and destination_pod_name = ? and type = ? and 
+					node_name = ? and l7_type = ? and l7_dns_cnames = ? and l7_dns_observation_source = ? and l7_http_code = ? and 
+					l7_http_method = ? and l7_http_url = ? and l7_http_protocol = ? and l7_http_headers = ? and event_type_type = ? and 
+					event_type_sub_type = ? and source_service_name = ? and source_service_namespace = ? and destination_service_name = ? and 
+					destination_service_namespace = ? and traffic_direction = ? and trace_observation_point = ? and drop_reason_desc = ? and is_reply = ? and 
+					l4_tcp_flags = ? `
+
+	updateStmt, err := db.Prepare("UPDATE " + tableName + " SET total=total+?, updated_time=? WHERE " + queryString)
+	if err != nil {
+		return err
+	}
+	defer updateStmt.Close()
+
+	insertQueryString := `(verdict,ip_source,ip_destination,ip_version,ip_encrypted,l4_tcp_source_port,l4_tcp_destination_port,
+			l4_udp_source_port,l4_udp_destination_port,l4_icmpv4_type,l4_icmpv4_code,l4_icmpv6_type,l4_icmpv6_code,
+			source_namespace,source_labels,source_pod_name,destination_namespace,destination_labels,destination_pod_name,
+			type,node_name,l7_type,l7_dns_cnames,l7_dns_observation_source,l7_http_code,l7_http_method,l7_http_url,l7_http_protocol,l7_http_headers,
+			event_type_type,event_type_sub_type,source_service_name,source_service_namespace,destination_service_name,destination_service_namespace,
+			traffic_direction,trace_observation_point,drop_reason_desc,is_reply,start_time,updated_time,total,l4_tcp_flags) 
+			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+
+	insertStmt, err := db.Prepare("INSERT INTO " + tableName + insertQueryString)
+	if err != nil {
+		return err
+	}
+	defer insertStmt.Close()
+
+	for _, ciliumlog := range ciliumLogs {
+		result, err := updateStmt.Exec(
+			getCiliumLogTotal(ciliumlog),
+			ciliumlog.UpdatedTime,
+			ciliumlog.Verdict,
+			ciliumlog.IpSource,
+			ciliumlog.IpDestination,
+			ciliumlog.IpVersion,
+			ciliumlog.IpEncrypted,
+			ciliumlog.L4TCPSourcePort,
+			ciliumlog.L4TCPDestinationPort,
+			ciliumlog.L4UDPSourcePort,
+			ciliumlog.L4UDPDestinationPort,
+			ciliumlog.L4ICMPv4Type,
+			ciliumlog.L4ICMPv4Code,
+			ciliumlog.L4ICMPv6Type,
+			ciliumlog.L4ICMPv6Code,
+			ciliumlog.SourceNamespace,
+			ciliumlog.SourceLabels,
+			ciliumlog.SourcePodName,
+			ciliumlog.DestinationNamespace,
+			ciliumlog.DestinationLabels,
+			ciliumlog.DestinationPodName,
+			ciliumlog.Type,
+			ciliumlog.NodeName,
+			ciliumlog.L7Type,
+			ciliumlog.L7DnsCnames,
+			ciliumlog.L7DnsObservationsource,
+			ciliumlog.L7HttpCode,
+			ciliumlog.L7HttpMethod,
+			ciliumlog.L7HttpUrl,
+			ciliumlog.L7HttpProtocol,
+			ciliumlog.L7HttpHeaders,
+			ciliumlog.EventTypeType,
+			ciliumlog.EventTypeSubType,
+			ciliumlog.SourceServiceName,
+			ciliumlog.SourceServiceNamespace,
+			ciliumlog.DestinationServiceName,
+			ciliumlog.DestinationServiceNamespace,
+			ciliumlog.TrafficDirection,
+			ciliumlog.TraceObservationPoint,
+			ciliumlog.DropReasonDesc,
+			ciliumlog.IsReply,
+			ciliumlog.L4TCPFlags,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected > 0 {
+			continue
+		}
+
+		if _, err := insertStmt.Exec(
+			ciliumlog.Verdict,
+			ciliumlog.IpSource,
+			ciliumlog.IpDestination,
+			ciliumlog.IpVersion,
+			ciliumlog.IpEncrypted,
+			ciliumlog.L4TCPSourcePort,
+			ciliumlog.L4TCPDestinationPort,
+			ciliumlog.L4UDPSourcePort,
+			ciliumlog.L4UDPDestinationPort,
+			ciliumlog.L4ICMPv4Type,
+			ciliumlog.L4ICMPv4Code,
+			ciliumlog.L4ICMPv6Type,
+			ciliumlog.L4ICMPv6Code,
+			ciliumlog.SourceNamespace,
+			ciliumlog.SourceLabels,
+			ciliumlog.SourcePodName,
+			ciliumlog.DestinationNamespace,
+			ciliumlog.DestinationLabels,
+			ciliumlog.DestinationPodName,
+			ciliumlog.Type,
+			ciliumlog.NodeName,
+			ciliumlog.L7Type,
+			ciliumlog.L7DnsCnames,
+			ciliumlog.L7DnsObservationsource,
+			ciliumlog.L7HttpCode,
+			ciliumlog.L7HttpMethod,
+			ciliumlog.L7HttpUrl,
+			ciliumlog.L7HttpProtocol,
+			ciliumlog.L7HttpHeaders,
+			ciliumlog.EventTypeType,
+			ciliumlog.EventTypeSubType,
+			ciliumlog.SourceServiceName,
+			ciliumlog.SourceServiceNamespace,
+			ciliumlog.DestinationServiceName,
+			ciliumlog.DestinationServiceNamespace,
+			ciliumlog.TrafficDirection,
+			ciliumlog.TraceObservationPoint,
+			ciliumlog.DropReasonDesc,
+			ciliumlog.IsReply,
+			ciliumlog.StartTime,
+			ciliumlog.UpdatedTime,
+			getCiliumLogTotal(ciliumlog),
+			ciliumlog.L4TCPFlags,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func GetCiliumLogs(cfg types.ConfigDB, ciliumFilter types.CiliumLog) ([]types.CiliumLog, []uint32, error) {
-	ciliumLogs := []types.CiliumLog{}
-	ciliumTotalCount := []uint32{}
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		ciliumLogs, ciliumTotalCount, err = GetCiliumLogsMySQL(cfg, ciliumFilter)
-	} else if cfg.DBDriver == "sqlite3" {
-		ciliumLogs, ciliumTotalCount, err = GetCiliumLogsSQLite(cfg, ciliumFilter)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []types.CiliumLog{}, []uint32{}, err
+	}
+	return store.GetCiliumLogs(ciliumFilter)
+}
+
+// GetCiliumLogsUpdatedAfter returns the network logs updated after the time, with their whole totals
+func GetCiliumLogsUpdatedAfter(cfg types.ConfigDB, updatedTime int64) ([]types.CiliumLog, []uint32, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []types.CiliumLog{}, []uint32{}, err
 	}
-	return ciliumLogs, ciliumTotalCount, err
+	return store.GetCiliumLogsUpdatedAfter(updatedTime)
 }
 
 func GetPodNames(cfg types.ConfigDB, filter types.ObsPodDetail) ([]string, error) {
-	res := []string{}
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		res, err = GetPodNamesMySQL(cfg, filter)
-	} else if cfg.DBDriver == "sqlite3" {
-		res, err = GetPodNamesSQLite(cfg, filter)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []string{}, err
 	}
-	return res, err
+	return store.GetPodNames(filter)
 }
 
 func GetDeployNames(cfg types.ConfigDB, filter types.ObsPodDetail) ([]string, error) {
-	res := []string{}
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		res, err = GetDeployNamesMySQL(cfg, filter)
-	} else if cfg.DBDriver == "sqlite3" {
-		res, err = GetDeployNamesSQLite(cfg, filter)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []string{}, err
 	}
-	return res, err
+	return store.GetDeployNames(filter)
 }
 
 // =============== //
 // == Policy DB == //
 // =============== //
 func GetPolicyYamls(cfg types.ConfigDB, policyType string, filterOptions types.PolicyFilter) ([]types.PolicyYaml, error) {
-	var err error
-	var results []types.PolicyYaml
+	store, err := GetStore(cfg)
+	if err != nil {
+		return nil, nil
+	}
 
-	if cfg.DBDriver == "mysql" {
-		results, err = GetPolicyYamlsMySQL(cfg, policyType, filterOptions)
-		if err != nil {
-			return nil, err
-		}
-	} else if cfg.DBDriver == "sqlite3" {
-		results, err = GetPolicyYamlsSQLite(cfg, policyType, filterOptions)
-		if err != nil {
-			return nil, err
-		}
+	results, _, err := store.GetPolicyYamls(policyType, filterOptions, types.PageRequest{})
+	if err != nil {
+		return nil, err
 	}
 	return results, nil
 }
 
+// GetPolicyYamlsPage returns a page of the policy yamls and the token of the next page
+func GetPolicyYamlsPage(cfg types.ConfigDB, policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	store, err := GetStore(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return store.GetPolicyYamls(policyType, filterOptions, page)
+}
+
 func UpdateOrInsertPolicyYamls(cfg types.ConfigDB, policies []types.PolicyYaml) error {
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		err = UpdateOrInsertPolicyYamlsMySQL(cfg, policies)
-	} else if cfg.DBDriver == "sqlite3" {
-		err = UpdateOrInsertPolicyYamlsSQLite(cfg, policies)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
 	}
-	return err
+	return store.UpdateOrInsertPolicyYamls(policies)
 }
 
 func DeletePolicyBasedOnPolicyName(cfg types.ConfigDB, policyName, namespace, labels string) error {
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		err = DeletePolicyBasedOnPolicyNameMySQL(cfg, policyName, namespace, labels)
-	} else if cfg.DBDriver == "sqlite3" {
-		err = DeletePolicyBasedOnPolicyNameSQLite(cfg, policyName, namespace, labels)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
 	}
-	return err
+	return store.DeletePolicyBasedOnPolicyName(policyName, namespace, labels)
 }
 
 // ============= //
 // == Summary == //
 // ============= //
 func UpsertSystemSummary(cfg types.ConfigDB, summaryMap map[types.SystemSummary]types.SysSummaryTimeCount) error {
-	var err = errors.New("unknown db driver")
-	if cfg.DBDriver == "mysql" {
-		err = UpsertSystemSummaryMySQL(cfg, summaryMap)
-	} else if cfg.DBDriver == "sqlite3" {
-		err = UpsertSystemSummarySQLite(cfg, summaryMap)
+	store, err := GetStore(cfg)
+	if err != nil {
+		return err
 	}
-	return err
+	return store.UpsertSystemSummary(summaryMap)
 }
 
-func upsertSysSummarySQL(db *sql.DB, summary types.SystemSummary, timeCount types.SysSummaryTimeCount) error {
-	// sorts pod labels upon pod restart
-	sortedLabels := strings.Split(summary.Labels, ",")
-	sort.Strings(sortedLabels)
-	summary.Labels = strings.Join(sortedLabels, ",")
-
-	hash := HashSystemSummary(&summary)
+// sqlDB is the connection of the queries shared by the drivers
+type sqlDB interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
+// upsertSysSummariesSQL upserts the summaries with a statement prepared once for the batch,
+// upsertClause is the driver specific update of the existing ones
+func upsertSysSummariesSQL(db sqlDB, tableName, upsertClause string, summaryMap map[types.SystemSummary]types.SysSummaryTimeCount) error {
 	insertQueryString := `(cluster_name,cluster_id,workspace_id,namespace_name,namespace_id,container_name,container_image,container_id,podname,operation,labels,deployment_name,
 				source,destination,destination_namespace,destination_labels,type,ip,port,protocol,action,bindport,bindaddr,updated_time,count,hash_id) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
 
-	uniqueQueryString := " ON CONFLICT(hash_id) DO UPDATE SET count=count+?,updated_time=?;"
-	insertQuery := "INSERT INTO " + TableSystemSummarySQLite + insertQueryString + uniqueQueryString
+	insertQuery := "INSERT INTO " + tableName + insertQueryString + upsertClause
 
 	insertStmt, err := db.Prepare(insertQuery)
 	if err != nil {
@@ -446,58 +644,76 @@ func upsertSysSummarySQL(db *sql.DB, summary types.SystemSummary, timeCount type
 	}
 	defer insertStmt.Close()
 
-	_, err = insertStmt.Exec(
-		summary.ClusterName,
-		summary.ClusterId,
-		summary.WorkspaceId,
-		summary.NamespaceName,
-		summary.NamespaceId,
-		summary.ContainerName,
-		summary.ContainerImage,
-		summary.ContainerID,
-		summary.PodName,
-		summary.Operation,
-		summary.Labels,
-		summary.Deployment,
-		summary.Source,
-		summary.Destination,
-		summary.DestNamespace,
-		summary.DestLabels,
-		summary.NwType,
-		summary.IP,
-		summary.Port,
-		summary.Protocol,
-		summary.Action,
-		summary.BindPort,
-		summary.BindAddress,
-		timeCount.UpdatedTime,
-		timeCount.Count,
-		hash,
-		timeCount.Count,
-		timeCount.UpdatedTime,
-	)
-	if err != nil {
-		log.Error().Msg(err.Error())
-		return err
+	for summary, timeCount := range summaryMap {
+		// sorts pod labels upon pod restart
+		sortedLabels := strings.Split(summary.Labels, ",")
+		sort.Strings(sortedLabels)
+		summary.Labels = strings.Join(sortedLabels, ",")
+
+		hash := HashSystemSummary(&summary)
+
+		if _, err := insertStmt.Exec(
+			summary.ClusterName,
+			summary.ClusterId,
+			summary.WorkspaceId,
+			summary.NamespaceName,
+			summary.NamespaceId,
+			summary.ContainerName,
+			summary.ContainerImage,
+			summary.ContainerID,
+			summary.PodName,
+			summary.Operation,
+			summary.Labels,
+			summary.Deployment,
+			summary.Source,
+			summary.Destination,
+			summary.DestNamespace,
+			summary.DestLabels,
+			summary.NwType,
+			summary.IP,
+			summary.Port,
+			summary.Protocol,
+			summary.Action,
+			summary.BindPort,
+			summary.BindAddress,
+			timeCount.UpdatedTime,
+			timeCount.Count,
+			hash,
+			timeCount.Count,
+			timeCount.UpdatedTime,
+		); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// GetSystemSummary returns the summaries of the filter followed by the rollups the old ones were
+// downsampled into, a rollup is the summary of its bucket without a pod
 func GetSystemSummary(cfg types.ConfigDB, filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
-	var err = errors.New("unknown db driver")
-	res := []types.SystemSummary{}
+	store, err := GetStore(cfg)
+	if err != nil {
+		return []types.SystemSummary{}, err
+	}
+
+	summaries, err := store.GetSystemSummary(filterOptions)
+	if err != nil {
+		return summaries, err
+	}
 
-	if cfg.DBDriver == "mysql" {
-		res, err = GetSystemSummaryMySQL(cfg, filterOptions)
-	} else if cfg.DBDriver == "sqlite3" {
-		res, err = GetSystemSummarySQLite(cfg, filterOptions)
+	rollups, err := store.GetSystemSummaryRollups(filterOptions)
+	if err != nil {
+		return summaries, err
+	}
+	for _, rollup := range rollups {
+		summaries = append(summaries, rollupSummary(rollup))
 	}
 
-	return res, err
+	return summaries, nil
 }
 
-func getSysSummarySQL(db *sql.DB, dbName string, filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
+func getSysSummarySQL(db sqlDB, dbName string, filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
 	var results *sql.Rows
 	var err error
 
@@ -621,28 +837,57 @@ var (
 	PurgeDBMap     types.ConfigPurgeOldDBEntries
 )
 
+// InitPurgeOldDBEntries starts the purge cron job if enabled, it runs on the leader only
+// so the replicas do not purge and downsample the same rows
 func InitPurgeOldDBEntries() {
 	log = logger.GetInstance()
 	CfgDB = cfg.GetCfgDB()
 
-	if cfg.GetCfgPurgeOldDBEntriesEnable() {
+	if cfg.GetCfgPurgeOldDBEntriesEnable() && PurgeDBCronJob == nil {
 
-		PurgeDBCronJob = cron.New()
-		err := PurgeDBCronJob.AddFunc(cfg.GetCfgPurgeOldDBEntriesCronJobTime(), PurgeOldDBEntriesCronJob) // time interval
+		cronJob := cron.New()
+		err := cronJob.AddFunc(cfg.GetCfgPurgeOldDBEntriesCronJobTime(), PurgeOldDBEntriesCronJob) // time interval
 		if err != nil {
 			log.Error().Msg(err.Error())
 			return
 		}
-		PurgeDBCronJob.Start()
+		cronJob.Start()
+		PurgeDBCronJob = cronJob
 		log.Info().Msg("Purging Old DB Entries cron job started")
 	}
 }
 
-// Checking which type of Database
+// StopPurgeOldDBEntries stops the purge cron job, on the loss of the leadership
+func StopPurgeOldDBEntries() {
+	if PurgeDBCronJob == nil {
+		return
+	}
+
+	PurgeDBCronJob.Stop()
+	PurgeDBCronJob = nil
+	log.Info().Msg("Purging Old DB Entries cron job stopped")
+}
+
+// PurgeOldDBEntriesCronJob purges the old entries of the configured database
 func PurgeOldDBEntriesCronJob() {
-	if cfg.CurrentCfg.ConfigDB.DBDriver == "mysql" {
-		PurgeOldDBEntriesMySQL(types.ConfigDB{})
-	} else if cfg.CurrentCfg.ConfigDB.DBDriver == "sqlite3" {
-		PurgeOldDBEntriesSQLite(types.ConfigDB{})
+	store, err := GetStore(cfg.CurrentCfg.ConfigDB)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return
+	}
+
+	report, err := store.PurgeOldDBEntries(cfg.GetCfgPurgeOldDBEntries())
+	if err != nil {
+		log.Error().Msg(err.Error())
+	}
+
+	for _, table := range sortedTables(report.Compacted) {
+		log.Info().Msgf("compacted %d log records of %s", report.Compacted[table], table)
+	}
+	for _, table := range sortedTables(report.Downsampled) {
+		log.Info().Msgf("downsampled %d rows of %s", report.Downsampled[table], table)
+	}
+	for _, table := range sortedTables(report.Purged) {
+		log.Info().Msgf("purged %d rows of %s", report.Purged[table], table)
 	}
 }
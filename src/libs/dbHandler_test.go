@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/accuknox/auto-policy-discovery/src/config"
 	"github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,6 +31,7 @@ func TestGetNetworkPolicies(t *testing.T) {
 	flowID, _ := json.Marshal(flowIDsPrt)
 
 	rows := mock.NewRows([]string{
+		"id",            // int
 		"apiVersion",    // str
 		"kind",          // str
 		"flow_ids",      // []byte
@@ -44,7 +46,7 @@ func TestGetNetworkPolicies(t *testing.T) {
 		"generatedTime", // uint64
 		"updatedTime",   // uint64
 	}).
-		AddRow("", "test", flowID, "", "", "", "", "", "", "", spec, 0, 0)
+		AddRow(1, "", "test", flowID, "", "", "", "", "", "", "", spec, 0, 0)
 
 	mock.ExpectQuery("^SELECT (.+) FROM network_policy*").
 		WillReturnRows(rows)
@@ -72,19 +74,20 @@ func TestInsertNetworkPolicies(t *testing.T) {
 	prep := mock.ExpectPrepare("INSERT INTO network_policy")
 	prep.ExpectExec().
 		WithArgs(
-			"",               // str
-			"kind",           // str
-			flowID,           // []byte
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			spec,             // []byte
-			sqlmock.AnyArg(), // uint64
-			sqlmock.AnyArg(), // uint64
+			"",                // str
+			"kind",            // str
+			flowID,            // []byte
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			spec,              // []byte
+			HashSelector(nil), // str
+			sqlmock.AnyArg(),  // uint64
+			sqlmock.AnyArg(),  // uint64
 		).WillReturnResult(sqlmock.NewResult(0, 1))
 
 	nfe := []types.KnoxNetworkPolicy{
@@ -116,19 +119,20 @@ func TestInsertNetworkPoliciesSQLite(t *testing.T) {
 	prep := mock.ExpectPrepare("INSERT INTO network_policy")
 	prep.ExpectExec().
 		WithArgs(
-			"",               // str
-			"kind",           // str
-			flowID,           // []byte
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			"",               // str
-			spec,             // []byte
-			sqlmock.AnyArg(), // uint64
-			sqlmock.AnyArg(), // uint64
+			"",                // str
+			"kind",            // str
+			flowID,            // []byte
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			"",                // str
+			spec,              // []byte
+			HashSelector(nil), // str
+			sqlmock.AnyArg(),  // uint64
+			sqlmock.AnyArg(),  // uint64
 		).WillReturnResult(sqlmock.NewResult(0, 1))
 
 	nfe := []types.KnoxNetworkPolicy{
@@ -144,3 +148,108 @@ func TestInsertNetworkPoliciesSQLite(t *testing.T) {
 		t.Errorf(Unmet+"%s", err)
 	}
 }
+
+// ==================== //
+// == System Anomaly == //
+// ==================== //
+
+func TestUpsertSystemAnomaly(t *testing.T) {
+	anomaly := types.SystemAnomaly{
+		ClusterName: "default",
+		Namespace:   "default",
+		Labels:      "app=nginx",
+		Operation:   "Process",
+		Resource:    "/bin/bash",
+		Severity:    types.AnomalySeverityHigh,
+		Count:       2,
+		FirstSeen:   100,
+		LastSeen:    100,
+	}
+
+	// new anomaly
+	_, mock := NewMock()
+	mock.ExpectQuery("^SELECT id FROM system_anomaly").
+		WillReturnRows(mock.NewRows([]string{"id"}))
+	mock.ExpectExec("INSERT INTO system_anomaly").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	isNew, err := UpsertSystemAnomaly(types.ConfigDB{DBDriver: "mysql"}, anomaly)
+	assert.NoError(t, err)
+	assert.True(t, isNew)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf(Unmet+"%s", err)
+	}
+
+	// anomaly seen before
+	_, mock = NewMock()
+	mock.ExpectQuery("^SELECT id FROM system_anomaly").
+		WillReturnRows(mock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE system_anomaly SET count=count").
+		WithArgs(2, 100, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	isNew, err = UpsertSystemAnomaly(types.ConfigDB{DBDriver: "mysql"}, anomaly)
+	assert.NoError(t, err)
+	assert.False(t, isNew)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf(Unmet+"%s", err)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	cfg := types.ConfigDB{DBDriver: "mysql"}
+
+	_, mock := NewMock()
+	mock.ExpectQuery("^SELECT id,driver,topic,msgPartition,msgOffset,msgID,payload,reason,createdTime FROM dead_letter WHERE topic = \\? and id IN \\(\\?,\\?\\) ORDER BY id LIMIT \\?").
+		WithArgs("cilium-hubble", 1, 2, 10).
+		WillReturnRows(mock.NewRows([]string{"id", "driver", "topic", "msgPartition", "msgOffset", "msgID", "payload", "reason", "createdTime"}).
+			AddRow(1, "kafka", "cilium-hubble", 0, 42, "", []byte("{}"), "Unable to parse feed-consumer message", 100))
+
+	deadLetters, err := GetDeadLetters(cfg, types.DeadLetterFilter{Topic: "cilium-hubble", IDs: []int64{1, 2}, Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, int64(42), deadLetters[0].Offset)
+		assert.Equal(t, []byte("{}"), deadLetters[0].Payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf(Unmet+"%s", err)
+	}
+
+	_, mock = NewMock()
+	mock.ExpectExec("^DELETE FROM dead_letter WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, DeleteDeadLetters(cfg, []int64{1, 2}))
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf(Unmet+"%s", err)
+	}
+}
+
+// ========================== //
+// == Purge Old DB Entries == //
+// ========================== //
+
+func TestInitPurgeOldDBEntries(t *testing.T) {
+	prevPurge := config.CurrentCfg.ConfigPurgeOldDBEntries
+	defer func() { config.CurrentCfg.ConfigPurgeOldDBEntries = prevPurge }()
+	defer StopPurgeOldDBEntries()
+
+	// disabled, no cron job
+	config.CurrentCfg.ConfigPurgeOldDBEntries = types.ConfigPurgeOldDBEntries{Enable: false, CronJobTimeInterval: "@every 1h"}
+	InitPurgeOldDBEntries()
+	assert.Nil(t, PurgeDBCronJob)
+
+	// enabled, the purge is scheduled once however many times the leadership is won
+	config.CurrentCfg.ConfigPurgeOldDBEntries.Enable = true
+	InitPurgeOldDBEntries()
+	cronJob := PurgeDBCronJob
+	if assert.NotNil(t, cronJob) {
+		assert.Len(t, cronJob.Entries(), 1)
+	}
+	InitPurgeOldDBEntries()
+	assert.Same(t, cronJob, PurgeDBCronJob)
+
+	StopPurgeOldDBEntries()
+	assert.Nil(t, PurgeDBCronJob)
+}
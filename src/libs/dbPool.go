@@ -0,0 +1,80 @@
+package libs
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// ============== //
+// == DB Pool == //
+// ============== //
+
+// the long-lived handles of the databases, one per data source,
+// their connections are pooled by database/sql so the callers never close them
+var (
+	dbHandles      = map[string]*sql.DB{}
+	dbHandlesMutex sync.Mutex
+)
+
+// openDB returns the handle of the data source, opened on the first use
+func openDB(cfg types.ConfigDB, driverName, dataSourceName string) *sql.DB {
+	dbHandlesMutex.Lock()
+	defer dbHandlesMutex.Unlock()
+
+	key := driverName + "|" + dataSourceName
+	if db, ok := dbHandles[key]; ok {
+		return db
+	}
+
+	db, err := sql.Open(driverName, dataSourceName)
+	for err != nil {
+		log.Error().Msgf("%s driver:%s, user:%s, host:%s, port:%s, dbname:%s conn-error:%s",
+			driverName, cfg.DBDriver, cfg.DBUser, cfg.DBHost, cfg.DBPort, cfg.DBName, err.Error())
+		time.Sleep(time.Second * 1)
+		db, err = sql.Open(driverName, dataSourceName)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+
+	waitForDB(db)
+
+	dbHandles[key] = db
+	return db
+}
+
+// CloseDBs closes the handles of all the databases, on shutdown
+func CloseDBs() {
+	dbHandlesMutex.Lock()
+	defer dbHandlesMutex.Unlock()
+
+	for key, db := range dbHandles {
+		if err := db.Close(); err != nil {
+			log.Warn().Msgf("error while closing db err=%v", err.Error())
+		}
+		delete(dbHandles, key)
+	}
+
+	closeLogSegments()
+}
+
+// inTx runs the statements of a batch in one transaction, rolled back on error
+func inTx(db *sql.DB, batch func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := batch(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
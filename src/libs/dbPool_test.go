@@ -0,0 +1,26 @@
+package libs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenDB(t *testing.T) {
+	dir := t.TempDir()
+	cfg := types.ConfigDB{DBDriver: "sqlite3", MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: 60}
+
+	knoxDB := openDB(cfg, "sqlite3", filepath.Join(dir, "knox.db"))
+	assert.Same(t, knoxDB, openDB(cfg, "sqlite3", filepath.Join(dir, "knox.db")))
+	assert.Equal(t, 4, knoxDB.Stats().MaxOpenConnections)
+
+	obsDB := openDB(cfg, "sqlite3", filepath.Join(dir, "observability.db"))
+	assert.NotSame(t, knoxDB, obsDB)
+
+	CloseDBs()
+	assert.Error(t, knoxDB.Ping())
+	assert.NotSame(t, knoxDB, openDB(cfg, "sqlite3", filepath.Join(dir, "knox.db")))
+	CloseDBs()
+}
@@ -0,0 +1,568 @@
+package libs
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ================ //
+// == Encryption == //
+// ================ //
+
+// The sensitive columns of SQLite, the command lines, the file paths, the network peers and the
+// policies, are encrypted with AES-GCM by the SQLite handlers. The nonce is the HMAC of the value,
+// so a value always encrypts the same under a key and the where clauses still match the encrypted
+// filters. The values are prefixed with the name of their key; on migration the values of the
+// other keys, and the plaintext ones of before the encryption, are re-encrypted with the active key.
+
+// encryptedPrefix prefixes the encrypted values, followed by the name of the key and ':'
+const encryptedPrefix = "enc:v1:"
+
+// ErrNoEncryptionKey is returned for an encryption enabled without its active key
+var ErrNoEncryptionKey = errors.New("no active encryption key")
+
+// ErrUnknownEncryptionKey is returned for a value encrypted with a key not in the key ring
+var ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+
+// keyNamePattern is the pattern of the names of the keys, as the keys of a Secret without the
+// characters of the glob patterns
+var keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// minKeySize is the minimum size of a key, the keys of any size are derived to AES-256 ones
+const minKeySize = 16
+
+type encryptionKey struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+// fieldCipher encrypts the sensitive columns, a nil cipher leaves them as they are
+type fieldCipher struct {
+	active string
+	keys   map[string]encryptionKey
+}
+
+func deriveKey(secret []byte, label string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(label))
+	return h.Sum(nil)
+}
+
+// newFieldCipher returns the cipher of the key ring, the active key is the only key if not set
+func newFieldCipher(secrets map[string][]byte, active string) (*fieldCipher, error) {
+	if active == "" && len(secrets) == 1 {
+		for name := range secrets {
+			active = name
+		}
+	}
+	if _, ok := secrets[active]; !ok || active == "" {
+		return nil, ErrNoEncryptionKey
+	}
+
+	c := &fieldCipher{active: active, keys: map[string]encryptionKey{}}
+	for name, secret := range secrets {
+		if !keyNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid encryption key name %q", name)
+		}
+		if len(secret) < minKeySize {
+			return nil, fmt.Errorf("encryption key %s shorter than %d bytes", name, minKeySize)
+		}
+
+		block, err := aes.NewCipher(deriveKey(secret, "encryption"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys[name] = encryptionKey{aead: aead, mac: deriveKey(secret, "nonce")}
+	}
+
+	return c, nil
+}
+
+// activePrefix is the prefix of the values encrypted with the active key
+func (c *fieldCipher) activePrefix() string {
+	return encryptedPrefix + c.active + ":"
+}
+
+func (c *fieldCipher) encryptBytes(plaintext []byte) []byte {
+	if c == nil || len(plaintext) == 0 {
+		return plaintext
+	}
+
+	key := c.keys[c.active]
+	h := hmac.New(sha256.New, key.mac)
+	h.Write(plaintext)
+	nonce := h.Sum(nil)[:key.aead.NonceSize()]
+
+	sealed := key.aead.Seal(nonce, nonce, plaintext, nil)
+	return []byte(c.activePrefix() + base64.RawStdEncoding.EncodeToString(sealed))
+}
+
+// decryptBytes decrypts a value with its key, the values not encrypted are returned as they are
+func (c *fieldCipher) decryptBytes(value []byte) ([]byte, error) {
+	if c == nil || !bytes.HasPrefix(value, []byte(encryptedPrefix)) {
+		return value, nil
+	}
+
+	name, encoded, ok := strings.Cut(string(value[len(encryptedPrefix):]), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid encrypted value")
+	}
+	key, ok := c.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, name)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < key.aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted value")
+	}
+
+	nonce := sealed[:key.aead.NonceSize()]
+	return key.aead.Open(nil, nonce, sealed[key.aead.NonceSize():], nil)
+}
+
+func (c *fieldCipher) encrypt(plaintext string) string {
+	return string(c.encryptBytes([]byte(plaintext)))
+}
+
+func (c *fieldCipher) decrypt(value string) (string, error) {
+	plaintext, err := c.decryptBytes([]byte(value))
+	return string(plaintext), err
+}
+
+// encryptFields encrypts the fields in place
+func (c *fieldCipher) encryptFields(fields ...*string) {
+	for _, field := range fields {
+		*field = c.encrypt(*field)
+	}
+}
+
+// decryptFields decrypts the fields in place
+func (c *fieldCipher) decryptFields(fields ...*string) error {
+	for _, field := range fields {
+		plaintext, err := c.decrypt(*field)
+		if err != nil {
+			return err
+		}
+		*field = plaintext
+	}
+	return nil
+}
+
+// the sensitive fields of the records of the encrypted tables
+func kubearmorLogSecrets(l *types.KubeArmorLog) []*string {
+	return []*string{&l.Source, &l.Resource, &l.Data}
+}
+
+func ciliumLogSecrets(l *types.CiliumLog) []*string {
+	return []*string{&l.IpSource, &l.IpDestination, &l.L7DnsCnames, &l.L7HttpUrl, &l.L7HttpHeaders}
+}
+
+func systemSummarySecrets(s *types.SystemSummary) []*string {
+	return []*string{&s.Source, &s.Destination, &s.IP, &s.BindAddress}
+}
+
+func systemAnomalySecrets(a *types.SystemAnomaly) []*string {
+	return []*string{&a.FromSource, &a.Resource}
+}
+
+// ============== //
+// == Key Ring == //
+// ============== //
+
+// loadEncryptionKeys reads the keys of the key directory, or else of the Secret
+func loadEncryptionKeys(enc types.ConfigEncryption) (map[string][]byte, error) {
+	if enc.KeyDir != "" {
+		return readKeyDir(enc.KeyDir)
+	}
+	if enc.Secret != "" {
+		return readKeySecret(enc.Secret)
+	}
+	return nil, ErrNoEncryptionKey
+}
+
+// readKeyDir reads a key per file, skipping the hidden entries of a mounted Secret
+func readKeyDir(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string][]byte{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys[entry.Name()] = bytes.TrimSpace(secret)
+	}
+
+	return keys, nil
+}
+
+// readKeySecret reads a key per data entry of the Secret "namespace/name"
+func readKeySecret(secretName string) (map[string][]byte, error) {
+	namespace, name, ok := strings.Cut(secretName, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid encryption secret %q, expected namespace/name", secretName)
+	}
+
+	restConfig, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string][]byte{}
+	for key, value := range secret.Data {
+		keys[key] = bytes.TrimSpace(value)
+	}
+	return keys, nil
+}
+
+// the ciphers of the key rings, loaded on the first use
+var (
+	sqliteCiphers      = map[types.ConfigEncryption]*fieldCipher{}
+	sqliteCiphersMutex sync.Mutex
+)
+
+// getSQLiteCipher returns the cipher of the configuration, nil if the encryption is disabled
+func getSQLiteCipher(cfg types.ConfigDB) (*fieldCipher, error) {
+	if !cfg.Encryption.Enable {
+		return nil, nil
+	}
+
+	sqliteCiphersMutex.Lock()
+	defer sqliteCiphersMutex.Unlock()
+
+	if c, ok := sqliteCiphers[cfg.Encryption]; ok {
+		return c, nil
+	}
+
+	keys, err := loadEncryptionKeys(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newFieldCipher(keys, cfg.Encryption.ActiveKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sqliteCiphers[cfg.Encryption] = c
+	return c, nil
+}
+
+// ============== //
+// == Rotation == //
+// ============== //
+
+// encryptedColumns are the encrypted columns of a table, of the observability db or else of
+// the knox db
+type encryptedColumns struct {
+	table   string
+	obs     bool
+	columns []string
+}
+
+// sqliteEncryptedColumns are the encrypted columns without hashes, the summaries and the rollups
+// are hashed on their encrypted columns and re-encrypted on their own
+var sqliteEncryptedColumns = []encryptedColumns{
+	{TableNetworkPolicySQLite_TableName, false, []string{"spec"}},
+	{TableSystemPolicySQLite_TableName, false, []string{"spec"}},
+	{PolicyYamlSQLite_TableName, false, []string{"policy_yaml"}},
+	{WorkloadProcessFileSetSQLite_TableName, false, []string{"fromSource", "fileset"}},
+	{TableSystemAnomalySQLite_TableName, false, []string{"fromSource", "resource"}},
+	{TableDeadLetterSQLite_TableName, false, []string{"payload"}},
+	{TableSystemLogsSQLite_TableName, true, []string{"source", "resource", "data"}},
+	{TableNetworkLogsSQLite_TableName, true, []string{"ip_source", "ip_destination", "l7_dns_cnames", "l7_http_url", "l7_http_headers"}},
+}
+
+// notActiveClause selects the rows with a value of the columns not encrypted with the active key
+func notActiveClause(c *fieldCipher, columns []string) (string, []interface{}) {
+	clauses := []string{}
+	args := []interface{}{}
+	for _, column := range columns {
+		clauses = append(clauses, "("+column+" != '' and "+column+" NOT GLOB ?)")
+		args = append(args, c.activePrefix()+"*")
+	}
+	return " WHERE " + strings.Join(clauses, " or "), args
+}
+
+// RotateEncryptionSQLite re-encrypts the values of the encrypted columns not encrypted with the
+// active key, the plaintext ones included, and returns the number of rows of each table
+func RotateEncryptionSQLite(cfg types.ConfigDB) (map[string]int64, error) {
+	rotated := map[string]int64{}
+
+	c, err := getSQLiteCipher(cfg)
+	if err != nil || c == nil {
+		return rotated, err
+	}
+
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+	obsDB := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+
+	for _, table := range sqliteEncryptedColumns {
+		tableDB := db
+		if table.obs {
+			tableDB = obsDB
+		}
+		if err := inTx(tableDB, func(tx *sql.Tx) error {
+			count, err := rotateColumnsSQL(tx, c, table)
+			rotated[table.table] = count
+			return err
+		}); err != nil {
+			return rotated, err
+		}
+	}
+
+	if err := inTx(obsDB, func(tx *sql.Tx) error {
+		count, err := rotateSummariesSQL(tx, c)
+		rotated[TableSystemSummarySQLite] = count
+		return err
+	}); err != nil {
+		return rotated, err
+	}
+
+	err = inTx(obsDB, func(tx *sql.Tx) error {
+		count, err := rotateSummaryRollupsSQL(tx, c)
+		rotated[TableSystemSummaryRollup_TableName] = count
+		return err
+	})
+	return rotated, err
+}
+
+// rotateColumnsSQL re-encrypts the columns of a table, the values are written back as the same
+// type, text or blob, so the where clauses still compare them
+func rotateColumnsSQL(db sqlDB, c *fieldCipher, table encryptedColumns) (int64, error) {
+	whereClause, args := notActiveClause(c, table.columns)
+	results, err := db.Query("SELECT rowid,"+strings.Join(table.columns, ",")+" FROM "+table.table+whereClause, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := [][]interface{}{}
+	for results.Next() {
+		var id int64
+		values := make([]interface{}, len(table.columns))
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := results.Scan(dest...); err != nil {
+			results.Close()
+			return 0, err
+		}
+		rows = append(rows, append(values, id))
+	}
+	results.Close()
+	if err := results.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	updateStmt, err := db.Prepare("UPDATE " + table.table + " SET " + strings.Join(table.columns, "=?,") + "=? WHERE rowid = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer updateStmt.Close()
+
+	for _, row := range rows {
+		for i, value := range row[:len(table.columns)] {
+			switch v := value.(type) {
+			case string:
+				plaintext, err := c.decrypt(v)
+				if err != nil {
+					return 0, err
+				}
+				row[i] = c.encrypt(plaintext)
+			case []byte:
+				plaintext, err := c.decryptBytes(v)
+				if err != nil {
+					return 0, err
+				}
+				row[i] = c.encryptBytes(plaintext)
+			}
+		}
+		if _, err := updateStmt.Exec(row...); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(rows)), nil
+}
+
+// summaryHashColumns are the columns of the hash of a summary
+const summaryHashColumns = `cluster_name,cluster_id,workspace_id,namespace_name,namespace_id,container_name,container_image,container_id,podname,
+	operation,labels,deployment_name,source,destination,destination_namespace,destination_labels,type,ip,port,protocol,action,bindport,bindaddr`
+
+func summaryHashFields(summary *types.SystemSummary) []interface{} {
+	return []interface{}{
+		&summary.ClusterName,
+		&summary.ClusterId,
+		&summary.WorkspaceId,
+		&summary.NamespaceName,
+		&summary.NamespaceId,
+		&summary.ContainerName,
+		&summary.ContainerImage,
+		&summary.ContainerID,
+		&summary.PodName,
+		&summary.Operation,
+		&summary.Labels,
+		&summary.Deployment,
+		&summary.Source,
+		&summary.Destination,
+		&summary.DestNamespace,
+		&summary.DestLabels,
+		&summary.NwType,
+		&summary.IP,
+		&summary.Port,
+		&summary.Protocol,
+		&summary.Action,
+		&summary.BindPort,
+		&summary.BindAddress,
+	}
+}
+
+// reencryptSummary re-encrypts the sensitive fields of a summary with the active key
+func reencryptSummary(c *fieldCipher, summary *types.SystemSummary) error {
+	if err := c.decryptFields(systemSummarySecrets(summary)...); err != nil {
+		return err
+	}
+	c.encryptFields(systemSummarySecrets(summary)...)
+	return nil
+}
+
+// rotateSummariesSQL re-encrypts the summaries and their hashes
+func rotateSummariesSQL(db sqlDB, c *fieldCipher) (int64, error) {
+	whereClause, args := notActiveClause(c, []string{"source", "destination", "ip", "bindaddr"})
+	results, err := db.Query("SELECT rowid,"+summaryHashColumns+" FROM "+TableSystemSummarySQLite+whereClause, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []int64{}
+	summaries := []types.SystemSummary{}
+	hashes := []string{}
+	for results.Next() {
+		var id int64
+		summary := types.SystemSummary{}
+		if err := results.Scan(append([]interface{}{&id}, summaryHashFields(&summary)...)...); err != nil {
+			results.Close()
+			return 0, err
+		}
+		if err := reencryptSummary(c, &summary); err != nil {
+			results.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		summaries = append(summaries, summary)
+		hashes = append(hashes, HashSystemSummary(&summary))
+	}
+	results.Close()
+	if err := results.Err(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(ids)), updateSummarySecretsSQL(db, TableSystemSummarySQLite, ids, summaries, hashes)
+}
+
+// rotateSummaryRollupsSQL re-encrypts the rollups and their hashes
+func rotateSummaryRollupsSQL(db sqlDB, c *fieldCipher) (int64, error) {
+	whereClause, args := notActiveClause(c, []string{"source", "destination", "ip", "bindaddr"})
+	results, err := db.Query("SELECT rowid,granularity,bucket_time,"+rollupColumns+" FROM "+TableSystemSummaryRollup_TableName+whereClause, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []int64{}
+	summaries := []types.SystemSummary{}
+	hashes := []string{}
+	for results.Next() {
+		var id int64
+		rollup := summaryRollup{}
+		dest := append([]interface{}{&id, &rollup.Granularity, &rollup.BucketTime}, rollupFields(&rollup.Summary)...)
+		if err := results.Scan(dest...); err != nil {
+			results.Close()
+			return 0, err
+		}
+		if err := reencryptSummary(c, &rollup.Summary); err != nil {
+			results.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		summaries = append(summaries, rollup.Summary)
+		hashes = append(hashes, hashSummaryRollup(&rollup))
+	}
+	results.Close()
+	if err := results.Err(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(ids)), updateSummarySecretsSQL(db, TableSystemSummaryRollup_TableName, ids, summaries, hashes)
+}
+
+// updateSummarySecretsSQL updates the sensitive fields of the summaries of a table with their hashes
+func updateSummarySecretsSQL(db sqlDB, tableName string, ids []int64, summaries []types.SystemSummary, hashes []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	updateStmt, err := db.Prepare("UPDATE " + tableName + " SET source=?,destination=?,ip=?,bindaddr=?,hash_id=? WHERE rowid = ?")
+	if err != nil {
+		return err
+	}
+	defer updateStmt.Close()
+
+	for i, summary := range summaries {
+		if _, err := updateStmt.Exec(summary.Source, summary.Destination, summary.IP, summary.BindAddress, hashes[i], ids[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
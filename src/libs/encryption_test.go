@@ -0,0 +1,178 @@
+package libs
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldCipher(t *testing.T) {
+	c, err := newFieldCipher(map[string][]byte{"key-1": []byte("0123456789abcdef")}, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// a value always encrypts the same, so the encrypted filters match
+	encrypted := c.encrypt("/bin/sh -c ls")
+	assert.True(t, strings.HasPrefix(encrypted, "enc:v1:key-1:"))
+	assert.Equal(t, encrypted, c.encrypt("/bin/sh -c ls"))
+	assert.NotEqual(t, encrypted, c.encrypt("/bin/sh -c id"))
+
+	plaintext, err := c.decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "/bin/sh -c ls", plaintext)
+
+	// the empty and the plaintext values are left as they are
+	assert.Equal(t, "", c.encrypt(""))
+	plaintext, err = c.decrypt("/bin/ls")
+	assert.NoError(t, err)
+	assert.Equal(t, "/bin/ls", plaintext)
+
+	// a nil cipher is the disabled encryption
+	var disabled *fieldCipher
+	assert.Equal(t, "/bin/ls", disabled.encrypt("/bin/ls"))
+
+	_, err = disabled.decrypt(encrypted)
+	assert.NoError(t, err)
+
+	other, err := newFieldCipher(map[string][]byte{"key-2": []byte("fedcba9876543210")}, "key-2")
+	assert.NoError(t, err)
+	_, err = other.decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrUnknownEncryptionKey)
+}
+
+func TestNewFieldCipher(t *testing.T) {
+	keys := map[string][]byte{"key-1": []byte("0123456789abcdef"), "key-2": []byte("fedcba9876543210")}
+
+	_, err := newFieldCipher(keys, "")
+	assert.ErrorIs(t, err, ErrNoEncryptionKey)
+	_, err = newFieldCipher(keys, "key-3")
+	assert.ErrorIs(t, err, ErrNoEncryptionKey)
+
+	_, err = newFieldCipher(map[string][]byte{"key-1": []byte("short")}, "key-1")
+	assert.Error(t, err)
+	_, err = newFieldCipher(map[string][]byte{"key*": []byte("0123456789abcdef")}, "key*")
+	assert.Error(t, err)
+}
+
+func TestReadKeyDir(t *testing.T) {
+	dir := t.TempDir()
+
+	// the layout of a mounted Secret
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "..data", "key-1"), []byte("0123456789abcdef\n"), 0600))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "key-1"), filepath.Join(dir, "key-1")))
+
+	keys, err := readKeyDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"key-1": []byte("0123456789abcdef")}, keys)
+}
+
+func TestRotateEncryptionSQLite(t *testing.T) {
+	defer func(db *sql.DB) { MockDB = db }(MockDB)
+	MockDB = nil
+
+	dir := t.TempDir()
+	keyDir := filepath.Join(dir, "keys")
+	assert.NoError(t, os.Mkdir(keyDir, 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(keyDir, "key-1"), []byte("0123456789abcdef"), 0600))
+
+	prevObsDBName := config.CurrentCfg.ConfigObservability.DBName
+	defer func() { config.CurrentCfg.ConfigObservability.DBName = prevObsDBName }()
+	config.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+
+	// the logs written before the encryption was enabled
+	cfg := types.ConfigDB{DBDriver: "sqlite3", SQLiteDBPath: filepath.Join(dir, "knox.db")}
+	store, err := GetStore(cfg)
+	if !assert.NoError(t, err) || !assert.NoError(t, store.Migrate()) {
+		return
+	}
+	ciliumLog := types.CiliumLog{Verdict: "FORWARDED", IpSource: "10.0.0.1", IpDestination: "10.0.0.2", UpdatedTime: 100}
+	assert.NoError(t, store.UpdateOrInsertCiliumLogs([]types.CiliumLog{ciliumLog}))
+
+	summary := types.SystemSummary{ClusterName: "enc-cluster", Operation: "File", Source: "/bin/cat", Destination: "/etc/shadow"}
+	assert.NoError(t, store.UpsertSystemSummary(map[types.SystemSummary]types.SysSummaryTimeCount{summary: {Count: 1, UpdatedTime: 100}}))
+
+	anomaly := types.SystemAnomaly{ClusterName: "enc-cluster", Operation: "File", FromSource: "/bin/cat", Resource: "/etc/shadow", Count: 1}
+	_, err = store.UpsertSystemAnomaly(anomaly)
+	assert.NoError(t, err)
+	assert.NoError(t, store.InsertDeadLetter(types.DeadLetter{Driver: "kafka", Topic: "kubearmor", Payload: []byte(`{"resource":"/etc/shadow"}`)}))
+
+	// the plaintext values are encrypted on migration, then rotated to the next active key
+	for _, activeKey := range []string{"key-1", "key-2"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(keyDir, "key-2"), []byte("fedcba9876543210"), 0600))
+		cfg.Encryption = types.ConfigEncryption{Enable: true, KeyDir: keyDir, ActiveKey: activeKey}
+		store, err = GetStore(cfg)
+		if !assert.NoError(t, err) || !assert.NoError(t, store.Migrate()) {
+			return
+		}
+
+		var ipSource string
+		obsDB := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+		assert.NoError(t, obsDB.QueryRow("SELECT ip_source FROM "+TableNetworkLogsSQLite_TableName).Scan(&ipSource))
+		assert.True(t, strings.HasPrefix(ipSource, "enc:v1:"+activeKey+":"))
+
+		var fromSource string
+		var payload []byte
+		db := connectSQLite(cfg, cfg.SQLiteDBPath)
+		assert.NoError(t, db.QueryRow("SELECT fromSource FROM "+TableSystemAnomalySQLite_TableName).Scan(&fromSource))
+		assert.True(t, strings.HasPrefix(fromSource, "enc:v1:"+activeKey+":"))
+		assert.NoError(t, db.QueryRow("SELECT payload FROM "+TableDeadLetterSQLite_TableName).Scan(&payload))
+		assert.True(t, strings.HasPrefix(string(payload), "enc:v1:"+activeKey+":"))
+
+		// the rotated anomaly is still the one upserted
+		created, err := store.UpsertSystemAnomaly(anomaly)
+		assert.NoError(t, err)
+		assert.False(t, created)
+
+		// the filters still match the encrypted columns, and the same summaries are counted together
+		ciliumLogs, totals, err := store.GetCiliumLogs(types.CiliumLog{IpSource: "10.0.0.1"})
+		assert.NoError(t, err)
+		if assert.Len(t, ciliumLogs, 1) {
+			assert.Equal(t, "10.0.0.2", ciliumLogs[0].IpDestination)
+		}
+		assert.NoError(t, store.UpdateOrInsertCiliumLogs([]types.CiliumLog{ciliumLog}))
+		_, totals, err = store.GetCiliumLogs(types.CiliumLog{IpSource: "10.0.0.1"})
+		assert.NoError(t, err)
+		assert.Len(t, totals, 1)
+
+		assert.NoError(t, store.UpsertSystemSummary(map[types.SystemSummary]types.SysSummaryTimeCount{summary: {Count: 1, UpdatedTime: 200}}))
+		summaries, err := store.GetSystemSummary(types.SystemSummary{Source: "/bin/cat"})
+		assert.NoError(t, err)
+		if assert.Len(t, summaries, 1) {
+			assert.Equal(t, "/etc/shadow", summaries[0].Destination)
+		}
+	}
+
+	// the values of the retired key are all re-encrypted, it is no longer needed
+	assert.NoError(t, os.Remove(filepath.Join(keyDir, "key-1")))
+	cfg.Encryption = types.ConfigEncryption{Enable: true, KeyDir: keyDir}
+	store, err = GetStore(cfg)
+	assert.NoError(t, err)
+
+	summaries, err := store.GetSystemSummary(types.SystemSummary{ClusterName: "enc-cluster"})
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, "/bin/cat", summaries[0].Source)
+		assert.Equal(t, 3, int(summaries[0].Count))
+	}
+
+	anomalies, err := store.GetSystemAnomalies(types.AnomalyFilter{Cluster: "enc-cluster"})
+	assert.NoError(t, err)
+	if assert.Len(t, anomalies, 1) {
+		assert.Equal(t, "/etc/shadow", anomalies[0].Resource)
+		assert.Equal(t, int32(3), anomalies[0].Count)
+	}
+
+	deadLetters, err := store.GetDeadLetters(types.DeadLetterFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, []byte(`{"resource":"/etc/shadow"}`), deadLetters[0].Payload)
+	}
+}
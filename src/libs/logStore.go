@@ -0,0 +1,835 @@
+package libs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// =============== //
+// == Log Store == //
+// =============== //
+
+// The bolt log store keeps the raw network and system logs out of the SQL database. The logs
+// are appended to time-partitioned segments, a bolt file per kind of log and per segment
+// duration, instead of being updated row by row. The records of a log are merged when read,
+// and merged on disk by the compaction of the purge. The expired segments are dropped whole.
+// With the encryption enabled the records are encrypted whole with the active key, the records
+// of a retired key are encrypted again when their segment is compacted.
+
+// the stores of the raw logs (cfg.LogStore)
+const (
+	LogStoreSQL  = "sql"
+	LogStoreBolt = "bolt"
+)
+
+// ErrUnknownLogStore is returned for the log stores neither sql nor bolt
+var ErrUnknownLogStore = errors.New("unknown log store")
+
+// logKind is a kind of log, its segments are the files of its prefix, purged by the
+// retention policy of its SQL table
+type logKind struct {
+	prefix string
+	table  string
+}
+
+var (
+	ciliumLogKind    = logKind{"network", TableNetworkLogs_TableName}
+	kubearmorLogKind = logKind{"system", TableSystemLogs_TableName}
+)
+
+// logsBucket is the bucket of the records of a segment
+var logsBucket = []byte("logs")
+
+// logRecord is a record of a segment, Log is the json of the log without its times and
+// total, the same for all the records of the log
+type logRecord struct {
+	Log         json.RawMessage `json:"log"`
+	StartTime   int64           `json:"start_time,omitempty"`
+	UpdatedTime int64           `json:"updated_time"`
+	Total       int64           `json:"total"`
+}
+
+// merge adds the total of a record of the same log, and keeps the widest times
+func (r *logRecord) merge(other logRecord) {
+	r.Total += other.Total
+	if other.StartTime != 0 && (r.StartTime == 0 || other.StartTime < r.StartTime) {
+		r.StartTime = other.StartTime
+	}
+	if other.UpdatedTime > r.UpdatedTime {
+		r.UpdatedTime = other.UpdatedTime
+	}
+}
+
+// encodeLogRecord returns the value of a record, encrypted if the cipher is not nil
+func encodeLogRecord(c *fieldCipher, record logRecord) ([]byte, error) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return c.encryptBytes(value), nil
+}
+
+// decodeLogRecord returns the record of a value, encrypted with any of the keys of the cipher
+func decodeLogRecord(c *fieldCipher, value []byte) (logRecord, error) {
+	record := logRecord{}
+
+	value, err := c.decryptBytes(value)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(value, &record)
+	return record, err
+}
+
+// logKey orders the records of a segment by namespace and time, so a namespace is scanned
+// from a time on; the sequence keeps the records of the same time apart
+func logKey(namespace string, t int64, seq uint64) []byte {
+	key := make([]byte, 0, len(namespace)+17)
+	key = append(key, namespace...)
+	key = append(key, 0)
+	key = binary.BigEndian.AppendUint64(key, uint64(t))
+	return binary.BigEndian.AppendUint64(key, seq)
+}
+
+// logKeyTime returns the time of the key of a record
+func logKeyTime(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[len(key)-16 : len(key)-8]))
+}
+
+func logKeyNamespace(key []byte) string {
+	return string(key[:len(key)-17])
+}
+
+// ============== //
+// == Segments == //
+// ============== //
+
+// the open segments, by path; reading and writing hold the lock for reading,
+// dropping a segment holds it for writing
+var (
+	logSegments      = map[string]*bolt.DB{}
+	logSegmentsMutex sync.Mutex
+	logSegmentsLock  sync.RWMutex
+)
+
+// segment is the file of the logs of a kind from start on
+type segment struct {
+	path  string
+	start int64
+}
+
+func segmentDuration(cfg types.ConfigDB) int64 {
+	if cfg.LogSegmentDuration > 0 {
+		return cfg.LogSegmentDuration
+	}
+	return 3600
+}
+
+func segmentPath(cfg types.ConfigDB, kind logKind, start int64) string {
+	return filepath.Join(cfg.LogStorePath, kind.prefix+"-"+strconv.FormatInt(start, 10)+".db")
+}
+
+// listSegments returns the segments of a kind, oldest first
+func listSegments(cfg types.ConfigDB, kind logKind) ([]segment, error) {
+	paths, err := filepath.Glob(filepath.Join(cfg.LogStorePath, kind.prefix+"-*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := []segment{}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".db")
+		start, err := strconv.ParseInt(strings.TrimPrefix(name, kind.prefix+"-"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: path, start: start})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start < segments[j].start })
+
+	return segments, nil
+}
+
+// openSegment returns the handle of a segment, opened on the first use
+func openSegment(path string) (*bolt.DB, error) {
+	logSegmentsMutex.Lock()
+	defer logSegmentsMutex.Unlock()
+
+	if db, ok := logSegments[path]; ok {
+		return db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	logSegments[path] = db
+	return db, nil
+}
+
+// dropSegment closes and removes a segment
+func dropSegment(path string) error {
+	logSegmentsLock.Lock()
+	defer logSegmentsLock.Unlock()
+
+	logSegmentsMutex.Lock()
+	defer logSegmentsMutex.Unlock()
+
+	if db, ok := logSegments[path]; ok {
+		if err := db.Close(); err != nil {
+			return err
+		}
+		delete(logSegments, path)
+	}
+	return os.Remove(path)
+}
+
+// closeLogSegments closes the handles of all the segments, on shutdown
+func closeLogSegments() {
+	logSegmentsMutex.Lock()
+	defer logSegmentsMutex.Unlock()
+
+	for path, db := range logSegments {
+		if err := db.Close(); err != nil {
+			log.Warn().Msgf("error while closing log segment err=%v", err.Error())
+		}
+		delete(logSegments, path)
+	}
+}
+
+// ============ //
+// == Append == //
+// ============ //
+
+// appendLogs appends the records to the segments of their times, in a transaction per segment
+func appendLogs(cfg types.ConfigDB, kind logKind, namespaces []string, records []logRecord) error {
+	logSegmentsLock.RLock()
+	defer logSegmentsLock.RUnlock()
+
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
+
+	duration := segmentDuration(cfg)
+	bySegment := map[int64][]int{}
+	for i, record := range records {
+		start := record.UpdatedTime - record.UpdatedTime%duration
+		bySegment[start] = append(bySegment[start], i)
+	}
+
+	for start, indexes := range bySegment {
+		db, err := openSegment(segmentPath(cfg, kind, start))
+		if err != nil {
+			return err
+		}
+
+		if err := db.Update(func(tx *bolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(logsBucket)
+			if err != nil {
+				return err
+			}
+
+			for _, i := range indexes {
+				value, err := encodeLogRecord(c, records[i])
+				if err != nil {
+					return err
+				}
+				seq, err := bucket.NextSequence()
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(logKey(namespaces[i], records[i].UpdatedTime, seq), value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ========== //
+// == Scan == //
+// ========== //
+
+// scanLogs merges the records of a kind from the time on, of the namespace if any, in the order
+// the logs were first appended
+func scanLogs(cfg types.ConfigDB, kind logKind, namespace string, from int64) ([]logRecord, error) {
+	logSegmentsLock.RLock()
+	defer logSegmentsLock.RUnlock()
+
+	segments, err := listSegments(cfg, kind)
+	if err != nil {
+		return nil, err
+	}
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := segmentDuration(cfg)
+	merged := []logRecord{}
+	indexes := map[string]int{}
+
+	for _, s := range segments {
+		if s.start+duration <= from {
+			continue
+		}
+
+		db, err := openSegment(s.path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := db.View(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(logsBucket)
+			if bucket == nil {
+				return nil
+			}
+
+			return scanSegment(c, bucket, namespace, from, func(key []byte, record logRecord) {
+				if i, ok := indexes[string(record.Log)]; ok {
+					merged[i].merge(record)
+					return
+				}
+				indexes[string(record.Log)] = len(merged)
+				merged = append(merged, record)
+			})
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
+// scanMatchingLogs merges the records of a kind from the time on and keeps the logs matched, the
+// logs matched have records from the time on. The records merged from the time on are the
+// candidates, their start time and total may miss the records before the time; the whole records
+// of the candidates are only merged if there is any, so the logs are matched on their whole totals.
+func scanMatchingLogs(cfg types.ConfigDB, kind logKind, namespace string, from int64, candidate, match func(record logRecord) (bool, error)) ([]logRecord, error) {
+	if from <= 0 {
+		records, err := scanLogs(cfg, kind, namespace, 0)
+		if err != nil {
+			return nil, err
+		}
+		return filterLogs(records, match)
+	}
+
+	records, err := scanLogs(cfg, kind, namespace, from)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := filterLogs(records, candidate)
+	if err != nil || len(candidates) == 0 {
+		return candidates, err
+	}
+
+	// the merged records of the candidates may miss the ones before the time
+	wanted := map[string]bool{}
+	for _, record := range candidates {
+		wanted[string(record.Log)] = true
+	}
+
+	records, err = scanLogs(cfg, kind, namespace, 0)
+	if err != nil {
+		return nil, err
+	}
+	return filterLogs(records, func(record logRecord) (bool, error) {
+		if !wanted[string(record.Log)] {
+			return false, nil
+		}
+		return match(record)
+	})
+}
+
+// filterLogs keeps the merged records matched
+func filterLogs(records []logRecord, match func(record logRecord) (bool, error)) ([]logRecord, error) {
+	kept := []logRecord{}
+	for _, record := range records {
+		ok, err := match(record)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, record)
+		}
+	}
+	return kept, nil
+}
+
+// scanSegment calls fn on the records of a segment from the time on, of the namespace if any;
+// a namespace is a range of the keys
+func scanSegment(c *fieldCipher, bucket *bolt.Bucket, namespace string, from int64, fn func(key []byte, record logRecord)) error {
+	cur := bucket.Cursor()
+
+	var k, v []byte
+	var prefix []byte
+	if namespace != "" {
+		prefix = append([]byte(namespace), 0)
+		k, v = cur.Seek(logKey(namespace, from, 0))
+	} else {
+		k, v = cur.First()
+	}
+
+	for ; k != nil; k, v = cur.Next() {
+		if prefix != nil && !bytes.HasPrefix(k, prefix) {
+			break
+		}
+		if logKeyTime(k) < from {
+			continue
+		}
+
+		record, err := decodeLogRecord(c, v)
+		if err != nil {
+			return err
+		}
+		fn(k, record)
+	}
+
+	return nil
+}
+
+// matchLogFilter returns whether the fields set in the filter are the same in the log,
+// as the where clauses of the SQL stores
+func matchLogFilter(filter, log interface{}) bool {
+	f := reflect.ValueOf(filter)
+	l := reflect.ValueOf(log)
+
+	for i := 0; i < f.NumField(); i++ {
+		if f.Field(i).IsZero() {
+			continue
+		}
+		if f.Field(i).Interface() != l.Field(i).Interface() {
+			return false
+		}
+	}
+	return true
+}
+
+// ===================== //
+// == Purge & Compact == //
+// ===================== //
+
+// compactLogs merges the records of the same log in each segment of a kind appended since
+// its last compaction, and returns the number of records merged away
+func compactLogs(cfg types.ConfigDB, kind logKind) (int64, error) {
+	logSegmentsLock.RLock()
+	defer logSegmentsLock.RUnlock()
+
+	segments, err := listSegments(cfg, kind)
+	if err != nil {
+		return 0, err
+	}
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	var compacted int64
+	for _, s := range segments {
+		db, err := openSegment(s.path)
+		if err != nil {
+			return compacted, err
+		}
+
+		if err := db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(logsBucket)
+			if bucket == nil {
+				return nil
+			}
+
+			keys := 0
+			namespaces := map[string]string{}
+			merged := []logRecord{}
+			indexes := map[string]int{}
+			if err := scanSegment(c, bucket, "", 0, func(key []byte, record logRecord) {
+				keys++
+				if i, ok := indexes[string(record.Log)]; ok {
+					merged[i].merge(record)
+					return
+				}
+				indexes[string(record.Log)] = len(merged)
+				namespaces[string(record.Log)] = logKeyNamespace(key)
+				merged = append(merged, record)
+			}); err != nil {
+				return err
+			}
+
+			if keys == len(merged) {
+				return nil
+			}
+
+			if err := tx.DeleteBucket(logsBucket); err != nil {
+				return err
+			}
+			bucket, err := tx.CreateBucket(logsBucket)
+			if err != nil {
+				return err
+			}
+
+			for i, record := range merged {
+				value, err := encodeLogRecord(c, record)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(logKey(namespaces[string(record.Log)], record.UpdatedTime, uint64(i+1)), value); err != nil {
+					return err
+				}
+			}
+			compacted += int64(keys - len(merged))
+
+			return bucket.SetSequence(uint64(len(merged)))
+		}); err != nil {
+			return compacted, err
+		}
+	}
+
+	return compacted, nil
+}
+
+// purgeLogs drops the segments older than the max age, deletes the older records of the segment
+// the max age falls in, and likewise for the records past the max rows newest
+func purgeLogs(cfg types.ConfigDB, kind logKind, retention types.ConfigRetention, now int64, report PurgeReport) error {
+	if retention.MaxAge > 0 {
+		if err := purgeLogsBefore(cfg, kind, now-retention.MaxAge, report); err != nil {
+			return err
+		}
+	}
+
+	if retention.MaxRows > 0 {
+		oldest, found, err := oldestKeptLog(cfg, kind, retention.MaxRows)
+		if err != nil {
+			return err
+		}
+		if found {
+			return purgeLogsBefore(cfg, kind, oldest, report)
+		}
+	}
+
+	return nil
+}
+
+// oldestKeptLog returns the time of the max rows-th newest record
+func oldestKeptLog(cfg types.ConfigDB, kind logKind, maxRows int64) (int64, bool, error) {
+	logSegmentsLock.RLock()
+	defer logSegmentsLock.RUnlock()
+
+	segments, err := listSegments(cfg, kind)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var newer int64
+	for i := len(segments) - 1; i >= 0; i-- {
+		db, err := openSegment(segments[i].path)
+		if err != nil {
+			return 0, false, err
+		}
+
+		times := []int64{}
+		if err := db.View(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(logsBucket)
+			if bucket == nil {
+				return nil
+			}
+			return bucket.ForEach(func(k, v []byte) error {
+				times = append(times, logKeyTime(k))
+				return nil
+			})
+		}); err != nil {
+			return 0, false, err
+		}
+
+		if newer+int64(len(times)) >= maxRows {
+			sort.Slice(times, func(i, j int) bool { return times[i] > times[j] })
+			return times[maxRows-newer-1], true, nil
+		}
+		newer += int64(len(times))
+	}
+
+	return 0, false, nil
+}
+
+// purgeLogsBefore drops the segments ending before the time, and deletes the records before
+// the time of the segment it falls in
+func purgeLogsBefore(cfg types.ConfigDB, kind logKind, before int64, report PurgeReport) error {
+	segments, err := listSegments(cfg, kind)
+	if err != nil {
+		return err
+	}
+
+	duration := segmentDuration(cfg)
+	for _, s := range segments {
+		if s.start >= before {
+			break
+		}
+
+		purged, err := purgeSegmentBefore(s.path, before)
+		if err != nil {
+			return err
+		}
+		report.Purged[kind.table] += purged
+
+		if s.start+duration <= before {
+			if err := dropSegment(s.path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func purgeSegmentBefore(path string, before int64) (int64, error) {
+	logSegmentsLock.RLock()
+	defer logSegmentsLock.RUnlock()
+
+	db, err := openSegment(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(logsBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		// the keys are deleted after the scan, a cursor skips a key on delete
+		keys := [][]byte{}
+		if err := bucket.ForEach(func(k, v []byte) error {
+			if logKeyTime(k) < before {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = int64(len(keys))
+		return nil
+	})
+
+	return purged, err
+}
+
+// ==================== //
+// == Bolt Log Store == //
+// ==================== //
+
+// boltLogStore is the Store of a database driver with the raw logs in the bolt log store,
+// the policies, the summaries and everything else stay in the SQL store
+type boltLogStore struct {
+	Store
+	cfg types.ConfigDB
+}
+
+// kubearmorLogFields keeps the fields of a kubearmor log stored by the SQL stores
+func kubearmorLogFields(kubearmorLog types.KubeArmorLog) types.KubeArmorLog {
+	return types.KubeArmorLog{
+		ClusterName:   kubearmorLog.ClusterName,
+		NamespaceName: kubearmorLog.NamespaceName,
+		PodName:       kubearmorLog.PodName,
+		ContainerName: kubearmorLog.ContainerName,
+		Operation:     kubearmorLog.Operation,
+		Labels:        kubearmorLog.Labels,
+		Data:          kubearmorLog.Data,
+		Category:      kubearmorLog.Category,
+		Action:        kubearmorLog.Action,
+		Result:        kubearmorLog.Result,
+		Source:        kubearmorLog.Source,
+		Resource:      kubearmorLog.Resource,
+		UpdatedTime:   kubearmorLog.UpdatedTime,
+	}
+}
+
+func (s *boltLogStore) UpdateOrInsertKubearmorLogs(kubearmorLogMap map[types.KubeArmorLog]int) error {
+	updatedTime := ConvertStrToUnixTime("now")
+
+	namespaces := []string{}
+	records := []logRecord{}
+	for kubearmorLog, count := range kubearmorLogMap {
+		fields := kubearmorLogFields(kubearmorLog)
+		fields.UpdatedTime = 0
+
+		logJSON, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		namespaces = append(namespaces, fields.NamespaceName)
+		records = append(records, logRecord{Log: logJSON, UpdatedTime: updatedTime, Total: int64(count)})
+	}
+
+	return appendLogs(s.cfg, kubearmorLogKind, namespaces, records)
+}
+
+func (s *boltLogStore) GetKubearmorLogs(filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
+	resLog := []types.KubeArmorLog{}
+	resTotal := []uint32{}
+
+	// the logs last updated at the time of the filter have a record of the time
+	filter := kubearmorLogFields(filterLog)
+	match := func(record logRecord) (bool, error) {
+		kubearmorLog, err := decodeKubearmorLog(record)
+		return err == nil && matchLogFilter(filter, kubearmorLog), err
+	}
+	records, err := scanMatchingLogs(s.cfg, kubearmorLogKind, filterLog.NamespaceName, filter.UpdatedTime, match, match)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, record := range records {
+		kubearmorLog, err := decodeKubearmorLog(record)
+		if err != nil {
+			return nil, nil, err
+		}
+		resLog = append(resLog, kubearmorLog)
+		resTotal = append(resTotal, uint32(record.Total))
+	}
+
+	return resLog, resTotal, nil
+}
+
+func decodeKubearmorLog(record logRecord) (types.KubeArmorLog, error) {
+	kubearmorLog := types.KubeArmorLog{}
+	if err := json.Unmarshal(record.Log, &kubearmorLog); err != nil {
+		return kubearmorLog, err
+	}
+	kubearmorLog.UpdatedTime = record.UpdatedTime
+	return kubearmorLog, nil
+}
+
+func (s *boltLogStore) UpdateOrInsertCiliumLogs(ciliumLogs []types.CiliumLog) error {
+	namespaces := []string{}
+	records := []logRecord{}
+	for _, ciliumLog := range ciliumLogs {
+		record := logRecord{StartTime: ciliumLog.StartTime, UpdatedTime: ciliumLog.UpdatedTime, Total: getCiliumLogTotal(ciliumLog)}
+		ciliumLog.StartTime, ciliumLog.UpdatedTime, ciliumLog.Total = 0, 0, 0
+
+		logJSON, err := json.Marshal(ciliumLog)
+		if err != nil {
+			return err
+		}
+		record.Log = logJSON
+		namespaces = append(namespaces, ciliumLog.SourceNamespace)
+		records = append(records, record)
+	}
+
+	return appendLogs(s.cfg, ciliumLogKind, namespaces, records)
+}
+
+func (s *boltLogStore) GetCiliumLogs(ciliumFilter types.CiliumLog) ([]types.CiliumLog, []uint32, error) {
+	// the logs last updated at the time of the filter have a record of the time, the logs
+	// started at the time of the filter have all their records from the time on
+	from := ciliumFilter.StartTime
+	if ciliumFilter.UpdatedTime != 0 {
+		from = ciliumFilter.UpdatedTime
+	}
+
+	candidateFilter := ciliumFilter
+	candidateFilter.StartTime, candidateFilter.Total = 0, 0
+
+	return getCiliumLogs(s.cfg, ciliumFilter.SourceNamespace, from, func(ciliumLog types.CiliumLog) bool {
+		return matchLogFilter(candidateFilter, ciliumLog)
+	}, func(ciliumLog types.CiliumLog) bool {
+		return matchLogFilter(ciliumFilter, ciliumLog)
+	})
+}
+
+// GetCiliumLogsUpdatedAfter returns the logs with records after the time, with their whole totals
+func (s *boltLogStore) GetCiliumLogsUpdatedAfter(updatedTime int64) ([]types.CiliumLog, []uint32, error) {
+	updated := func(ciliumLog types.CiliumLog) bool {
+		return ciliumLog.UpdatedTime > updatedTime
+	}
+	return getCiliumLogs(s.cfg, "", updatedTime+1, updated, updated)
+}
+
+// getCiliumLogs returns the logs matched, of the namespace if any, with records from the time on,
+// see scanMatchingLogs
+func getCiliumLogs(cfg types.ConfigDB, namespace string, from int64, candidate, match func(ciliumLog types.CiliumLog) bool) ([]types.CiliumLog, []uint32, error) {
+	resLog := []types.CiliumLog{}
+	resTotal := []uint32{}
+
+	decoded := func(match func(ciliumLog types.CiliumLog) bool) func(record logRecord) (bool, error) {
+		return func(record logRecord) (bool, error) {
+			ciliumLog, err := decodeCiliumLog(record)
+			return err == nil && match(ciliumLog), err
+		}
+	}
+	records, err := scanMatchingLogs(cfg, ciliumLogKind, namespace, from, decoded(candidate), decoded(match))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, record := range records {
+		ciliumLog, err := decodeCiliumLog(record)
+		if err != nil {
+			return nil, nil, err
+		}
+		resLog = append(resLog, ciliumLog)
+		resTotal = append(resTotal, uint32(record.Total))
+	}
+
+	return resLog, resTotal, nil
+}
+
+func decodeCiliumLog(record logRecord) (types.CiliumLog, error) {
+	ciliumLog := types.CiliumLog{}
+	if err := json.Unmarshal(record.Log, &ciliumLog); err != nil {
+		return ciliumLog, err
+	}
+	ciliumLog.StartTime, ciliumLog.UpdatedTime, ciliumLog.Total = record.StartTime, record.UpdatedTime, record.Total
+	return ciliumLog, nil
+}
+
+// PurgeOldDBEntries purges the SQL store, then compacts the segments of the logs and purges
+// them by the retention policies of the log tables
+func (s *boltLogStore) PurgeOldDBEntries(purge types.ConfigPurgeOldDBEntries) (PurgeReport, error) {
+	report, err := s.Store.PurgeOldDBEntries(purge)
+	if err != nil {
+		return report, err
+	}
+
+	now := ConvertStrToUnixTime("now")
+	for _, kind := range []logKind{ciliumLogKind, kubearmorLogKind} {
+		compacted, err := compactLogs(s.cfg, kind)
+		report.Compacted[kind.table] += compacted
+		if err != nil {
+			return report, err
+		}
+
+		if err := purgeLogs(s.cfg, kind, purge.Retention[kind.table], now, report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
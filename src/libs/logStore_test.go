@@ -0,0 +1,165 @@
+package libs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestLogKey(t *testing.T) {
+	key := logKey("ns-a", 3600, 7)
+	assert.Equal(t, int64(3600), logKeyTime(key))
+	assert.Equal(t, "ns-a", logKeyNamespace(key))
+
+	// a namespace is scanned in time order
+	assert.Equal(t, -1, bytes.Compare(logKey("ns-a", 3600, 9), logKey("ns-a", 3601, 1)))
+	assert.Equal(t, -1, bytes.Compare(logKey("ns-a", 9999, 1), logKey("ns-b", 0, 1)))
+}
+
+func TestMatchLogFilter(t *testing.T) {
+	ciliumLog := types.CiliumLog{Verdict: "DROPPED", SourceNamespace: "ns-a", UpdatedTime: 100}
+
+	assert.True(t, matchLogFilter(types.CiliumLog{}, ciliumLog))
+	assert.True(t, matchLogFilter(types.CiliumLog{Verdict: "DROPPED", UpdatedTime: 100}, ciliumLog))
+	assert.False(t, matchLogFilter(types.CiliumLog{Verdict: "FORWARDED"}, ciliumLog))
+}
+
+func TestBoltLogStoreSegments(t *testing.T) {
+	cfg := types.ConfigDB{LogStorePath: t.TempDir(), LogSegmentDuration: 3600}
+	defer closeLogSegments()
+	store := &boltLogStore{cfg: cfg}
+
+	// the logs of three segments, one of them appended twice
+	assert.NoError(t, store.UpdateOrInsertCiliumLogs([]types.CiliumLog{
+		{Verdict: "FORWARDED", SourceNamespace: "ns-a", DestinationPodName: "pod-a", StartTime: 10, UpdatedTime: 100},
+		{Verdict: "FORWARDED", SourceNamespace: "ns-b", DestinationPodName: "pod-b", UpdatedTime: 3600 + 100},
+		{Verdict: "FORWARDED", SourceNamespace: "ns-a", DestinationPodName: "pod-c", UpdatedTime: 7200 + 100},
+	}))
+	assert.NoError(t, store.UpdateOrInsertCiliumLogs([]types.CiliumLog{
+		{Verdict: "FORWARDED", SourceNamespace: "ns-a", DestinationPodName: "pod-a", StartTime: 20, UpdatedTime: 200, Total: 2},
+	}))
+
+	segments, err := listSegments(cfg, ciliumLogKind)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 3)
+
+	ciliumLogs, totals, err := store.GetCiliumLogs(types.CiliumLog{SourceNamespace: "ns-a"})
+	assert.NoError(t, err)
+	if assert.Len(t, ciliumLogs, 2) {
+		assert.Equal(t, "pod-a", ciliumLogs[0].DestinationPodName)
+		assert.Equal(t, int64(10), ciliumLogs[0].StartTime)
+		assert.Equal(t, int64(200), ciliumLogs[0].UpdatedTime)
+		assert.Equal(t, []uint32{3, 1}, totals)
+	}
+
+	// the filters of a time skip the segments before, the logs keep their whole totals and times
+	ciliumLogs, totals, err = store.GetCiliumLogs(types.CiliumLog{StartTime: 10, UpdatedTime: 200})
+	assert.NoError(t, err)
+	if assert.Len(t, ciliumLogs, 1) {
+		assert.Equal(t, "pod-a", ciliumLogs[0].DestinationPodName)
+		assert.Equal(t, []uint32{3}, totals)
+	}
+	ciliumLogs, _, err = store.GetCiliumLogs(types.CiliumLog{UpdatedTime: 100})
+	assert.NoError(t, err)
+	assert.Empty(t, ciliumLogs)
+
+	candidates := 0
+	records, err := scanMatchingLogs(cfg, ciliumLogKind, "", 7200, func(record logRecord) (bool, error) {
+		candidates++
+		return false, nil
+	}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Equal(t, 1, candidates)
+
+	// the logs updated after the time keep their whole totals
+	ciliumLogs, totals, err = store.GetCiliumLogsUpdatedAfter(150)
+	assert.NoError(t, err)
+	if assert.Len(t, ciliumLogs, 3) {
+		assert.Equal(t, "pod-a", ciliumLogs[0].DestinationPodName)
+		assert.Equal(t, []uint32{3, 1, 1}, totals)
+	}
+	ciliumLogs, _, err = store.GetCiliumLogsUpdatedAfter(3600 + 100)
+	assert.NoError(t, err)
+	if assert.Len(t, ciliumLogs, 1) {
+		assert.Equal(t, "pod-c", ciliumLogs[0].DestinationPodName)
+	}
+	ciliumLogs, _, err = store.GetCiliumLogsUpdatedAfter(7200 + 100)
+	assert.NoError(t, err)
+	assert.Empty(t, ciliumLogs)
+
+	// the records of the log are merged on disk
+	compacted, err := compactLogs(cfg, ciliumLogKind)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), compacted)
+	compacted, err = compactLogs(cfg, ciliumLogKind)
+	assert.NoError(t, err)
+	assert.Zero(t, compacted)
+
+	// the expired segments are dropped, the segment of the max age is purged by record
+	report := newPurgeReport()
+	assert.NoError(t, purgeLogs(cfg, ciliumLogKind, types.ConfigRetention{MaxAge: 7200}, 3600+3600+3600+150, report))
+	assert.Equal(t, int64(2), report.Purged[TableNetworkLogs_TableName])
+
+	segments, err = listSegments(cfg, ciliumLogKind)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 2)
+
+	ciliumLogs, _, err = store.GetCiliumLogs(types.CiliumLog{})
+	assert.NoError(t, err)
+	if assert.Len(t, ciliumLogs, 1) {
+		assert.Equal(t, "pod-c", ciliumLogs[0].DestinationPodName)
+	}
+}
+
+func TestBoltLogStoreEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	defer closeLogSegments()
+
+	keyDir := filepath.Join(dir, "keys")
+	assert.NoError(t, os.Mkdir(keyDir, 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(keyDir, "key-1"), []byte("0123456789abcdef"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(keyDir, "key-2"), []byte("fedcba9876543210"), 0600))
+
+	cfg := types.ConfigDB{LogStorePath: dir, LogSegmentDuration: 3600}
+	ciliumLog := types.CiliumLog{Verdict: "FORWARDED", SourceNamespace: "ns-a", IpSource: "10.0.0.1", IpDestination: "10.0.0.2", UpdatedTime: 100}
+
+	// the records of each of the active keys are merged together
+	for _, activeKey := range []string{"key-1", "key-2"} {
+		cfg.Encryption = types.ConfigEncryption{Enable: true, KeyDir: keyDir, ActiveKey: activeKey}
+		assert.NoError(t, (&boltLogStore{cfg: cfg}).UpdateOrInsertCiliumLogs([]types.CiliumLog{ciliumLog}))
+	}
+
+	store := &boltLogStore{cfg: cfg}
+	ciliumLogs, totals, err := store.GetCiliumLogs(types.CiliumLog{IpSource: "10.0.0.1"})
+	assert.NoError(t, err)
+	if assert.Len(t, ciliumLogs, 1) {
+		assert.Equal(t, "10.0.0.2", ciliumLogs[0].IpDestination)
+		assert.Equal(t, []uint32{2}, totals)
+	}
+
+	// the compaction encrypts the records again with the active key
+	compacted, err := compactLogs(cfg, ciliumLogKind)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), compacted)
+
+	segments, err := listSegments(cfg, ciliumLogKind)
+	assert.NoError(t, err)
+	for _, s := range segments {
+		db, err := openSegment(s.path)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, db.View(func(tx *bolt.Tx) error {
+			return tx.Bucket(logsBucket).ForEach(func(k, v []byte) error {
+				assert.True(t, bytes.HasPrefix(v, []byte("enc:v1:key-2:")), string(v))
+				return nil
+			})
+		}))
+	}
+}
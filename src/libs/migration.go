@@ -0,0 +1,304 @@
+package libs
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// ================ //
+// == Migrations == //
+// ================ //
+
+// The migrations of a schema are the files migrations/<schema>/<version>_<description>.sql,
+// applied in the order of their version. A migration is never changed once released,
+// every change of the tables is a new migration of each schema.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+const SchemaVersion_TableName = "schema_version"
+
+// the schemas of the databases, sqlite keeps the observability tables in their own database
+const (
+	SchemaMySQL     = "mysql"
+	SchemaPostgres  = "postgres"
+	SchemaSQLite    = "sqlite3"
+	SchemaSQLiteOBS = "sqlite3_obs"
+)
+
+// ErrSchemaTooNew is returned for a database migrated by a newer discovery engine
+var ErrSchemaTooNew = errors.New("db schema is newer than the discovery engine")
+
+// Migration is a forward change of a schema
+type Migration struct {
+	Name        string
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// migrationHooks are the changes of the migrations the statements cannot do,
+// run after the statements of the migration of the same name
+var migrationHooks = map[string]func(db sqlDB, schema string) error{
+	"0002_policy_indexes": backfillSelectorHashes,
+}
+
+// GetMigrations returns the migrations of a schema in the order of their version
+func GetMigrations(schema string) ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir(path.Join("migrations", schema))
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		if entry.IsDir() || name == entry.Name() {
+			continue
+		}
+
+		version, description, found := strings.Cut(name, "_")
+		if !found {
+			return nil, fmt.Errorf("migration %s/%s: no version", schema, entry.Name())
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s/%s: %v", schema, entry.Name(), err)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", schema, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Name:        name,
+			Version:     v,
+			Description: strings.ReplaceAll(description, "_", " "),
+			Statements:  splitStatements(string(content)),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %s/%d: expected version %d", schema, migration.Version, i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// splitStatements splits a migration into its statements, ended by a ; at the end of a line
+func splitStatements(content string) []string {
+	statements := []string{}
+
+	var statement strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		statement.WriteString(line + "\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(statement.String()))
+			statement.Reset()
+		}
+	}
+	if strings.TrimSpace(statement.String()) != "" {
+		statements = append(statements, strings.TrimSpace(statement.String()))
+	}
+
+	return statements
+}
+
+// GetSchemaVersion returns the version of the schema of the database, 0 before any migration
+func GetSchemaVersion(db *sql.DB) (int, error) {
+	query := "CREATE TABLE IF NOT EXISTS " + SchemaVersion_TableName + " (" +
+		"	version INTEGER NOT NULL," +
+		"	description varchar(256) DEFAULT NULL," +
+		"	applied_time bigint NOT NULL" +
+		"  );"
+	if _, err := db.Exec(query); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM " + SchemaVersion_TableName).Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// the database lock the replicas of the discovery engine migrate a shared database under,
+// a named lock for mysql and an advisory lock for postgres
+const (
+	migrationLockName    = "knoxautopolicy_migration"
+	migrationLockKey     = 0x6b6e6f78 // "knox"
+	migrationLockTimeout = 10 * time.Minute
+)
+
+// lockMigrations takes the lock of the migrations of a shared database on a connection of its own,
+// the lock is released with the connection; the sqlite databases are not shared
+func lockMigrations(db *sql.DB, schema string) (func(), error) {
+	if schema != SchemaMySQL && schema != SchemaPostgres {
+		return func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationLockTimeout)
+	defer cancel()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	release := ""
+	if schema == SchemaMySQL {
+		var acquired sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, int(migrationLockTimeout.Seconds())).Scan(&acquired)
+		if err == nil && acquired.Int64 != 1 {
+			err = errors.New("timed out waiting for the migration lock")
+		}
+		release = "SELECT RELEASE_LOCK('" + migrationLockName + "')"
+	} else {
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock("+strconv.Itoa(migrationLockKey)+")")
+		release = "SELECT pg_advisory_unlock(" + strconv.Itoa(migrationLockKey) + ")"
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), release); err != nil {
+			log.Error().Msgf("failed to release the migration lock: %s", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// migrateSchema applies the migrations of the schema the database is missing, one replica at a time,
+// it refuses to touch a database migrated by a newer discovery engine
+func migrateSchema(db *sql.DB, schema string) error {
+	migrations, err := GetMigrations(schema)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockMigrations(db, schema)
+	if err != nil {
+		return fmt.Errorf("migration lock: %w", err)
+	}
+	defer unlock()
+
+	current, err := GetSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := len(migrations)
+	if current > latest {
+		return fmt.Errorf("%w: %s schema version %d, supported version %d", ErrSchemaTooNew, schema, current, latest)
+	}
+
+	for _, migration := range migrations[current:] {
+		log.Info().Msgf("migrating the %s schema to version %d (%s)", schema, migration.Version, migration.Description)
+		if err := applyMigration(db, schema, migration); err != nil {
+			return fmt.Errorf("migration %s/%d: %w", schema, migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies a migration and records its version in one transaction,
+// mysql commits each of the statements anyway
+func applyMigration(db *sql.DB, schema string, migration Migration) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		var q sqlDB = tx
+		if schema == SchemaPostgres {
+			q = postgresTx{tx}
+		}
+
+		for _, statement := range migration.Statements {
+			if _, err := q.Exec(statement); err != nil {
+				return err
+			}
+		}
+
+		if hook, ok := migrationHooks[migration.Name]; ok {
+			if err := hook(q, schema); err != nil {
+				return err
+			}
+		}
+
+		_, err := q.Exec("INSERT INTO "+SchemaVersion_TableName+"(version,description,applied_time) values(?,?,?)",
+			migration.Version, migration.Description, ConvertStrToUnixTime("now"))
+		return err
+	})
+}
+
+// backfillSelectorHashes hashes the selectors of the network policies stored before the hashes
+func backfillSelectorHashes(db sqlDB, schema string) error {
+	key := "id"
+	if schema == SchemaSQLite {
+		key = "rowid"
+	}
+
+	results, err := db.Query("SELECT " + key + ",spec FROM " + TableNetworkPolicy_TableName + " WHERE selector_hash IS NULL")
+	if err != nil {
+		return err
+	}
+
+	hashes := map[int64]string{}
+	for results.Next() {
+		var id int64
+		specByte := []byte{}
+		if err := results.Scan(&id, &specByte); err != nil {
+			results.Close()
+			return err
+		}
+
+		spec := types.Spec{}
+		if err := json.Unmarshal(specByte, &spec); err != nil {
+			results.Close()
+			return err
+		}
+		hashes[id] = HashSelector(spec.Selector.MatchLabels)
+	}
+	results.Close()
+	if err := results.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := db.Prepare("UPDATE " + TableNetworkPolicy_TableName + " SET selector_hash=? WHERE " + key + "=?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, hash := range hashes {
+		if _, err := stmt.Exec(hash, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package libs
+
+import (
+	"database/sql"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMigrations(t *testing.T) {
+	for _, schema := range []string{SchemaMySQL, SchemaPostgres, SchemaSQLite, SchemaSQLiteOBS} {
+		migrations, err := GetMigrations(schema)
+		if assert.NoError(t, err, schema) && assert.NotEmpty(t, migrations, schema) {
+			assert.Equal(t, 1, migrations[0].Version)
+			assert.Equal(t, "initial schema", migrations[0].Description)
+			for _, migration := range migrations {
+				assert.NotEmpty(t, migration.Statements, "%s/%d", schema, migration.Version)
+			}
+		}
+	}
+
+	_, err := GetMigrations("unknown")
+	assert.Error(t, err)
+}
+
+func TestSplitStatements(t *testing.T) {
+	content := "-- comment\n\nCREATE TABLE a (\n\tid int\n);\nCREATE INDEX a_idx ON a (id);\n\nDROP TABLE b"
+	assert.Equal(t, []string{
+		"CREATE TABLE a (\n\tid int\n);",
+		"CREATE INDEX a_idx ON a (id);",
+		"DROP TABLE b",
+	}, splitStatements(content))
+}
+
+func TestMigrateSchema(t *testing.T) {
+	defer func(db *sql.DB) { MockDB = db }(MockDB)
+	MockDB = nil
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "knox.db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	migrations, err := GetMigrations(SchemaSQLite)
+	assert.NoError(t, err)
+
+	assert.NoError(t, migrateSchema(db, SchemaSQLite))
+	version, err := GetSchemaVersion(db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+
+	// the migrated database is left as is
+	assert.NoError(t, migrateSchema(db, SchemaSQLite))
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+SchemaVersion_TableName).Scan(&count))
+	assert.Equal(t, len(migrations), count)
+
+	// a database of a newer discovery engine is refused
+	_, err = db.Exec("INSERT INTO "+SchemaVersion_TableName+"(version,description,applied_time) values(?,?,?)", len(migrations)+1, "newer", 0)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, migrateSchema(db, SchemaSQLite), ErrSchemaTooNew)
+}
+
+func TestLockMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	// mysql takes a named lock
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).
+		WithArgs(migrationLockName, 600).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT RELEASE_LOCK('" + migrationLockName + "')")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	unlock, err := lockMigrations(db, SchemaMySQL)
+	if assert.NoError(t, err) {
+		unlock()
+	}
+
+	// the lock held by another replica until the timeout
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+	_, err = lockMigrations(db, SchemaMySQL)
+	assert.Error(t, err)
+
+	// postgres takes an advisory lock
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock(")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock(")).WillReturnResult(sqlmock.NewResult(0, 0))
+	unlock, err = lockMigrations(db, SchemaPostgres)
+	if assert.NoError(t, err) {
+		unlock()
+	}
+
+	// sqlite is not shared
+	unlock, err = lockMigrations(db, SchemaSQLite)
+	if assert.NoError(t, err) {
+		unlock()
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,204 @@
+-- initial schema, the tables created before the migrations
+
+CREATE TABLE IF NOT EXISTS network_policy (
+	id serial PRIMARY KEY,
+	apiVersion varchar(20) DEFAULT NULL,
+	kind varchar(20) DEFAULT NULL,
+	flow_ids JSONB DEFAULT NULL,
+	name varchar(128) DEFAULT NULL,
+	cluster_name varchar(50) DEFAULT NULL,
+	namespace varchar(50) DEFAULT NULL,
+	type varchar(10) DEFAULT NULL,
+	rule varchar(30) DEFAULT NULL,
+	status varchar(10) DEFAULT NULL,
+	outdated varchar(128) DEFAULT NULL,
+	spec JSONB DEFAULT NULL,
+	generatedTime bigint NOT NULL,
+	updatedTime bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS network_policy_name_idx ON network_policy (name);
+CREATE INDEX IF NOT EXISTS network_policy_namespace_idx ON network_policy (cluster_name,namespace,status);
+CREATE INDEX IF NOT EXISTS network_policy_spec_idx ON network_policy USING GIN (spec);
+
+CREATE TABLE IF NOT EXISTS system_policy (
+	id serial PRIMARY KEY,
+	apiVersion varchar(40) DEFAULT NULL,
+	kind varchar(20) DEFAULT NULL,
+	name varchar(128) DEFAULT NULL,
+	clusterName varchar(50) DEFAULT NULL,
+	namespace varchar(50) DEFAULT NULL,
+	type varchar(20) NOT NULL,
+	status varchar(10) DEFAULT NULL,
+	outdated varchar(128) DEFAULT NULL,
+	spec JSONB DEFAULT NULL,
+	generatedTime bigint NOT NULL,
+	updatedTime bigint NOT NULL,
+	latest BOOLEAN
+);
+CREATE INDEX IF NOT EXISTS system_policy_name_idx ON system_policy (name);
+CREATE INDEX IF NOT EXISTS system_policy_namespace_idx ON system_policy (namespace,status);
+CREATE INDEX IF NOT EXISTS system_policy_spec_idx ON system_policy USING GIN (spec);
+
+CREATE TABLE IF NOT EXISTS workload_process_fileset (
+	id serial PRIMARY KEY,
+	policyName varchar(128) DEFAULT NULL,
+	clusterName varchar(50) DEFAULT NULL,
+	namespace varchar(50) DEFAULT NULL,
+	containerName varchar(100) NOT NULL,
+	labels varchar(1000) DEFAULT NULL,
+	fromSource varchar(256) DEFAULT NULL,
+	settype varchar(16) DEFAULT NULL,
+	fileset text DEFAULT NULL,
+	createdTime bigint NOT NULL,
+	updatedTime bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS workload_process_fileset_workload_idx ON workload_process_fileset (clusterName,namespace,containerName);
+
+CREATE TABLE IF NOT EXISTS system_logs (
+	id serial PRIMARY KEY,
+	cluster_name varchar(50) DEFAULT NULL,
+	host_name varchar(50) DEFAULT NULL,
+	namespace_name varchar(50) DEFAULT NULL,
+	pod_name varchar(100) DEFAULT NULL,
+	container_id varchar(100) DEFAULT NULL,
+	container_name varchar(100) DEFAULT NULL,
+	uid INTEGER,
+	type varchar(50) DEFAULT NULL,
+	source text DEFAULT NULL,
+	operation varchar(50) DEFAULT NULL,
+	resource text DEFAULT NULL,
+	labels text DEFAULT NULL,
+	data text DEFAULT NULL,
+	category varchar(50) DEFAULT NULL,
+	action varchar(50) DEFAULT NULL,
+	start_time bigint DEFAULT NULL,
+	updated_time bigint DEFAULT NULL,
+	result varchar(100) DEFAULT NULL,
+	total INTEGER
+);
+CREATE INDEX IF NOT EXISTS system_logs_pod_idx ON system_logs (cluster_name,namespace_name,pod_name);
+
+CREATE TABLE IF NOT EXISTS network_logs (
+	id serial PRIMARY KEY,
+	verdict varchar(100) DEFAULT NULL,
+	ip_source varchar(100) DEFAULT NULL,
+	ip_destination varchar(100) DEFAULT NULL,
+	ip_version varchar(100) DEFAULT NULL,
+	ip_encrypted BOOLEAN,
+	l4_tcp_source_port INTEGER,
+	l4_tcp_destination_port INTEGER,
+	l4_udp_source_port INTEGER,
+	l4_udp_destination_port INTEGER,
+	l4_icmpv4_type INTEGER,
+	l4_icmpv4_code INTEGER,
+	l4_icmpv6_type INTEGER,
+	l4_icmpv6_code INTEGER,
+	source_namespace varchar(100) DEFAULT NULL,
+	source_labels text DEFAULT NULL,
+	source_pod_name varchar(100) DEFAULT NULL,
+	destination_namespace varchar(100) DEFAULT NULL,
+	destination_labels text DEFAULT NULL,
+	destination_pod_name varchar(100) DEFAULT NULL,
+	type varchar(100) DEFAULT NULL,
+	node_name varchar(100) DEFAULT NULL,
+	l7_type varchar(100) DEFAULT NULL,
+	l7_dns_cnames text DEFAULT NULL,
+	l7_dns_observation_source varchar(150) DEFAULT NULL,
+	l7_http_code INTEGER,
+	l7_http_method varchar(100) DEFAULT NULL,
+	l7_http_url text DEFAULT NULL,
+	l7_http_protocol varchar(50) DEFAULT NULL,
+	l7_http_headers text DEFAULT NULL,
+	event_type_type INTEGER,
+	event_type_sub_type INTEGER,
+	source_service_name varchar(150) DEFAULT NULL,
+	source_service_namespace varchar(100) DEFAULT NULL,
+	destination_service_name varchar(150) DEFAULT NULL,
+	destination_service_namespace varchar(100) DEFAULT NULL,
+	traffic_direction varchar(100) DEFAULT NULL,
+	trace_observation_point varchar(100) DEFAULT NULL,
+	drop_reason_desc varchar(100) DEFAULT NULL,
+	is_reply BOOLEAN,
+	start_time bigint NOT NULL,
+	updated_time bigint NOT NULL,
+	total INTEGER
+);
+CREATE INDEX IF NOT EXISTS network_logs_source_idx ON network_logs (source_namespace,source_pod_name);
+CREATE INDEX IF NOT EXISTS network_logs_destination_idx ON network_logs (destination_namespace,destination_pod_name);
+
+CREATE TABLE IF NOT EXISTS policy_yaml (
+	id serial PRIMARY KEY,
+	type varchar(50) DEFAULT NULL,
+	kind varchar(50) DEFAULT NULL,
+	cluster_name varchar(50) DEFAULT NULL,
+	namespace varchar(50) DEFAULT NULL,
+	labels text DEFAULT NULL,
+	policy_name varchar(150) DEFAULT NULL,
+	policy_yaml text DEFAULT NULL,
+	updated_time bigint NOT NULL,
+	workspace_id INTEGER NOT NULL,
+	cluster_id INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS policy_yaml_name_idx ON policy_yaml (policy_name);
+CREATE INDEX IF NOT EXISTS policy_yaml_namespace_idx ON policy_yaml (type,cluster_name,namespace);
+
+CREATE TABLE IF NOT EXISTS system_summary (
+	id serial PRIMARY KEY,
+	cluster_name varchar(50) DEFAULT NULL,
+	cluster_id int DEFAULT NULL,
+	workspace_id int DEFAULT NULL,
+	namespace_name varchar(50) DEFAULT NULL,
+	namespace_id int DEFAULT NULL,
+	container_name varchar(100) DEFAULT NULL,
+	container_image text DEFAULT NULL,
+	container_id varchar(150) DEFAULT NULL,
+	podname varchar(100) DEFAULT NULL,
+	operation varchar(10) DEFAULT NULL,
+	labels text DEFAULT NULL,
+	deployment_name varchar(100) DEFAULT NULL,
+	source text DEFAULT NULL,
+	destination text DEFAULT NULL,
+	destination_namespace varchar(50) DEFAULT NULL,
+	destination_labels text DEFAULT NULL,
+	type varchar(10) DEFAULT NULL,
+	ip varchar(64) DEFAULT NULL,
+	port int DEFAULT NULL,
+	protocol varchar(10) DEFAULT NULL,
+	bindport varchar(10) DEFAULT NULL,
+	bindaddr varchar(64) DEFAULT NULL,
+	action varchar(10) DEFAULT NULL,
+	count int NOT NULL,
+	updated_time bigint NOT NULL,
+	hash_id varchar(50) DEFAULT NULL UNIQUE
+);
+CREATE INDEX IF NOT EXISTS system_summary_namespace_idx ON system_summary (cluster_name,namespace_name);
+CREATE INDEX IF NOT EXISTS system_summary_updated_time_idx ON system_summary (updated_time);
+
+CREATE TABLE IF NOT EXISTS system_anomaly (
+	id serial PRIMARY KEY,
+	clusterName varchar(50) DEFAULT NULL,
+	namespace varchar(50) DEFAULT NULL,
+	containerName varchar(100) DEFAULT NULL,
+	labels varchar(1000) DEFAULT NULL,
+	fromSource varchar(256) DEFAULT NULL,
+	operation varchar(16) DEFAULT NULL,
+	resource varchar(1000) DEFAULT NULL,
+	severity varchar(16) DEFAULT NULL,
+	count int NOT NULL,
+	firstSeen bigint NOT NULL,
+	lastSeen bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS system_anomaly_workload_idx ON system_anomaly (clusterName,namespace,containerName);
+
+CREATE TABLE IF NOT EXISTS dead_letter (
+	id bigserial PRIMARY KEY,
+	driver varchar(16) DEFAULT NULL,
+	topic varchar(256) DEFAULT NULL,
+	msgPartition int DEFAULT NULL,
+	msgOffset bigint DEFAULT NULL,
+	msgID varchar(64) DEFAULT NULL,
+	payload bytea,
+	reason varchar(1000) DEFAULT NULL,
+	createdTime bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS dead_letter_topic_idx ON dead_letter (topic);
@@ -0,0 +1,17 @@
+-- the indexes of the paged policy queries, and the hash of the selector of the network
+-- policies so the policies of a selector are looked up instead of filtered
+
+ALTER TABLE network_policy ADD COLUMN IF NOT EXISTS selector_hash varchar(64) DEFAULT NULL;
+
+DROP INDEX IF EXISTS network_policy_namespace_idx;
+CREATE INDEX IF NOT EXISTS network_policy_namespace_idx ON network_policy (cluster_name,namespace,status,id);
+CREATE INDEX IF NOT EXISTS network_policy_kind_idx ON network_policy (kind,id);
+CREATE INDEX IF NOT EXISTS network_policy_selector_idx ON network_policy (selector_hash,id);
+
+DROP INDEX IF EXISTS system_policy_namespace_idx;
+CREATE INDEX IF NOT EXISTS system_policy_namespace_idx ON system_policy (namespace,status,id);
+CREATE INDEX IF NOT EXISTS system_policy_status_idx ON system_policy (status,id);
+
+DROP INDEX IF EXISTS policy_yaml_namespace_idx;
+CREATE INDEX IF NOT EXISTS policy_yaml_namespace_idx ON policy_yaml (type,cluster_name,namespace,id);
+CREATE INDEX IF NOT EXISTS policy_yaml_kind_idx ON policy_yaml (type,kind,id);
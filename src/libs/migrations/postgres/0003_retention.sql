@@ -0,0 +1,35 @@
+-- the rollups the old summaries are downsampled into, and the indexes of the times the
+-- retention policies purge the logs by
+
+CREATE TABLE IF NOT EXISTS system_summary_rollup (
+	id serial PRIMARY KEY,
+	granularity varchar(10) NOT NULL,
+	bucket_time bigint NOT NULL,
+	cluster_name varchar(50) DEFAULT NULL,
+	cluster_id int DEFAULT NULL,
+	workspace_id int DEFAULT NULL,
+	namespace_name varchar(50) DEFAULT NULL,
+	namespace_id int DEFAULT NULL,
+	container_name varchar(100) DEFAULT NULL,
+	operation varchar(10) DEFAULT NULL,
+	labels text DEFAULT NULL,
+	deployment_name varchar(100) DEFAULT NULL,
+	source text DEFAULT NULL,
+	destination text DEFAULT NULL,
+	destination_namespace varchar(50) DEFAULT NULL,
+	destination_labels text DEFAULT NULL,
+	type varchar(10) DEFAULT NULL,
+	ip varchar(64) DEFAULT NULL,
+	port int DEFAULT NULL,
+	protocol varchar(10) DEFAULT NULL,
+	bindport varchar(10) DEFAULT NULL,
+	bindaddr varchar(64) DEFAULT NULL,
+	action varchar(10) DEFAULT NULL,
+	count bigint NOT NULL,
+	hash_id varchar(64) NOT NULL UNIQUE
+);
+CREATE INDEX IF NOT EXISTS system_summary_rollup_bucket_idx ON system_summary_rollup (granularity,bucket_time);
+
+CREATE INDEX IF NOT EXISTS network_logs_updated_time_idx ON network_logs (updated_time);
+CREATE INDEX IF NOT EXISTS system_logs_updated_time_idx ON system_logs (updated_time);
+CREATE INDEX IF NOT EXISTS workload_process_fileset_updated_time_idx ON workload_process_fileset (updatedTime);
@@ -0,0 +1,3 @@
+-- the tcp flags of the network logs, the flows of a connection are told apart by them
+
+ALTER TABLE network_logs ADD COLUMN IF NOT EXISTS l4_tcp_flags VARCHAR(50) NOT NULL DEFAULT '';
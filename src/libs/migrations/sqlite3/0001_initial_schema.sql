@@ -0,0 +1,95 @@
+-- initial schema, the tables created before the migrations
+
+CREATE TABLE IF NOT EXISTS `network_policy` (
+	`id` INTEGER AUTO_INCREMENT,
+	`apiVersion` varchar(20) DEFAULT NULL,
+	`kind` varchar(20) DEFAULT NULL,
+	`flow_ids` JSON DEFAULT NULL,
+	`name` varchar(50) DEFAULT NULL,
+	`cluster_name` varchar(50) DEFAULT NULL,
+	`namespace` varchar(50) DEFAULT NULL,
+	`type` varchar(10) DEFAULT NULL,
+	`rule` varchar(30) DEFAULT NULL,
+	`status` varchar(10) DEFAULT NULL,
+	`outdated` varchar(50) DEFAULT NULL,
+	`spec` JSON DEFAULT NULL,
+	`generatedTime` bigint NOT NULL,
+	`updatedTime` bigint NOT NULL,
+	PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `system_policy` (
+	`id` INTEGER AUTO_INCREMENT,
+	`apiVersion` varchar(40) DEFAULT NULL,
+	`kind` varchar(20) DEFAULT NULL,
+	`name` varchar(128) DEFAULT NULL,
+	`clusterName` varchar(50) DEFAULT NULL,
+	`namespace` varchar(50) DEFAULT NULL,
+	`type` varchar(20) NOT NULL,
+	`status` varchar(10) DEFAULT NULL,
+	`outdated` varchar(50) DEFAULT NULL,
+	`spec` JSON DEFAULT NULL,
+	`generatedTime` bigint NOT NULL,
+	`updatedTime` bigint NOT NULL,
+	`latest` BOOLEAN,
+	PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `workload_process_fileset` (
+	`id` INTEGER AUTO_INCREMENT,
+	`policyName` varchar(128) DEFAULT NULL,
+	`clusterName` varchar(50) DEFAULT NULL,
+	`namespace` varchar(50) DEFAULT NULL,
+	`containerName` varchar(100) NOT NULL,
+	`labels` varchar(1000) DEFAULT NULL,
+	`fromSource` varchar(256) DEFAULT NULL,
+	`settype` varchar(16) DEFAULT NULL,
+	`fileset` text DEFAULT NULL,
+	`createdTime` bigint NOT NULL,
+	`updatedTime` bigint NOT NULL,
+	PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `policy_yaml` (
+	`id` INTEGER AUTO_INCREMENT,
+	`type` varchar(50) DEFAULT NULL,
+	`kind` varchar(50) DEFAULT NULL,
+	`cluster_name` varchar(50) DEFAULT NULL,
+	`namespace` varchar(50) DEFAULT NULL,
+	`labels` text DEFAULT NULL,
+	`policy_name` varchar(150) DEFAULT NULL,
+	`policy_yaml` text DEFAULT NULL,
+	`updated_time` bigint NOT NULL,
+	`workspace_id` INTEGER NOT NULL,
+	`cluster_id` INTEGER NOT NULL,
+	PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `system_anomaly` (
+	`id` INTEGER,
+	`clusterName` varchar(50) DEFAULT NULL,
+	`namespace` varchar(50) DEFAULT NULL,
+	`containerName` varchar(100) DEFAULT NULL,
+	`labels` varchar(1000) DEFAULT NULL,
+	`fromSource` varchar(256) DEFAULT NULL,
+	`operation` varchar(16) DEFAULT NULL,
+	`resource` varchar(1000) DEFAULT NULL,
+	`severity` varchar(16) DEFAULT NULL,
+	`count` int NOT NULL,
+	`firstSeen` bigint NOT NULL,
+	`lastSeen` bigint NOT NULL,
+	PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `dead_letter` (
+	`id` INTEGER,
+	`driver` varchar(16) DEFAULT NULL,
+	`topic` varchar(256) DEFAULT NULL,
+	`msgPartition` int DEFAULT NULL,
+	`msgOffset` bigint DEFAULT NULL,
+	`msgID` varchar(64) DEFAULT NULL,
+	`payload` BLOB,
+	`reason` varchar(1000) DEFAULT NULL,
+	`createdTime` bigint NOT NULL,
+	PRIMARY KEY (`id`)
+);
@@ -0,0 +1,15 @@
+-- the indexes of the paged policy queries, and the hash of the selector of the network
+-- policies so the policies of a selector are looked up instead of filtered, the sqlite
+-- indexes end with the rowid the pages are keyed by
+
+ALTER TABLE `network_policy` ADD COLUMN `selector_hash` varchar(64) DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS `network_policy_namespace_idx` ON `network_policy` (`cluster_name`,`namespace`,`status`);
+CREATE INDEX IF NOT EXISTS `network_policy_kind_idx` ON `network_policy` (`kind`);
+CREATE INDEX IF NOT EXISTS `network_policy_selector_idx` ON `network_policy` (`selector_hash`);
+
+CREATE INDEX IF NOT EXISTS `system_policy_namespace_idx` ON `system_policy` (`namespace`,`status`);
+CREATE INDEX IF NOT EXISTS `system_policy_status_idx` ON `system_policy` (`status`);
+
+CREATE INDEX IF NOT EXISTS `policy_yaml_namespace_idx` ON `policy_yaml` (`type`,`cluster_name`,`namespace`);
+CREATE INDEX IF NOT EXISTS `policy_yaml_kind_idx` ON `policy_yaml` (`type`,`kind`);
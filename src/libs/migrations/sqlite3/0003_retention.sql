@@ -0,0 +1,4 @@
+-- the index of the time the retention policy purges the workload process file sets by,
+-- the logs and the summaries are in the observability db
+
+CREATE INDEX IF NOT EXISTS `workload_process_fileset_updated_time_idx` ON `workload_process_fileset` (`updatedTime`);
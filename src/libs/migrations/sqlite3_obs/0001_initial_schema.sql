@@ -0,0 +1,98 @@
+-- initial schema, the tables created before the migrations
+
+CREATE TABLE IF NOT EXISTS `system_logs` (
+	`id` INTEGER AUTO_INCREMENT,
+	`cluster_name` varchar(50) DEFAULT NULL,
+	`namespace_name` varchar(50) DEFAULT NULL,
+	`pod_name` varchar(50) DEFAULT NULL,
+	`container_name` varchar(100) DEFAULT NULL,
+	`source` varchar(250) DEFAULT NULL,
+	`resource` varchar(250) DEFAULT NULL,
+	`operation` varchar(250) DEFAULT NULL,
+	`labels` varchar(250) DEFAULT NULL,
+	`data` varchar(250) DEFAULT NULL,
+	`category` varchar(50) DEFAULT NULL,
+	`action` varchar(50) DEFAULT NULL,
+	`updated_time` bigint NOT NULL,
+	`result` varchar(100) DEFAULT NULL,
+	`total` INTEGER,
+	PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `network_logs` (
+	`id` INTEGER AUTO_INCREMENT,
+	`verdict` varchar(100) DEFAULT NULL,
+	`ip_source` varchar(100) DEFAULT NULL,
+	`ip_destination` varchar(100) DEFAULT NULL,
+	`ip_version` varchar(100) DEFAULT NULL,
+	`ip_encrypted` BOOLEAN,
+	`l4_tcp_source_port` INTEGER,
+	`l4_tcp_destination_port` INTEGER,
+	`l4_udp_source_port` INTEGER,
+	`l4_udp_destination_port` INTEGER,
+	`l4_icmpv4_type` INTEGER,
+	`l4_icmpv4_code` INTEGER,
+	`l4_icmpv6_type` INTEGER,
+	`l4_icmpv6_code` INTEGER,
+	`source_namespace` varchar(100) DEFAULT NULL,
+	`source_labels` varchar(200) DEFAULT NULL,
+	`source_pod_name` varchar(100) DEFAULT NULL,
+	`destination_namespace` varchar(100) DEFAULT NULL,
+	`destination_labels` varchar(200) DEFAULT NULL,
+	`destination_pod_name` varchar(100) DEFAULT NULL,
+	`type` varchar(100) DEFAULT NULL,
+	`node_name` varchar(100) DEFAULT NULL,
+	`l7_type` varchar(100) DEFAULT NULL,
+	`l7_dns_cnames` varchar(100) DEFAULT NULL,
+	`l7_dns_observation_source` varchar(150) DEFAULT NULL,
+	`l7_http_code` INTEGER,
+	`l7_http_method` varchar(100) DEFAULT NULL,
+	`l7_http_url` varchar(200) DEFAULT NULL,
+	`l7_http_protocol` varchar(50) DEFAULT NULL,
+	`l7_http_headers` varchar(200) DEFAULT NULL,
+	`event_type_type` INTEGER,
+	`event_type_sub_type` INTEGER,
+	`source_service_name` varchar(150) DEFAULT NULL,
+	`source_service_namespace` varchar(100) DEFAULT NULL,
+	`destination_service_name` varchar(100) DEFAULT NULL,
+	`destination_service_namespace` varchar(100) DEFAULT NULL,
+	`traffic_direction` varchar(100) DEFAULT NULL,
+	`trace_observation_point` varchar(100) DEFAULT NULL,
+	`drop_reason_desc` varchar(100) DEFAULT NULL,
+	`is_reply` BOOLEAN,
+	`start_time` bigint NOT NULL,
+	`updated_time` bigint NOT NULL,
+	`total` INTEGER,
+	PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `system_summary` (
+	`id` INTEGER AUTO_INCREMENT,
+	`cluster_name` varchar(50) DEFAULT NULL,
+	`cluster_id` int DEFAULT NULL,
+	`workspace_id` int DEFAULT NULL,
+	`namespace_name` varchar(50) DEFAULT NULL,
+	`namespace_id` int DEFAULT NULL,
+	`container_name` varchar(50) DEFAULT NULL,
+	`container_image` varchar(100) DEFAULT NULL,
+	`container_id` varchar(150) DEFAULT NULL,
+	`podname` varchar(50) DEFAULT NULL,
+	`operation` varchar(10) DEFAULT NULL,
+	`labels` varchar(100) DEFAULT NULL,
+	`deployment_name` varchar(50) DEFAULT NULL,
+	`source` varchar(100) DEFAULT NULL,
+	`destination` varchar(100) DEFAULT NULL,
+	`destination_namespace` varchar(50) DEFAULT NULL,
+	`destination_labels` varchar(50) DEFAULT NULL,
+	`type` varchar(10) DEFAULT NULL,
+	`ip` int DEFAULT NULL,
+	`port` varchar(10) DEFAULT NULL,
+	`protocol` varchar(10) DEFAULT NULL,
+	`bindport` varchar(10) DEFAULT NULL,
+	`bindaddr` varchar(10) DEFAULT NULL,
+	`action` varchar(10) DEFAULT NULL,
+	`count` int NOT NULL,
+	`updated_time` bigint NOT NULL,
+	`hash_id` varchar(50) DEFAULT NULL UNIQUE,
+	PRIMARY KEY (`id`)
+);
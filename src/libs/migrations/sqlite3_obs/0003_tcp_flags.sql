@@ -0,0 +1,3 @@
+-- the tcp flags of the network logs, the flows of a connection are told apart by them
+
+ALTER TABLE `network_logs` ADD COLUMN `l4_tcp_flags` varchar(50) NOT NULL DEFAULT '';
@@ -0,0 +1,164 @@
+package libs
+
+import (
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// mysqlStore is the Store of the MySQL database
+type mysqlStore struct {
+	cfg types.ConfigDB
+}
+
+func (s *mysqlStore) GetNetworkPolicies(filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
+	return GetNetworkPoliciesFromMySQL(s.cfg, filter, page)
+}
+
+func (s *mysqlStore) InsertNetworkPolicies(policies []types.KnoxNetworkPolicy) error {
+	return InsertNetworkPoliciesToMySQL(s.cfg, policies)
+}
+
+func (s *mysqlStore) UpdateNetworkPolicy(policy types.KnoxNetworkPolicy) error {
+	return UpdateNetworkPolicyToMySQL(s.cfg, policy)
+}
+
+func (s *mysqlStore) UpdateOutdatedNetworkPolicy(outdatedPolicy string, latestPolicy string) error {
+	return UpdateOutdatedNetworkPolicyFromMySQL(s.cfg, outdatedPolicy, latestPolicy)
+}
+
+func (s *mysqlStore) GetSystemPolicies(namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error) {
+	return GetSystemPoliciesFromMySQL(s.cfg, namespace, status, page)
+}
+
+func (s *mysqlStore) InsertSystemPolicies(policies []types.KnoxSystemPolicy) error {
+	return InsertSystemPoliciesToMySQL(s.cfg, policies)
+}
+
+func (s *mysqlStore) UpdateSystemPolicy(policy types.KnoxSystemPolicy) error {
+	return UpdateSystemPolicyToMySQL(s.cfg, policy)
+}
+
+func (s *mysqlStore) UpdateOutdatedSystemPolicy(outdatedPolicy string, latestPolicy string) error {
+	return UpdateOutdatedSystemPolicyFromMySQL(s.cfg, outdatedPolicy, latestPolicy)
+}
+
+func (s *mysqlStore) GetWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error) {
+	return GetWorkloadProcessFileSetMySQL(s.cfg, wpfs)
+}
+
+func (s *mysqlStore) InsertWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, policyName string, fs []string) error {
+	return InsertWorkloadProcessFileSetMySQL(s.cfg, wpfs, policyName, fs)
+}
+
+func (s *mysqlStore) UpdateWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, fs []string) error {
+	return UpdateWorkloadProcessFileSetMySQL(s.cfg, wpfs, fs)
+}
+
+func (s *mysqlStore) ClearWPFS(wpfs types.WorkloadProcessFileSet, duration int64) error {
+	return ClearWPFSDbMySQL(s.cfg, wpfs, duration)
+}
+
+func (s *mysqlStore) GetWorkloadProcessFileSetCreatedTime(wpfs types.WorkloadProcessFileSet) (int64, error) {
+	return GetWorkloadProcessFileSetCreatedTimeMySQL(s.cfg, wpfs)
+}
+
+func (s *mysqlStore) UpsertSystemAnomaly(anomaly types.SystemAnomaly) (bool, error) {
+	return UpsertSystemAnomalyMySQL(s.cfg, anomaly)
+}
+
+func (s *mysqlStore) GetSystemAnomalies(filter types.AnomalyFilter) ([]types.SystemAnomaly, error) {
+	return GetSystemAnomaliesMySQL(s.cfg, filter)
+}
+
+func (s *mysqlStore) InsertDeadLetter(deadLetter types.DeadLetter) error {
+	return InsertDeadLetterMySQL(s.cfg, deadLetter)
+}
+
+func (s *mysqlStore) GetDeadLetters(filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	return GetDeadLettersMySQL(s.cfg, filter)
+}
+
+func (s *mysqlStore) DeleteDeadLetters(ids []int64) error {
+	return DeleteDeadLettersMySQL(s.cfg, ids)
+}
+
+func (s *mysqlStore) UpdateOrInsertKubearmorLogs(kubearmorLogMap map[types.KubeArmorLog]int) error {
+	return UpdateOrInsertKubearmorLogsMySQL(s.cfg, kubearmorLogMap)
+}
+
+func (s *mysqlStore) GetKubearmorLogs(filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
+	return GetSystemLogsMySQL(s.cfg, filterLog)
+}
+
+func (s *mysqlStore) UpdateOrInsertCiliumLogs(ciliumLogs []types.CiliumLog) error {
+	return UpdateOrInsertCiliumLogsMySQL(s.cfg, ciliumLogs)
+}
+
+func (s *mysqlStore) GetCiliumLogs(ciliumFilter types.CiliumLog) ([]types.CiliumLog, []uint32, error) {
+	return GetCiliumLogsMySQL(s.cfg, ciliumFilter)
+}
+
+func (s *mysqlStore) GetCiliumLogsUpdatedAfter(updatedTime int64) ([]types.CiliumLog, []uint32, error) {
+	return GetCiliumLogsUpdatedAfterMySQL(s.cfg, updatedTime)
+}
+
+func (s *mysqlStore) GetPodNames(filter types.ObsPodDetail) ([]string, error) {
+	return GetPodNamesMySQL(s.cfg, filter)
+}
+
+func (s *mysqlStore) GetDeployNames(filter types.ObsPodDetail) ([]string, error) {
+	return GetDeployNamesMySQL(s.cfg, filter)
+}
+
+func (s *mysqlStore) GetPolicyYamls(policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	return GetPolicyYamlsMySQL(s.cfg, policyType, filterOptions, page)
+}
+
+func (s *mysqlStore) UpdateOrInsertPolicyYamls(policies []types.PolicyYaml) error {
+	return UpdateOrInsertPolicyYamlsMySQL(s.cfg, policies)
+}
+
+func (s *mysqlStore) DeletePolicyBasedOnPolicyName(policyName, namespace, labels string) error {
+	return DeletePolicyBasedOnPolicyNameMySQL(s.cfg, policyName, namespace, labels)
+}
+
+func (s *mysqlStore) UpsertSystemSummary(summaryMap map[types.SystemSummary]types.SysSummaryTimeCount) error {
+	return UpsertSystemSummaryMySQL(s.cfg, summaryMap)
+}
+
+func (s *mysqlStore) GetSystemSummary(filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
+	return GetSystemSummaryMySQL(s.cfg, filterOptions)
+}
+
+func (s *mysqlStore) UpsertSystemSummaryRollups(rollups []types.SystemSummaryRollup) error {
+	return UpsertSystemSummaryRollupsMySQL(s.cfg, rollups)
+}
+
+func (s *mysqlStore) GetSystemSummaryRollups(filterOptions types.SystemSummary) ([]types.SystemSummaryRollup, error) {
+	return GetSystemSummaryRollupsMySQL(s.cfg, filterOptions)
+}
+
+func (s *mysqlStore) ClearDBTables() error {
+	return ClearDBTablesMySQL(s.cfg)
+}
+
+func (s *mysqlStore) ClearNetworkDBTable() error {
+	return ClearNetworkDBTableMySQL(s.cfg)
+}
+
+func (s *mysqlStore) ClearPolicyYamlDBTable() error {
+	return ClearPolicyYamlDBTableMySQL(s.cfg)
+}
+
+func (s *mysqlStore) ClearSystemSummaryDBTables() error {
+	return ClearSystemSummaryDBTablesMySQL(s.cfg)
+}
+
+func (s *mysqlStore) Migrate() error {
+	db := connectMySQL(s.cfg)
+
+	return migrateSchema(db, SchemaMySQL)
+}
+
+func (s *mysqlStore) PurgeOldDBEntries(purge types.ConfigPurgeOldDBEntries) (PurgeReport, error) {
+	return PurgeOldDBEntriesMySQL(s.cfg, purge)
+}
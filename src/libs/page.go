@@ -0,0 +1,79 @@
+package libs
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// ========== //
+// == Page == //
+// ========== //
+
+// The policies are paged by their id, the page token is the id of the last row of the
+// previous page so a page never skips nor repeats a row when rows are inserted meanwhile.
+// The id of the sqlite tables is never filled, their rows are paged by the rowid instead.
+
+// DefaultPageSize is the size of the pages the policies are read by when the caller wants them all
+const DefaultPageSize = 500
+
+// ErrInvalidPageToken is returned for a page token not issued by a previous page
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// EncodePageToken returns the token of the page following the row of id
+func EncodePageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// DecodePageToken returns the id of the last row of the previous page
+func DecodePageToken(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidPageToken
+	}
+
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id < 0 {
+		return 0, ErrInvalidPageToken
+	}
+
+	return id, nil
+}
+
+// concatPageClause adds the cursor of the page on the key column to the where clause and returns
+// the order and the limit of the query, one row past the page tells whether a next page follows
+func concatPageClause(whereClause *string, args *[]interface{}, key string, page types.PageRequest) (string, error) {
+	if page.Token != "" {
+		after, err := DecodePageToken(page.Token)
+		if err != nil {
+			return "", err
+		}
+
+		if *whereClause == "" {
+			*whereClause = " WHERE "
+		} else {
+			*whereClause = *whereClause + " and "
+		}
+		*whereClause = *whereClause + key + " > ?"
+		*args = append(*args, after)
+	}
+
+	if page.Size > 0 {
+		*args = append(*args, page.Size+1)
+		return " ORDER BY " + key + " LIMIT ?", nil
+	}
+
+	return " ORDER BY " + key, nil
+}
+
+// cutPage drops the row fetched past the page and returns the token of the next page,
+// empty on the last page
+func cutPage[T any](page types.PageRequest, results []T, ids []int64) ([]T, string) {
+	if page.Size <= 0 || len(results) <= page.Size {
+		return results, ""
+	}
+
+	return results[:page.Size], EncodePageToken(ids[page.Size-1])
+}
@@ -0,0 +1,47 @@
+package libs
+
+import (
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageToken(t *testing.T) {
+	id, err := DecodePageToken(EncodePageToken(42))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+
+	for _, token := range []string{"invalid", "LTE", "!"} {
+		_, err := DecodePageToken(token)
+		assert.ErrorIs(t, err, ErrInvalidPageToken, token)
+	}
+}
+
+func TestConcatPageClause(t *testing.T) {
+	whereClause := " WHERE namespace = ?"
+	args := []interface{}{"default"}
+
+	order, err := concatPageClause(&whereClause, &args, "id", types.PageRequest{Size: 2, Token: EncodePageToken(7)})
+	assert.NoError(t, err)
+	assert.Equal(t, " WHERE namespace = ? and id > ?", whereClause)
+	assert.Equal(t, " ORDER BY id LIMIT ?", order)
+	assert.Equal(t, []interface{}{"default", int64(7), 3}, args)
+
+	whereClause, args = "", nil
+	order, err = concatPageClause(&whereClause, &args, "rowid", types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, whereClause)
+	assert.Equal(t, " ORDER BY rowid", order)
+	assert.Empty(t, args)
+}
+
+func TestCutPage(t *testing.T) {
+	page, next := cutPage(types.PageRequest{Size: 2}, []string{"a", "b", "c"}, []int64{1, 2, 3})
+	assert.Equal(t, []string{"a", "b"}, page)
+	assert.Equal(t, EncodePageToken(2), next)
+
+	page, next = cutPage(types.PageRequest{Size: 2}, []string{"a", "b"}, []int64{1, 2})
+	assert.Equal(t, []string{"a", "b"}, page)
+	assert.Empty(t, next)
+}
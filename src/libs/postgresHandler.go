@@ -0,0 +1,346 @@
+package libs
+
+import (
+	"database/sql"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+
+	_ "github.com/lib/pq"
+)
+
+// ================ //
+// == Connection == //
+// ================ //
+
+// postgresDB rewrites the ? placeholders of the queries into the $n placeholders of postgres,
+// so the queries are shared with the other drivers
+type postgresDB struct {
+	*sql.DB
+}
+
+func (db postgresDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(rebindPostgres(query), args...)
+}
+
+func (db postgresDB) Prepare(query string) (*sql.Stmt, error) {
+	return db.DB.Prepare(rebindPostgres(query))
+}
+
+func (db postgresDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(rebindPostgres(query), args...)
+}
+
+func (db postgresDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(rebindPostgres(query), args...)
+}
+
+// postgresTx rewrites the placeholders of the queries of a transaction like postgresDB
+type postgresTx struct {
+	*sql.Tx
+}
+
+func (tx postgresTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(rebindPostgres(query), args...)
+}
+
+func (tx postgresTx) Prepare(query string) (*sql.Stmt, error) {
+	return tx.Tx.Prepare(rebindPostgres(query))
+}
+
+func (tx postgresTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(rebindPostgres(query), args...)
+}
+
+func (tx postgresTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(rebindPostgres(query), args...)
+}
+
+// rebindPostgres numbers the ? placeholders of a query, none of the queries has a ? in a literal
+func rebindPostgres(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String()
+}
+
+func connectPostgres(cfg types.ConfigDB) (db postgresDB) {
+	if MockDB != nil {
+		return postgresDB{MockDB}
+	}
+
+	dbconn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.DBUser, cfg.DBPass),
+		Host:     cfg.DBHost + ":" + cfg.DBPort,
+		Path:     "/" + cfg.DBName,
+		RawQuery: "sslmode=disable",
+	}
+	return postgresDB{openDB(cfg, "postgres", dbconn.String())}
+}
+
+// ==================== //
+// == Network Policy == //
+// ==================== //
+
+func GetNetworkPoliciesFromPostgres(cfg types.ConfigDB, filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
+	return getNetworkPoliciesSQL(connectPostgres(cfg), filter, page)
+}
+
+func UpdateNetworkPolicyToPostgres(cfg types.ConfigDB, policy types.KnoxNetworkPolicy) error {
+	return updateNetworkPolicySQL(connectPostgres(cfg), policy)
+}
+
+func UpdateOutdatedNetworkPolicyFromPostgres(cfg types.ConfigDB, outdatedPolicy string, latestPolicy string) error {
+	return updateOutdatedNetworkPolicySQL(connectPostgres(cfg), outdatedPolicy, latestPolicy)
+}
+
+func InsertNetworkPoliciesToPostgres(cfg types.ConfigDB, policies []types.KnoxNetworkPolicy) error {
+	return insertNetworkPoliciesSQL(connectPostgres(cfg), policies)
+}
+
+// =================== //
+// == System Policy == //
+// =================== //
+
+func UpdateOutdatedSystemPolicyFromPostgres(cfg types.ConfigDB, outdatedPolicy string, latestPolicy string) error {
+	return updateOutdatedSystemPolicySQL(connectPostgres(cfg), outdatedPolicy, latestPolicy)
+}
+
+func GetSystemPoliciesFromPostgres(cfg types.ConfigDB, namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error) {
+	return getSystemPoliciesSQL(connectPostgres(cfg), namespace, status, page)
+}
+
+func InsertSystemPoliciesToPostgres(cfg types.ConfigDB, policies []types.KnoxSystemPolicy) error {
+	return insertSystemPoliciesSQL(connectPostgres(cfg), policies)
+}
+
+func UpdateSystemPolicyToPostgres(cfg types.ConfigDB, policy types.KnoxSystemPolicy) error {
+	return updateSystemPolicySQL(connectPostgres(cfg), policy)
+}
+
+// =========== //
+// == Table == //
+// =========== //
+
+func ClearNetworkDBTablePostgres(cfg types.ConfigDB) error {
+	return clearNetworkDBTableSQL(connectPostgres(cfg))
+}
+
+func ClearPolicyYamlDBTablePostgres(cfg types.ConfigDB) error {
+	return clearPolicyYamlDBTableSQL(connectPostgres(cfg))
+}
+
+func ClearSystemSummaryDBTablesPostgres(cfg types.ConfigDB) error {
+	return clearSystemSummaryDBTablesSQL(connectPostgres(cfg))
+}
+
+func ClearDBTablesPostgres(cfg types.ConfigDB) error {
+	return clearDBTablesSQL(connectPostgres(cfg))
+}
+
+// GetWorkloadProcessFileSetPostgres Handle File Sets in context to a given fromSource
+func GetWorkloadProcessFileSetPostgres(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error) {
+	return getWorkloadProcessFileSetSQL(connectPostgres(cfg), wpfs)
+}
+
+// InsertWorkloadProcessFileSetPostgres inserts a file set under the policy name, a new one if empty
+func InsertWorkloadProcessFileSetPostgres(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, policyName string, fs []string) error {
+	return insertWorkloadProcessFileSetSQL(connectPostgres(cfg), wpfs, policyName, fs)
+}
+
+// Clears out WPFS DB on full or as per options specified
+func ClearWPFSDbPostgres(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, duration int64) error {
+	return clearWPFSDbSQL(connectPostgres(cfg), wpfs, duration)
+}
+
+func UpdateWorkloadProcessFileSetPostgres(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, fs []string) error {
+	return updateWorkloadProcessFileSetSQL(connectPostgres(cfg), wpfs, fs)
+}
+
+// GetWorkloadProcessFileSetCreatedTimePostgres returns the creation time of the WPFS entry
+func GetWorkloadProcessFileSetCreatedTimePostgres(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet) (int64, error) {
+	return getWorkloadProcessFileSetCreatedTimeSQL(connectPostgres(cfg), wpfs)
+}
+
+// ==================== //
+// == System Anomaly == //
+// ==================== //
+
+// UpsertSystemAnomalyPostgres inserts a new anomaly or updates the count of an existing one,
+// returns true if the anomaly was not seen before
+func UpsertSystemAnomalyPostgres(cfg types.ConfigDB, anomaly types.SystemAnomaly) (bool, error) {
+	return upsertSystemAnomalySQL(connectPostgres(cfg), anomaly)
+}
+
+func GetSystemAnomaliesPostgres(cfg types.ConfigDB, filter types.AnomalyFilter) ([]types.SystemAnomaly, error) {
+	return getSystemAnomaliesSQL(connectPostgres(cfg), filter)
+}
+
+// ================= //
+// == Dead Letter == //
+// ================= //
+
+func InsertDeadLetterPostgres(cfg types.ConfigDB, deadLetter types.DeadLetter) error {
+	return insertDeadLetterSQL(connectPostgres(cfg), deadLetter)
+}
+
+// GetDeadLettersPostgres returns the dead letters in the order they were written
+func GetDeadLettersPostgres(cfg types.ConfigDB, filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	return getDeadLettersSQL(connectPostgres(cfg), filter)
+}
+
+func DeleteDeadLettersPostgres(cfg types.ConfigDB, ids []int64) error {
+	return deleteDeadLettersSQL(connectPostgres(cfg), ids)
+}
+
+// UpdateOrInsertKubearmorLogsPostgres -- Update existing log or insert a new log into DB, in one transaction
+func UpdateOrInsertKubearmorLogsPostgres(cfg types.ConfigDB, kubearmorlogmap map[types.KubeArmorLog]int) error {
+	db := connectPostgres(cfg)
+
+	start := time.Now().UnixMilli()
+	err := inTx(db.DB, func(tx *sql.Tx) error {
+		return updateOrInsertKubearmorLogsSQL(postgresTx{tx}, TableSystemLogs_TableName, kubearmorlogmap)
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return err
+	}
+	log.Info().Msgf("postgres update or insert %d time-taken-ms:%d", len(kubearmorlogmap), time.Now().UnixMilli()-start)
+
+	return nil
+}
+
+// GetSystemLogsPostgres
+func GetSystemLogsPostgres(cfg types.ConfigDB, filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
+	return getSystemLogsSQL(connectPostgres(cfg), filterLog)
+}
+
+// GetNetworkLogsMySQL
+func GetCiliumLogsPostgres(cfg types.ConfigDB, filterLog types.CiliumLog) ([]types.CiliumLog, []uint32, error) {
+	return getCiliumLogsSQL(connectPostgres(cfg), filterLog, 0)
+}
+
+// GetCiliumLogsUpdatedAfterPostgres returns the network logs updated after the time
+func GetCiliumLogsUpdatedAfterPostgres(cfg types.ConfigDB, updatedTime int64) ([]types.CiliumLog, []uint32, error) {
+	return getCiliumLogsSQL(connectPostgres(cfg), types.CiliumLog{}, updatedTime)
+}
+
+// UpdateOrInsertCiliumLogsPostgres -- Update existing log with time and count or insert a new log, in one transaction
+func UpdateOrInsertCiliumLogsPostgres(cfg types.ConfigDB, ciliumlogs []types.CiliumLog) error {
+	db := connectPostgres(cfg)
+
+	err := inTx(db.DB, func(tx *sql.Tx) error {
+		return updateOrInsertCiliumLogsSQL(postgresTx{tx}, TableNetworkLogs_TableName, ciliumlogs)
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
+	}
+
+	return err
+}
+
+func GetPodNamesPostgres(cfg types.ConfigDB, filter types.ObsPodDetail) ([]string, error) {
+	return getPodNamesSQL(connectPostgres(cfg), filter)
+}
+
+func GetDeployNamesPostgres(cfg types.ConfigDB, filter types.ObsPodDetail) ([]string, error) {
+	return getDeployNamesSQL(connectPostgres(cfg), filter)
+}
+
+// =============== //
+// == Policy DB == //
+// =============== //
+
+func GetPolicyYamlsPostgres(cfg types.ConfigDB, policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	return getPolicyYamlsSQL(connectPostgres(cfg), policyType, filterOptions, page)
+}
+
+func UpdateOrInsertPolicyYamlsPostgres(cfg types.ConfigDB, policies []types.PolicyYaml) error {
+	return updateOrInsertPolicyYamlsSQL(connectPostgres(cfg), policies)
+}
+
+func DeletePolicyBasedOnPolicyNamePostgres(cfg types.ConfigDB, policyName, namespace, labels string) error {
+	return deletePolicyBasedOnPolicyNameSQL(connectPostgres(cfg), policyName, namespace, labels)
+}
+
+// ================ //
+// == Summary DB == //
+// ================ //
+func UpsertSystemSummaryPostgres(cfg types.ConfigDB, sysSummary map[types.SystemSummary]types.SysSummaryTimeCount) error {
+	db := connectPostgres(cfg)
+
+	err := inTx(db.DB, func(tx *sql.Tx) error {
+		return upsertSysSummariesSQL(postgresTx{tx}, TableSystemSummary_TableName, " ON CONFLICT(hash_id) DO UPDATE SET count="+TableSystemSummary_TableName+".count+?,updated_time=?;", sysSummary)
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
+	}
+
+	return err
+}
+
+func GetSystemSummaryPostgres(cfg types.ConfigDB, filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
+	db := connectPostgres(cfg)
+
+	res, err := getSysSummarySQL(db, TableSystemSummary_TableName, filterOptions)
+
+	return res, err
+}
+
+// UpsertSystemSummaryRollupsPostgres adds the counts of the rollups to the counts of the same rollups
+func UpsertSystemSummaryRollupsPostgres(cfg types.ConfigDB, rollups []types.SystemSummaryRollup) error {
+	db := connectPostgres(cfg)
+
+	return inTx(db.DB, func(tx *sql.Tx) error {
+		return upsertSummaryRollupsSQL(postgresTx{tx}, " ON CONFLICT(hash_id) DO UPDATE SET count="+TableSystemSummaryRollup_TableName+".count+?", summaryRollupsMap(rollups))
+	})
+}
+
+func GetSystemSummaryRollupsPostgres(cfg types.ConfigDB, filterOptions types.SystemSummary) ([]types.SystemSummaryRollup, error) {
+	db := connectPostgres(cfg)
+
+	return getSummaryRollupsSQL(db, filterOptions)
+}
+
+// ========================== //
+// == Purge Old DB Entries == //
+// ========================== //
+
+// PurgeOldDBEntriesPostgres downsamples the old summaries and purges the tables by their retention policies
+func PurgeOldDBEntriesPostgres(cfg types.ConfigDB, purge types.ConfigPurgeOldDBEntries) (PurgeReport, error) {
+	db := connectPostgres(cfg)
+	report := newPurgeReport()
+	now := ConvertStrToUnixTime("now")
+
+	if purge.Downsampling.Enable {
+		if err := inTx(db.DB, func(tx *sql.Tx) error {
+			return downsampleSummariesSQL(postgresTx{tx}, "id", " ON CONFLICT(hash_id) DO UPDATE SET count="+TableSystemSummaryRollup_TableName+".count+?", purge.Downsampling, now, report)
+		}); err != nil {
+			return report, err
+		}
+	}
+
+	for _, table := range retentionTables {
+		if err := purgeTableSQL(db, table, purge.Retention[table.name], now, report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
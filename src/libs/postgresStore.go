@@ -0,0 +1,164 @@
+package libs
+
+import (
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// postgresStore is the Store of the PostgreSQL database
+type postgresStore struct {
+	cfg types.ConfigDB
+}
+
+func (s *postgresStore) GetNetworkPolicies(filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
+	return GetNetworkPoliciesFromPostgres(s.cfg, filter, page)
+}
+
+func (s *postgresStore) InsertNetworkPolicies(policies []types.KnoxNetworkPolicy) error {
+	return InsertNetworkPoliciesToPostgres(s.cfg, policies)
+}
+
+func (s *postgresStore) UpdateNetworkPolicy(policy types.KnoxNetworkPolicy) error {
+	return UpdateNetworkPolicyToPostgres(s.cfg, policy)
+}
+
+func (s *postgresStore) UpdateOutdatedNetworkPolicy(outdatedPolicy string, latestPolicy string) error {
+	return UpdateOutdatedNetworkPolicyFromPostgres(s.cfg, outdatedPolicy, latestPolicy)
+}
+
+func (s *postgresStore) GetSystemPolicies(namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error) {
+	return GetSystemPoliciesFromPostgres(s.cfg, namespace, status, page)
+}
+
+func (s *postgresStore) InsertSystemPolicies(policies []types.KnoxSystemPolicy) error {
+	return InsertSystemPoliciesToPostgres(s.cfg, policies)
+}
+
+func (s *postgresStore) UpdateSystemPolicy(policy types.KnoxSystemPolicy) error {
+	return UpdateSystemPolicyToPostgres(s.cfg, policy)
+}
+
+func (s *postgresStore) UpdateOutdatedSystemPolicy(outdatedPolicy string, latestPolicy string) error {
+	return UpdateOutdatedSystemPolicyFromPostgres(s.cfg, outdatedPolicy, latestPolicy)
+}
+
+func (s *postgresStore) GetWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error) {
+	return GetWorkloadProcessFileSetPostgres(s.cfg, wpfs)
+}
+
+func (s *postgresStore) InsertWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, policyName string, fs []string) error {
+	return InsertWorkloadProcessFileSetPostgres(s.cfg, wpfs, policyName, fs)
+}
+
+func (s *postgresStore) UpdateWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, fs []string) error {
+	return UpdateWorkloadProcessFileSetPostgres(s.cfg, wpfs, fs)
+}
+
+func (s *postgresStore) ClearWPFS(wpfs types.WorkloadProcessFileSet, duration int64) error {
+	return ClearWPFSDbPostgres(s.cfg, wpfs, duration)
+}
+
+func (s *postgresStore) GetWorkloadProcessFileSetCreatedTime(wpfs types.WorkloadProcessFileSet) (int64, error) {
+	return GetWorkloadProcessFileSetCreatedTimePostgres(s.cfg, wpfs)
+}
+
+func (s *postgresStore) UpsertSystemAnomaly(anomaly types.SystemAnomaly) (bool, error) {
+	return UpsertSystemAnomalyPostgres(s.cfg, anomaly)
+}
+
+func (s *postgresStore) GetSystemAnomalies(filter types.AnomalyFilter) ([]types.SystemAnomaly, error) {
+	return GetSystemAnomaliesPostgres(s.cfg, filter)
+}
+
+func (s *postgresStore) InsertDeadLetter(deadLetter types.DeadLetter) error {
+	return InsertDeadLetterPostgres(s.cfg, deadLetter)
+}
+
+func (s *postgresStore) GetDeadLetters(filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	return GetDeadLettersPostgres(s.cfg, filter)
+}
+
+func (s *postgresStore) DeleteDeadLetters(ids []int64) error {
+	return DeleteDeadLettersPostgres(s.cfg, ids)
+}
+
+func (s *postgresStore) UpdateOrInsertKubearmorLogs(kubearmorLogMap map[types.KubeArmorLog]int) error {
+	return UpdateOrInsertKubearmorLogsPostgres(s.cfg, kubearmorLogMap)
+}
+
+func (s *postgresStore) GetKubearmorLogs(filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
+	return GetSystemLogsPostgres(s.cfg, filterLog)
+}
+
+func (s *postgresStore) UpdateOrInsertCiliumLogs(ciliumLogs []types.CiliumLog) error {
+	return UpdateOrInsertCiliumLogsPostgres(s.cfg, ciliumLogs)
+}
+
+func (s *postgresStore) GetCiliumLogs(ciliumFilter types.CiliumLog) ([]types.CiliumLog, []uint32, error) {
+	return GetCiliumLogsPostgres(s.cfg, ciliumFilter)
+}
+
+func (s *postgresStore) GetCiliumLogsUpdatedAfter(updatedTime int64) ([]types.CiliumLog, []uint32, error) {
+	return GetCiliumLogsUpdatedAfterPostgres(s.cfg, updatedTime)
+}
+
+func (s *postgresStore) GetPodNames(filter types.ObsPodDetail) ([]string, error) {
+	return GetPodNamesPostgres(s.cfg, filter)
+}
+
+func (s *postgresStore) GetDeployNames(filter types.ObsPodDetail) ([]string, error) {
+	return GetDeployNamesPostgres(s.cfg, filter)
+}
+
+func (s *postgresStore) GetPolicyYamls(policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	return GetPolicyYamlsPostgres(s.cfg, policyType, filterOptions, page)
+}
+
+func (s *postgresStore) UpdateOrInsertPolicyYamls(policies []types.PolicyYaml) error {
+	return UpdateOrInsertPolicyYamlsPostgres(s.cfg, policies)
+}
+
+func (s *postgresStore) DeletePolicyBasedOnPolicyName(policyName, namespace, labels string) error {
+	return DeletePolicyBasedOnPolicyNamePostgres(s.cfg, policyName, namespace, labels)
+}
+
+func (s *postgresStore) UpsertSystemSummary(summaryMap map[types.SystemSummary]types.SysSummaryTimeCount) error {
+	return UpsertSystemSummaryPostgres(s.cfg, summaryMap)
+}
+
+func (s *postgresStore) GetSystemSummary(filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
+	return GetSystemSummaryPostgres(s.cfg, filterOptions)
+}
+
+func (s *postgresStore) UpsertSystemSummaryRollups(rollups []types.SystemSummaryRollup) error {
+	return UpsertSystemSummaryRollupsPostgres(s.cfg, rollups)
+}
+
+func (s *postgresStore) GetSystemSummaryRollups(filterOptions types.SystemSummary) ([]types.SystemSummaryRollup, error) {
+	return GetSystemSummaryRollupsPostgres(s.cfg, filterOptions)
+}
+
+func (s *postgresStore) ClearDBTables() error {
+	return ClearDBTablesPostgres(s.cfg)
+}
+
+func (s *postgresStore) ClearNetworkDBTable() error {
+	return ClearNetworkDBTablePostgres(s.cfg)
+}
+
+func (s *postgresStore) ClearPolicyYamlDBTable() error {
+	return ClearPolicyYamlDBTablePostgres(s.cfg)
+}
+
+func (s *postgresStore) ClearSystemSummaryDBTables() error {
+	return ClearSystemSummaryDBTablesPostgres(s.cfg)
+}
+
+func (s *postgresStore) Migrate() error {
+	db := connectPostgres(s.cfg)
+
+	return migrateSchema(db.DB, SchemaPostgres)
+}
+
+func (s *postgresStore) PurgeOldDBEntries(purge types.ConfigPurgeOldDBEntries) (PurgeReport, error) {
+	return PurgeOldDBEntriesPostgres(s.cfg, purge)
+}
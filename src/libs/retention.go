@@ -0,0 +1,349 @@
+package libs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// =============== //
+// == Retention == //
+// =============== //
+
+const TableSystemSummaryRollup_TableName = "system_summary_rollup"
+
+// the granularities of the summary rollups
+const (
+	RollupHourly = "hourly"
+	RollupDaily  = "daily"
+)
+
+// PurgeReport is the number of rows a purge run removed from each table
+type PurgeReport struct {
+	Purged      map[string]int64 // deleted by the retention policy of the table
+	Downsampled map[string]int64 // rolled up into the rollups of a coarser granularity
+	Compacted   map[string]int64 // merged into the other records of their logs by the log store
+}
+
+func newPurgeReport() PurgeReport {
+	return PurgeReport{
+		Purged:      map[string]int64{},
+		Downsampled: map[string]int64{},
+		Compacted:   map[string]int64{},
+	}
+}
+
+// sortedTables returns the tables of the counts of a report in order
+func sortedTables(counts map[string]int64) []string {
+	tables := []string{}
+	for table := range counts {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	return tables
+}
+
+// retentionTable is a table purged by its retention policy, by the age of timeColumn
+type retentionTable struct {
+	name       string
+	timeColumn string
+}
+
+// retentionTables are the tables purged by the retention policies, the summaries are
+// downsampled before their retention policy deletes them
+var retentionTables = []retentionTable{
+	{TableNetworkLogs_TableName, "updated_time"},
+	{TableSystemLogs_TableName, "updated_time"},
+	{WorkloadProcessFileSet_TableName, "updatedTime"},
+	{TableSystemSummary_TableName, "updated_time"},
+	{TableSystemSummaryRollup_TableName, "bucket_time"},
+}
+
+// purgeTableSQL deletes the rows older than the max age and the rows past the max rows newest,
+// the rows as old as the oldest kept row are kept as well
+func purgeTableSQL(db sqlDB, table retentionTable, retention types.ConfigRetention, now int64, report PurgeReport) error {
+	if retention.MaxAge > 0 {
+		res, err := db.Exec("DELETE FROM "+table.name+" WHERE "+table.timeColumn+" < ?", now-retention.MaxAge)
+		if err != nil {
+			return err
+		}
+		purged, _ := res.RowsAffected()
+		report.Purged[table.name] += purged
+	}
+
+	if retention.MaxRows > 0 {
+		results, err := db.Query("SELECT "+table.timeColumn+" FROM "+table.name+" ORDER BY "+table.timeColumn+" DESC LIMIT 1 OFFSET ?", retention.MaxRows-1)
+		if err != nil {
+			return err
+		}
+
+		var oldest int64
+		found := results.Next()
+		if found {
+			if err := results.Scan(&oldest); err != nil {
+				results.Close()
+				return err
+			}
+		}
+		results.Close()
+		if err := results.Err(); err != nil {
+			return err
+		}
+
+		if found {
+			res, err := db.Exec("DELETE FROM "+table.name+" WHERE "+table.timeColumn+" < ?", oldest)
+			if err != nil {
+				return err
+			}
+			purged, _ := res.RowsAffected()
+			report.Purged[table.name] += purged
+		}
+	}
+
+	return nil
+}
+
+// ================== //
+// == Downsampling == //
+// ================== //
+
+// summaryRollup is the key of a rollup, the summaries of the pods of a workload in the
+// bucket of the granularity fall in the same rollup
+type summaryRollup struct {
+	Granularity string
+	BucketTime  int64
+	Summary     types.SystemSummary
+}
+
+// rollupColumns are the columns of the summaries kept by the rollups
+const rollupColumns = `cluster_name,cluster_id,workspace_id,namespace_name,namespace_id,container_name,operation,labels,deployment_name,
+	source,destination,destination_namespace,destination_labels,type,ip,port,protocol,bindport,bindaddr,action`
+
+func rollupFields(summary *types.SystemSummary) []interface{} {
+	return []interface{}{
+		&summary.ClusterName,
+		&summary.ClusterId,
+		&summary.WorkspaceId,
+		&summary.NamespaceName,
+		&summary.NamespaceId,
+		&summary.ContainerName,
+		&summary.Operation,
+		&summary.Labels,
+		&summary.Deployment,
+		&summary.Source,
+		&summary.Destination,
+		&summary.DestNamespace,
+		&summary.DestLabels,
+		&summary.NwType,
+		&summary.IP,
+		&summary.Port,
+		&summary.Protocol,
+		&summary.BindPort,
+		&summary.BindAddress,
+		&summary.Action,
+	}
+}
+
+func rollupBucket(granularity string, t int64) int64 {
+	size := int64(3600)
+	if granularity == RollupDaily {
+		size = 24 * 3600
+	}
+	return t - t%size
+}
+
+// newSummaryRollup returns the rollup of the granularity a summary falls in
+func newSummaryRollup(granularity string, summary types.SystemSummary) summaryRollup {
+	bucket := rollupBucket(granularity, summary.UpdatedTime)
+
+	summary.ContainerImage = ""
+	summary.ContainerID = ""
+	summary.PodName = ""
+	summary.PodId = 0
+	summary.Count = 0
+	summary.UpdatedTime = 0
+
+	return summaryRollup{Granularity: granularity, BucketTime: bucket, Summary: summary}
+}
+
+func hashSummaryRollup(rollup *summaryRollup) string {
+	h := sha256.New()
+	h.Write([]byte(rollup.Granularity + strconv.FormatInt(rollup.BucketTime, 10) + HashSystemSummary(&rollup.Summary)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// downsampleSummariesSQL rolls the summaries older than hourly after up into hourly rollups,
+// and the hourly rollups older than daily after up into daily rollups
+func downsampleSummariesSQL(db sqlDB, key, upsertClause string, downsampling types.ConfigDownsampling, now int64, report PurgeReport) error {
+	if downsampling.HourlyAfter > 0 {
+		query := "SELECT " + key + "," + rollupColumns + ",count,updated_time FROM " + TableSystemSummary_TableName + " WHERE updated_time < ?"
+		args := []interface{}{now - downsampling.HourlyAfter}
+		if err := downsampleSQL(db, query, args, TableSystemSummary_TableName, key, upsertClause, RollupHourly, report); err != nil {
+			return err
+		}
+	}
+
+	if downsampling.DailyAfter > 0 {
+		query := "SELECT " + key + "," + rollupColumns + ",count,bucket_time FROM " + TableSystemSummaryRollup_TableName + " WHERE granularity = ? and bucket_time < ?"
+		args := []interface{}{RollupHourly, now - downsampling.DailyAfter}
+		if err := downsampleSQL(db, query, args, TableSystemSummaryRollup_TableName, key, upsertClause, RollupDaily, report); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downsampleSQL upserts the rows of the query into the rollups of the granularity and deletes them
+func downsampleSQL(db sqlDB, query string, args []interface{}, tableName, key, upsertClause, granularity string, report PurgeReport) error {
+	results, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rollups := map[summaryRollup]int64{}
+	ids := []int64{}
+	for results.Next() {
+		var id, count int64
+		summary := types.SystemSummary{}
+
+		dest := append([]interface{}{&id}, rollupFields(&summary)...)
+		if err := results.Scan(append(dest, &count, &summary.UpdatedTime)...); err != nil {
+			results.Close()
+			return err
+		}
+
+		rollups[newSummaryRollup(granularity, summary)] += count
+		ids = append(ids, id)
+	}
+	results.Close()
+	if err := results.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := upsertSummaryRollupsSQL(db, upsertClause, rollups); err != nil {
+		return err
+	}
+
+	deleteStmt, err := db.Prepare("DELETE FROM " + tableName + " WHERE " + key + " = ?")
+	if err != nil {
+		return err
+	}
+	defer deleteStmt.Close()
+
+	for _, id := range ids {
+		if _, err := deleteStmt.Exec(id); err != nil {
+			return err
+		}
+	}
+	report.Downsampled[tableName] += int64(len(ids))
+
+	return nil
+}
+
+// upsertSummaryRollupsSQL adds the counts to the counts of the rollups,
+// upsertClause is the driver specific update of the existing ones
+func upsertSummaryRollupsSQL(db sqlDB, upsertClause string, rollups map[summaryRollup]int64) error {
+	upsertStmt, err := db.Prepare("INSERT INTO " + TableSystemSummaryRollup_TableName + "(granularity,bucket_time," + rollupColumns +
+		",count,hash_id) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)" + upsertClause)
+	if err != nil {
+		return err
+	}
+	defer upsertStmt.Close()
+
+	for rollup, count := range rollups {
+		rollup := rollup
+		args := append([]interface{}{rollup.Granularity, rollup.BucketTime}, rollupFields(&rollup.Summary)...)
+		if _, err := upsertStmt.Exec(append(args, count, hashSummaryRollup(&rollup), count)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// summaryRollupsMap returns the counts of the rollups by their keys, the counts of the same
+// rollups add up
+func summaryRollupsMap(rollups []types.SystemSummaryRollup) map[summaryRollup]int64 {
+	rollupMap := map[summaryRollup]int64{}
+	for _, rollup := range rollups {
+		rollupMap[newSummaryRollup(rollup.Granularity, rollupSummary(rollup))] += rollup.Count
+	}
+
+	return rollupMap
+}
+
+// ===================== //
+// == Serving Rollups == //
+// ===================== //
+
+// getSummaryRollupsSQL returns the rollups of the summaries of the filter, the rollups keep no
+// pod so none of them is of a filter on the pod or the container of a pod
+func getSummaryRollupsSQL(db sqlDB, filterOptions types.SystemSummary) ([]types.SystemSummaryRollup, error) {
+	rollups := []types.SystemSummaryRollup{}
+	if filterOptions.PodName != "" || filterOptions.ContainerImage != "" || filterOptions.ContainerID != "" {
+		return rollups, nil
+	}
+
+	var whereClause string
+	var args []interface{}
+
+	filters := []struct {
+		column string
+		value  interface{}
+		set    bool
+	}{
+		{"cluster_name", filterOptions.ClusterName, filterOptions.ClusterName != ""},
+		{"cluster_id", filterOptions.ClusterId, filterOptions.ClusterId != 0},
+		{"workspace_id", filterOptions.WorkspaceId, filterOptions.WorkspaceId != 0},
+		{"namespace_name", filterOptions.NamespaceName, filterOptions.NamespaceName != ""},
+		{"namespace_id", filterOptions.NamespaceId, filterOptions.NamespaceId != 0},
+		{"container_name", filterOptions.ContainerName, filterOptions.ContainerName != ""},
+		{"operation", filterOptions.Operation, filterOptions.Operation != ""},
+		{"labels", filterOptions.Labels, filterOptions.Labels != ""},
+		{"deployment_name", filterOptions.Deployment, filterOptions.Deployment != ""},
+		{"source", filterOptions.Source, filterOptions.Source != ""},
+		{"destination", filterOptions.Destination, filterOptions.Destination != ""},
+	}
+	for _, filter := range filters {
+		if filter.set {
+			concatWhereClause(&whereClause, filter.column)
+			args = append(args, filter.value)
+		}
+	}
+
+	results, err := db.Query("SELECT granularity,bucket_time,"+rollupColumns+",count FROM "+TableSystemSummaryRollup_TableName+
+		whereClause+" ORDER BY bucket_time", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		rollup := types.SystemSummaryRollup{}
+		dest := append([]interface{}{&rollup.Granularity, &rollup.BucketTime}, rollupFields(&rollup.Summary)...)
+		if err := results.Scan(append(dest, &rollup.Count)...); err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, rollup)
+	}
+
+	return rollups, results.Err()
+}
+
+// rollupSummary returns a rollup as the summary of its bucket
+func rollupSummary(rollup types.SystemSummaryRollup) types.SystemSummary {
+	summary := rollup.Summary
+	summary.Count = int32(rollup.Count)
+	summary.UpdatedTime = rollup.BucketTime
+
+	return summary
+}
@@ -0,0 +1,51 @@
+package libs
+
+import (
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummaryRollup(t *testing.T) {
+	summary := types.SystemSummary{NamespaceName: "ns-a", Deployment: "deploy-a", Operation: "Process", Source: "/bin/sh", Count: 2, UpdatedTime: 7200 + 10}
+	other := summary
+	other.PodName, other.ContainerID, other.Count, other.UpdatedTime = "pod-b", "container-b", 3, 7200+3599
+
+	// the pods of the workload in the hour fall in the same rollup
+	rollup := newSummaryRollup(RollupHourly, summary)
+	assert.Equal(t, rollup, newSummaryRollup(RollupHourly, other))
+	assert.Equal(t, int64(7200), rollup.BucketTime)
+	assert.Equal(t, hashSummaryRollup(&rollup), hashSummaryRollup(&rollup))
+
+	other.UpdatedTime = 7200 + 3600
+	assert.NotEqual(t, rollup, newSummaryRollup(RollupHourly, other))
+
+	daily := newSummaryRollup(RollupDaily, other)
+	assert.Equal(t, int64(0), daily.BucketTime)
+	assert.NotEqual(t, hashSummaryRollup(&rollup), hashSummaryRollup(&daily))
+}
+
+func TestSummaryRollupsMap(t *testing.T) {
+	summary := types.SystemSummary{NamespaceName: "ns-a", Deployment: "deploy-a", Operation: "Process", Source: "/bin/sh"}
+	rollups := []types.SystemSummaryRollup{
+		{Granularity: RollupHourly, BucketTime: 7200, Summary: summary, Count: 2},
+		{Granularity: RollupHourly, BucketTime: 7200, Summary: summary, Count: 3},
+		{Granularity: RollupDaily, BucketTime: 0, Summary: summary, Count: 4},
+	}
+
+	// the counts of the same rollups add up
+	rollupMap := summaryRollupsMap(rollups)
+	assert.Len(t, rollupMap, 2)
+	assert.Equal(t, int64(5), rollupMap[newSummaryRollup(RollupHourly, rollupSummary(rollups[0]))])
+
+	// a rollup is served as the summary of its bucket
+	served := rollupSummary(rollups[2])
+	assert.Equal(t, int32(4), served.Count)
+	assert.Equal(t, int64(0), served.UpdatedTime)
+	assert.Equal(t, "deploy-a", served.Deployment)
+}
+
+func TestSortedTables(t *testing.T) {
+	assert.Equal(t, []string{"network_logs", "system_logs"}, sortedTables(map[string]int64{"system_logs": 1, "network_logs": 2}))
+}
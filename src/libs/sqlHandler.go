@@ -0,0 +1,1553 @@
+package libs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// The queries shared by the mysql and the postgres drivers, run on the connection or the
+// transaction of the driver. postgresDB and postgresTx rebind the placeholders for postgres.
+
+// jsonArg is the argument of a JSON column, postgres takes the documents as text
+func jsonArg(db sqlDB, doc []byte) interface{} {
+	switch db.(type) {
+	case postgresDB, postgresTx:
+		return string(doc)
+	}
+	return doc
+}
+
+// ==================== //
+// == Network Policy == //
+// ==================== //
+
+func getNetworkPoliciesSQL(db sqlDB, filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
+	policies := []types.KnoxNetworkPolicy{}
+	ids := []int64{}
+	var results *sql.Rows
+
+	query := "SELECT id,apiVersion,kind,flow_ids,name,cluster_name,namespace,type,rule,status,outdated,spec,generatedTime,updatedTime FROM " + TableNetworkPolicy_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	if filter.Cluster != "" {
+		concatWhereClause(&whereClause, "cluster_name")
+		args = append(args, filter.Cluster)
+	}
+	if filter.Namespace != "" {
+		concatWhereClause(&whereClause, "namespace")
+		args = append(args, filter.Namespace)
+	}
+	if filter.Status != "" {
+		concatWhereClause(&whereClause, "status")
+		args = append(args, filter.Status)
+	}
+	if filter.Type != "" {
+		concatWhereClause(&whereClause, "type")
+		args = append(args, filter.Type)
+	}
+	if filter.Rule != "" {
+		concatWhereClause(&whereClause, "rule")
+		args = append(args, filter.Rule)
+	}
+	if len(filter.Selector) > 0 {
+		concatWhereClause(&whereClause, "selector_hash")
+		args = append(args, HashSelector(filter.Selector))
+	}
+
+	order, err := concatPageClause(&whereClause, &args, "id", page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err = db.Query(query+whereClause+order, args...)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, "", err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		policy := types.KnoxNetworkPolicy{}
+
+		var id int64
+		var name, clusterName, namespace, policyType, rule, status string
+		specByte := []byte{}
+		spec := types.Spec{}
+
+		flowIDsByte := []byte{}
+		flowIDs := []int{}
+
+		if err := results.Scan(
+			&id,
+			&policy.APIVersion,
+			&policy.Kind,
+			&flowIDsByte,
+			&name,
+			&clusterName,
+			&namespace,
+			&policyType,
+			&rule,
+			&status,
+			&policy.Outdated,
+			&specByte,
+			&policy.GeneratedTime,
+			&policy.UpdatedTime,
+		); err != nil {
+			return nil, "", err
+		}
+
+		if err := json.Unmarshal(specByte, &spec); err != nil {
+			return nil, "", err
+		}
+
+		if err := json.Unmarshal(flowIDsByte, &flowIDs); err != nil {
+			return nil, "", err
+		}
+
+		policy.Metadata = map[string]string{
+			"name":         name,
+			"cluster_name": clusterName,
+			"namespace":    namespace,
+			"type":         policyType,
+			"rule":         rule,
+			"status":       status,
+		}
+
+		policy.FlowIDs = flowIDs
+		policy.Spec = spec
+
+		policies = append(policies, policy)
+		ids = append(ids, id)
+	}
+
+	policies, next := cutPage(page, policies, ids)
+	return policies, next, nil
+}
+
+func updateNetworkPolicySQL(db sqlDB, policy types.KnoxNetworkPolicy) error {
+	// set status -> outdated
+	stmt, err := db.Prepare("UPDATE " + TableNetworkPolicy_TableName +
+		" SET apiVersion=?,kind=?,cluster_name=?,namespace=?,type=?,status=?,outdated=?,spec=?,selector_hash=?,updatedTime=? WHERE name = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	specPointer := &policy.Spec
+	spec, err := json.Marshal(specPointer)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(
+		policy.APIVersion,
+		policy.Kind,
+		policy.Metadata["cluster_name"],
+		policy.Metadata["namespace"],
+		policy.Metadata["type"],
+		policy.Metadata["status"],
+		policy.Outdated,
+		jsonArg(db, spec),
+		HashSelector(policy.Spec.Selector.MatchLabels),
+		ConvertStrToUnixTime("now"),
+		policy.Metadata["name"])
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func updateOutdatedNetworkPolicySQL(db sqlDB, outdatedPolicy string, latestPolicy string) error {
+	var err error
+
+	// set status -> outdated
+	stmt1, err := db.Prepare("UPDATE " + TableNetworkPolicy_TableName + " SET status=? WHERE name=?")
+	if err != nil {
+		return err
+	}
+	defer stmt1.Close()
+
+	_, err = stmt1.Exec("outdated", outdatedPolicy)
+	if err != nil {
+		return err
+	}
+
+	// set outdated -> latest' name
+	stmt2, err := db.Prepare("UPDATE " + TableNetworkPolicy_TableName + " SET outdated=? WHERE name=?")
+	if err != nil {
+		return err
+	}
+	defer stmt2.Close()
+
+	_, err = stmt2.Exec(latestPolicy, outdatedPolicy)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func insertNetworkPolicySQL(db sqlDB, policy types.KnoxNetworkPolicy) error {
+	stmt, err := db.Prepare("INSERT INTO " + TableNetworkPolicy_TableName + "(apiVersion,kind,flow_ids,name,cluster_name,namespace,type,rule,status,outdated,spec,selector_hash,generatedTime,updatedTime) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	flowIDsPointer := &policy.FlowIDs
+	flowids, err := json.Marshal(flowIDsPointer)
+	if err != nil {
+		return err
+	}
+
+	specPointer := &policy.Spec
+	spec, err := json.Marshal(specPointer)
+	if err != nil {
+		return err
+	}
+
+	currTime := ConvertStrToUnixTime("now")
+
+	_, err = stmt.Exec(policy.APIVersion,
+		policy.Kind,
+		jsonArg(db, flowids),
+		policy.Metadata["name"],
+		policy.Metadata["cluster_name"],
+		policy.Metadata["namespace"],
+		policy.Metadata["type"],
+		policy.Metadata["rule"],
+		policy.Metadata["status"],
+		policy.Outdated,
+		jsonArg(db, spec),
+		HashSelector(policy.Spec.Selector.MatchLabels),
+		currTime,
+		currTime)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func insertNetworkPoliciesSQL(db sqlDB, policies []types.KnoxNetworkPolicy) error {
+	for _, policy := range policies {
+		if err := insertNetworkPolicySQL(db, policy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// =================== //
+// == System Policy == //
+// =================== //
+
+func updateOutdatedSystemPolicySQL(db sqlDB, outdatedPolicy string, latestPolicy string) error {
+	var err error
+
+	// set status -> outdated
+	stmt1, err := db.Prepare("UPDATE " + TableSystemPolicy_TableName + " SET status=? WHERE name=?")
+	if err != nil {
+		return err
+	}
+	defer stmt1.Close()
+
+	_, err = stmt1.Exec("outdated", outdatedPolicy)
+	if err != nil {
+		return err
+	}
+
+	// set outdated -> latest' name
+	stmt2, err := db.Prepare("UPDATE " + TableNetworkPolicy_TableName + " SET outdated=? WHERE name=?")
+	if err != nil {
+		return err
+	}
+	defer stmt2.Close()
+
+	_, err = stmt2.Exec(latestPolicy, outdatedPolicy)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func getSystemPoliciesSQL(db sqlDB, namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error) {
+	policies := []types.KnoxSystemPolicy{}
+	ids := []int64{}
+	var results *sql.Rows
+
+	query := "SELECT id,apiVersion,kind,name,clusterName,namespace,type,status,outdated,spec,generatedTime,updatedTime,latest FROM " + TableSystemPolicy_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	if namespace != "" {
+		concatWhereClause(&whereClause, "namespace")
+		args = append(args, namespace)
+	}
+	if status != "" {
+		concatWhereClause(&whereClause, "status")
+		args = append(args, status)
+	}
+
+	order, err := concatPageClause(&whereClause, &args, "id", page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err = db.Query(query+whereClause+order, args...)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, "", err
+	}
+
+	defer results.Close()
+
+	for results.Next() {
+		policy := types.KnoxSystemPolicy{}
+
+		var id int64
+		var name, clusterName, namespace, policyType, status string
+		specByte := []byte{}
+		spec := types.KnoxSystemSpec{}
+
+		if err := results.Scan(
+			&id,
+			&policy.APIVersion,
+			&policy.Kind,
+			&name,
+			&clusterName,
+			&namespace,
+			&policyType,
+			&status,
+			&policy.Outdated,
+			&specByte,
+			&policy.GeneratedTime,
+			&policy.UpdatedTime,
+			&policy.Latest,
+		); err != nil {
+			return nil, "", err
+		}
+
+		if err := json.Unmarshal(specByte, &spec); err != nil {
+			return nil, "", err
+		}
+
+		policy.Metadata = map[string]string{
+			"name":        name,
+			"clusterName": clusterName,
+			"namespace":   namespace,
+			"type":        policyType,
+			"status":      status,
+		}
+
+		policy.Spec = spec
+
+		policies = append(policies, policy)
+		ids = append(ids, id)
+	}
+
+	policies, next := cutPage(page, policies, ids)
+	return policies, next, nil
+}
+
+func insertSystemPolicySQL(db sqlDB, policy types.KnoxSystemPolicy) error {
+	stmt, err := db.Prepare("INSERT INTO " + TableSystemPolicy_TableName + "(apiVersion,kind,name,clusterName,namespace,type,status,outdated,spec,generatedTime,updatedTime,latest) values(?,?,?,?,?,?,?,?,?,?,?,?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	specPointer := &policy.Spec
+	spec, err := json.Marshal(specPointer)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(
+		policy.APIVersion,
+		policy.Kind,
+		policy.Metadata["name"],
+		policy.Metadata["clusterName"],
+		policy.Metadata["namespace"],
+		policy.Metadata["type"],
+		policy.Metadata["status"],
+		policy.Outdated,
+		jsonArg(db, spec),
+		ConvertStrToUnixTime("now"),
+		ConvertStrToUnixTime("now"),
+		true)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func insertSystemPoliciesSQL(db sqlDB, policies []types.KnoxSystemPolicy) error {
+	for _, policy := range policies {
+		if err := insertSystemPolicySQL(db, policy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func updateSystemPolicySQL(db sqlDB, policy types.KnoxSystemPolicy) error {
+	// set status -> outdated
+	stmt, err := db.Prepare("UPDATE " + TableSystemPolicy_TableName +
+		" SET apiVersion=?,kind=?,clusterName=?,namespace=?,type=?,status=?,outdated=?,spec=?,updatedTime=?,latest=? WHERE name = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	specPointer := &policy.Spec
+	spec, err := json.Marshal(specPointer)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(
+		policy.APIVersion,
+		policy.Kind,
+		policy.Metadata["clusterName"],
+		policy.Metadata["namespace"],
+		policy.Metadata["type"],
+		policy.Metadata["status"],
+		policy.Outdated,
+		jsonArg(db, spec),
+		ConvertStrToUnixTime("now"),
+		true,
+		policy.Metadata["name"])
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// =========== //
+// == Table == //
+// =========== //
+
+func clearNetworkDBTableSQL(db sqlDB) error {
+	query := "DELETE FROM " + TableNetworkPolicy_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func clearPolicyYamlDBTableSQL(db sqlDB) error {
+	query := "DELETE FROM " + PolicyYaml_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// clearSystemSummaryDBTablesSQL clears the summaries and the rollups they were downsampled into
+func clearSystemSummaryDBTablesSQL(db sqlDB) error {
+	query := "DELETE FROM " + TableSystemSummary_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	query = "DELETE FROM " + TableSystemSummaryRollup_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func clearDBTablesSQL(db sqlDB) error {
+	query := "DELETE FROM " + TableNetworkPolicy_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	query = "DELETE FROM " + TableSystemPolicy_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	query = "DELETE FROM " + WorkloadProcessFileSet_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func concatWhereClause(whereClause *string, field string) {
+	if *whereClause == "" {
+		*whereClause = " WHERE "
+	} else {
+		*whereClause = *whereClause + " and "
+	}
+	*whereClause = *whereClause + field + " = ?"
+}
+
+// concatWhereClauseIn adds a placeholder for each of the count values of field
+func concatWhereClauseIn(whereClause *string, field string, count int) {
+	if *whereClause == "" {
+		*whereClause = " WHERE "
+	} else {
+		*whereClause = *whereClause + " and "
+	}
+	*whereClause = *whereClause + field + " IN (" + strings.TrimSuffix(strings.Repeat("?,", count), ",") + ")"
+}
+
+// concatWhereClauseIntRange adds the placeholders of the bounds of the range of field
+func concatWhereClauseIntRange(whereClause *string, field string) {
+	if *whereClause == "" {
+		*whereClause = " WHERE "
+	} else {
+		*whereClause = *whereClause + " and "
+	}
+	*whereClause = *whereClause + field + " between ? and ?"
+}
+
+// concatWhereClauseAfter adds the placeholder of the lower bound, excluded, of field
+func concatWhereClauseAfter(whereClause *string, field string) {
+	if *whereClause == "" {
+		*whereClause = " WHERE "
+	} else {
+		*whereClause = *whereClause + " and "
+	}
+	*whereClause = *whereClause + field + " > ?"
+}
+
+// =============================== //
+// == Workload Process File Set == //
+// =============================== //
+
+// getWorkloadProcessFileSetSQL Handle File Sets in context to a given fromSource
+func getWorkloadProcessFileSetSQL(db sqlDB, wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error) {
+	var results *sql.Rows
+	var err error
+
+	query := "SELECT policyName,clusterName,namespace,containerName,labels,fromSource,settype,fileset FROM " + WorkloadProcessFileSet_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	if wpfs.ClusterName != "" {
+		concatWhereClause(&whereClause, "clusterName")
+		args = append(args, wpfs.ClusterName)
+	}
+	if wpfs.Namespace != "" {
+		concatWhereClause(&whereClause, "namespace")
+		args = append(args, wpfs.Namespace)
+	}
+	if wpfs.ContainerName != "" {
+		concatWhereClause(&whereClause, "containerName")
+		args = append(args, wpfs.ContainerName)
+	}
+	if wpfs.Labels != "" {
+		concatWhereClause(&whereClause, "labels")
+		args = append(args, wpfs.Labels)
+	}
+	if wpfs.FromSource != "" {
+		concatWhereClause(&whereClause, "fromSource")
+		args = append(args, wpfs.FromSource)
+	}
+	if wpfs.SetType != "" {
+		concatWhereClause(&whereClause, "settype")
+		args = append(args, wpfs.SetType)
+	}
+
+	results, err = db.Query(query+whereClause, args...)
+
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, nil, err
+	}
+	defer results.Close()
+
+	var loc_wpfs types.WorkloadProcessFileSet
+	res := types.ResourceSetMap{}
+	pnMap := types.PolicyNameMap{}
+
+	for results.Next() {
+		var fscsv string
+		var fs []string
+		var policyName string
+
+		if err := results.Scan(
+			&policyName,
+			&loc_wpfs.ClusterName,
+			&loc_wpfs.Namespace,
+			&loc_wpfs.ContainerName,
+			&loc_wpfs.Labels,
+			&loc_wpfs.FromSource,
+			&loc_wpfs.SetType,
+			&fscsv,
+		); err != nil {
+			return nil, nil, err
+		}
+		fs = strings.Split(fscsv, types.RecordSeparator)
+		res[loc_wpfs] = fs
+		pnMap[loc_wpfs] = policyName
+	}
+
+	return res, pnMap, nil
+}
+
+// insertWorkloadProcessFileSetSQL inserts a file set under the policy name, a new one if empty
+func insertWorkloadProcessFileSetSQL(db sqlDB, wpfs types.WorkloadProcessFileSet, policyName string, fs []string) error {
+	if policyName == "" {
+		policyName = "autopol-" + strings.ToLower(wpfs.SetType) + "-" + RandSeq(15)
+	}
+	time := ConvertStrToUnixTime("now")
+
+	stmt, err := db.Prepare("INSERT INTO " + WorkloadProcessFileSet_TableName +
+		"(policyName,clusterName,namespace,containerName,labels,fromSource,settype,fileset,createdtime,updatedtime) values(?,?,?,?,?,?,?,?,?,?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	fsset := strings.Join(fs[:], types.RecordSeparator)
+
+	_, err = stmt.Exec(
+		policyName,
+		wpfs.ClusterName,
+		wpfs.Namespace,
+		wpfs.ContainerName,
+		wpfs.Labels,
+		wpfs.FromSource,
+		wpfs.SetType,
+		fsset,
+		time,
+		time)
+	return err
+}
+
+// Clears out WPFS DB on full or as per options specified
+func clearWPFSDbSQL(db sqlDB, wpfs types.WorkloadProcessFileSet, duration int64) error {
+	var err error
+
+	query := "DELETE FROM " + WorkloadProcessFileSet_TableName
+
+	var whereClause string
+	var args []interface{}
+	time := ConvertStrToUnixTime("now")
+
+	if wpfs.ClusterName != "" {
+		concatWhereClause(&whereClause, "clusterName")
+		args = append(args, wpfs.ClusterName)
+	}
+	if wpfs.Namespace != "" {
+		concatWhereClause(&whereClause, "namespace")
+		args = append(args, wpfs.Namespace)
+	}
+	if wpfs.ContainerName != "" {
+		concatWhereClause(&whereClause, "containerName")
+		args = append(args, wpfs.ContainerName)
+	}
+	if wpfs.Labels != "" {
+		concatWhereClause(&whereClause, "labels")
+		args = append(args, wpfs.Labels)
+	}
+	if wpfs.FromSource != "" {
+		concatWhereClause(&whereClause, "fromSource")
+		args = append(args, wpfs.FromSource)
+	}
+	if duration != 0 {
+		concatWhereClauseIntRange(&whereClause, "createdtime")
+		args = append(args, time-duration, time)
+	}
+
+	_, err = db.Exec(query+whereClause, args...)
+
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return err
+	}
+	return err
+}
+
+func updateWorkloadProcessFileSetSQL(db sqlDB, wpfs types.WorkloadProcessFileSet, fs []string) error {
+	var err error
+	time := ConvertStrToUnixTime("now")
+
+	// set status -> outdated
+	stmt, err := db.Prepare("UPDATE " + WorkloadProcessFileSet_TableName +
+		" SET fileset=?,updatedtime=? WHERE clusterName = ? and containerName = ? and namespace = ? and labels = ? and fromSource = ? and settype = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	fsset := strings.Join(fs[:], types.RecordSeparator)
+
+	_, err = stmt.Exec(fsset,
+		time,
+		wpfs.ClusterName,
+		wpfs.ContainerName,
+		wpfs.Namespace,
+		wpfs.Labels,
+		wpfs.FromSource,
+		wpfs.SetType)
+
+	/*
+		a, err := res.RowsAffected()
+		if err == nil {
+			log.Info().Msgf("UPDATE rows affected:%d", a)
+		}
+	*/
+	return err
+}
+
+// getWorkloadProcessFileSetCreatedTimeSQL returns the creation time of the WPFS entry
+func getWorkloadProcessFileSetCreatedTimeSQL(db sqlDB, wpfs types.WorkloadProcessFileSet) (int64, error) {
+	var createdTime int64
+	err := db.QueryRow("SELECT createdTime FROM "+WorkloadProcessFileSet_TableName+
+		" WHERE clusterName = ? and containerName = ? and namespace = ? and labels = ? and fromSource = ? and settype = ?",
+		wpfs.ClusterName,
+		wpfs.ContainerName,
+		wpfs.Namespace,
+		wpfs.Labels,
+		wpfs.FromSource,
+		wpfs.SetType).Scan(&createdTime)
+	if err != nil {
+		return 0, err
+	}
+
+	return createdTime, nil
+}
+
+// ==================== //
+// == System Anomaly == //
+// ==================== //
+
+// upsertSystemAnomalySQL inserts a new anomaly or updates the count of an existing one,
+// returns true if the anomaly was not seen before
+func upsertSystemAnomalySQL(db sqlDB, anomaly types.SystemAnomaly) (bool, error) {
+	keyClause := " WHERE clusterName = ? and namespace = ? and containerName = ? and labels = ? and fromSource = ? and operation = ? and resource = ?"
+	keyArgs := []interface{}{
+		anomaly.ClusterName,
+		anomaly.Namespace,
+		anomaly.ContainerName,
+		anomaly.Labels,
+		anomaly.FromSource,
+		anomaly.Operation,
+		anomaly.Resource,
+	}
+
+	var id int
+	err := db.QueryRow("SELECT id FROM "+TableSystemAnomaly_TableName+keyClause, keyArgs...).Scan(&id)
+	if err == nil {
+		_, err = db.Exec("UPDATE "+TableSystemAnomaly_TableName+" SET count=count+?,lastSeen=? WHERE id = ?",
+			anomaly.Count, anomaly.LastSeen, id)
+		return false, err
+	} else if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	_, err = db.Exec("INSERT INTO "+TableSystemAnomaly_TableName+
+		"(clusterName,namespace,containerName,labels,fromSource,operation,resource,severity,count,firstSeen,lastSeen) values(?,?,?,?,?,?,?,?,?,?,?)",
+		anomaly.ClusterName,
+		anomaly.Namespace,
+		anomaly.ContainerName,
+		anomaly.Labels,
+		anomaly.FromSource,
+		anomaly.Operation,
+		anomaly.Resource,
+		anomaly.Severity,
+		anomaly.Count,
+		anomaly.FirstSeen,
+		anomaly.LastSeen)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func getSystemAnomaliesSQL(db sqlDB, filter types.AnomalyFilter) ([]types.SystemAnomaly, error) {
+	query := "SELECT clusterName,namespace,containerName,labels,fromSource,operation,resource,severity,count,firstSeen,lastSeen FROM " + TableSystemAnomaly_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	if filter.Cluster != "" {
+		concatWhereClause(&whereClause, "clusterName")
+		args = append(args, filter.Cluster)
+	}
+	if filter.Namespace != "" {
+		concatWhereClause(&whereClause, "namespace")
+		args = append(args, filter.Namespace)
+	}
+
+	results, err := db.Query(query+whereClause+" ORDER BY firstSeen", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	anomalies := []types.SystemAnomaly{}
+	for results.Next() {
+		var anomaly types.SystemAnomaly
+		if err := results.Scan(
+			&anomaly.ClusterName,
+			&anomaly.Namespace,
+			&anomaly.ContainerName,
+			&anomaly.Labels,
+			&anomaly.FromSource,
+			&anomaly.Operation,
+			&anomaly.Resource,
+			&anomaly.Severity,
+			&anomaly.Count,
+			&anomaly.FirstSeen,
+			&anomaly.LastSeen,
+		); err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	return anomalies, results.Err()
+}
+
+// ================= //
+// == Dead Letter == //
+// ================= //
+
+func insertDeadLetterSQL(db sqlDB, deadLetter types.DeadLetter) error {
+	_, err := db.Exec("INSERT INTO "+TableDeadLetter_TableName+
+		"(driver,topic,msgPartition,msgOffset,msgID,payload,reason,createdTime) values(?,?,?,?,?,?,?,?)",
+		deadLetter.Driver,
+		deadLetter.Topic,
+		deadLetter.Partition,
+		deadLetter.Offset,
+		deadLetter.MessageID,
+		deadLetter.Payload,
+		deadLetter.Reason,
+		deadLetter.CreatedTime)
+	return err
+}
+
+// getDeadLettersSQL returns the dead letters in the order they were written
+func getDeadLettersSQL(db sqlDB, filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	query := "SELECT id,driver,topic,msgPartition,msgOffset,msgID,payload,reason,createdTime FROM " + TableDeadLetter_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	if filter.Topic != "" {
+		concatWhereClause(&whereClause, "topic")
+		args = append(args, filter.Topic)
+	}
+	if len(filter.IDs) > 0 {
+		concatWhereClauseIn(&whereClause, "id", len(filter.IDs))
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+
+	query = query + whereClause + " ORDER BY id"
+	if filter.Limit > 0 {
+		query = query + " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	results, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	deadLetters := []types.DeadLetter{}
+	for results.Next() {
+		var deadLetter types.DeadLetter
+		if err := results.Scan(
+			&deadLetter.ID,
+			&deadLetter.Driver,
+			&deadLetter.Topic,
+			&deadLetter.Partition,
+			&deadLetter.Offset,
+			&deadLetter.MessageID,
+			&deadLetter.Payload,
+			&deadLetter.Reason,
+			&deadLetter.CreatedTime,
+		); err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, results.Err()
+}
+
+func deleteDeadLettersSQL(db sqlDB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var whereClause string
+	var args []interface{}
+
+	concatWhereClauseIn(&whereClause, "id", len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := db.Exec("DELETE FROM "+TableDeadLetter_TableName+whereClause, args...)
+	return err
+}
+
+// =================== //
+// == Observability == //
+// =================== //
+
+func getSystemLogsSQL(db sqlDB, filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
+	resLog := []types.KubeArmorLog{}
+	resTotal := []uint32{}
+
+	var results *sql.Rows
+	var err error
+
+	queryString := `cluster_name,namespace_name,pod_name,container_name,operation,labels,data,category,action,updated_time,result,total,source,resource`
+
+	query := "SELECT " + queryString + " FROM " + TableSystemLogs_TableName + " "
+
+	var whereClause string
+	var args []interface{}
+
+	if filterLog.ClusterName != "" {
+		concatWhereClause(&whereClause, "cluster_name")
+		args = append(args, filterLog.ClusterName)
+	}
+	if filterLog.NamespaceName != "" {
+		concatWhereClause(&whereClause, "namespace_name")
+		args = append(args, filterLog.NamespaceName)
+	}
+	if filterLog.PodName != "" {
+		concatWhereClause(&whereClause, "pod_name")
+		args = append(args, filterLog.PodName)
+	}
+	if filterLog.ContainerName != "" {
+		concatWhereClause(&whereClause, "container_name")
+		args = append(args, filterLog.ContainerName)
+	}
+	if filterLog.Operation != "" {
+		concatWhereClause(&whereClause, "operation")
+		args = append(args, filterLog.Operation)
+	}
+	if filterLog.Labels != "" {
+		concatWhereClause(&whereClause, "labels")
+		args = append(args, filterLog.Labels)
+	}
+	if filterLog.Data != "" {
+		concatWhereClause(&whereClause, "data")
+		args = append(args, filterLog.Data)
+	}
+	if filterLog.Category != "" {
+		concatWhereClause(&whereClause, "category")
+		args = append(args, filterLog.Category)
+	}
+	if filterLog.Action != "" {
+		concatWhereClause(&whereClause, "action")
+		args = append(args, filterLog.Action)
+	}
+	if filterLog.UpdatedTime != 0 {
+		concatWhereClause(&whereClause, "updated_time")
+		args = append(args, filterLog.UpdatedTime)
+	}
+	if filterLog.Result != "" {
+		concatWhereClause(&whereClause, "result")
+		args = append(args, filterLog.Result)
+	}
+	if filterLog.Source != "" {
+		concatWhereClause(&whereClause, "source")
+		args = append(args, filterLog.Source)
+	}
+	if filterLog.Resource != "" {
+		concatWhereClause(&whereClause, "resource")
+		args = append(args, filterLog.Resource)
+	}
+
+	results, err = db.Query(query+whereClause, args...)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, nil, err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var loc_log types.KubeArmorLog
+		var loc_total uint32
+		if err := results.Scan(
+			&loc_log.ClusterName,
+			&loc_log.NamespaceName,
+			&loc_log.PodName,
+			&loc_log.ContainerName,
+			&loc_log.Operation,
+			&loc_log.Labels,
+			&loc_log.Data,
+			&loc_log.Category,
+			&loc_log.Action,
+			&loc_log.UpdatedTime,
+			&loc_log.Result,
+			&loc_total,
+			&loc_log.Source,
+			&loc_log.Resource,
+		); err != nil {
+			return nil, nil, err
+		}
+		resLog = append(resLog, loc_log)
+		resTotal = append(resTotal, loc_total)
+	}
+
+	return resLog, resTotal, err
+}
+
+// getCiliumLogsSQL returns the network logs of the filter, updated after the time if any
+func getCiliumLogsSQL(db sqlDB, filterLog types.CiliumLog, updatedAfter int64) ([]types.CiliumLog, []uint32, error) {
+	resLog := []types.CiliumLog{}
+	resTotal := []uint32{}
+
+	var results *sql.Rows
+	var err error
+
+	queryString := ` verdict,ip_source,ip_destination,ip_version,ip_encrypted,l4_tcp_source_port,l4_tcp_destination_port,
+	l4_udp_source_port,l4_udp_destination_port,l4_icmpv4_type,l4_icmpv4_code,l4_icmpv6_type,l4_icmpv6_code,
+	source_namespace,source_labels,source_pod_name,destination_namespace,destination_labels,destination_pod_name,
+	type,node_name,l7_type,l7_dns_cnames,l7_dns_observation_source,l7_http_code,l7_http_method,l7_http_url,l7_http_protocol,l7_http_headers,
+	event_type_type,event_type_sub_type,source_service_name,source_service_namespace,destination_service_name,destination_service_namespace,
+	traffic_direction,trace_observation_point,drop_reason_desc,is_reply,start_time,updated_time,total,l4_tcp_flags`
+
+	query := "SELECT " + queryString + " FROM " + TableNetworkLogs_TableName + " "
+
+	var whereClause string
+	var args []interface{}
+
+	if filterLog.Verdict != "" {
+		concatWhereClause(&whereClause, "verdict")
+		args = append(args, filterLog.Verdict)
+	}
+	if filterLog.IpSource != "" {
+		concatWhereClause(&whereClause, "ip_source")
+		args = append(args, filterLog.IpSource)
+	}
+	if filterLog.IpDestination != "" {
+		concatWhereClause(&whereClause, "ip_destination")
+		args = append(args, filterLog.IpDestination)
+	}
+	if filterLog.IpVersion != "" {
+		concatWhereClause(&whereClause, "ip_version")
+		args = append(args, filterLog.IpVersion)
+	}
+	if filterLog.IpEncrypted {
+		concatWhereClause(&whereClause, "ip_encrypted")
+		args = append(args, filterLog.IpEncrypted)
+	}
+	if filterLog.L4TCPSourcePort != 0 {
+		concatWhereClause(&whereClause, "l4_tcp_source_port")
+		args = append(args, filterLog.L4TCPSourcePort)
+	}
+	if filterLog.L4TCPDestinationPort != 0 {
+		concatWhereClause(&whereClause, "l4_tcp_destination_port")
+		args = append(args, filterLog.L4TCPDestinationPort)
+	}
+	if filterLog.L4TCPFlags != "" {
+		concatWhereClause(&whereClause, "l4_tcp_flags")
+		args = append(args, filterLog.L4TCPFlags)
+	}
+	if filterLog.L4UDPSourcePort != 0 {
+		concatWhereClause(&whereClause, "l4_udp_source_port")
+		args = append(args, filterLog.L4UDPSourcePort)
+	}
+	if filterLog.L4UDPDestinationPort != 0 {
+		concatWhereClause(&whereClause, "l4_udp_destination_port")
+		args = append(args, filterLog.L4UDPDestinationPort)
+	}
+	if filterLog.L4ICMPv4Type != 0 {
+		concatWhereClause(&whereClause, "l4_icmpv4_type")
+		args = append(args, filterLog.L4ICMPv4Type)
+	}
+	if filterLog.L4ICMPv4Code != 0 {
+		concatWhereClause(&whereClause, "l4_icmpv4_code")
+		args = append(args, filterLog.L4ICMPv4Code)
+	}
+	if filterLog.L4ICMPv6Type != 0 {
+		concatWhereClause(&whereClause, "l4_icmpv6_type")
+		args = append(args, filterLog.L4ICMPv6Type)
+	}
+	if filterLog.L4ICMPv6Code != 0 {
+		concatWhereClause(&whereClause, "l4_icmpv6_code")
+		args = append(args, filterLog.L4ICMPv6Code)
+	}
+	if filterLog.SourceNamespace != "" {
+		concatWhereClause(&whereClause, "source_namespace")
+		args = append(args, filterLog.SourceNamespace)
+	}
+	if filterLog.SourceLabels != "" {
+		concatWhereClause(&whereClause, "source_labels")
+		args = append(args, filterLog.SourceLabels)
+	}
+	if filterLog.SourcePodName != "" {
+		concatWhereClause(&whereClause, "source_pod_name")
+		args = append(args, filterLog.SourcePodName)
+	}
+	if filterLog.DestinationNamespace != "" {
+		concatWhereClause(&whereClause, "destination_namespace")
+		args = append(args, filterLog.DestinationNamespace)
+	}
+	if filterLog.DestinationLabels != "" {
+		concatWhereClause(&whereClause, "destination_labels")
+		args = append(args, filterLog.DestinationLabels)
+	}
+	if filterLog.Type != "" {
+		concatWhereClause(&whereClause, "type")
+		args = append(args, filterLog.Type)
+	}
+	if filterLog.NodeName != "" {
+		concatWhereClause(&whereClause, "node_name")
+		args = append(args, filterLog.NodeName)
+	}
+	if filterLog.L7Type != "" {
+		concatWhereClause(&whereClause, "l7_type")
+		args = append(args, filterLog.L7Type)
+	}
+	if filterLog.L7DnsCnames != "" {
+		concatWhereClause(&whereClause, "l7_dns_cnames")
+		args = append(args, filterLog.L7DnsCnames)
+	}
+	if filterLog.L7DnsObservationsource != "" {
+		concatWhereClause(&whereClause, "l7_dns_observation_source")
+		args = append(args, filterLog.L7DnsObservationsource)
+	}
+	if filterLog.L7HttpCode != 0 {
+		concatWhereClause(&whereClause, "l7_http_code")
+		args = append(args, filterLog.L7HttpCode)
+	}
+	if filterLog.L7HttpMethod != "" {
+		concatWhereClause(&whereClause, "l7_http_method")
+		args = append(args, filterLog.L7HttpMethod)
+	}
+	if filterLog.L7HttpUrl != "" {
+		concatWhereClause(&whereClause, "l7_http_url")
+		args = append(args, filterLog.L7HttpUrl)
+	}
+	if filterLog.L7HttpProtocol != "" {
+		concatWhereClause(&whereClause, "l7_http_protocol")
+		args = append(args, filterLog.L7HttpProtocol)
+	}
+	if filterLog.L7HttpHeaders != "" {
+		concatWhereClause(&whereClause, "l7_http_headers")
+		args = append(args, filterLog.L7HttpHeaders)
+	}
+	if filterLog.EventTypeType != 0 {
+		concatWhereClause(&whereClause, "event_type_type")
+		args = append(args, filterLog.EventTypeType)
+	}
+	if filterLog.EventTypeSubType != 0 {
+		concatWhereClause(&whereClause, "event_type_sub_type")
+		args = append(args, filterLog.EventTypeSubType)
+	}
+	if filterLog.SourceServiceName != "" {
+		concatWhereClause(&whereClause, "source_service_name")
+		args = append(args, filterLog.SourceServiceName)
+	}
+	if filterLog.SourceServiceNamespace != "" {
+		concatWhereClause(&whereClause, "source_service_namespace")
+		args = append(args, filterLog.SourceServiceNamespace)
+	}
+	if filterLog.DestinationServiceName != "" {
+		concatWhereClause(&whereClause, "destination_service_name")
+		args = append(args, filterLog.DestinationServiceName)
+	}
+	if filterLog.DestinationServiceNamespace != "" {
+		concatWhereClause(&whereClause, "destination_service_namespace")
+		args = append(args, filterLog.DestinationServiceNamespace)
+	}
+	if filterLog.TrafficDirection != "" {
+		concatWhereClause(&whereClause, "traffic_direction")
+		args = append(args, filterLog.TrafficDirection)
+	}
+	if filterLog.TraceObservationPoint != "" {
+		concatWhereClause(&whereClause, "trace_observation_point")
+		args = append(args, filterLog.TraceObservationPoint)
+	}
+	if filterLog.DropReasonDesc != "" {
+		concatWhereClause(&whereClause, "drop_reason_desc")
+		args = append(args, filterLog.DropReasonDesc)
+	}
+	if filterLog.IsReply {
+		concatWhereClause(&whereClause, "is_reply")
+		args = append(args, filterLog.IsReply)
+	}
+	if filterLog.StartTime != 0 {
+		concatWhereClause(&whereClause, "start_time")
+		args = append(args, filterLog.StartTime)
+	}
+	if filterLog.UpdatedTime != 0 {
+		concatWhereClause(&whereClause, "updated_time")
+		args = append(args, filterLog.UpdatedTime)
+	}
+	if filterLog.Total != 0 {
+		concatWhereClause(&whereClause, "total")
+		args = append(args, filterLog.Total)
+	}
+	if updatedAfter != 0 {
+		concatWhereClauseAfter(&whereClause, "updated_time")
+		args = append(args, updatedAfter)
+	}
+
+	results, err = db.Query(query+whereClause, args...)
+
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, nil, err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var loc_log types.CiliumLog
+		var loc_total uint32
+		if err := results.Scan(
+			&loc_log.Verdict,
+			&loc_log.IpSource,
+			&loc_log.IpDestination,
+			&loc_log.IpVersion,
+			&loc_log.IpEncrypted,
+			&loc_log.L4TCPSourcePort,
+			&loc_log.L4TCPDestinationPort,
+			&loc_log.L4UDPSourcePort,
+			&loc_log.L4UDPDestinationPort,
+			&loc_log.L4ICMPv4Type,
+			&loc_log.L4ICMPv4Code,
+			&loc_log.L4ICMPv6Type,
+			&loc_log.L4ICMPv6Code,
+			&loc_log.SourceNamespace,
+			&loc_log.SourceLabels,
+			&loc_log.SourcePodName,
+			&loc_log.DestinationNamespace,
+			&loc_log.DestinationLabels,
+			&loc_log.DestinationPodName,
+			&loc_log.Type,
+			&loc_log.NodeName,
+			&loc_log.L7Type,
+			&loc_log.L7DnsCnames,
+			&loc_log.L7DnsObservationsource,
+			&loc_log.L7HttpCode,
+			&loc_log.L7HttpMethod,
+			&loc_log.L7HttpUrl,
+			&loc_log.L7HttpProtocol,
+			&loc_log.L7HttpHeaders,
+			&loc_log.EventTypeType,
+			&loc_log.EventTypeSubType,
+			&loc_log.SourceServiceName,
+			&loc_log.SourceServiceNamespace,
+			&loc_log.DestinationServiceName,
+			&loc_log.DestinationServiceNamespace,
+			&loc_log.TrafficDirection,
+			&loc_log.TraceObservationPoint,
+			&loc_log.DropReasonDesc,
+			&loc_log.IsReply,
+			&loc_log.StartTime,
+			&loc_log.UpdatedTime,
+			&loc_total,
+			&loc_log.L4TCPFlags,
+		); err != nil {
+			return nil, nil, err
+		}
+		resLog = append(resLog, loc_log)
+		resTotal = append(resTotal, loc_total)
+	}
+	return resLog, resTotal, err
+}
+
+func getPodNamesSQL(db sqlDB, filter types.ObsPodDetail) ([]string, error) {
+	resPodNames := []string{}
+
+	var results *sql.Rows
+	var err error
+
+	// Get podnames from system table
+	query := "SELECT podname FROM " + TableSystemSummary_TableName + " "
+
+	var whereClause string
+	var sysargs []interface{}
+
+	if filter.ClusterName != "" {
+		concatWhereClause(&whereClause, "cluster_name")
+		sysargs = append(sysargs, filter.ClusterName)
+	}
+	if filter.Namespace != "" {
+		concatWhereClause(&whereClause, "namespace_name")
+		sysargs = append(sysargs, filter.Namespace)
+	}
+	if filter.PodName != "" {
+		concatWhereClause(&whereClause, "podname")
+		sysargs = append(sysargs, filter.PodName)
+	}
+	if filter.Labels != "" {
+		concatWhereClause(&whereClause, "labels")
+		sysargs = append(sysargs, filter.Labels)
+	}
+	if filter.ContainerName != "" {
+		concatWhereClause(&whereClause, "container_name")
+		sysargs = append(sysargs, filter.ContainerName)
+	}
+	if filter.DeployName != "" {
+		concatWhereClause(&whereClause, "deployment_name")
+		sysargs = append(sysargs, filter.DeployName)
+	}
+
+	results, err = db.Query(query+whereClause, sysargs...)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var locPodName string
+		if err := results.Scan(
+			&locPodName,
+		); err != nil {
+			return nil, err
+		}
+		resPodNames = append(resPodNames, locPodName)
+	}
+
+	return resPodNames, err
+}
+
+func getDeployNamesSQL(db sqlDB, filter types.ObsPodDetail) ([]string, error) {
+	resDeployNames := []string{}
+
+	var results *sql.Rows
+	var err error
+
+	// Get podnames from system table
+	query := "SELECT deployment_name FROM " + TableSystemSummary_TableName + " "
+
+	var whereClause string
+	var sysargs []interface{}
+
+	if filter.ClusterName != "" {
+		concatWhereClause(&whereClause, "cluster_name")
+		sysargs = append(sysargs, filter.ClusterName)
+	}
+	if filter.Namespace != "" {
+		concatWhereClause(&whereClause, "namespace_name")
+		sysargs = append(sysargs, filter.Namespace)
+	}
+	if filter.DeployName != "" {
+		concatWhereClause(&whereClause, "deployment_name")
+		sysargs = append(sysargs, filter.DeployName)
+	}
+	if filter.Labels != "" {
+		concatWhereClause(&whereClause, "labels")
+		sysargs = append(sysargs, filter.Labels)
+	}
+
+	results, err = db.Query(query+whereClause, sysargs...)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var locDeployName string
+		if err := results.Scan(
+			&locDeployName,
+		); err != nil {
+			return nil, err
+		}
+		resDeployNames = append(resDeployNames, locDeployName)
+	}
+
+	return resDeployNames, err
+}
+
+// =============== //
+// == Policy DB == //
+// =============== //
+
+func getPolicyYamlsSQL(db sqlDB, policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	policies := []types.PolicyYaml{}
+	ids := []int64{}
+
+	var results *sql.Rows
+
+	query := "SELECT id,type,kind,cluster_name,namespace,labels,policy_name,policy_yaml,workspace_id,cluster_id FROM " + PolicyYaml_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	concatWhereClause(&whereClause, "type")
+	args = append(args, policyType)
+
+	if filterOptions.Namespace != "" {
+		concatWhereClause(&whereClause, "namespace")
+		args = append(args, filterOptions.Namespace)
+	}
+
+	if filterOptions.Cluster != "" {
+		concatWhereClause(&whereClause, "cluster_name")
+		args = append(args, filterOptions.Cluster)
+	}
+
+	if filterOptions.Kind != "" {
+		concatWhereClause(&whereClause, "kind")
+		args = append(args, filterOptions.Kind)
+	}
+
+	if labels := LabelMapToString(filterOptions.Labels); labels != "" {
+		concatWhereClause(&whereClause, "labels")
+		args = append(args, labels)
+	}
+
+	order, err := concatPageClause(&whereClause, &args, "id", page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err = db.Query(query+whereClause+order, args...)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, "", err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var id int64
+		var labels string
+		policy := types.PolicyYaml{}
+
+		if err := results.Scan(
+			&id,
+			&policy.Type,
+			&policy.Kind,
+			&policy.Cluster,
+			&policy.Namespace,
+			&labels,
+			&policy.Name,
+			&policy.Yaml,
+			&policy.WorkspaceId,
+			&policy.ClusterId,
+		); err != nil {
+			return nil, "", err
+		}
+
+		policy.Labels = LabelMapFromString(labels)
+		policies = append(policies, policy)
+		ids = append(ids, id)
+	}
+
+	policies, next := cutPage(page, policies, ids)
+	return policies, next, nil
+}
+
+func updateOrInsertPolicyYamlsSQL(db sqlDB, policies []types.PolicyYaml) error {
+	for _, pol := range policies {
+		if err := updateOrInsertPolicyYamlSQL(pol, db); err != nil {
+			log.Error().Msg(err.Error())
+		}
+	}
+
+	return nil
+}
+
+func updateOrInsertPolicyYamlSQL(policy types.PolicyYaml, db sqlDB) error {
+	var err error
+	queryString := ` policy_name = ? `
+
+	query := "UPDATE " + PolicyYaml_TableName + " SET policy_yaml=?, updated_time=? WHERE " + queryString + " "
+
+	updateStmt, err := db.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer updateStmt.Close()
+
+	result, err := updateStmt.Exec(
+		policy.Yaml,
+		ConvertStrToUnixTime("now"),
+		policy.Name,
+	)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err == nil && rowsAffected == 0 {
+
+		insertStmt, err := db.Prepare("INSERT INTO " + PolicyYaml_TableName +
+			"(type,kind,cluster_name,namespace,labels,policy_name,policy_yaml,updated_time,workspace_id,cluster_id) values(?,?,?,?,?,?,?,?,?,?)")
+		if err != nil {
+			return err
+		}
+		defer insertStmt.Close()
+
+		_, err = insertStmt.Exec(
+			policy.Type,
+			policy.Kind,
+			config.GetCfgClusterName(),
+			policy.Namespace,
+			LabelMapToString(policy.Labels),
+			policy.Name,
+			policy.Yaml,
+			ConvertStrToUnixTime("now"),
+			policy.WorkspaceId,
+			policy.ClusterId,
+		)
+		if err != nil {
+			log.Error().Msg(err.Error())
+		}
+	}
+
+	return err
+}
+
+func deletePolicyBasedOnPolicyNameSQL(db sqlDB, policyName, namespace, labels string) error {
+	query := "DELETE FROM " + PolicyYaml_TableName + " WHERE policy_name = ? AND namespace = ? AND labels = ?"
+	deleteStmt, err := db.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer func(deleteStmt *sql.Stmt) {
+		err := deleteStmt.Close()
+		if err != nil {
+			log.Warn().Msgf("error while closing deleteStmt err=%v", err.Error())
+		}
+	}(deleteStmt)
+
+	result, err := deleteStmt.Exec(policyName, namespace, labels)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 0 {
+		log.Info().Msgf("deleted policy %s from db", policyName)
+	}
+
+	return nil
+}
@@ -3,9 +3,7 @@ package libs
 import (
 	"database/sql"
 	"encoding/json"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -22,6 +20,8 @@ const TableSystemLogsSQLite_TableName = "system_logs"
 const TableNetworkLogsSQLite_TableName = "network_logs"
 const PolicyYamlSQLite_TableName = "policy_yaml"
 const TableSystemSummarySQLite = "system_summary"
+const TableSystemAnomalySQLite_TableName = "system_anomaly"
+const TableDeadLetterSQLite_TableName = "dead_letter"
 
 // ================ //
 // == Connection == //
@@ -42,39 +42,9 @@ func NewMockSQLite() (*sql.DB, sqlmock.Sqlmock) {
 	return db, mock
 }
 
-func waitForDBSQLite(db *sql.DB) {
-	for {
-		err := db.Ping()
-		if err != nil {
-			time.Sleep(time.Second * 1)
-			log.Error().Msgf("db.Ping() failed. Will retry. err=%s", err.Error())
-		} else {
-			break
-		}
-	}
-}
-
-var connectSQLiteOBSOnce sync.Once
-var SQLiteOBSDB *sql.DB
-
+// connectSQLiteOBS connects the database of the observability tables
 func connectSQLiteOBS(cfg types.ConfigDB, dbpath string) (db *sql.DB) {
-	if MockDB != nil {
-		return MockDB
-	}
-	connectSQLiteOBSOnce.Do(func() {
-		var err error
-		SQLiteOBSDB, err = sql.Open(cfg.DBDriver, dbpath+"?_journal=OFF")
-		for err != nil {
-			log.Error().Msgf("sqlite driver:%s, user:%s, host:%s, port:%s, dbname:%s conn-error:%s",
-				cfg.DBDriver, cfg.DBUser, cfg.DBHost, cfg.DBPort, cfg.DBName, err.Error())
-			time.Sleep(time.Second * 1)
-			SQLiteOBSDB, err = sql.Open(cfg.DBDriver, dbpath)
-		}
-		SQLiteOBSDB.SetMaxIdleConns(0)
-	})
-	waitForDBSQLite(SQLiteOBSDB)
-
-	return SQLiteOBSDB
+	return connectSQLite(cfg, dbpath)
 }
 
 func connectSQLite(cfg types.ConfigDB, dbpath string) (db *sql.DB) {
@@ -82,57 +52,70 @@ func connectSQLite(cfg types.ConfigDB, dbpath string) (db *sql.DB) {
 		return MockDB
 	}
 
-	db, err := sql.Open(cfg.DBDriver, dbpath+"?_journal=OFF")
-	for err != nil {
-		log.Error().Msgf("sqlite driver:%s, user:%s, host:%s, port:%s, dbname:%s conn-error:%s",
-			cfg.DBDriver, cfg.DBUser, cfg.DBHost, cfg.DBPort, cfg.DBName, err.Error())
-		time.Sleep(time.Second * 1)
-		db, err = sql.Open(cfg.DBDriver, dbpath)
-	}
-	db.SetMaxIdleConns(0)
-	waitForDBSQLite(db)
-
-	return db
+	return openDB(cfg, "sqlite3", dbpath+"?_journal=OFF")
 }
 
 // ==================== //
 // == Network Policy == //
 // ==================== //
 
-func GetNetworkPoliciesFromSQLite(cfg types.ConfigDB, cluster, namespace, status string) ([]types.KnoxNetworkPolicy, error) {
+func GetNetworkPoliciesFromSQLite(cfg types.ConfigDB, filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, "", err
+	}
 
 	policies := []types.KnoxNetworkPolicy{}
+	ids := []int64{}
 	var results *sql.Rows
-	var err error
 
-	query := "SELECT apiVersion,kind,flow_ids,name,cluster_name,namespace,type,rule,status,outdated,spec,generatedTime,updatedTime FROM " + TableNetworkPolicySQLite_TableName
-	if cluster != "" && namespace != "" && status != "" {
-		query = query + " WHERE cluster_name = ? and namespace = ? and status = ? "
-		results, err = db.Query(query, cluster, namespace, status)
-	} else if cluster != "" && status != "" {
-		query = query + " WHERE cluster_name = ? and status = ? "
-		results, err = db.Query(query, cluster, status)
-	} else if namespace != "" && status != "" {
-		query = query + " WHERE namespace = ? and status = ? "
-		results, err = db.Query(query, namespace, status)
-	} else if status != "" {
-		query = query + " WHERE status = ? "
-		results, err = db.Query(query, status)
-	} else {
-		results, err = db.Query(query)
+	query := "SELECT rowid,apiVersion,kind,flow_ids,name,cluster_name,namespace,type,rule,status,outdated,spec,generatedTime,updatedTime FROM " + TableNetworkPolicySQLite_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	if filter.Cluster != "" {
+		concatWhereClauseSQLite(&whereClause, "cluster_name")
+		args = append(args, filter.Cluster)
+	}
+	if filter.Namespace != "" {
+		concatWhereClauseSQLite(&whereClause, "namespace")
+		args = append(args, filter.Namespace)
+	}
+	if filter.Status != "" {
+		concatWhereClauseSQLite(&whereClause, "status")
+		args = append(args, filter.Status)
+	}
+	if filter.Type != "" {
+		concatWhereClauseSQLite(&whereClause, "type")
+		args = append(args, filter.Type)
+	}
+	if filter.Rule != "" {
+		concatWhereClauseSQLite(&whereClause, "rule")
+		args = append(args, filter.Rule)
+	}
+	if len(filter.Selector) > 0 {
+		concatWhereClauseSQLite(&whereClause, "selector_hash")
+		args = append(args, HashSelector(filter.Selector))
 	}
-	defer results.Close()
 
+	order, err := concatPageClause(&whereClause, &args, "rowid", page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err = db.Query(query+whereClause+order, args...)
 	if err != nil {
 		log.Error().Msg(err.Error())
-		return nil, err
+		return nil, "", err
 	}
+	defer results.Close()
 
 	for results.Next() {
 		policy := types.KnoxNetworkPolicy{}
 
+		var id int64
 		var name, clusterName, namespace, policyType, rule, status string
 		specByte := []byte{}
 		spec := types.Spec{}
@@ -141,6 +124,7 @@ func GetNetworkPoliciesFromSQLite(cfg types.ConfigDB, cluster, namespace, status
 		flowIDs := []int{}
 
 		if err := results.Scan(
+			&id,
 			&policy.APIVersion,
 			&policy.Kind,
 			&flowIDsByte,
@@ -155,15 +139,19 @@ func GetNetworkPoliciesFromSQLite(cfg types.ConfigDB, cluster, namespace, status
 			&policy.GeneratedTime,
 			&policy.UpdatedTime,
 		); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
+		specByte, err := c.decryptBytes(specByte)
+		if err != nil {
+			return nil, "", err
+		}
 		if err := json.Unmarshal(specByte, &spec); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if err := json.Unmarshal(flowIDsByte, &flowIDs); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		policy.Metadata = map[string]string{
@@ -179,17 +167,22 @@ func GetNetworkPoliciesFromSQLite(cfg types.ConfigDB, cluster, namespace, status
 		policy.Spec = spec
 
 		policies = append(policies, policy)
+		ids = append(ids, id)
 	}
 
-	return policies, nil
+	policies, next := cutPage(page, policies, ids)
+	return policies, next, nil
 }
 
 func UpdateNetworkPolicyToSQLite(cfg types.ConfigDB, policy types.KnoxNetworkPolicy) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
 
 	stmt, err := db.Prepare("UPDATE " + TableNetworkPolicySQLite_TableName +
-		" SET apiVersion=?,kind=?,cluster_name=?,namespace=?,type=?,status=?,outdated=?,spec=?,updatedTime=? WHERE name = ?")
+		" SET apiVersion=?,kind=?,cluster_name=?,namespace=?,type=?,status=?,outdated=?,spec=?,selector_hash=?,updatedTime=? WHERE name = ?")
 	if err != nil {
 		return err
 	}
@@ -200,6 +193,7 @@ func UpdateNetworkPolicyToSQLite(cfg types.ConfigDB, policy types.KnoxNetworkPol
 	if err != nil {
 		return err
 	}
+	spec = c.encryptBytes(spec)
 
 	_, err = stmt.Exec(
 		policy.APIVersion,
@@ -210,6 +204,7 @@ func UpdateNetworkPolicyToSQLite(cfg types.ConfigDB, policy types.KnoxNetworkPol
 		policy.Metadata["status"],
 		policy.Outdated,
 		spec,
+		HashSelector(policy.Spec.Selector.MatchLabels),
 		ConvertStrToUnixTime("now"),
 		policy.Metadata["name"])
 	if err != nil {
@@ -221,7 +216,6 @@ func UpdateNetworkPolicyToSQLite(cfg types.ConfigDB, policy types.KnoxNetworkPol
 
 func UpdateOutdatedNetworkPolicyFromSQLite(cfg types.ConfigDB, outdatedPolicy string, latestPolicy string) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
 
 	var err error
 
@@ -253,7 +247,12 @@ func UpdateOutdatedNetworkPolicyFromSQLite(cfg types.ConfigDB, outdatedPolicy st
 }
 
 func insertNetworkPolicySQLite(cfg types.ConfigDB, db *sql.DB, policy types.KnoxNetworkPolicy) error {
-	stmt, err := db.Prepare("INSERT INTO " + TableNetworkPolicySQLite_TableName + "(apiVersion,kind,flow_ids,name,cluster_name,namespace,type,rule,status,outdated,spec,generatedTime,updatedTime) values(?,?,?,?,?,?,?,?,?,?,?,?,?)")
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := db.Prepare("INSERT INTO " + TableNetworkPolicySQLite_TableName + "(apiVersion,kind,flow_ids,name,cluster_name,namespace,type,rule,status,outdated,spec,selector_hash,generatedTime,updatedTime) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)")
 	if err != nil {
 		return err
 	}
@@ -270,6 +269,7 @@ func insertNetworkPolicySQLite(cfg types.ConfigDB, db *sql.DB, policy types.Knox
 	if err != nil {
 		return err
 	}
+	spec = c.encryptBytes(spec)
 
 	currTime := ConvertStrToUnixTime("now")
 
@@ -284,6 +284,7 @@ func insertNetworkPolicySQLite(cfg types.ConfigDB, db *sql.DB, policy types.Knox
 		policy.Metadata["status"],
 		policy.Outdated,
 		spec,
+		HashSelector(policy.Spec.Selector.MatchLabels),
 		currTime,
 		currTime)
 	if err != nil {
@@ -295,7 +296,6 @@ func insertNetworkPolicySQLite(cfg types.ConfigDB, db *sql.DB, policy types.Knox
 
 func InsertNetworkPoliciesToSQLite(cfg types.ConfigDB, policies []types.KnoxNetworkPolicy) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
 
 	for _, policy := range policies {
 		if err := insertNetworkPolicySQLite(cfg, db, policy); err != nil {
@@ -312,7 +312,6 @@ func InsertNetworkPoliciesToSQLite(cfg types.ConfigDB, policies []types.KnoxNetw
 
 func UpdateOutdatedSystemPolicyFromSQLite(cfg types.ConfigDB, outdatedPolicy string, latestPolicy string) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
 
 	var err error
 
@@ -343,43 +342,53 @@ func UpdateOutdatedSystemPolicyFromSQLite(cfg types.ConfigDB, outdatedPolicy str
 	return nil
 }
 
-func GetSystemPoliciesFromSQLite(cfg types.ConfigDB, namespace, status string) ([]types.KnoxSystemPolicy, error) {
+func GetSystemPoliciesFromSQLite(cfg types.ConfigDB, namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error) {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, "", err
+	}
 
 	policies := []types.KnoxSystemPolicy{}
+	ids := []int64{}
 	var results *sql.Rows
-	var err error
 
-	query := "SELECT apiVersion,kind,name,clusterName,namespace,type,status,outdated,spec,generatedTime,updatedTime,latest FROM " + TableSystemPolicySQLite_TableName
-
-	if namespace != "" && status != "" {
-		query = query + " WHERE namespace = ? and status = ? "
-		results, err = db.Query(query, namespace, status)
-	} else if namespace != "" {
-		query = query + " WHERE namespace = ? "
-		results, err = db.Query(query, namespace)
-	} else if status != "" {
-		query = query + " WHERE status = ? "
-		results, err = db.Query(query, status)
-	} else {
-		results, err = db.Query(query)
+	query := "SELECT rowid,apiVersion,kind,name,clusterName,namespace,type,status,outdated,spec,generatedTime,updatedTime,latest FROM " + TableSystemPolicySQLite_TableName
+
+	var whereClause string
+	var args []interface{}
+
+	if namespace != "" {
+		concatWhereClauseSQLite(&whereClause, "namespace")
+		args = append(args, namespace)
+	}
+	if status != "" {
+		concatWhereClauseSQLite(&whereClause, "status")
+		args = append(args, status)
 	}
 
+	order, err := concatPageClause(&whereClause, &args, "rowid", page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err = db.Query(query+whereClause+order, args...)
 	if err != nil {
 		log.Error().Msg(err.Error())
-		return nil, err
+		return nil, "", err
 	}
 	defer results.Close()
 
 	for results.Next() {
 		policy := types.KnoxSystemPolicy{}
 
+		var id int64
 		var name, clusterName, namespace, policyType, status string
 		specByte := []byte{}
 		spec := types.KnoxSystemSpec{}
 
 		if err := results.Scan(
+			&id,
 			&policy.APIVersion,
 			&policy.Kind,
 			&name,
@@ -393,11 +402,15 @@ func GetSystemPoliciesFromSQLite(cfg types.ConfigDB, namespace, status string) (
 			&policy.UpdatedTime,
 			&policy.Latest,
 		); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
+		specByte, err := c.decryptBytes(specByte)
+		if err != nil {
+			return nil, "", err
+		}
 		if err := json.Unmarshal(specByte, &spec); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		policy.Metadata = map[string]string{
@@ -411,12 +424,19 @@ func GetSystemPoliciesFromSQLite(cfg types.ConfigDB, namespace, status string) (
 		policy.Spec = spec
 
 		policies = append(policies, policy)
+		ids = append(ids, id)
 	}
 
-	return policies, nil
+	policies, next := cutPage(page, policies, ids)
+	return policies, next, nil
 }
 
 func insertSystemPolicySQLite(cfg types.ConfigDB, db *sql.DB, policy types.KnoxSystemPolicy) error {
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
+
 	stmt, err := db.Prepare("INSERT INTO " + TableSystemPolicySQLite_TableName + "(apiVersion,kind,name,clusterName,namespace,type,status,outdated,spec,generatedTime,updatedTime,latest) values(?,?,?,?,?,?,?,?,?,?,?,?)")
 	if err != nil {
 		return err
@@ -428,6 +448,7 @@ func insertSystemPolicySQLite(cfg types.ConfigDB, db *sql.DB, policy types.KnoxS
 	if err != nil {
 		return err
 	}
+	spec = c.encryptBytes(spec)
 
 	_, err = stmt.Exec(
 		policy.APIVersion,
@@ -451,7 +472,6 @@ func insertSystemPolicySQLite(cfg types.ConfigDB, db *sql.DB, policy types.KnoxS
 
 func InsertSystemPoliciesToSQLite(cfg types.ConfigDB, policies []types.KnoxSystemPolicy) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
 
 	for _, policy := range policies {
 		if err := insertSystemPolicySQLite(cfg, db, policy); err != nil {
@@ -464,7 +484,10 @@ func InsertSystemPoliciesToSQLite(cfg types.ConfigDB, policies []types.KnoxSyste
 
 func UpdateSystemPolicyToSQLite(cfg types.ConfigDB, policy types.KnoxSystemPolicy) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
 
 	// set status -> outdated
 	stmt, err := db.Prepare("UPDATE " + TableSystemPolicySQLite_TableName +
@@ -479,6 +502,7 @@ func UpdateSystemPolicyToSQLite(cfg types.ConfigDB, policy types.KnoxSystemPolic
 	if err != nil {
 		return err
 	}
+	spec = c.encryptBytes(spec)
 
 	_, err = stmt.Exec(
 		policy.APIVersion,
@@ -503,84 +527,40 @@ func UpdateSystemPolicyToSQLite(cfg types.ConfigDB, policy types.KnoxSystemPolic
 // == Table == //
 // =========== //
 
-func ClearDBTablesSQLite(cfg types.ConfigDB) error {
+func ClearNetworkDBTableSQLite(cfg types.ConfigDB) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
 
 	query := "DELETE FROM " + TableNetworkPolicySQLite_TableName
-	if _, err := db.Query(query); err != nil {
+	if _, err := db.Exec(query); err != nil {
 		return err
 	}
 
-	query = "DELETE FROM " + TableSystemPolicySQLite_TableName
-	if _, err := db.Query(query); err != nil {
-		return err
-	}
+	return nil
+}
 
-	query = "DELETE FROM " + WorkloadProcessFileSetSQLite_TableName
-	if _, err := db.Query(query); err != nil {
-		return err
-	}
+func ClearPolicyYamlDBTableSQLite(cfg types.ConfigDB) error {
+	return clearPolicyYamlDBTableSQL(connectSQLite(cfg, cfg.SQLiteDBPath))
+}
 
-	return nil
+// ClearSystemSummaryDBTablesSQLite clears the summaries and the rollups of the observability db
+func ClearSystemSummaryDBTablesSQLite(cfg types.ConfigDB) error {
+	return clearSystemSummaryDBTablesSQL(connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName()))
 }
 
-func CreateTableNetworkPolicySQLite(cfg types.ConfigDB) error {
+func ClearDBTablesSQLite(cfg types.ConfigDB) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
-
-	tableName := TableNetworkPolicySQLite_TableName
-
-	query :=
-		"CREATE TABLE IF NOT EXISTS `" + tableName + "` (" +
-			"	`id` INTEGER AUTO_INCREMENT," +
-			"	`apiVersion` varchar(20) DEFAULT NULL," +
-			"	`kind` varchar(20) DEFAULT NULL," +
-			"	`flow_ids` JSON DEFAULT NULL," +
-			"	`name` varchar(50) DEFAULT NULL," +
-			"	`cluster_name` varchar(50) DEFAULT NULL," +
-			"	`namespace` varchar(50) DEFAULT NULL," +
-			"	`type` varchar(10) DEFAULT NULL," +
-			"	`rule` varchar(30) DEFAULT NULL," +
-			"	`status` varchar(10) DEFAULT NULL," +
-			"	`outdated` varchar(50) DEFAULT NULL," +
-			"	`spec` JSON DEFAULT NULL," +
-			"	`generatedTime` bigint NOT NULL," +
-			"	`updatedTime` bigint NOT NULL," +
-			"	PRIMARY KEY (`id`)" +
-			"  );"
 
+	query := "DELETE FROM " + TableNetworkPolicySQLite_TableName
 	if _, err := db.Exec(query); err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func CreateTableSystemPolicySQLite(cfg types.ConfigDB) error {
-	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
-
-	tableName := TableSystemPolicySQLite_TableName
-
-	query :=
-		"CREATE TABLE IF NOT EXISTS `" + tableName + "` (" +
-			"	`id` INTEGER AUTO_INCREMENT," +
-			"	`apiVersion` varchar(40) DEFAULT NULL," +
-			"	`kind` varchar(20) DEFAULT NULL," +
-			"	`name` varchar(128) DEFAULT NULL," +
-			"	`clusterName` varchar(50) DEFAULT NULL," +
-			"	`namespace` varchar(50) DEFAULT NULL," +
-			"   `type` varchar(20) NOT NULL," +
-			"	`status` varchar(10) DEFAULT NULL," +
-			"	`outdated` varchar(50) DEFAULT NULL," +
-			"	`spec` JSON DEFAULT NULL," +
-			"	`generatedTime` bigint NOT NULL," +
-			"	`updatedTime` bigint NOT NULL," +
-			"	`latest` BOOLEAN," +
-			"	PRIMARY KEY (`id`)" +
-			"  );"
+	query = "DELETE FROM " + TableSystemPolicySQLite_TableName
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
 
+	query = "DELETE FROM " + WorkloadProcessFileSetSQLite_TableName
 	if _, err := db.Exec(query); err != nil {
 		return err
 	}
@@ -588,183 +568,6 @@ func CreateTableSystemPolicySQLite(cfg types.ConfigDB) error {
 	return nil
 }
 
-func CreateTableWorkLoadProcessFileSetSQLite(cfg types.ConfigDB) error {
-	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
-
-	tableName := WorkloadProcessFileSetSQLite_TableName
-
-	query :=
-		"CREATE TABLE IF NOT EXISTS `" + tableName + "` (" +
-			"	`id` INTEGER AUTO_INCREMENT," +
-			"	`policyName` varchar(128) DEFAULT NULL," +
-			"	`clusterName` varchar(50) DEFAULT NULL," +
-			"	`namespace` varchar(50) DEFAULT NULL," +
-			"   `containerName` varchar(100) NOT NULL," +
-			"	`labels` varchar(1000) DEFAULT NULL," +
-			"	`fromSource` varchar(256) DEFAULT NULL," +
-			"	`settype` varchar(16) DEFAULT NULL," + // settype: "file" or "process"
-			"	`fileset` text DEFAULT NULL," +
-			"	`createdTime` bigint NOT NULL," +
-			"	`updatedTime` bigint NOT NULL," +
-			"	PRIMARY KEY (`id`)" +
-			"  );"
-
-	_, err := db.Exec(query)
-	return err
-}
-
-func CreateTableSystemLogsSQLite(cfg types.ConfigDB) error {
-	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
-
-	tableName := TableSystemLogsSQLite_TableName
-
-	query :=
-		"CREATE TABLE IF NOT EXISTS `" + tableName + "` (" +
-			"	`id` INTEGER AUTO_INCREMENT," +
-			"	`cluster_name` varchar(50) DEFAULT NULL," +
-			"	`namespace_name` varchar(50) DEFAULT NULL," +
-			"	`pod_name` varchar(50) DEFAULT NULL," +
-			"	`container_name` varchar(100) DEFAULT NULL," +
-			"	`source` varchar(250) DEFAULT NULL," +
-			"	`resource` varchar(250) DEFAULT NULL," +
-			"	`operation` varchar(250) DEFAULT NULL," +
-			"	`labels` varchar(250) DEFAULT NULL," +
-			"	`data` varchar(250) DEFAULT NULL," +
-			"	`category` varchar(50) DEFAULT NULL," +
-			"	`action` varchar(50) DEFAULT NULL," +
-			"	`updated_time` bigint NOT NULL," +
-			"	`result` varchar(100) DEFAULT NULL," +
-			"	`total` INTEGER, " +
-			"	PRIMARY KEY (`id`)" +
-			"  );"
-
-	_, err := db.Exec(query)
-	return err
-}
-
-func CreateTableNetworkLogsSQLite(cfg types.ConfigDB) error {
-	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
-
-	tableName := TableNetworkLogsSQLite_TableName
-
-	query :=
-		"CREATE TABLE IF NOT EXISTS `" + tableName + "` (" +
-			"	`id` INTEGER AUTO_INCREMENT," +
-			"	`verdict` varchar(100) DEFAULT NULL," +
-			"	`ip_source` varchar(100) DEFAULT NULL," +
-			"	`ip_destination` varchar(100) DEFAULT NULL," +
-			"	`ip_version` varchar(100) DEFAULT NULL," +
-			"	`ip_encrypted` BOOLEAN," +
-			"	`l4_tcp_source_port` INTEGER," +
-			"	`l4_tcp_destination_port` INTEGER," +
-			"	`l4_udp_source_port` INTEGER," +
-			"	`l4_udp_destination_port` INTEGER," +
-			"	`l4_icmpv4_type` INTEGER," +
-			"	`l4_icmpv4_code` INTEGER," +
-			"	`l4_icmpv6_type` INTEGER," +
-			"	`l4_icmpv6_code` INTEGER," +
-			"	`source_namespace` varchar(100) DEFAULT NULL," +
-			"	`source_labels` varchar(200) DEFAULT NULL," +
-			"	`source_pod_name` varchar(100) DEFAULT NULL," +
-			"	`destination_namespace` varchar(100) DEFAULT NULL," +
-			"	`destination_labels` varchar(200) DEFAULT NULL," +
-			"	`destination_pod_name` varchar(100) DEFAULT NULL," +
-			"	`type` varchar(100) DEFAULT NULL," +
-			"	`node_name` varchar(100) DEFAULT NULL," +
-			"	`l7_type` varchar(100) DEFAULT NULL," +
-			"	`l7_dns_cnames` varchar(100) DEFAULT NULL," +
-			"	`l7_dns_observation_source` varchar(150) DEFAULT NULL," +
-			"	`l7_http_code` INTEGER," +
-			"	`l7_http_method` varchar(100) DEFAULT NULL," +
-			"	`l7_http_url` varchar(200) DEFAULT NULL," +
-			"	`l7_http_protocol` varchar(50) DEFAULT NULL," +
-			"	`l7_http_headers` varchar(200) DEFAULT NULL," +
-			"	`event_type_type` INTEGER," +
-			"	`event_type_sub_type` INTEGER," +
-			"	`source_service_name` varchar(150) DEFAULT NULL," +
-			"	`source_service_namespace` varchar(100) DEFAULT NULL," +
-			"	`destination_service_name` varchar(100) DEFAULT NULL," +
-			"	`destination_service_namespace` varchar(100) DEFAULT NULL," +
-			"	`traffic_direction` varchar(100) DEFAULT NULL," +
-			"	`trace_observation_point` varchar(100) DEFAULT NULL," +
-			"	`drop_reason_desc` varchar(100) DEFAULT NULL," +
-			"	`is_reply` BOOLEAN," +
-			"	`start_time` bigint NOT NULL," +
-			"	`updated_time` bigint NOT NULL," +
-			"	`total` INTEGER, " +
-			"	PRIMARY KEY (`id`)" +
-			"  );"
-
-	_, err := db.Exec(query)
-	return err
-}
-
-func CreatePolicyTableSQLite(cfg types.ConfigDB) error {
-	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
-
-	tableName := PolicyYamlSQLite_TableName
-
-	query :=
-		"CREATE TABLE IF NOT EXISTS `" + tableName + "` (" +
-			"	`id` INTEGER AUTO_INCREMENT," +
-			"	`type` varchar(50) DEFAULT NULL," +
-			"	`kind` varchar(50) DEFAULT NULL," +
-			"	`cluster_name` varchar(50) DEFAULT NULL," +
-			"	`namespace` varchar(50) DEFAULT NULL," +
-			"	`labels` text DEFAULT NULL," +
-			"	`policy_name` varchar(150) DEFAULT NULL," +
-			"	`policy_yaml` text DEFAULT NULL," +
-			"	`updated_time` bigint NOT NULL," +
-			"	`workspace_id` INTEGER NOT NULL," +
-			"	`cluster_id` INTEGER NOT NULL," +
-			"	PRIMARY KEY (`id`)" +
-			"  );"
-
-	_, err := db.Exec(query)
-	return err
-}
-
-func CreateSystemSummaryTableSQLite(cfg types.ConfigDB) error {
-	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
-
-	query :=
-		"CREATE TABLE IF NOT EXISTS `" + TableSystemSummarySQLite + "` (" +
-			"	`id` INTEGER AUTO_INCREMENT," +
-			"	`cluster_name` varchar(50) DEFAULT NULL," +
-			"	`cluster_id` int DEFAULT NULL," +
-			"	`workspace_id` int DEFAULT NULL," +
-			"	`namespace_name` varchar(50) DEFAULT NULL," +
-			"	`namespace_id` int DEFAULT NULL," +
-			"	`container_name` varchar(50) DEFAULT NULL," +
-			"	`container_image` varchar(100) DEFAULT NULL," +
-			"	`container_id` varchar(150) DEFAULT NULL," +
-			"	`podname` varchar(50) DEFAULT NULL," +
-			"	`operation` varchar(10) DEFAULT NULL," +
-			"	`labels` varchar(100) DEFAULT NULL," +
-			"	`deployment_name` varchar(50) DEFAULT NULL," +
-			"	`source` varchar(100) DEFAULT NULL," +
-			"	`destination` varchar(100) DEFAULT NULL," +
-			"	`destination_namespace` varchar(50) DEFAULT NULL," +
-			"	`destination_labels` varchar(50) DEFAULT NULL," +
-			"	`type` varchar(10) DEFAULT NULL," +
-			"	`ip` int DEFAULT NULL," +
-			"	`port` varchar(10) DEFAULT NULL," +
-			"	`protocol` varchar(10) DEFAULT NULL," +
-			"	`bindport` varchar(10) DEFAULT NULL," +
-			"	`bindaddr` varchar(10) DEFAULT NULL," +
-			"	`action` varchar(10) DEFAULT NULL," +
-			"	`count` int NOT NULL," +
-			"	`updated_time` bigint NOT NULL," +
-			"	`hash_id` varchar(50) DEFAULT NULL UNIQUE," +
-			"	PRIMARY KEY (`id`)" +
-			"  );"
-
-	_, err := db.Exec(query)
-	return err
-}
-
 func concatWhereClauseSQLite(whereClause *string, field string) {
 	if *whereClause == "" {
 		*whereClause = " WHERE "
@@ -774,22 +577,35 @@ func concatWhereClauseSQLite(whereClause *string, field string) {
 	*whereClause = *whereClause + field + " = ?"
 }
 
-func concatWhereClauseIntRangeSQLite(whereClause *string, field string, start int64, end int64) {
+// concatWhereClauseInSQLite adds a placeholder for each of the count values of field
+func concatWhereClauseInSQLite(whereClause *string, field string, count int) {
 	if *whereClause == "" {
 		*whereClause = " WHERE "
 	} else {
 		*whereClause = *whereClause + " and "
 	}
-	*whereClause = *whereClause + field + " between " + strconv.Itoa(int(start)) + " and " + strconv.Itoa(int(end))
+	*whereClause = *whereClause + field + " IN (" + strings.TrimSuffix(strings.Repeat("?,", count), ",") + ")"
+}
+
+// concatWhereClauseIntRangeSQLite adds the placeholders of the bounds of the range of field
+func concatWhereClauseIntRangeSQLite(whereClause *string, field string) {
+	if *whereClause == "" {
+		*whereClause = " WHERE "
+	} else {
+		*whereClause = *whereClause + " and "
+	}
+	*whereClause = *whereClause + field + " between ? and ?"
 }
 
 // GetWorkloadProcessFileSetMySQL Handle File Sets in context to a given fromSource
 func GetWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error) {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var results *sql.Rows
-	var err error
 
 	query := "SELECT policyName,clusterName,namespace,containerName,labels,fromSource,settype,fileset FROM " + WorkloadProcessFileSetSQLite_TableName
 
@@ -814,7 +630,7 @@ func GetWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadProc
 	}
 	if wpfs.FromSource != "" {
 		concatWhereClauseSQLite(&whereClause, "fromSource")
-		args = append(args, wpfs.FromSource)
+		args = append(args, c.encrypt(wpfs.FromSource))
 	}
 	if wpfs.SetType != "" {
 		concatWhereClauseSQLite(&whereClause, "settype")
@@ -850,6 +666,9 @@ func GetWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadProc
 		); err != nil {
 			return nil, nil, err
 		}
+		if err := c.decryptFields(&loc_wpfs.FromSource, &fscsv); err != nil {
+			return nil, nil, err
+		}
 		fs = strings.Split(fscsv, types.RecordSeparator)
 		res[loc_wpfs] = fs
 		pnMap[loc_wpfs] = policyName
@@ -858,10 +677,16 @@ func GetWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadProc
 	return res, pnMap, nil
 }
 
-func InsertWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, fs []string) error {
+// InsertWorkloadProcessFileSetSQLite inserts a file set under the policy name, a new one if empty
+func InsertWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, policyName string, fs []string) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
-	policyName := "autopol-" + strings.ToLower(wpfs.SetType) + "-" + RandSeq(15)
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
+	if policyName == "" {
+		policyName = "autopol-" + strings.ToLower(wpfs.SetType) + "-" + RandSeq(15)
+	}
 	time := ConvertStrToUnixTime("now")
 
 	stmt, err := db.Prepare("INSERT INTO " + WorkloadProcessFileSetSQLite_TableName +
@@ -878,9 +703,9 @@ func InsertWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadP
 		wpfs.Namespace,
 		wpfs.ContainerName,
 		wpfs.Labels,
-		wpfs.FromSource,
+		c.encrypt(wpfs.FromSource),
 		wpfs.SetType,
-		fsset,
+		c.encrypt(fsset),
 		time,
 		time)
 	return err
@@ -889,9 +714,10 @@ func InsertWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadP
 // Clears out WPFS DB on full or as per options specified
 func ClearWPFSDbSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, duration int64) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
-
-	var err error
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
 
 	query := "DELETE FROM " + WorkloadProcessFileSetSQLite_TableName
 
@@ -917,13 +743,14 @@ func ClearWPFSDbSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, du
 	}
 	if wpfs.FromSource != "" {
 		concatWhereClauseSQLite(&whereClause, "fromSource")
-		args = append(args, wpfs.FromSource)
+		args = append(args, c.encrypt(wpfs.FromSource))
 	}
 	if duration != 0 {
-		concatWhereClauseIntRangeSQLite(&whereClause, "createdtime", time-duration, time)
+		concatWhereClauseIntRangeSQLite(&whereClause, "createdtime")
+		args = append(args, time-duration, time)
 	}
 
-	_, err = db.Query(query+whereClause, args...)
+	_, err = db.Exec(query+whereClause, args...)
 
 	if err != nil {
 		log.Error().Msg(err.Error())
@@ -934,9 +761,11 @@ func ClearWPFSDbSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, du
 
 func UpdateWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet, fs []string) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
 
-	var err error
 	time := ConvertStrToUnixTime("now")
 
 	// set status -> outdated
@@ -948,13 +777,13 @@ func UpdateWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadP
 	defer stmt.Close()
 	fsset := strings.Join(fs[:], types.RecordSeparator)
 
-	_, err = stmt.Exec(fsset,
+	_, err = stmt.Exec(c.encrypt(fsset),
 		time,
 		wpfs.ClusterName,
 		wpfs.ContainerName,
 		wpfs.Namespace,
 		wpfs.Labels,
-		wpfs.FromSource,
+		c.encrypt(wpfs.FromSource),
 		wpfs.SetType)
 
 	/*
@@ -966,107 +795,291 @@ func UpdateWorkloadProcessFileSetSQLite(cfg types.ConfigDB, wpfs types.WorkloadP
 	return err
 }
 
-// =================== //
-// == Observability == //
-// =================== //
+// GetWorkloadProcessFileSetCreatedTimeSQLite returns the creation time of the WPFS entry
+func GetWorkloadProcessFileSetCreatedTimeSQLite(cfg types.ConfigDB, wpfs types.WorkloadProcessFileSet) (int64, error) {
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return 0, err
+	}
 
-// UpdateOrInsertKubearmorLogsSQLite -- Update existing log or insert a new log into DB
-func UpdateOrInsertKubearmorLogsSQLite(cfg types.ConfigDB, kubearmorlogmap map[types.KubeArmorLog]int) error {
-	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	var createdTime int64
+	err = db.QueryRow("SELECT createdTime FROM "+WorkloadProcessFileSetSQLite_TableName+
+		" WHERE clusterName = ? and containerName = ? and namespace = ? and labels = ? and fromSource = ? and settype = ?",
+		wpfs.ClusterName,
+		wpfs.ContainerName,
+		wpfs.Namespace,
+		wpfs.Labels,
+		c.encrypt(wpfs.FromSource),
+		wpfs.SetType).Scan(&createdTime)
+	if err != nil {
+		return 0, err
+	}
 
-	start := time.Now().UnixNano() / int64(time.Millisecond)
-	log.Info().Msgf("sqlite update or insert %d", len(kubearmorlogmap))
-	for kubearmorlog, count := range kubearmorlogmap {
-		if err := updateOrInsertKubearmorLogsSQLite(db, kubearmorlog, count); err != nil {
-			log.Error().Msg(err.Error())
-		}
+	return createdTime, nil
+}
+
+// ==================== //
+// == System Anomaly == //
+// ==================== //
+
+// UpsertSystemAnomalySQLite inserts a new anomaly or updates the count of an existing one,
+// returns true if the anomaly was not seen before
+func UpsertSystemAnomalySQLite(cfg types.ConfigDB, anomaly types.SystemAnomaly) (bool, error) {
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return false, err
+	}
+	// the encryption is deterministic, the encrypted key of an anomaly still matches its row
+	c.encryptFields(systemAnomalySecrets(&anomaly)...)
+
+	keyClause := " WHERE clusterName = ? and namespace = ? and containerName = ? and labels = ? and fromSource = ? and operation = ? and resource = ?"
+	keyArgs := []interface{}{
+		anomaly.ClusterName,
+		anomaly.Namespace,
+		anomaly.ContainerName,
+		anomaly.Labels,
+		anomaly.FromSource,
+		anomaly.Operation,
+		anomaly.Resource,
+	}
+
+	var id int
+	err = db.QueryRow("SELECT id FROM "+TableSystemAnomalySQLite_TableName+keyClause, keyArgs...).Scan(&id)
+	if err == nil {
+		_, err = db.Exec("UPDATE "+TableSystemAnomalySQLite_TableName+" SET count=count+?,lastSeen=? WHERE id = ?",
+			anomaly.Count, anomaly.LastSeen, id)
+		return false, err
+	} else if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	_, err = db.Exec("INSERT INTO "+TableSystemAnomalySQLite_TableName+
+		"(clusterName,namespace,containerName,labels,fromSource,operation,resource,severity,count,firstSeen,lastSeen) values(?,?,?,?,?,?,?,?,?,?,?)",
+		anomaly.ClusterName,
+		anomaly.Namespace,
+		anomaly.ContainerName,
+		anomaly.Labels,
+		anomaly.FromSource,
+		anomaly.Operation,
+		anomaly.Resource,
+		anomaly.Severity,
+		anomaly.Count,
+		anomaly.FirstSeen,
+		anomaly.LastSeen)
+	if err != nil {
+		return false, err
 	}
-	end := time.Now().UnixNano() / int64(time.Millisecond)
-	log.Info().Msgf("return sqlite update or insert %d time-taken-ms:%d", len(kubearmorlogmap), end-start)
-	return nil
+
+	return true, nil
 }
 
-func updateOrInsertKubearmorLogsSQLite(db *sql.DB, kubearmorlog types.KubeArmorLog, count int) error {
-	queryString := `cluster_name = ? and namespace_name = ? and pod_name = ? and container_name = ? and operation = ? and labels = ? 
-					and data = ? and category = ? and action = ? and result = ? and source = ? and resource = ?`
+func GetSystemAnomaliesSQLite(cfg types.ConfigDB, filter types.AnomalyFilter) ([]types.SystemAnomaly, error) {
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	query := "UPDATE " + TableSystemLogs_TableName + " SET total=total+?, updated_time=? WHERE " + queryString + " "
+	query := "SELECT clusterName,namespace,containerName,labels,fromSource,operation,resource,severity,count,firstSeen,lastSeen FROM " + TableSystemAnomalySQLite_TableName
 
-	updateStmt, err := db.Prepare(query)
+	var whereClause string
+	var args []interface{}
+
+	if filter.Cluster != "" {
+		concatWhereClauseSQLite(&whereClause, "clusterName")
+		args = append(args, filter.Cluster)
+	}
+	if filter.Namespace != "" {
+		concatWhereClauseSQLite(&whereClause, "namespace")
+		args = append(args, filter.Namespace)
+	}
+
+	results, err := db.Query(query+whereClause+" ORDER BY firstSeen", args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer updateStmt.Close()
+	defer results.Close()
 
-	result, err := updateStmt.Exec(
-		count,
-		ConvertStrToUnixTime("now"),
-		kubearmorlog.ClusterName,
-		kubearmorlog.NamespaceName,
-		kubearmorlog.PodName,
-		kubearmorlog.ContainerName,
-		kubearmorlog.Operation,
-		kubearmorlog.Labels,
-		kubearmorlog.Data,
-		kubearmorlog.Category,
-		kubearmorlog.Action,
-		kubearmorlog.Result,
-		kubearmorlog.Source,
-		kubearmorlog.Resource,
-	)
+	anomalies := []types.SystemAnomaly{}
+	for results.Next() {
+		var anomaly types.SystemAnomaly
+		if err := results.Scan(
+			&anomaly.ClusterName,
+			&anomaly.Namespace,
+			&anomaly.ContainerName,
+			&anomaly.Labels,
+			&anomaly.FromSource,
+			&anomaly.Operation,
+			&anomaly.Resource,
+			&anomaly.Severity,
+			&anomaly.Count,
+			&anomaly.FirstSeen,
+			&anomaly.LastSeen,
+		); err != nil {
+			return nil, err
+		}
+		if err := c.decryptFields(systemAnomalySecrets(&anomaly)...); err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	return anomalies, results.Err()
+}
+
+// ================= //
+// == Dead Letter == //
+// ================= //
+
+func InsertDeadLetterSQLite(cfg types.ConfigDB, deadLetter types.DeadLetter) error {
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+	c, err := getSQLiteCipher(cfg)
 	if err != nil {
-		log.Error().Msg(err.Error())
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	_, err = db.Exec("INSERT INTO "+TableDeadLetterSQLite_TableName+
+		"(driver,topic,msgPartition,msgOffset,msgID,payload,reason,createdTime) values(?,?,?,?,?,?,?,?)",
+		deadLetter.Driver,
+		deadLetter.Topic,
+		deadLetter.Partition,
+		deadLetter.Offset,
+		deadLetter.MessageID,
+		c.encryptBytes(deadLetter.Payload),
+		deadLetter.Reason,
+		deadLetter.CreatedTime)
+	return err
+}
 
-	if err == nil && rowsAffected == 0 {
+// GetDeadLettersSQLite returns the dead letters in the order they were written
+func GetDeadLettersSQLite(cfg types.ConfigDB, filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		updateQueryString := `(cluster_name,namespace_name,pod_name,container_name,operation,labels,data,category,action,
-		updated_time,result,total,source,resource) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+	query := "SELECT id,driver,topic,msgPartition,msgOffset,msgID,payload,reason,createdTime FROM " + TableDeadLetterSQLite_TableName
 
-		updateQuery := "INSERT INTO " + TableSystemLogs_TableName + updateQueryString
+	var whereClause string
+	var args []interface{}
 
-		insertStmt, err := db.Prepare(updateQuery)
-		if err != nil {
-			return err
+	if filter.Topic != "" {
+		concatWhereClauseSQLite(&whereClause, "topic")
+		args = append(args, filter.Topic)
+	}
+	if len(filter.IDs) > 0 {
+		concatWhereClauseInSQLite(&whereClause, "id", len(filter.IDs))
+		for _, id := range filter.IDs {
+			args = append(args, id)
 		}
-		defer insertStmt.Close()
+	}
 
-		_, err = insertStmt.Exec(
-			kubearmorlog.ClusterName,
-			kubearmorlog.NamespaceName,
-			kubearmorlog.PodName,
-			kubearmorlog.ContainerName,
-			kubearmorlog.Operation,
-			kubearmorlog.Labels,
-			kubearmorlog.Data,
-			kubearmorlog.Category,
-			kubearmorlog.Action,
-			ConvertStrToUnixTime("now"),
-			kubearmorlog.Result,
-			count,
-			kubearmorlog.Source,
-			kubearmorlog.Resource)
-		if err != nil {
-			log.Error().Msg(err.Error())
-			return err
+	query = query + whereClause + " ORDER BY id"
+	if filter.Limit > 0 {
+		query = query + " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	results, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	deadLetters := []types.DeadLetter{}
+	for results.Next() {
+		var deadLetter types.DeadLetter
+		if err := results.Scan(
+			&deadLetter.ID,
+			&deadLetter.Driver,
+			&deadLetter.Topic,
+			&deadLetter.Partition,
+			&deadLetter.Offset,
+			&deadLetter.MessageID,
+			&deadLetter.Payload,
+			&deadLetter.Reason,
+			&deadLetter.CreatedTime,
+		); err != nil {
+			return nil, err
+		}
+		if deadLetter.Payload, err = c.decryptBytes(deadLetter.Payload); err != nil {
+			return nil, err
 		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, results.Err()
+}
+
+func DeleteDeadLettersSQLite(cfg types.ConfigDB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
 	}
 
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+
+	var whereClause string
+	var args []interface{}
+
+	concatWhereClauseInSQLite(&whereClause, "id", len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := db.Exec("DELETE FROM "+TableDeadLetterSQLite_TableName+whereClause, args...)
+	return err
+}
+
+// =================== //
+// == Observability == //
+// =================== //
+
+// UpdateOrInsertKubearmorLogsSQLite -- Update existing log or insert a new log into DB, in one transaction
+func UpdateOrInsertKubearmorLogsSQLite(cfg types.ConfigDB, kubearmorlogmap map[types.KubeArmorLog]int) error {
+	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
+
+	if c != nil {
+		encryptedLogMap := map[types.KubeArmorLog]int{}
+		for kubearmorlog, count := range kubearmorlogmap {
+			c.encryptFields(kubearmorLogSecrets(&kubearmorlog)...)
+			encryptedLogMap[kubearmorlog] += count
+		}
+		kubearmorlogmap = encryptedLogMap
+	}
+
+	start := time.Now().UnixMilli()
+	err = inTx(db, func(tx *sql.Tx) error {
+		return updateOrInsertKubearmorLogsSQL(tx, TableSystemLogsSQLite_TableName, kubearmorlogmap)
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return err
+	}
+	log.Info().Msgf("sqlite update or insert %d time-taken-ms:%d", len(kubearmorlogmap), time.Now().UnixMilli()-start)
+
 	return nil
 }
 
 // GetSystemLogsMySQL
 func GetSystemLogsSQLite(cfg types.ConfigDB, filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
 	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.encryptFields(kubearmorLogSecrets(&filterLog)...)
 
 	resLog := []types.KubeArmorLog{}
 	resTotal := []uint32{}
 
 	var results *sql.Rows
-	var err error
 
 	queryString := `cluster_name,namespace_name,pod_name,container_name,operation,labels,data,category,action,updated_time,result,total,source,resource`
 
@@ -1156,6 +1169,9 @@ func GetSystemLogsSQLite(cfg types.ConfigDB, filterLog types.KubeArmorLog) ([]ty
 		); err != nil {
 			return nil, nil, err
 		}
+		if err := c.decryptFields(kubearmorLogSecrets(&loc_log)...); err != nil {
+			return nil, nil, err
+		}
 		resLog = append(resLog, loc_log)
 		resTotal = append(resTotal, loc_total)
 	}
@@ -1165,20 +1181,34 @@ func GetSystemLogsSQLite(cfg types.ConfigDB, filterLog types.KubeArmorLog) ([]ty
 
 // GetNetworkLogsMySQL
 func GetCiliumLogsSQLite(cfg types.ConfigDB, filterLog types.CiliumLog) ([]types.CiliumLog, []uint32, error) {
+	return getCiliumLogsSQLite(cfg, filterLog, 0)
+}
+
+// GetCiliumLogsUpdatedAfterSQLite returns the network logs updated after the time
+func GetCiliumLogsUpdatedAfterSQLite(cfg types.ConfigDB, updatedTime int64) ([]types.CiliumLog, []uint32, error) {
+	return getCiliumLogsSQLite(cfg, types.CiliumLog{}, updatedTime)
+}
+
+// getCiliumLogsSQLite returns the network logs of the filter, updated after the time if any
+func getCiliumLogsSQLite(cfg types.ConfigDB, filterLog types.CiliumLog, updatedAfter int64) ([]types.CiliumLog, []uint32, error) {
 	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.encryptFields(ciliumLogSecrets(&filterLog)...)
 
 	resLog := []types.CiliumLog{}
 	resTotal := []uint32{}
 
 	var results *sql.Rows
-	var err error
 
 	queryString := ` verdict,ip_source,ip_destination,ip_version,ip_encrypted,l4_tcp_source_port,l4_tcp_destination_port,
 	l4_udp_source_port,l4_udp_destination_port,l4_icmpv4_type,l4_icmpv4_code,l4_icmpv6_type,l4_icmpv6_code,
 	source_namespace,source_labels,source_pod_name,destination_namespace,destination_labels,destination_pod_name,
 	type,node_name,l7_type,l7_dns_cnames,l7_dns_observation_source,l7_http_code,l7_http_method,l7_http_url,l7_http_protocol,l7_http_headers,
 	event_type_type,event_type_sub_type,source_service_name,source_service_namespace,destination_service_name,destination_service_namespace,
-	traffic_direction,trace_observation_point,drop_reason_desc,is_reply,start_time,updated_time,total`
+	traffic_direction,trace_observation_point,drop_reason_desc,is_reply,start_time,updated_time,total,l4_tcp_flags`
 
 	query := "SELECT " + queryString + " FROM " + TableNetworkLogsSQLite_TableName + " "
 
@@ -1213,6 +1243,10 @@ func GetCiliumLogsSQLite(cfg types.ConfigDB, filterLog types.CiliumLog) ([]types
 		concatWhereClause(&whereClause, "l4_tcp_destination_port")
 		args = append(args, filterLog.L4TCPDestinationPort)
 	}
+	if filterLog.L4TCPFlags != "" {
+		concatWhereClause(&whereClause, "l4_tcp_flags")
+		args = append(args, filterLog.L4TCPFlags)
+	}
 	if filterLog.L4UDPSourcePort != 0 {
 		concatWhereClause(&whereClause, "l4_udp_source_port")
 		args = append(args, filterLog.L4UDPSourcePort)
@@ -1349,6 +1383,10 @@ func GetCiliumLogsSQLite(cfg types.ConfigDB, filterLog types.CiliumLog) ([]types
 		concatWhereClause(&whereClause, "total")
 		args = append(args, filterLog.Total)
 	}
+	if updatedAfter != 0 {
+		concatWhereClauseAfter(&whereClause, "updated_time")
+		args = append(args, updatedAfter)
+	}
 
 	results, err = db.Query(query+whereClause, args...)
 
@@ -1404,159 +1442,41 @@ func GetCiliumLogsSQLite(cfg types.ConfigDB, filterLog types.CiliumLog) ([]types
 			&loc_log.StartTime,
 			&loc_log.UpdatedTime,
 			&loc_total,
+			&loc_log.L4TCPFlags,
 		); err != nil {
 			return nil, nil, err
 		}
+		if err := c.decryptFields(ciliumLogSecrets(&loc_log)...); err != nil {
+			return nil, nil, err
+		}
 		resLog = append(resLog, loc_log)
 		resTotal = append(resTotal, loc_total)
 	}
 	return resLog, resTotal, err
 }
 
-// UpdateCiliumLogsMySQL -- Update existing log with time and count
+// UpdateOrInsertCiliumLogsSQLite -- Update existing log with time and count or insert a new log, in one transaction
 func UpdateOrInsertCiliumLogsSQLite(cfg types.ConfigDB, ciliumlogs []types.CiliumLog) error {
-	var err error = nil
 	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
-
-	for _, ciliumLog := range ciliumlogs {
-		if err := updateOrInsertCiliumLogSQLite(db, ciliumLog); err != nil {
-			log.Error().Msg(err.Error())
-		}
-	}
-	return err
-}
-
-func updateOrInsertCiliumLogSQLite(db *sql.DB, ciliumlog types.CiliumLog) error {
-	var err error
-	queryString := `verdict = ? and ip_source = ? and ip_destination = ? and ip_version = ? and ip_encrypted = ? and l4_tcp_source_port = ? and 
-					l4_tcp_destination_port = ? and l4_udp_source_port = ? and l4_udp_destination_port = ? and l4_icmpv4_type = ? and 
-					l4_icmpv4_code = ? and l4_icmpv6_type = ? and l4_icmpv6_code = ? and source_namespace = ? and source_labels = ? and 
-					source_pod_name = ? and destination_namespace = ? and destination_labels = ? and destination_pod_name = ? and type = ? and 
-					node_name = ? and l7_type = ? and l7_dns_cnames = ? and l7_dns_observation_source = ? and l7_http_code = ? and 
-					l7_http_method = ? and l7_http_url = ? and l7_http_protocol = ? and l7_http_headers = ? and event_type_type = ? and 
-					event_type_sub_type = ? and source_service_name = ? and source_service_namespace = ? and destination_service_name = ? and 
-					destination_service_namespace = ? and traffic_direction = ? and trace_observation_point = ? and drop_reason_desc = ? and is_reply = ? `
-
-	query := "UPDATE " + TableNetworkLogsSQLite_TableName + " SET total=total+1, updated_time=? WHERE " + queryString + " "
-
-	updateStmt, err := db.Prepare(query)
+	c, err := getSQLiteCipher(cfg)
 	if err != nil {
 		return err
 	}
-	defer updateStmt.Close()
 
-	result, err := updateStmt.Exec(
-		ciliumlog.UpdatedTime,
-		ciliumlog.Verdict,
-		ciliumlog.IpSource,
-		ciliumlog.IpDestination,
-		ciliumlog.IpVersion,
-		ciliumlog.IpEncrypted,
-		ciliumlog.L4TCPSourcePort,
-		ciliumlog.L4TCPDestinationPort,
-		ciliumlog.L4UDPSourcePort,
-		ciliumlog.L4UDPDestinationPort,
-		ciliumlog.L4ICMPv4Type,
-		ciliumlog.L4ICMPv4Code,
-		ciliumlog.L4ICMPv6Type,
-		ciliumlog.L4ICMPv6Code,
-		ciliumlog.SourceNamespace,
-		ciliumlog.SourceLabels,
-		ciliumlog.SourcePodName,
-		ciliumlog.DestinationNamespace,
-		ciliumlog.DestinationLabels,
-		ciliumlog.DestinationPodName,
-		ciliumlog.Type,
-		ciliumlog.NodeName,
-		ciliumlog.L7Type,
-		ciliumlog.L7DnsCnames,
-		ciliumlog.L7DnsObservationsource,
-		ciliumlog.L7HttpCode,
-		ciliumlog.L7HttpMethod,
-		ciliumlog.L7HttpUrl,
-		ciliumlog.L7HttpProtocol,
-		ciliumlog.L7HttpHeaders,
-		ciliumlog.EventTypeType,
-		ciliumlog.EventTypeSubType,
-		ciliumlog.SourceServiceName,
-		ciliumlog.SourceServiceNamespace,
-		ciliumlog.DestinationServiceName,
-		ciliumlog.DestinationServiceNamespace,
-		ciliumlog.TrafficDirection,
-		ciliumlog.TraceObservationPoint,
-		ciliumlog.DropReasonDesc,
-		ciliumlog.IsReply,
-	)
-	if err != nil {
-		log.Error().Msg(err.Error())
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-
-	if err == nil && rowsAffected == 0 {
-		insertQueryString := `(verdict,ip_source,ip_destination,ip_version,ip_encrypted,l4_tcp_source_port,l4_tcp_destination_port,
-			l4_udp_source_port,l4_udp_destination_port,l4_icmpv4_type,l4_icmpv4_code,l4_icmpv6_type,l4_icmpv6_code,
-			source_namespace,source_labels,source_pod_name,destination_namespace,destination_labels,destination_pod_name,
-			type,node_name,l7_type,l7_dns_cnames,l7_dns_observation_source,l7_http_code,l7_http_method,l7_http_url,l7_http_protocol,l7_http_headers,
-			event_type_type,event_type_sub_type,source_service_name,source_service_namespace,destination_service_name,destination_service_namespace,
-			traffic_direction,trace_observation_point,drop_reason_desc,is_reply,start_time,updated_time,total) 
-			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
-
-		query := "INSERT INTO " + TableNetworkLogsSQLite_TableName + insertQueryString
-
-		insertStmt, err := db.Prepare(query)
-		if err != nil {
-			return err
+	if c != nil {
+		encryptedLogs := make([]types.CiliumLog, len(ciliumlogs))
+		for i, ciliumlog := range ciliumlogs {
+			c.encryptFields(ciliumLogSecrets(&ciliumlog)...)
+			encryptedLogs[i] = ciliumlog
 		}
-		defer insertStmt.Close()
+		ciliumlogs = encryptedLogs
+	}
 
-		_, err = insertStmt.Exec(
-			ciliumlog.Verdict,
-			ciliumlog.IpSource,
-			ciliumlog.IpDestination,
-			ciliumlog.IpVersion,
-			ciliumlog.IpEncrypted,
-			ciliumlog.L4TCPSourcePort,
-			ciliumlog.L4TCPDestinationPort,
-			ciliumlog.L4UDPSourcePort,
-			ciliumlog.L4UDPDestinationPort,
-			ciliumlog.L4ICMPv4Type,
-			ciliumlog.L4ICMPv4Code,
-			ciliumlog.L4ICMPv6Type,
-			ciliumlog.L4ICMPv6Code,
-			ciliumlog.SourceNamespace,
-			ciliumlog.SourceLabels,
-			ciliumlog.SourcePodName,
-			ciliumlog.DestinationNamespace,
-			ciliumlog.DestinationLabels,
-			ciliumlog.DestinationPodName,
-			ciliumlog.Type,
-			ciliumlog.NodeName,
-			ciliumlog.L7Type,
-			ciliumlog.L7DnsCnames,
-			ciliumlog.L7DnsObservationsource,
-			ciliumlog.L7HttpCode,
-			ciliumlog.L7HttpMethod,
-			ciliumlog.L7HttpUrl,
-			ciliumlog.L7HttpProtocol,
-			ciliumlog.L7HttpHeaders,
-			ciliumlog.EventTypeType,
-			ciliumlog.EventTypeSubType,
-			ciliumlog.SourceServiceName,
-			ciliumlog.SourceServiceNamespace,
-			ciliumlog.DestinationServiceName,
-			ciliumlog.DestinationServiceNamespace,
-			ciliumlog.TrafficDirection,
-			ciliumlog.TraceObservationPoint,
-			ciliumlog.DropReasonDesc,
-			ciliumlog.IsReply,
-			ciliumlog.StartTime,
-			ciliumlog.UpdatedTime,
-			1)
-		if err != nil {
-			log.Error().Msg(err.Error())
-		}
+	err = inTx(db, func(tx *sql.Tx) error {
+		return updateOrInsertCiliumLogsSQL(tx, TableNetworkLogsSQLite_TableName, ciliumlogs)
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
 	}
 
 	return err
@@ -1585,7 +1505,7 @@ func GetPodNamesSQLite(cfg types.ConfigDB, filter types.ObsPodDetail) ([]string,
 		sysargs = append(sysargs, filter.Namespace)
 	}
 	if filter.PodName != "" {
-		concatWhereClause(&whereClause, "pod_name")
+		concatWhereClause(&whereClause, "podname")
 		sysargs = append(sysargs, filter.PodName)
 	}
 	if filter.Labels != "" {
@@ -1676,16 +1596,19 @@ func GetDeployNamesSQLite(cfg types.ConfigDB, filter types.ObsPodDetail) ([]stri
 // == Policy DB == //
 // =============== //
 
-func GetPolicyYamlsSQLite(cfg types.ConfigDB, policyType string, filterOptions types.PolicyFilter) ([]types.PolicyYaml, error) {
+func GetPolicyYamlsSQLite(cfg types.ConfigDB, policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error) {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, "", err
+	}
 
 	policies := []types.PolicyYaml{}
+	ids := []int64{}
 
 	var results *sql.Rows
-	var err error
 
-	query := "SELECT type,kind,cluster_name,namespace,labels,policy_name,policy_yaml,workspace_id,cluster_id FROM " + PolicyYaml_TableName
+	query := "SELECT rowid,type,kind,cluster_name,namespace,labels,policy_name,policy_yaml,workspace_id,cluster_id FROM " + PolicyYaml_TableName
 
 	var whereClause string
 	var args []interface{}
@@ -1703,23 +1626,35 @@ func GetPolicyYamlsSQLite(cfg types.ConfigDB, policyType string, filterOptions t
 		args = append(args, filterOptions.Cluster)
 	}
 
+	if filterOptions.Kind != "" {
+		concatWhereClause(&whereClause, "kind")
+		args = append(args, filterOptions.Kind)
+	}
+
 	if labels := LabelMapToString(filterOptions.Labels); labels != "" {
 		concatWhereClause(&whereClause, "labels")
 		args = append(args, labels)
 	}
 
-	results, err = db.Query(query+whereClause, args...)
+	order, err := concatPageClause(&whereClause, &args, "rowid", page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err = db.Query(query+whereClause+order, args...)
 	if err != nil {
 		log.Error().Msg(err.Error())
-		return nil, err
+		return nil, "", err
 	}
 	defer results.Close()
 
 	for results.Next() {
+		var id int64
 		var labels string
 		policy := types.PolicyYaml{}
 
 		if err := results.Scan(
+			&id,
 			&policy.Type,
 			&policy.Kind,
 			&policy.Cluster,
@@ -1730,21 +1665,30 @@ func GetPolicyYamlsSQLite(cfg types.ConfigDB, policyType string, filterOptions t
 			&policy.WorkspaceId,
 			&policy.ClusterId,
 		); err != nil {
-			return nil, err
+			return nil, "", err
+		}
+		if policy.Yaml, err = c.decryptBytes(policy.Yaml); err != nil {
+			return nil, "", err
 		}
 
 		policy.Labels = LabelMapFromString(labels)
 		policies = append(policies, policy)
+		ids = append(ids, id)
 	}
 
-	return policies, nil
+	policies, next := cutPage(page, policies, ids)
+	return policies, next, nil
 }
 
 func UpdateOrInsertPolicyYamlsSQLite(cfg types.ConfigDB, policies []types.PolicyYaml) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
 
 	for _, pol := range policies {
+		pol.Yaml = c.encryptBytes(pol.Yaml)
 		if err := updateOrInsertPolicyYamlSQLite(db, pol); err != nil {
 			log.Error().Msg(err.Error())
 		}
@@ -1805,12 +1749,6 @@ func updateOrInsertPolicyYamlSQLite(db *sql.DB, policy types.PolicyYaml) error {
 
 func DeletePolicyBasedOnPolicyNameSQLite(cfg types.ConfigDB, policyName, namespace, labels string) error {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			log.Warn().Msgf("error while closing db err=%v", err.Error())
-		}
-	}(db)
 
 	query := "DELETE FROM " + PolicyYamlSQLite_TableName + " WHERE policy_name = ? AND namespace = ? AND labels = ?"
 	deleteStmt, err := db.Prepare(query)
@@ -1847,42 +1785,121 @@ func DeletePolicyBasedOnPolicyNameSQLite(cfg types.ConfigDB, policyName, namespa
 // ================ //
 func UpsertSystemSummarySQLite(cfg types.ConfigDB, sysSummary map[types.SystemSummary]types.SysSummaryTimeCount) error {
 	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
 
-	for ss, sstc := range sysSummary {
-		if err := upsertSysSummarySQL(db, ss, sstc); err != nil {
-			log.Error().Msg(err.Error())
-			return err
+	if c != nil {
+		encryptedSummary := map[types.SystemSummary]types.SysSummaryTimeCount{}
+		for summary, timeCount := range sysSummary {
+			c.encryptFields(systemSummarySecrets(&summary)...)
+			encryptedSummary[summary] = timeCount
 		}
+		sysSummary = encryptedSummary
 	}
 
-	return nil
+	err = inTx(db, func(tx *sql.Tx) error {
+		return upsertSysSummariesSQL(tx, TableSystemSummarySQLite, " ON CONFLICT(hash_id) DO UPDATE SET count=count+?,updated_time=?;", sysSummary)
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
+	}
+
+	return err
 }
 
 func GetSystemSummarySQLite(cfg types.ConfigDB, filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
 	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.encryptFields(systemSummarySecrets(&filterOptions)...)
 
 	res, err := getSysSummarySQL(db, TableSystemSummarySQLite, filterOptions)
+	if err != nil {
+		return res, err
+	}
 
-	return res, err
+	for i := range res {
+		if err := c.decryptFields(systemSummarySecrets(&res[i])...); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// UpsertSystemSummaryRollupsSQLite adds the counts of the rollups to the counts of the same rollups,
+// the rollups are hashed on their encrypted columns as the downsampled ones
+func UpsertSystemSummaryRollupsSQLite(cfg types.ConfigDB, rollups []types.SystemSummaryRollup) error {
+	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return err
+	}
+
+	encryptedRollups := make([]types.SystemSummaryRollup, len(rollups))
+	for i, rollup := range rollups {
+		c.encryptFields(systemSummarySecrets(&rollup.Summary)...)
+		encryptedRollups[i] = rollup
+	}
+
+	return inTx(db, func(tx *sql.Tx) error {
+		return upsertSummaryRollupsSQL(tx, " ON CONFLICT(hash_id) DO UPDATE SET count=count+?", summaryRollupsMap(encryptedRollups))
+	})
+}
+
+func GetSystemSummaryRollupsSQLite(cfg types.ConfigDB, filterOptions types.SystemSummary) ([]types.SystemSummaryRollup, error) {
+	db := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	c, err := getSQLiteCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.encryptFields(systemSummarySecrets(&filterOptions)...)
+
+	res, err := getSummaryRollupsSQL(db, filterOptions)
+	if err != nil {
+		return res, err
+	}
+
+	for i := range res {
+		if err := c.decryptFields(systemSummarySecrets(&res[i].Summary)...); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 // ========================== //
 // == Purge Old DB Entries == //
 // ========================== //
-func PurgeOldDBEntriesSQLite(cfg types.ConfigDB) {
+// PurgeOldDBEntriesSQLite downsamples the old summaries and purges the tables by their retention policies
+func PurgeOldDBEntriesSQLite(cfg types.ConfigDB, purge types.ConfigPurgeOldDBEntries) (PurgeReport, error) {
 	db := connectSQLite(cfg, cfg.SQLiteDBPath)
-	defer db.Close()
+	// the logs and the summaries are in the observability db
+	obsDB := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
 
-	timeNow := (ConvertStrToUnixTime("now"))
-	purgeTime := (config.GetCfgObservabilitySummaryCronInterval()) //sec
-	PurgeTimeValue, err := strconv.ParseInt(purgeTime, 10, 64)
-	if err != nil {
-		log.Error().Msg(err.Error())
+	report := newPurgeReport()
+	now := ConvertStrToUnixTime("now")
+
+	if purge.Downsampling.Enable {
+		if err := inTx(obsDB, func(tx *sql.Tx) error {
+			return downsampleSummariesSQL(tx, "rowid", " ON CONFLICT(hash_id) DO UPDATE SET count=count+?", purge.Downsampling, now, report)
+		}); err != nil {
+			return report, err
+		}
 	}
-	// Running DB Query
-	ConvertedValue := timeNow - PurgeTimeValue
-	query := "DELETE FROM system_summary WHERE updated_time < " + strconv.Itoa(int(ConvertedValue))
-	if _, err := db.Query(query); err != nil {
-		log.Error().Msg(err.Error())
+
+	for _, table := range retentionTables {
+		tableDB := obsDB
+		if table.name == WorkloadProcessFileSetSQLite_TableName {
+			tableDB = db
+		}
+		if err := purgeTableSQL(tableDB, table, purge.Retention[table.name], now, report); err != nil {
+			return report, err
+		}
 	}
+
+	return report, nil
 }
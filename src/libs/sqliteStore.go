@@ -0,0 +1,178 @@
+package libs
+
+import (
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// sqliteStore is the Store of the SQLite database
+type sqliteStore struct {
+	cfg types.ConfigDB
+}
+
+func (s *sqliteStore) GetNetworkPolicies(filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error) {
+	return GetNetworkPoliciesFromSQLite(s.cfg, filter, page)
+}
+
+func (s *sqliteStore) InsertNetworkPolicies(policies []types.KnoxNetworkPolicy) error {
+	return InsertNetworkPoliciesToSQLite(s.cfg, policies)
+}
+
+func (s *sqliteStore) UpdateNetworkPolicy(policy types.KnoxNetworkPolicy) error {
+	return UpdateNetworkPolicyToSQLite(s.cfg, policy)
+}
+
+func (s *sqliteStore) UpdateOutdatedNetworkPolicy(outdatedPolicy string, latestPolicy string) error {
+	return UpdateOutdatedNetworkPolicyFromSQLite(s.cfg, outdatedPolicy, latestPolicy)
+}
+
+func (s *sqliteStore) GetSystemPolicies(namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error) {
+	return GetSystemPoliciesFromSQLite(s.cfg, namespace, status, page)
+}
+
+func (s *sqliteStore) InsertSystemPolicies(policies []types.KnoxSystemPolicy) error {
+	return InsertSystemPoliciesToSQLite(s.cfg, policies)
+}
+
+func (s *sqliteStore) UpdateSystemPolicy(policy types.KnoxSystemPolicy) error {
+	return UpdateSystemPolicyToSQLite(s.cfg, policy)
+}
+
+func (s *sqliteStore) UpdateOutdatedSystemPolicy(outdatedPolicy string, latestPolicy string) error {
+	return UpdateOutdatedSystemPolicyFromSQLite(s.cfg, outdatedPolicy, latestPolicy)
+}
+
+func (s *sqliteStore) GetWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error) {
+	return GetWorkloadProcessFileSetSQLite(s.cfg, wpfs)
+}
+
+func (s *sqliteStore) InsertWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, policyName string, fs []string) error {
+	return InsertWorkloadProcessFileSetSQLite(s.cfg, wpfs, policyName, fs)
+}
+
+func (s *sqliteStore) UpdateWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, fs []string) error {
+	return UpdateWorkloadProcessFileSetSQLite(s.cfg, wpfs, fs)
+}
+
+func (s *sqliteStore) ClearWPFS(wpfs types.WorkloadProcessFileSet, duration int64) error {
+	return ClearWPFSDbSQLite(s.cfg, wpfs, duration)
+}
+
+func (s *sqliteStore) GetWorkloadProcessFileSetCreatedTime(wpfs types.WorkloadProcessFileSet) (int64, error) {
+	return GetWorkloadProcessFileSetCreatedTimeSQLite(s.cfg, wpfs)
+}
+
+func (s *sqliteStore) UpsertSystemAnomaly(anomaly types.SystemAnomaly) (bool, error) {
+	return UpsertSystemAnomalySQLite(s.cfg, anomaly)
+}
+
+func (s *sqliteStore) GetSystemAnomalies(filter types.AnomalyFilter) ([]types.SystemAnomaly, error) {
+	return GetSystemAnomaliesSQLite(s.cfg, filter)
+}
+
+func (s *sqliteStore) InsertDeadLetter(deadLetter types.DeadLetter) error {
+	return InsertDeadLetterSQLite(s.cfg, deadLetter)
+}
+
+func (s *sqliteStore) GetDeadLetters(filter types.DeadLetterFilter) ([]types.DeadLetter, error) {
+	return GetDeadLettersSQLite(s.cfg, filter)
+}
+
+func (s *sqliteStore) DeleteDeadLetters(ids []int64) error {
+	return DeleteDeadLettersSQLite(s.cfg, ids)
+}
+
+func (s *sqliteStore) UpdateOrInsertKubearmorLogs(kubearmorLogMap map[types.KubeArmorLog]int) error {
+	return UpdateOrInsertKubearmorLogsSQLite(s.cfg, kubearmorLogMap)
+}
+
+func (s *sqliteStore) GetKubearmorLogs(filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error) {
+	return GetSystemLogsSQLite(s.cfg, filterLog)
+}
+
+func (s *sqliteStore) UpdateOrInsertCiliumLogs(ciliumLogs []types.CiliumLog) error {
+	return UpdateOrInsertCiliumLogsSQLite(s.cfg, ciliumLogs)
+}
+
+func (s *sqliteStore) GetCiliumLogs(ciliumFilter types.CiliumLog) ([]types.CiliumLog, []uint32, error) {
+	return GetCiliumLogsSQLite(s.cfg, ciliumFilter)
+}
+
+func (s *sqliteStore) GetCiliumLogsUpdatedAfter(updatedTime int64) ([]types.CiliumLog, []uint32, error) {
+	return GetCiliumLogsUpdatedAfterSQLite(s.cfg, updatedTime)
+}
+
+func (s *sqliteStore) GetPodNames(filter types.ObsPodDetail) ([]string, error) {
+	return GetPodNamesSQLite(s.cfg, filter)
+}
+
+func (s *sqliteStore) GetDeployNames(filter types.ObsPodDetail) ([]string, error) {
+	return GetDeployNamesSQLite(s.cfg, filter)
+}
+
+func (s *sqliteStore) GetPolicyYamls(policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	return GetPolicyYamlsSQLite(s.cfg, policyType, filterOptions, page)
+}
+
+func (s *sqliteStore) UpdateOrInsertPolicyYamls(policies []types.PolicyYaml) error {
+	return UpdateOrInsertPolicyYamlsSQLite(s.cfg, policies)
+}
+
+func (s *sqliteStore) DeletePolicyBasedOnPolicyName(policyName, namespace, labels string) error {
+	return DeletePolicyBasedOnPolicyNameSQLite(s.cfg, policyName, namespace, labels)
+}
+
+func (s *sqliteStore) UpsertSystemSummary(summaryMap map[types.SystemSummary]types.SysSummaryTimeCount) error {
+	return UpsertSystemSummarySQLite(s.cfg, summaryMap)
+}
+
+func (s *sqliteStore) GetSystemSummary(filterOptions types.SystemSummary) ([]types.SystemSummary, error) {
+	return GetSystemSummarySQLite(s.cfg, filterOptions)
+}
+
+func (s *sqliteStore) UpsertSystemSummaryRollups(rollups []types.SystemSummaryRollup) error {
+	return UpsertSystemSummaryRollupsSQLite(s.cfg, rollups)
+}
+
+func (s *sqliteStore) GetSystemSummaryRollups(filterOptions types.SystemSummary) ([]types.SystemSummaryRollup, error) {
+	return GetSystemSummaryRollupsSQLite(s.cfg, filterOptions)
+}
+
+func (s *sqliteStore) ClearDBTables() error {
+	return ClearDBTablesSQLite(s.cfg)
+}
+
+func (s *sqliteStore) ClearNetworkDBTable() error {
+	return ClearNetworkDBTableSQLite(s.cfg)
+}
+
+func (s *sqliteStore) ClearPolicyYamlDBTable() error {
+	return ClearPolicyYamlDBTableSQLite(s.cfg)
+}
+
+func (s *sqliteStore) ClearSystemSummaryDBTables() error {
+	return ClearSystemSummaryDBTablesSQLite(s.cfg)
+}
+
+func (s *sqliteStore) Migrate() error {
+	if err := migrateSchema(connectSQLite(s.cfg, s.cfg.SQLiteDBPath), SchemaSQLite); err != nil {
+		return err
+	}
+
+	if err := migrateSchema(connectSQLiteOBS(s.cfg, config.GetCfgObservabilityDBName()), SchemaSQLiteOBS); err != nil {
+		return err
+	}
+
+	// the values of the keys other than the active one are re-encrypted before they are queried
+	rotated, err := RotateEncryptionSQLite(s.cfg)
+	for _, table := range sortedTables(rotated) {
+		if rotated[table] > 0 {
+			log.Info().Msgf("re-encrypted %d rows of %s", rotated[table], table)
+		}
+	}
+	return err
+}
+
+func (s *sqliteStore) PurgeOldDBEntries(purge types.ConfigPurgeOldDBEntries) (PurgeReport, error) {
+	return PurgeOldDBEntriesSQLite(s.cfg, purge)
+}
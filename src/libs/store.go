@@ -0,0 +1,131 @@
+package libs
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// =========== //
+// == Store == //
+// =========== //
+
+// Store is the storage of a database driver. Every backend has to pass the conformance
+// tests of store_test.go, so the discovery behaves the same whatever the driver is.
+// The policy queries return a page of the results and the token of the next page.
+type Store interface {
+	// network policy
+	GetNetworkPolicies(filter types.NetworkPolicyFilter, page types.PageRequest) ([]types.KnoxNetworkPolicy, string, error)
+	InsertNetworkPolicies(policies []types.KnoxNetworkPolicy) error
+	UpdateNetworkPolicy(policy types.KnoxNetworkPolicy) error
+	UpdateOutdatedNetworkPolicy(outdatedPolicy string, latestPolicy string) error
+
+	// system policy
+	GetSystemPolicies(namespace, status string, page types.PageRequest) ([]types.KnoxSystemPolicy, string, error)
+	InsertSystemPolicies(policies []types.KnoxSystemPolicy) error
+	UpdateSystemPolicy(policy types.KnoxSystemPolicy) error
+	UpdateOutdatedSystemPolicy(outdatedPolicy string, latestPolicy string) error
+
+	// workload process file set
+	GetWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet) (map[types.WorkloadProcessFileSet][]string, types.PolicyNameMap, error)
+	InsertWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, policyName string, fs []string) error
+	UpdateWorkloadProcessFileSet(wpfs types.WorkloadProcessFileSet, fs []string) error
+	ClearWPFS(wpfs types.WorkloadProcessFileSet, duration int64) error
+	GetWorkloadProcessFileSetCreatedTime(wpfs types.WorkloadProcessFileSet) (int64, error)
+
+	// system anomaly
+	UpsertSystemAnomaly(anomaly types.SystemAnomaly) (bool, error)
+	GetSystemAnomalies(filter types.AnomalyFilter) ([]types.SystemAnomaly, error)
+
+	// dead letter
+	InsertDeadLetter(deadLetter types.DeadLetter) error
+	GetDeadLetters(filter types.DeadLetterFilter) ([]types.DeadLetter, error)
+	DeleteDeadLetters(ids []int64) error
+
+	// observability logs
+	UpdateOrInsertKubearmorLogs(kubearmorLogMap map[types.KubeArmorLog]int) error
+	GetKubearmorLogs(filterLog types.KubeArmorLog) ([]types.KubeArmorLog, []uint32, error)
+	UpdateOrInsertCiliumLogs(ciliumLogs []types.CiliumLog) error
+	GetCiliumLogs(ciliumFilter types.CiliumLog) ([]types.CiliumLog, []uint32, error)
+	GetCiliumLogsUpdatedAfter(updatedTime int64) ([]types.CiliumLog, []uint32, error)
+	GetPodNames(filter types.ObsPodDetail) ([]string, error)
+	GetDeployNames(filter types.ObsPodDetail) ([]string, error)
+
+	// policy yaml
+	GetPolicyYamls(policyType string, filterOptions types.PolicyFilter, page types.PageRequest) ([]types.PolicyYaml, string, error)
+	UpdateOrInsertPolicyYamls(policies []types.PolicyYaml) error
+	DeletePolicyBasedOnPolicyName(policyName, namespace, labels string) error
+
+	// summary
+	UpsertSystemSummary(summaryMap map[types.SystemSummary]types.SysSummaryTimeCount) error
+	GetSystemSummary(filterOptions types.SystemSummary) ([]types.SystemSummary, error)
+	UpsertSystemSummaryRollups(rollups []types.SystemSummaryRollup) error
+	GetSystemSummaryRollups(filterOptions types.SystemSummary) ([]types.SystemSummaryRollup, error)
+
+	// table
+	Migrate() error
+	ClearDBTables() error
+	ClearNetworkDBTable() error
+	ClearPolicyYamlDBTable() error
+	ClearSystemSummaryDBTables() error
+	PurgeOldDBEntries(purge types.ConfigPurgeOldDBEntries) (PurgeReport, error)
+}
+
+// ErrUnknownDBDriver is returned for the drivers without a store
+var ErrUnknownDBDriver = errors.New("unknown db driver")
+
+var (
+	storeDrivers      = map[string]func(types.ConfigDB) Store{}
+	storeDriversMutex sync.RWMutex
+)
+
+// RegisterStore registers the store of a database driver (cfg.DBDriver)
+func RegisterStore(driver string, newStore func(types.ConfigDB) Store) {
+	storeDriversMutex.Lock()
+	defer storeDriversMutex.Unlock()
+
+	storeDrivers[driver] = newStore
+}
+
+// GetStore returns the store of the database driver of the configuration, with the raw logs
+// in the bolt log store if configured
+func GetStore(cfg types.ConfigDB) (Store, error) {
+	storeDriversMutex.RLock()
+	defer storeDriversMutex.RUnlock()
+
+	newStore, ok := storeDrivers[cfg.DBDriver]
+	if !ok {
+		return nil, ErrUnknownDBDriver
+	}
+
+	switch cfg.LogStore {
+	case "", LogStoreSQL:
+		return newStore(cfg), nil
+	case LogStoreBolt:
+		return &boltLogStore{Store: newStore(cfg), cfg: cfg}, nil
+	default:
+		return nil, ErrUnknownLogStore
+	}
+}
+
+// GetStoreDrivers returns the registered database drivers
+func GetStoreDrivers() []string {
+	storeDriversMutex.RLock()
+	defer storeDriversMutex.RUnlock()
+
+	drivers := []string{}
+	for driver := range storeDrivers {
+		drivers = append(drivers, driver)
+	}
+	sort.Strings(drivers)
+
+	return drivers
+}
+
+func init() {
+	RegisterStore("mysql", func(cfg types.ConfigDB) Store { return &mysqlStore{cfg: cfg} })
+	RegisterStore("postgres", func(cfg types.ConfigDB) Store { return &postgresStore{cfg: cfg} })
+	RegisterStore("sqlite3", func(cfg types.ConfigDB) Store { return &sqliteStore{cfg: cfg} })
+}
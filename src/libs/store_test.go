@@ -0,0 +1,605 @@
+package libs
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================ //
+// == Store Conformance Tests == //
+// ================================ //
+
+// testStore runs the conformance tests against the store of the configuration,
+// on a real database instead of the mocks of the other tests
+func testStore(t *testing.T, cfg types.ConfigDB) {
+	defer func(db *sql.DB) { MockDB = db }(MockDB)
+	MockDB = nil
+
+	store, err := GetStore(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, store.Migrate()) {
+		return
+	}
+	assert.NoError(t, store.ClearDBTables())
+
+	t.Run("NetworkPolicies", func(t *testing.T) { testStoreNetworkPolicies(t, store) })
+	t.Run("SystemPolicies", func(t *testing.T) { testStoreSystemPolicies(t, store) })
+	t.Run("WorkloadProcessFileSet", func(t *testing.T) { testStoreWorkloadProcessFileSet(t, store) })
+	t.Run("PolicyYamls", func(t *testing.T) { testStorePolicyYamls(t, store) })
+	t.Run("SystemSummary", func(t *testing.T) { testStoreSystemSummary(t, store) })
+	t.Run("SystemAnomalies", func(t *testing.T) { testStoreSystemAnomalies(t, store) })
+	t.Run("DeadLetters", func(t *testing.T) { testStoreDeadLetters(t, store) })
+	t.Run("ObservabilityLogs", func(t *testing.T) { testStoreObservabilityLogs(t, store) })
+	t.Run("PurgeOldDBEntries", func(t *testing.T) { testStorePurgeOldDBEntries(t, store) })
+}
+
+func newStoreNetworkPolicy(name, namespace, policyType, rule string) types.KnoxNetworkPolicy {
+	return types.KnoxNetworkPolicy{
+		APIVersion: "v1",
+		Kind:       "KnoxNetworkPolicy",
+		FlowIDs:    []int{1, 2},
+		Metadata: map[string]string{
+			"name":         name,
+			"cluster_name": "default",
+			"namespace":    namespace,
+			"type":         policyType,
+			"rule":         rule,
+			"status":       "latest",
+		},
+		Spec: types.Spec{Selector: types.Selector{MatchLabels: map[string]string{"app": name}}},
+	}
+}
+
+func testStoreNetworkPolicies(t *testing.T, store Store) {
+	assert.NoError(t, store.InsertNetworkPolicies([]types.KnoxNetworkPolicy{
+		newStoreNetworkPolicy("egress-a", "ns-a", "egress", "matchLabels"),
+		newStoreNetworkPolicy("ingress-a", "ns-a", "ingress", "matchLabels"),
+		newStoreNetworkPolicy("egress-b", "ns-b", "egress", "toCIDRs"),
+	}))
+
+	for _, tc := range []struct {
+		cluster, namespace, status, nwtype, rule string
+		expected                                 []string
+	}{
+		{"", "", "", "", "", []string{"egress-a", "ingress-a", "egress-b"}},
+		{"default", "", "", "", "", []string{"egress-a", "ingress-a", "egress-b"}},
+		{"default", "ns-a", "", "", "", []string{"egress-a", "ingress-a"}},
+		{"", "", "latest", "egress", "", []string{"egress-a", "egress-b"}},
+		{"", "", "", "egress", "toCIDRs", []string{"egress-b"}},
+		{"other", "", "", "", "", []string{}},
+	} {
+		filter := types.NetworkPolicyFilter{Cluster: tc.cluster, Namespace: tc.namespace, Status: tc.status, Type: tc.nwtype, Rule: tc.rule}
+		policies, _, err := store.GetNetworkPolicies(filter, types.PageRequest{})
+		assert.NoError(t, err)
+
+		names := []string{}
+		for _, policy := range policies {
+			names = append(names, policy.Metadata["name"])
+		}
+		assert.ElementsMatch(t, tc.expected, names, "filter %+v", tc)
+	}
+
+	policies, _, err := store.GetNetworkPolicies(types.NetworkPolicyFilter{Namespace: "ns-b"}, types.PageRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, []int{1, 2}, policies[0].FlowIDs)
+		assert.Equal(t, map[string]string{"app": "egress-b"}, policies[0].Spec.Selector.MatchLabels)
+		assert.Equal(t, "KnoxNetworkPolicy", policies[0].Kind)
+	}
+
+	assert.NoError(t, store.UpdateOutdatedNetworkPolicy("egress-a", "egress-a-2"))
+	policies, _, err = store.GetNetworkPolicies(types.NetworkPolicyFilter{Status: "outdated"}, types.PageRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "egress-a", policies[0].Metadata["name"])
+		assert.Equal(t, "egress-a-2", policies[0].Outdated)
+	}
+
+	// the policies of a selector are looked up by its hash
+	policies, _, err = store.GetNetworkPolicies(types.NetworkPolicyFilter{Selector: map[string]string{"app": "egress-b"}}, types.PageRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "egress-b", policies[0].Metadata["name"])
+	}
+
+	// the pages follow the insertion order
+	names := []string{}
+	page := types.PageRequest{Size: 2}
+	for {
+		policies, next, err := store.GetNetworkPolicies(types.NetworkPolicyFilter{}, page)
+		if !assert.NoError(t, err) || !assert.LessOrEqual(t, len(policies), 2) {
+			break
+		}
+		for _, policy := range policies {
+			names = append(names, policy.Metadata["name"])
+		}
+		if next == "" {
+			break
+		}
+		page.Token = next
+	}
+	assert.Equal(t, []string{"egress-a", "ingress-a", "egress-b"}, names)
+
+	_, _, err = store.GetNetworkPolicies(types.NetworkPolicyFilter{}, types.PageRequest{Size: 2, Token: "invalid"})
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+
+	assert.NoError(t, store.ClearNetworkDBTable())
+	policies, _, err = store.GetNetworkPolicies(types.NetworkPolicyFilter{}, types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+}
+
+func testStoreSystemPolicies(t *testing.T, store Store) {
+	newPolicy := func(name, namespace string) types.KnoxSystemPolicy {
+		return types.KnoxSystemPolicy{
+			APIVersion: "v1",
+			Kind:       "KnoxSystemPolicy",
+			Metadata: map[string]string{
+				"name":        name,
+				"clusterName": "default",
+				"namespace":   namespace,
+				"type":        "file",
+				"status":      "latest",
+			},
+		}
+	}
+
+	assert.NoError(t, store.InsertSystemPolicies([]types.KnoxSystemPolicy{newPolicy("a", "ns-a"), newPolicy("b", "ns-b")}))
+
+	policies, _, err := store.GetSystemPolicies("ns-a", "", types.PageRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "a", policies[0].Metadata["name"])
+	}
+
+	assert.NoError(t, store.UpdateOutdatedSystemPolicy("a", "a-2"))
+	policies, _, err = store.GetSystemPolicies("", "latest", types.PageRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "b", policies[0].Metadata["name"])
+	}
+
+	policies, next, err := store.GetSystemPolicies("", "", types.PageRequest{Size: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) && assert.NotEmpty(t, next) {
+		assert.Equal(t, "a", policies[0].Metadata["name"])
+		policies, next, err = store.GetSystemPolicies("", "", types.PageRequest{Size: 1, Token: next})
+		assert.NoError(t, err)
+		assert.Len(t, policies, 1)
+		assert.Empty(t, next)
+	}
+}
+
+func testStoreWorkloadProcessFileSet(t *testing.T, store Store) {
+	wpfs := types.WorkloadProcessFileSet{
+		ClusterName:   "default",
+		Namespace:     "ns-a",
+		ContainerName: "app",
+		Labels:        "app=a",
+		FromSource:    "/bin/sh",
+		SetType:       "file",
+	}
+
+	assert.NoError(t, store.InsertWorkloadProcessFileSet(wpfs, "", []string{"/etc/hosts"}))
+
+	res, pnMap, err := store.GetWorkloadProcessFileSet(types.WorkloadProcessFileSet{Namespace: "ns-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/etc/hosts"}, res[wpfs])
+	assert.NotEmpty(t, pnMap[wpfs])
+
+	assert.NoError(t, store.UpdateWorkloadProcessFileSet(wpfs, []string{"/etc/hosts", "/etc/passwd"}))
+	res, _, err = store.GetWorkloadProcessFileSet(wpfs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/etc/hosts", "/etc/passwd"}, res[wpfs])
+
+	createdTime, err := store.GetWorkloadProcessFileSetCreatedTime(wpfs)
+	assert.NoError(t, err)
+	assert.NotZero(t, createdTime)
+
+	res, _, err = store.GetWorkloadProcessFileSet(types.WorkloadProcessFileSet{Namespace: "ns-b"})
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func testStorePolicyYamls(t *testing.T, store Store) {
+	policy := types.PolicyYaml{
+		Type:      types.PolicyTypeSystem,
+		Kind:      "KubeArmorPolicy",
+		Name:      "policy-a",
+		Namespace: "ns-a",
+		Cluster:   "default",
+		Labels:    types.LabelMap{"app": "a"},
+		Yaml:      []byte("kind: KubeArmorPolicy"),
+	}
+	assert.NoError(t, store.UpdateOrInsertPolicyYamls([]types.PolicyYaml{policy}))
+
+	// the existing policy is updated
+	policy.Yaml = []byte("kind: KubeArmorPolicy\n")
+	assert.NoError(t, store.UpdateOrInsertPolicyYamls([]types.PolicyYaml{policy}))
+
+	policies, _, err := store.GetPolicyYamls(types.PolicyTypeSystem, types.PolicyFilter{Namespace: "ns-a"}, types.PageRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "policy-a", policies[0].Name)
+		assert.Equal(t, policy.Yaml, policies[0].Yaml)
+		assert.Equal(t, policy.Labels, policies[0].Labels)
+	}
+
+	policies, _, err = store.GetPolicyYamls(types.PolicyTypeSystem, types.PolicyFilter{Kind: "KubeArmorHostPolicy"}, types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+
+	policies, _, err = store.GetPolicyYamls(types.PolicyTypeNetwork, types.PolicyFilter{}, types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+
+	assert.NoError(t, store.DeletePolicyBasedOnPolicyName("policy-a", "ns-a", LabelMapToString(policy.Labels)))
+	policies, _, err = store.GetPolicyYamls(types.PolicyTypeSystem, types.PolicyFilter{}, types.PageRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+}
+
+func testStoreSystemSummary(t *testing.T, store Store) {
+	summary := types.SystemSummary{
+		ClusterName:   "summary-cluster",
+		NamespaceName: "ns-a",
+		PodName:       "pod-a",
+		Operation:     "File",
+		Labels:        "b=2,a=1",
+		Deployment:    "deploy-a",
+		Source:        "/bin/sh",
+		Destination:   "/etc/hosts",
+		Action:        "Allow",
+	}
+
+	assert.NoError(t, store.UpsertSystemSummary(map[types.SystemSummary]types.SysSummaryTimeCount{
+		summary: {Count: 2, UpdatedTime: 100},
+	}))
+	assert.NoError(t, store.UpsertSystemSummary(map[types.SystemSummary]types.SysSummaryTimeCount{
+		summary: {Count: 3, UpdatedTime: 200},
+	}))
+
+	summaries, err := store.GetSystemSummary(types.SystemSummary{ClusterName: "summary-cluster"})
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, int32(5), summaries[0].Count)
+		assert.Equal(t, int64(200), summaries[0].UpdatedTime)
+		assert.Equal(t, "a=1,b=2", summaries[0].Labels)
+	}
+
+	for _, filter := range []types.ObsPodDetail{
+		{ClusterName: "summary-cluster"},
+		{ClusterName: "summary-cluster", Namespace: "ns-a", PodName: "pod-a"},
+		{ClusterName: "summary-cluster", Labels: "a=1,b=2"},
+		{ClusterName: "summary-cluster", DeployName: "deploy-a"},
+	} {
+		podNames, err := store.GetPodNames(filter)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"pod-a"}, podNames, "%+v", filter)
+	}
+
+	for _, filter := range []types.ObsPodDetail{
+		{ClusterName: "summary-cluster", PodName: "pod-b"},
+		{ClusterName: "summary-cluster", Labels: "a=1"},
+		{ClusterName: "summary-cluster", DeployName: "deploy-b"},
+	} {
+		podNames, err := store.GetPodNames(filter)
+		assert.NoError(t, err)
+		assert.Empty(t, podNames, "%+v", filter)
+	}
+
+	deployNames, err := store.GetDeployNames(types.ObsPodDetail{ClusterName: "summary-cluster", Labels: "a=1,b=2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deploy-a"}, deployNames)
+}
+
+func testStoreSystemAnomalies(t *testing.T, store Store) {
+	// the anomalies are never cleared, a cluster of its own keeps the runs on a database apart
+	cluster := "anomaly-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	anomaly := types.SystemAnomaly{ClusterName: cluster, Namespace: "ns-a", Operation: "File", FromSource: "/bin/cat", Resource: "/etc/shadow", Count: 1, FirstSeen: 100, LastSeen: 100}
+	created, err := store.UpsertSystemAnomaly(anomaly)
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	anomaly.LastSeen = 200
+	created, err = store.UpsertSystemAnomaly(anomaly)
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	anomalies, err := store.GetSystemAnomalies(types.AnomalyFilter{Cluster: cluster})
+	assert.NoError(t, err)
+	if assert.Len(t, anomalies, 1) {
+		assert.Equal(t, "/bin/cat", anomalies[0].FromSource)
+		assert.Equal(t, "/etc/shadow", anomalies[0].Resource)
+		assert.Equal(t, int32(2), anomalies[0].Count)
+		assert.Equal(t, int64(200), anomalies[0].LastSeen)
+	}
+}
+
+func testStoreDeadLetters(t *testing.T, store Store) {
+	assert.NoError(t, store.InsertDeadLetter(types.DeadLetter{Driver: "kafka", Topic: "cilium", Offset: 1, Payload: []byte("a")}))
+	assert.NoError(t, store.InsertDeadLetter(types.DeadLetter{Driver: "kafka", Topic: "kubearmor", Offset: 2, Payload: []byte("b")}))
+
+	deadLetters, err := store.GetDeadLetters(types.DeadLetterFilter{Topic: "cilium"})
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, []byte("a"), deadLetters[0].Payload)
+		assert.NoError(t, store.DeleteDeadLetters([]int64{deadLetters[0].ID}))
+	}
+
+	deadLetters, err = store.GetDeadLetters(types.DeadLetterFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+}
+
+func testStoreObservabilityLogs(t *testing.T, store Store) {
+	// the logs are never cleared, a pod of its own keeps the runs on a database apart
+	pod := "pod-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	kubearmorLog := types.KubeArmorLog{ClusterName: "logs-cluster", PodName: pod, Operation: "Process", Source: "/bin/sh", Resource: "/bin/ls"}
+	assert.NoError(t, store.UpdateOrInsertKubearmorLogs(map[types.KubeArmorLog]int{kubearmorLog: 2}))
+	assert.NoError(t, store.UpdateOrInsertKubearmorLogs(map[types.KubeArmorLog]int{kubearmorLog: 3}))
+
+	kubearmorLogs, totals, err := store.GetKubearmorLogs(types.KubeArmorLog{PodName: pod})
+	assert.NoError(t, err)
+	if assert.Len(t, kubearmorLogs, 1) && assert.Len(t, totals, 1) {
+		assert.Equal(t, uint32(5), totals[0])
+	}
+
+	ciliumLogs := []types.CiliumLog{
+		{Verdict: "FORWARDED", SourcePodName: pod, DestinationPodName: "pod-b", UpdatedTime: 100},
+		{Verdict: "FORWARDED", SourcePodName: pod, DestinationPodName: "pod-b", UpdatedTime: 200, Total: 4},
+		{Verdict: "DROPPED", SourcePodName: pod, DestinationPodName: "pod-c", UpdatedTime: 200},
+	}
+	assert.NoError(t, store.UpdateOrInsertCiliumLogs(ciliumLogs))
+
+	forwarded, totals, err := store.GetCiliumLogs(types.CiliumLog{SourcePodName: pod, Verdict: "FORWARDED"})
+	assert.NoError(t, err)
+	if assert.Len(t, forwarded, 1) && assert.Len(t, totals, 1) {
+		assert.Equal(t, uint32(5), totals[0])
+	}
+
+	all, _, err := store.GetCiliumLogs(types.CiliumLog{SourcePodName: pod})
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	// the rows updated after the time, with their whole totals and their tcp flags
+	assert.NoError(t, store.UpdateOrInsertCiliumLogs([]types.CiliumLog{
+		{Verdict: "FORWARDED", SourcePodName: pod, DestinationPodName: "pod-b", UpdatedTime: 300},
+		{Verdict: "FORWARDED", SourcePodName: pod, DestinationPodName: "pod-d", L4TCPDestinationPort: 80, L4TCPFlags: "SYN", UpdatedTime: 300},
+	}))
+	updated, totals, err := store.GetCiliumLogsUpdatedAfter(200)
+	assert.NoError(t, err)
+	found := map[string]uint32{}
+	for i, ciliumLog := range updated {
+		if ciliumLog.SourcePodName == pod {
+			found[ciliumLog.DestinationPodName+"/"+ciliumLog.L4TCPFlags] = totals[i]
+		}
+	}
+	assert.Equal(t, map[string]uint32{"pod-b/": 6, "pod-d/SYN": 1}, found)
+}
+
+func testStorePurgeOldDBEntries(t *testing.T, store Store) {
+	now := ConvertStrToUnixTime("now")
+	pod := "pod-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	assert.NoError(t, store.UpdateOrInsertCiliumLogs([]types.CiliumLog{
+		{Verdict: "FORWARDED", SourcePodName: pod, DestinationPodName: "pod-a", UpdatedTime: now - 7200},
+		{Verdict: "FORWARDED", SourcePodName: pod, DestinationPodName: "pod-b", UpdatedTime: now - 60},
+		{Verdict: "FORWARDED", SourcePodName: pod, DestinationPodName: "pod-c", UpdatedTime: now},
+	}))
+
+	// the summaries of the pods of a deployment are rolled up together
+	summary := types.SystemSummary{ClusterName: "purge-cluster", NamespaceName: "ns-a", Deployment: "deploy-a", Operation: "Process", Source: "/bin/sh", Destination: "/bin/ls"}
+	podA, podB, podC := summary, summary, summary
+	podA.PodName, podB.PodName, podC.PodName = "pod-a", "pod-b", "pod-c"
+	hour := rollupBucket(RollupHourly, now-7200)
+	assert.NoError(t, store.UpsertSystemSummary(map[types.SystemSummary]types.SysSummaryTimeCount{
+		podA: {Count: 2, UpdatedTime: hour + 1},
+		podB: {Count: 3, UpdatedTime: hour + 2},
+		podC: {Count: 1, UpdatedTime: now},
+	}))
+
+	report, err := store.PurgeOldDBEntries(types.ConfigPurgeOldDBEntries{
+		Retention: map[string]types.ConfigRetention{
+			TableNetworkLogs_TableName: {MaxAge: 3600, MaxRows: 1},
+		},
+		Downsampling: types.ConfigDownsampling{Enable: true, HourlyAfter: 3600},
+	})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, report.Purged[TableNetworkLogs_TableName], int64(2))
+	assert.GreaterOrEqual(t, report.Downsampled[TableSystemSummary_TableName], int64(2))
+
+	// the summaries of the pods are served as the rollup of their hour
+	rollups, err := store.GetSystemSummaryRollups(types.SystemSummary{ClusterName: "purge-cluster", Deployment: "deploy-a"})
+	assert.NoError(t, err)
+	if assert.Len(t, rollups, 1) {
+		assert.Equal(t, RollupHourly, rollups[0].Granularity)
+		assert.Equal(t, hour, rollups[0].BucketTime)
+		assert.Equal(t, int64(5), rollups[0].Count)
+		assert.Equal(t, "/bin/ls", rollups[0].Summary.Destination)
+	}
+	rollups, err = store.GetSystemSummaryRollups(types.SystemSummary{ClusterName: "purge-cluster", PodName: "pod-a"})
+	assert.NoError(t, err)
+	assert.Empty(t, rollups)
+
+	ciliumLogs, _, err := store.GetCiliumLogs(types.CiliumLog{SourcePodName: pod})
+	assert.NoError(t, err)
+	if assert.Len(t, ciliumLogs, 1) {
+		assert.Equal(t, "pod-c", ciliumLogs[0].DestinationPodName)
+	}
+
+	summaries, err := store.GetSystemSummary(types.SystemSummary{ClusterName: "purge-cluster"})
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, "pod-c", summaries[0].PodName)
+	}
+
+	// the hourly rollups are rolled up into daily ones, and purged in turn
+	report, err = store.PurgeOldDBEntries(types.ConfigPurgeOldDBEntries{
+		Downsampling: types.ConfigDownsampling{Enable: true, HourlyAfter: 3600, DailyAfter: 3600},
+	})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, report.Downsampled[TableSystemSummaryRollup_TableName], int64(1))
+	assert.Zero(t, report.Downsampled[TableSystemSummary_TableName])
+
+	report, err = store.PurgeOldDBEntries(types.ConfigPurgeOldDBEntries{
+		Retention: map[string]types.ConfigRetention{
+			TableSystemSummaryRollup_TableName: {MaxAge: 1},
+		},
+	})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, report.Purged[TableSystemSummaryRollup_TableName], int64(1))
+	assert.Empty(t, report.Downsampled)
+}
+
+func TestStoreSQLite(t *testing.T) {
+	dir := t.TempDir()
+
+	prevObsDBName := config.CurrentCfg.ConfigObservability.DBName
+	defer func() { config.CurrentCfg.ConfigObservability.DBName = prevObsDBName }()
+	config.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+
+	testStore(t, types.ConfigDB{DBDriver: "sqlite3", SQLiteDBPath: filepath.Join(dir, "knox.db")})
+}
+
+// TestStoreSQLiteEncrypted runs against SQLite with the sensitive columns encrypted
+func TestStoreSQLiteEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	keyDir := filepath.Join(dir, "keys")
+	assert.NoError(t, os.Mkdir(keyDir, 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(keyDir, "key-1"), []byte("0123456789abcdef"), 0600))
+
+	prevObsDBName := config.CurrentCfg.ConfigObservability.DBName
+	defer func() { config.CurrentCfg.ConfigObservability.DBName = prevObsDBName }()
+	config.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+
+	cfg := types.ConfigDB{
+		DBDriver:     "sqlite3",
+		SQLiteDBPath: filepath.Join(dir, "knox.db"),
+		Encryption:   types.ConfigEncryption{Enable: true, KeyDir: keyDir},
+	}
+	testStore(t, cfg)
+
+	// none of the sensitive values is written in plaintext
+	defer func(db *sql.DB) { MockDB = db }(MockDB)
+	MockDB = nil
+	db := connectSQLite(cfg, cfg.SQLiteDBPath)
+	obsDB := connectSQLiteOBS(cfg, config.GetCfgObservabilityDBName())
+	for _, table := range sqliteEncryptedColumns {
+		tableDB := db
+		if table.obs {
+			tableDB = obsDB
+		}
+		for _, column := range table.columns {
+			var count int
+			assert.NoError(t, tableDB.QueryRow("SELECT count(*) FROM "+table.table+" WHERE "+column+" != '' and "+column+" NOT GLOB 'enc:v1:*'").Scan(&count))
+			assert.Zero(t, count, table.table+"."+column)
+		}
+	}
+
+	// the anomalies and the dead letters written by the conformance tests are checked among them
+	for _, table := range []string{TableSystemAnomalySQLite_TableName, TableDeadLetterSQLite_TableName} {
+		var count int
+		assert.NoError(t, db.QueryRow("SELECT count(*) FROM "+table).Scan(&count))
+		assert.NotZero(t, count, table)
+	}
+}
+
+// TestStoreSQLiteBoltLogs runs against SQLite with the raw logs in the bolt log store
+func TestStoreSQLiteBoltLogs(t *testing.T) {
+	dir := t.TempDir()
+	defer closeLogSegments()
+
+	prevObsDBName := config.CurrentCfg.ConfigObservability.DBName
+	defer func() { config.CurrentCfg.ConfigObservability.DBName = prevObsDBName }()
+	config.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+
+	testStore(t, types.ConfigDB{
+		DBDriver:     "sqlite3",
+		SQLiteDBPath: filepath.Join(dir, "knox.db"),
+		LogStore:     LogStoreBolt,
+		LogStorePath: filepath.Join(dir, "logs"),
+	})
+}
+
+// TestStoreMySQL runs against the database of the TEST_MYSQL_* environment variables, if any
+func TestStoreMySQL(t *testing.T) {
+	host := os.Getenv("TEST_MYSQL_HOST")
+	if host == "" {
+		t.Skip("TEST_MYSQL_HOST not set")
+	}
+
+	testStore(t, types.ConfigDB{
+		DBDriver: "mysql",
+		DBHost:   host,
+		DBPort:   os.Getenv("TEST_MYSQL_PORT"),
+		DBUser:   os.Getenv("TEST_MYSQL_USER"),
+		DBPass:   os.Getenv("TEST_MYSQL_PASSWORD"),
+		DBName:   os.Getenv("TEST_MYSQL_DBNAME"),
+	})
+}
+
+// TestStorePostgres runs against the database of the TEST_POSTGRES_* environment variables,
+// or else against an embedded postgres with TEST_POSTGRES_EMBEDDED set
+func TestStorePostgres(t *testing.T) {
+	cfg := types.ConfigDB{
+		DBDriver: "postgres",
+		DBHost:   os.Getenv("TEST_POSTGRES_HOST"),
+		DBPort:   os.Getenv("TEST_POSTGRES_PORT"),
+		DBUser:   os.Getenv("TEST_POSTGRES_USER"),
+		DBPass:   os.Getenv("TEST_POSTGRES_PASSWORD"),
+		DBName:   os.Getenv("TEST_POSTGRES_DBNAME"),
+	}
+
+	if cfg.DBHost == "" {
+		if os.Getenv("TEST_POSTGRES_EMBEDDED") == "" {
+			t.Skip("neither TEST_POSTGRES_HOST nor TEST_POSTGRES_EMBEDDED set")
+		}
+
+		port := uint32(25432)
+		dir := t.TempDir()
+		db := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+			Port(port).
+			RuntimePath(filepath.Join(dir, "runtime")).
+			DataPath(filepath.Join(dir, "data")).
+			Logger(io.Discard))
+		if err := db.Start(); err != nil {
+			t.Fatalf("embedded postgres failed to start: %v", err)
+		}
+		defer func() { assert.NoError(t, db.Stop()) }()
+
+		cfg.DBHost, cfg.DBPort = "localhost", strconv.Itoa(int(port))
+		cfg.DBUser, cfg.DBPass, cfg.DBName = "postgres", "postgres", "postgres"
+	}
+
+	testStore(t, cfg)
+}
+
+func TestRebindPostgres(t *testing.T) {
+	assert.Equal(t, "SELECT id FROM t WHERE a = $1 and b IN ($2,$3)", rebindPostgres("SELECT id FROM t WHERE a = ? and b IN (?,?)"))
+	assert.Equal(t, "DELETE FROM t", rebindPostgres("DELETE FROM t"))
+}
+
+func TestGetStore(t *testing.T) {
+	assert.Equal(t, []string{"mysql", "postgres", "sqlite3"}, GetStoreDrivers())
+
+	_, err := GetStore(types.ConfigDB{DBDriver: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownDBDriver)
+
+	_, err = GetStore(types.ConfigDB{DBDriver: "sqlite3", LogStore: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownLogStore)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"math/rand"
 	"net"
 	"net/http"
@@ -8,7 +9,9 @@ import (
 	"os"
 	"time"
 
+	"github.com/accuknox/auto-policy-discovery/src/backup"
 	"github.com/accuknox/auto-policy-discovery/src/cluster"
+	"github.com/accuknox/auto-policy-discovery/src/common"
 	"github.com/accuknox/auto-policy-discovery/src/config"
 	"github.com/accuknox/auto-policy-discovery/src/libs"
 	"github.com/accuknox/auto-policy-discovery/src/license"
@@ -64,9 +67,32 @@ func init() {
 	log.Info().Msgf("KUBEARMOR: %+v", config.GetCfgKubeArmor())
 	log.Info().Msgf("AUTO-DEPLOY-DSP: %+v", config.GetCfgDsp())
 	log.Info().Msgf("TLS enabled: %t", viper.GetBool("server.tls.enable"))
+	log.Info().Msgf("PATH-AGGREGATION: %+v", config.GetCfgPathAggregation())
 
-	// 3. setup the tables in db
-	libs.CreateTablesIfNotExist(config.GetCfgDB())
+	// setup the file/process path aggregation rules
+	if err := common.SetPathAggregationConfig(config.GetCfgPathAggregation()); err != nil {
+		log.Error().Msgf("failed to load path aggregation config, using defaults: %v", err)
+	}
+
+	// 3. setup the tables in db, refusing a schema newer than this engine
+	if err := libs.MigrateDB(config.GetCfgDB()); err != nil {
+		log.Error().Msgf("failed to migrate the db: %v", err)
+		os.Exit(1)
+	}
+	if libs.MigrateOnly {
+		log.Info().Msg("db migrated")
+		libs.CloseDBs()
+		os.Exit(0)
+	}
+	if libs.ExportPath != "" || libs.ImportPath != "" {
+		code := 0
+		if err := runBackup(); err != nil {
+			log.Error().Msg(err.Error())
+			code = 1
+		}
+		libs.CloseDBs()
+		os.Exit(code)
+	}
 
 	// 4. Seed random number generator
 	rand.Seed(time.Now().UnixNano())
@@ -75,6 +101,40 @@ func init() {
 	license.InitializeConfig(cfg.K8sClient)
 }
 
+// runBackup exports the discovery state to the -export archive or imports it from the -import one
+func runBackup() error {
+	if libs.ExportPath != "" {
+		f, err := os.Create(libs.ExportPath)
+		if err != nil {
+			return err
+		}
+
+		manifest, err := backup.Export(f, config.GetCfgDB())
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to export the discovery state: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		log.Info().Msgf("exported the discovery state to %s: %v", libs.ExportPath, manifest.Counts)
+		return nil
+	}
+
+	f, err := os.Open(libs.ImportPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := backup.Import(f, config.GetCfgDB(), libs.ImportReplace)
+	if err != nil {
+		return fmt.Errorf("failed to import the discovery state: %w", err)
+	}
+	log.Info().Msgf("imported the discovery state from %s: %v", libs.ImportPath, manifest.Counts)
+	return nil
+}
+
 // ========== //
 // == Main == //
 // ========== //
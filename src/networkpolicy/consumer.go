@@ -16,12 +16,14 @@ func init() {
 	}
 }
 
-func GetPolicyYamlFromDB(consumer *libs.PolicyConsumer) []types.PolicyYaml {
-	// TODO: Use policy filters for network policies
-	policyYamls, err := libs.GetPolicyYamls(CfgDB, types.PolicyTypeNetwork, types.PolicyFilter{})
+// GetPolicyYamlFromDB returns a page of the network policies of the consumer stored in the DB
+// and the token of the next page, the cluster and the namespace are filtered by the DB
+func GetPolicyYamlFromDB(consumer *libs.PolicyConsumer, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	filter := types.PolicyFilter{Cluster: consumer.Filter.Cluster, Namespace: consumer.Filter.Namespace}
+	policyYamls, next, err := libs.GetPolicyYamlsPage(CfgDB, types.PolicyTypeNetwork, filter, page)
 	if err != nil {
 		log.Error().Msgf("fetching policy yaml from DB failed err=%v", err.Error())
-		return nil
+		return nil, "", err
 	}
-	return libs.FilterPolicyYamls(policyYamls, consumer)
+	return libs.FilterPolicyYamls(policyYamls, consumer), next, nil
 }
@@ -1,10 +1,8 @@
 package networkpolicy
 
 import (
-	"io/ioutil"
 	"math/bits"
 	"net"
-	"os"
 	"reflect"
 	"sort"
 	"strconv"
@@ -13,13 +11,11 @@ import (
 	"github.com/clarketm/json"
 	"golang.org/x/exp/slices"
 
-	"github.com/accuknox/auto-policy-discovery/src/cluster"
 	"github.com/accuknox/auto-policy-discovery/src/config"
 	"github.com/accuknox/auto-policy-discovery/src/libs"
 	"github.com/accuknox/auto-policy-discovery/src/plugin"
 	wpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/worker"
 	types "github.com/accuknox/auto-policy-discovery/src/types"
-	"github.com/cilium/cilium/api/v1/flow"
 )
 
 // ============================= //
@@ -109,6 +105,20 @@ func getHaveToCheckItems(igFlows types.NetworkLogFilter) int {
 	return check
 }
 
+func isVerdictSelected(verdict string) bool {
+	if verdict == "" { // logs without verdict, e.g. from kubearmor
+		return true
+	}
+
+	verdictFilter := config.CurrentCfg.ConfigNetPolicy.VerdictFilter
+	verdictNotFilter := config.CurrentCfg.ConfigNetPolicy.VerdictNotFilter
+
+	if len(verdictFilter) > 0 {
+		return libs.ContainsElement(verdictFilter, verdict)
+	}
+	return !libs.ContainsElement(verdictNotFilter, verdict)
+}
+
 func FilterNetworkLogsByConfig(logs []types.KnoxNetworkLog, pods []types.Pod) []types.KnoxNetworkLog {
 	filteredLogs := []types.KnoxNetworkLog{}
 
@@ -141,6 +151,11 @@ func FilterNetworkLogsByConfig(logs []types.KnoxNetworkLog, pods []types.Pod) []
 			continue
 		}
 
+		// the verdicts are also filtered by hubble when the flows come from the relay
+		if !isVerdictSelected(log.Verdict) {
+			continue
+		}
+
 		for _, filter := range NetworkLogFilters {
 			checkItems := getHaveToCheckItems(filter)
 
@@ -215,23 +230,31 @@ func FilterNetworkLogsByNamespace(targetNamespace string, logs []types.KnoxNetwo
 func getNetworkLogs() map[*types.KnoxNetworkLog]bool {
 	networkLogs := []types.KnoxNetworkLog{}
 
-	if NetworkLogFrom == "hubble" {
+	if NetworkLogFrom == "db" {
+		// ============================= //
+		// == Database (network_logs) == //
+		// ============================= //
+		log.Info().Msg("Get network logs from the database")
+
+		// get the network logs stored by the observability since the last run
+		flows := plugin.GetCiliumFlowsFromDB(CfgDB)
+		for _, flow := range flows {
+			NetworkLogMap[flow] = true
+		}
+	} else if NetworkLogFrom == "hubble" {
 		// ========================== //
 		// == Cilium Hubble Relay  == //
 		// ========================== //
 		log.Info().Msg("Get network log from the Cilium Hubble directly")
 
-		// get flows from hubble relay
+		// get flows from hubble relay, already converted and compacted at ingestion
 		flows := plugin.GetCiliumFlowsFromHubble(OperationTrigger)
-		if len(flows) == 0 || len(flows) < OperationTrigger {
+		if len(flows) == 0 {
 			return nil
 		}
 
-		// convert hubble flows -> network logs (but, in this case, no flow id)
 		for _, flow := range flows {
-			if log, valid := plugin.ConvertCiliumFlowToKnoxNetworkLog(flow); valid {
-				networkLogs = append(networkLogs, log)
-			}
+			NetworkLogMap[flow] = true
 		}
 	} else if NetworkLogFrom == "feed-consumer" {
 		// ==================== //
@@ -250,46 +273,30 @@ func getNetworkLogs() map[*types.KnoxNetworkLog]bool {
 			networkLogs = append(networkLogs, *flow)
 		}
 	} else if NetworkLogFrom == "file" {
-		// =============================== //
-		// == File (.json) for testing  == //
-		// =============================== //
-		log.Info().Msg("Get network logs from the json file : " + NetworkLogFile)
-		flows := []*flow.Flow{}
-
-		// Open jsonFile
-		logFile, err := os.Open(NetworkLogFile)
-		if err != nil {
-			log.Error().Msg(err.Error())
-			if err := logFile.Close(); err != nil {
-				log.Error().Msg(err.Error())
-			}
-			return nil
-		}
+		// ============================ //
+		// == File (hubble captures) == //
+		// ============================ //
+		log.Info().Msg("Get network logs from the file : " + NetworkLogFile)
 
-		byteValue, err := ioutil.ReadAll(logFile)
-		if err != nil {
-			log.Error().Msg(err.Error())
-		}
-
-		if err := json.Unmarshal(byteValue, &flows); err != nil {
-			log.Error().Msg(err.Error())
-			return nil
-		}
-
-		// replace the pod names in prepared-flows with the working pod names
-		pods := cluster.GetPodsFromK8sClient()
-		ReplaceMultiubuntuPodName(flows, pods)
+		// get the flows appended to the file (or directory) since the last run
+		flows := plugin.GetCiliumFlowsFromFile(NetworkLogFile)
 
 		// convert file flows -> network logs (but, in this case, no flow id..)
 		for _, flow := range flows {
 			if log, valid := plugin.ConvertCiliumFlowToKnoxNetworkLog(flow); valid {
-				//networkLogs = append(networkLogs, log)
 				NetworkLogMap[&log] = true
 			}
 		}
+	} else if NetworkLogFrom == "envoy" {
+		// =============================== //
+		// == Envoy (service mesh logs) == //
+		// =============================== //
+		log.Info().Msg("Get network logs from the envoy access logs")
 
-		if err := logFile.Close(); err != nil {
-			log.Error().Msg(err.Error())
+		// get the access logs received, and appended to the file (or directory) since the last run
+		flows := plugin.GetNetworkLogsFromEnvoy(NetworkLogFile)
+		for _, flow := range flows {
+			NetworkLogMap[flow] = true
 		}
 	} else if NetworkLogFrom == "kubearmor" {
 		// =============== //
@@ -782,7 +789,9 @@ func WriteNetworkPoliciesToFile(cluster, namespace string) {
 	libs.WriteCiliumPolicyToYamlFile(namespace, ciliumPolicies)
 }
 
-func GetNetPolicy(cluster, namespace, policyType string) *wpb.WorkerResponse {
+// GetNetPolicy returns a page of the latest network policies converted to the policy types,
+// the whole policies if the size of the page is zero
+func GetNetPolicy(cluster, namespace, policyType string, page types.PageRequest) *wpb.WorkerResponse {
 
 	var response wpb.WorkerResponse
 
@@ -791,10 +800,18 @@ func GetNetPolicy(cluster, namespace, policyType string) *wpb.WorkerResponse {
 	response.Ciliumpolicy = nil
 	response.Kubearmorpolicy = nil
 
+	filter := types.NetworkPolicyFilter{Cluster: cluster, Namespace: namespace, Status: "latest"}
+	latestPolicies, next, err := libs.GetNetworkPoliciesPage(CfgDB, filter, page)
+	if err != nil {
+		log.Error().Msgf("fetching network policies from DB failed err=%v", err.Error())
+		response.Res = err.Error()
+		return &response
+	}
+	response.NextPageToken = next
+
 	pt := strings.Split(policyType, ",")
 
 	if slices.IndexFunc(pt, func(c string) bool { return c == "CiliumNetworkPolicy" }) > -1 {
-		latestPolicies := libs.GetNetworkPolicies(CfgDB, cluster, namespace, "latest", "", "")
 		log.Info().Msgf("No. of latestPolicies - %d", len(latestPolicies))
 		ciliumPolicies := plugin.ConvertKnoxPoliciesToCiliumPolicies(latestPolicies)
 
@@ -813,8 +830,7 @@ func GetNetPolicy(cluster, namespace, policyType string) *wpb.WorkerResponse {
 
 	}
 	if slices.IndexFunc(pt, func(c string) bool { return c == "NetworkPolicy" }) > -1 {
-		knoxNetPolicies := libs.GetNetworkPolicies(config.CurrentCfg.ConfigDB, cluster, namespace, "latest", "", "")
-		policies := plugin.ConvertKnoxNetPolicyToK8sNetworkPolicy(cluster, namespace, knoxNetPolicies)
+		policies := plugin.ConvertKnoxNetPolicyToK8sNetworkPolicy(cluster, namespace, latestPolicies)
 
 		for i := range policies {
 			genericNetPol := wpb.Policy{}
@@ -833,112 +849,3 @@ func GetNetPolicy(cluster, namespace, policyType string) *wpb.WorkerResponse {
 
 	return &response
 }
-
-// ====================== //
-// == Internal Testing == //
-// ====================== //
-
-func ReplaceMultiubuntuPodName(flows []*flow.Flow, pods []types.Pod) {
-	var pod1Name, pod2Name, pod3Name, pod4Name, pod5Name string
-	var kubeDNS string
-
-	for _, pod := range pods {
-		if strings.Contains(pod.PodName, "ubuntu-1-deployment") {
-			pod1Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-2-deployment") {
-			pod2Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-3-deployment") {
-			pod3Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-4-deployment") {
-			pod4Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-5-deployment") {
-			pod5Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "kube-dns") && !strings.Contains(pod.PodName, "kube-dns-autoscaler") {
-			kubeDNS = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "coredns") && !strings.Contains(pod.PodName, "coredns-autoscaler") {
-			kubeDNS = pod.PodName
-		}
-	}
-
-	for i, flow := range flows {
-		if strings.Contains(flow.GetSource().GetPodName(), "ubuntu-1-deployment") {
-			flows[i].Source.PodName = pod1Name
-		}
-
-		if strings.Contains(flow.GetDestination().GetPodName(), "ubuntu-1-deployment") {
-			flows[i].Destination.PodName = pod1Name
-		}
-
-		///
-
-		if strings.Contains(flow.GetSource().GetPodName(), "ubuntu-2-deployment") {
-			flows[i].Source.PodName = pod2Name
-		}
-
-		if strings.Contains(flow.GetDestination().GetPodName(), "ubuntu-2-deployment") {
-			flows[i].Destination.PodName = pod2Name
-		}
-
-		///
-
-		if strings.Contains(flow.GetSource().GetPodName(), "ubuntu-3-deployment") {
-			flows[i].Source.PodName = pod3Name
-		}
-
-		if strings.Contains(flow.GetDestination().GetPodName(), "ubuntu-3-deployment") {
-			flows[i].Destination.PodName = pod3Name
-		}
-
-		///
-
-		if strings.Contains(flow.GetSource().GetPodName(), "ubuntu-4-deployment") {
-			flows[i].Source.PodName = pod4Name
-		}
-
-		if strings.Contains(flow.GetDestination().GetPodName(), "ubuntu-4-deployment") {
-			flows[i].Destination.PodName = pod4Name
-		}
-
-		///
-
-		if strings.Contains(flow.GetSource().GetPodName(), "ubuntu-5-deployment") {
-			flows[i].Source.PodName = pod5Name
-		}
-
-		if strings.Contains(flow.GetDestination().GetPodName(), "ubuntu-5-deployment") {
-			flows[i].Destination.PodName = pod5Name
-		}
-
-		///
-
-		if strings.Contains(flow.GetSource().GetPodName(), "kube-dns") && !strings.Contains(flow.GetSource().GetPodName(), "kube-dns-autoscaler") {
-			flows[i].Source.PodName = kubeDNS
-		}
-
-		if strings.Contains(flow.GetDestination().GetPodName(), "kube-dns") && !strings.Contains(flow.GetSource().GetPodName(), "kube-dns-autoscaler") {
-			flows[i].Destination.PodName = kubeDNS
-		}
-
-		///
-
-		if strings.Contains(flow.GetSource().GetPodName(), "coredns") && !strings.Contains(flow.GetSource().GetPodName(), "coredns-autoscaler") {
-			flows[i].Source.PodName = kubeDNS
-		}
-
-		if strings.Contains(flow.GetDestination().GetPodName(), "coredns") && !strings.Contains(flow.GetSource().GetPodName(), "coredns-autoscaler") {
-			flows[i].Destination.PodName = kubeDNS
-		}
-	}
-}
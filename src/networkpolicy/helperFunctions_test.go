@@ -3,6 +3,7 @@ package networkpolicy
 import (
 	"testing"
 
+	"github.com/accuknox/auto-policy-discovery/src/config"
 	"github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -133,3 +134,29 @@ func TestRemoveDstFromMergedDstSlice(t *testing.T) {
 
 	assert.Equal(t, expected, results, ShouldBeEqual)
 }
+
+// ============ //
+// == Filter == //
+// ============ //
+
+func TestFilterNetworkLogsByVerdict(t *testing.T) {
+	prevCfg := config.CurrentCfg.ConfigNetPolicy
+	defer func() { config.CurrentCfg.ConfigNetPolicy = prevCfg }()
+
+	logs := []types.KnoxNetworkLog{
+		{SrcIP: "10.0.0.1", Protocol: 17, Direction: "EGRESS", Verdict: "FORWARDED"},
+		{SrcIP: "10.0.0.2", Protocol: 17, Direction: "EGRESS", Verdict: "ERROR"},
+		{SrcIP: "10.0.0.3", Protocol: 17, Direction: "EGRESS"},
+	}
+
+	config.CurrentCfg.ConfigNetPolicy.VerdictNotFilter = []string{"ERROR"}
+	filtered := FilterNetworkLogsByConfig(logs, nil)
+	assert.Len(t, filtered, 2)
+
+	config.CurrentCfg.ConfigNetPolicy.VerdictNotFilter = nil
+	config.CurrentCfg.ConfigNetPolicy.VerdictFilter = []string{"ERROR"}
+	filtered = FilterNetworkLogsByConfig(logs, nil)
+	if assert.Len(t, filtered, 2) {
+		assert.Equal(t, "10.0.0.2", filtered[0].SrcIP)
+	}
+}
@@ -98,6 +98,9 @@ var NetworkLogFrom string
 var NetworkLogFile string
 var NetworkPolicyTo string
 
+var PolicyGapMaxAge int64
+var PolicyGapMaxCount int
+
 var CIDRBits int
 var HTTPThreshold int
 
@@ -124,6 +127,9 @@ func InitNetPolicyDiscoveryConfiguration() {
 	NetworkLogFile = cfg.GetCfgNetworkLogFile()
 	NetworkPolicyTo = cfg.GetCfgNetworkPolicyTo()
 
+	PolicyGapMaxAge = int64(cfg.GetCfgPolicyGapMaxAge())
+	PolicyGapMaxCount = cfg.GetCfgPolicyGapMaxCount()
+
 	L3DiscoveryLevel = cfg.GetCfgNetworkL3Level()
 	L4DiscoveryLevel = cfg.GetCfgNetworkL4Level()
 	L7DiscoveryLevel = cfg.GetCfgNetworkL7Level()
@@ -2160,6 +2166,9 @@ func PopulateNetworkPoliciesFromNetworkLogs(networkLogMap map[*types.KnoxNetwork
 		// update service ports (k8s service, endpoint, kube-dns)
 		updateServiceEndpoint(services, endpoints, pods)
 
+		// report the connections dropped because no policy allowed them
+		UpdatePolicyGaps(clusterName, networkLogs, pods)
+
 		log.Info().Msgf("FilterNetworkLogsByConfig for cluster [%s]", clusterName)
 		// filter ignoring network logs from configuration
 		filteredLogs := FilterNetworkLogsByConfig(networkLogs, pods)
@@ -2356,19 +2365,31 @@ func DiscoverNetworkPolicyMain() {
 // ===================================== //
 
 func StartNetworkLogRcvr() {
+	stopChan := NetworkStopChan
+
 	for {
 		if cfg.GetCfgNetworkLogFrom() == "hubble" {
-			plugin.StartHubbleRelay(NetworkStopChan /* &NetworkWaitG, */, cfg.GetCfgCiliumHubble())
+			plugin.StartHubbleRelay(stopChan /* &NetworkWaitG, */, cfg.GetCfgCiliumHubble())
+		} else if cfg.GetCfgNetworkLogFrom() == "envoy" {
+			plugin.StartEnvoyAccessLogReceiver(stopChan, cfg.GetCfgEnvoyReceiver())
 		} else if cfg.GetCfgNetworkLogFrom() == "feed-consumer" {
 			fc.ConsumerMutex.Lock()
 			fc.StartConsumer()
 			fc.ConsumerMutex.Unlock()
 		}
-		time.Sleep(time.Second * 2)
+
+		// the receiver is stopped with its worker
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(time.Second * 2):
+		}
 	}
 }
 
 func StartNetworkCronJob() {
+	// a stopped worker can be started again, e.g., by the next elected leader
+	NetworkStopChan = make(chan struct{})
 	go StartNetworkLogRcvr()
 
 	// init cron job
@@ -0,0 +1,299 @@
+package networkpolicy
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	"github.com/accuknox/auto-policy-discovery/src/plugin"
+	wpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/worker"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// DropReasonPolicyDenied is the hubble drop reason of the flows no policy allowed.
+// The flows matching a deny policy (POLICY_DENY) are dropped on purpose and are not gaps.
+const DropReasonPolicyDenied = "POLICY_DENIED"
+
+// PolicyGaps are the connections recently dropped by the policies in place, keyed by cluster,
+// workload and connection. They are rebuilt from the network logs, network-log-from: db
+// reads them back from the network_logs table after a restart.
+var (
+	PolicyGaps      map[string]*types.PolicyGap
+	PolicyGapsMutex *sync.Mutex
+)
+
+func init() {
+	PolicyGaps = map[string]*types.PolicyGap{}
+	PolicyGapsMutex = &sync.Mutex{}
+}
+
+// ================= //
+// == Policy Gaps == //
+// ================= //
+
+func isPolicyDenied(log types.KnoxNetworkLog) bool {
+	return log.Verdict == "DROPPED" && log.DropReason == DropReasonPolicyDenied && !log.IsReply
+}
+
+// getPolicyGapEndpoint describes an endpoint of a dropped connection by its labels,
+// or by the entity, fqdn or ip for the endpoints outside the pods
+func getPolicyGapEndpoint(namespace, podName string, reservedLabels []string, ip, dnsQuery string, pods []types.Pod) string {
+	if podName != "" {
+		if labels := getLabelArrayFromMap(getEndpointMatchLabels(podName, pods)); len(labels) > 0 {
+			return namespace + "/" + strings.Join(labels, ",")
+		}
+		return namespace + "/" + podName
+	}
+
+	entity := getEntityFromReservedLabels(reservedLabels)
+	if entity == "world" && dnsQuery != "" {
+		return dnsQuery
+	}
+	if entity != "" && entity != "world" {
+		return entity
+	}
+
+	return ip
+}
+
+func getPolicyGapL7(log types.KnoxNetworkLog) string {
+	if log.L7Protocol == libs.L7ProtocolHTTP {
+		return log.HTTPMethod + " " + log.HTTPPath
+	}
+	if libs.IsICMP(log.Protocol) {
+		return "type " + strconv.Itoa(log.ICMPType)
+	}
+	return ""
+}
+
+// buildPolicyGapPolicy builds the policy allowing just the dropped connection: the egress of
+// the source for the flows dropped on egress, the ingress of the destination otherwise
+func buildPolicyGapPolicy(log types.KnoxNetworkLog, pods []types.Pod) *types.KnoxNetworkPolicy {
+	var ports []types.SpecPort
+	var icmps []types.SpecICMP
+	var https []types.SpecHTTP
+
+	if !libs.IsICMP(log.Protocol) {
+		ports = []types.SpecPort{{Port: strconv.Itoa(log.DstPort), Protocol: libs.GetProtocol(log.Protocol)}}
+	} else {
+		family := "IPv4"
+		if log.Protocol == libs.IPProtocolICMPv6 {
+			family = "IPv6"
+		}
+		icmps = []types.SpecICMP{{Family: family, Type: uint8(log.ICMPType)}}
+	}
+	if log.L7Protocol == libs.L7ProtocolHTTP {
+		https = []types.SpecHTTP{{Method: log.HTTPMethod, Path: log.HTTPPath}}
+	}
+
+	var policy types.KnoxNetworkPolicy
+
+	if log.Direction == "INGRESS" {
+		policy = buildNewKnoxIngressPolicy()
+		policy.Spec.Selector.MatchLabels = getEndpointMatchLabels(log.DstPodName, pods)
+		policy.Metadata["namespace"] = log.DstNamespace
+
+		ingress := types.Ingress{ToPorts: ports, ICMPs: icmps, ToHTTPs: https}
+		if log.SrcPodName != "" {
+			ingress.MatchLabels = getEndpointMatchLabels(log.SrcPodName, pods)
+			if len(ingress.MatchLabels) == 0 {
+				return nil
+			}
+			if log.SrcNamespace != log.DstNamespace {
+				ingress.MatchLabels["io.kubernetes.pod.namespace"] = log.SrcNamespace
+			}
+		} else if entity := getEntityFromReservedLabels(log.SrcReservedLabels); entity != "" && entity != "world" {
+			ingress.FromEntities = []string{entity}
+		} else {
+			ingress.FromCIDRs = []types.SpecCIDR{{CIDRs: []string{log.SrcIP + "/32"}}}
+		}
+		policy.Spec.Ingress = append(policy.Spec.Ingress, ingress)
+	} else {
+		policy = buildNewKnoxEgressPolicy()
+		policy.Spec.Selector.MatchLabels = getEndpointMatchLabels(log.SrcPodName, pods)
+		policy.Metadata["namespace"] = log.SrcNamespace
+
+		egress := types.Egress{ToPorts: ports, ICMPs: icmps, ToHTTPs: https}
+		entity := getEntityFromReservedLabels(log.DstReservedLabels)
+		if log.DstPodName != "" {
+			egress.MatchLabels = getEndpointMatchLabels(log.DstPodName, pods)
+			if len(egress.MatchLabels) == 0 {
+				return nil
+			}
+			if log.SrcNamespace != log.DstNamespace {
+				egress.MatchLabels["io.kubernetes.pod.namespace"] = log.DstNamespace
+			}
+		} else if entity == "world" && log.DNSQuery != "" {
+			egress.ToFQDNs = []types.SpecFQDN{{MatchNames: []string{log.DNSQuery}}}
+		} else if entity != "" && entity != "world" {
+			egress.ToEntities = []string{entity}
+		} else {
+			egress.ToCIDRs = []types.SpecCIDR{{CIDRs: []string{log.DstIP + "/32"}}}
+		}
+		policy.Spec.Egress = append(policy.Spec.Egress, egress)
+	}
+
+	if len(policy.Spec.Selector.MatchLabels) == 0 {
+		return nil
+	}
+
+	return &policy
+}
+
+// UpdatePolicyGaps records the connections of the cluster dropped because no policy allowed them
+func UpdatePolicyGaps(clusterName string, networkLogs []types.KnoxNetworkLog, pods []types.Pod) {
+	PolicyGapsMutex.Lock()
+	defer PolicyGapsMutex.Unlock()
+
+	newGaps := 0
+
+	for _, log := range networkLogs {
+		if !isPolicyDenied(log) {
+			continue
+		}
+
+		src := getPolicyGapEndpoint(log.SrcNamespace, log.SrcPodName, log.SrcReservedLabels, log.SrcIP, "", pods)
+		dst := getPolicyGapEndpoint(log.DstNamespace, log.DstPodName, log.DstReservedLabels, log.DstIP, log.DNSQuery, pods)
+
+		gap := types.PolicyGap{
+			ClusterName: clusterName,
+			Namespace:   log.SrcNamespace,
+			Workload:    src,
+			Direction:   log.Direction,
+			Peer:        dst,
+			Protocol:    libs.GetProtocol(log.Protocol),
+			L7:          getPolicyGapL7(log),
+			DropReason:  log.DropReason,
+		}
+		if log.Direction == "INGRESS" {
+			gap.Namespace, gap.Workload, gap.Peer = log.DstNamespace, dst, src
+		}
+		if !libs.IsICMP(log.Protocol) {
+			gap.Port = log.DstPort
+		}
+
+		key := strings.Join([]string{gap.ClusterName, gap.Workload, gap.Direction, gap.Peer,
+			gap.Protocol, strconv.Itoa(gap.Port), gap.L7}, "|")
+
+		count := log.Total
+		if count <= 0 {
+			count = 1
+		}
+
+		if existing, ok := PolicyGaps[key]; ok {
+			existing.Count += count
+			if log.StartTime != 0 && (existing.FirstSeen == 0 || log.StartTime < existing.FirstSeen) {
+				existing.FirstSeen = log.StartTime
+			}
+			if log.UpdatedTime > existing.LastSeen {
+				existing.LastSeen = log.UpdatedTime
+			}
+			continue
+		}
+
+		gap.Count = count
+		gap.FirstSeen = log.StartTime
+		gap.LastSeen = log.UpdatedTime
+
+		if policy := buildPolicyGapPolicy(log, pods); policy != nil {
+			named := GeneratePolicyName(map[string]bool{}, *policy, clusterName)
+			ciliumPolicy := plugin.ConvertKnoxNetworkPolicyToCiliumPolicy(named)
+			gap.SuggestedPolicy = &ciliumPolicy
+		}
+
+		PolicyGaps[key] = &gap
+		newGaps++
+	}
+
+	if newGaps > 0 {
+		log.Info().Msgf("[%d] new connections dropped by the policies in place for cluster [%s]", newGaps, clusterName)
+	}
+
+	expirePolicyGaps(time.Now().Unix())
+}
+
+// expirePolicyGaps drops the gaps not seen for PolicyGapMaxAge seconds, then the least
+// recently seen ones beyond PolicyGapMaxCount, so that the peers by ip do not pile up
+func expirePolicyGaps(now int64) {
+	if PolicyGapMaxAge > 0 {
+		for key, gap := range PolicyGaps {
+			if gap.LastSeen < now-PolicyGapMaxAge {
+				delete(PolicyGaps, key)
+			}
+		}
+	}
+
+	if PolicyGapMaxCount <= 0 || len(PolicyGaps) <= PolicyGapMaxCount {
+		return
+	}
+
+	keys := make([]string, 0, len(PolicyGaps))
+	for key := range PolicyGaps {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return PolicyGaps[keys[i]].LastSeen < PolicyGaps[keys[j]].LastSeen
+	})
+	for _, key := range keys[:len(keys)-PolicyGapMaxCount] {
+		delete(PolicyGaps, key)
+	}
+}
+
+// GetPolicyGaps returns the policy gaps of the cluster/namespace, the most dropped first
+func GetPolicyGaps(clusterName, namespace string) []types.PolicyGap {
+	PolicyGapsMutex.Lock()
+	defer PolicyGapsMutex.Unlock()
+
+	gaps := []types.PolicyGap{}
+	for _, gap := range PolicyGaps {
+		if clusterName != "" && gap.ClusterName != clusterName {
+			continue
+		}
+		if namespace != "" && gap.Namespace != namespace {
+			continue
+		}
+		gaps = append(gaps, *gap)
+	}
+
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].Count != gaps[j].Count {
+			return gaps[i].Count > gaps[j].Count
+		}
+		return gaps[i].LastSeen > gaps[j].LastSeen
+	})
+
+	return gaps
+}
+
+// GetPolicyGapReport returns the policy gaps in the response, along with the suggested policies
+func GetPolicyGapReport(clusterName, namespace string) *wpb.WorkerResponse {
+	var response wpb.WorkerResponse
+
+	gaps := GetPolicyGaps(clusterName, namespace)
+
+	val, err := json.Marshal(gaps)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return &wpb.WorkerResponse{Res: err.Error()}
+	}
+	response.Res = string(val)
+
+	for _, gap := range gaps {
+		if gap.SuggestedPolicy == nil {
+			continue
+		}
+
+		val, err := json.Marshal(gap.SuggestedPolicy)
+		if err != nil {
+			log.Error().Msg(err.Error())
+			continue
+		}
+		response.Ciliumpolicy = append(response.Ciliumpolicy, &wpb.Policy{Data: val})
+	}
+
+	return &response
+}
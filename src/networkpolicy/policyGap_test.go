@@ -0,0 +1,165 @@
+package networkpolicy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var policyGapPods = []types.Pod{
+	{Namespace: "default", PodName: "frontend-1", Labels: []string{"app=frontend"}},
+	{Namespace: "default", PodName: "redis-1", Labels: []string{"app=redis"}},
+	{Namespace: "monitoring", PodName: "prometheus-1", Labels: []string{"app=prometheus"}},
+}
+
+func TestBuildPolicyGapPolicy(t *testing.T) {
+	egress := types.KnoxNetworkLog{
+		SrcNamespace: "default",
+		SrcPodName:   "frontend-1",
+		DstNamespace: "default",
+		DstPodName:   "redis-1",
+		DstPort:      6379,
+		Protocol:     6,
+		Direction:    "EGRESS",
+		Verdict:      "DROPPED",
+		DropReason:   DropReasonPolicyDenied,
+	}
+
+	policy := buildPolicyGapPolicy(egress, policyGapPods)
+	if assert.NotNil(t, policy) {
+		assert.Equal(t, map[string]string{"app": "frontend"}, policy.Spec.Selector.MatchLabels)
+		if assert.Len(t, policy.Spec.Egress, 1) {
+			assert.Equal(t, map[string]string{"app": "redis"}, policy.Spec.Egress[0].MatchLabels)
+			assert.Equal(t, []types.SpecPort{{Port: "6379", Protocol: "TCP"}}, policy.Spec.Egress[0].ToPorts)
+		}
+		assert.Empty(t, policy.Spec.Ingress)
+	}
+
+	// the ingress drops are fixed on the destination, across namespaces
+	ingress := egress
+	ingress.SrcNamespace = "monitoring"
+	ingress.SrcPodName = "prometheus-1"
+	ingress.Direction = "INGRESS"
+
+	policy = buildPolicyGapPolicy(ingress, policyGapPods)
+	if assert.NotNil(t, policy) {
+		assert.Equal(t, "default", policy.Metadata["namespace"])
+		assert.Equal(t, map[string]string{"app": "redis"}, policy.Spec.Selector.MatchLabels)
+		if assert.Len(t, policy.Spec.Ingress, 1) {
+			assert.Equal(t, map[string]string{
+				"app":                         "prometheus",
+				"io.kubernetes.pod.namespace": "monitoring",
+			}, policy.Spec.Ingress[0].MatchLabels)
+		}
+	}
+
+	// the external destinations are allowed by fqdn, or by ip
+	world := egress
+	world.DstNamespace, world.DstPodName = "", ""
+	world.DstIP = "1.1.1.1"
+	world.DstReservedLabels = []string{"reserved:world"}
+	world.DstPort = 443
+
+	policy = buildPolicyGapPolicy(world, policyGapPods)
+	if assert.NotNil(t, policy) && assert.Len(t, policy.Spec.Egress, 1) {
+		assert.Equal(t, []types.SpecCIDR{{CIDRs: []string{"1.1.1.1/32"}}}, policy.Spec.Egress[0].ToCIDRs)
+	}
+
+	world.DNSQuery = "example.com"
+	policy = buildPolicyGapPolicy(world, policyGapPods)
+	if assert.NotNil(t, policy) && assert.Len(t, policy.Spec.Egress, 1) {
+		assert.Equal(t, []types.SpecFQDN{{MatchNames: []string{"example.com"}}}, policy.Spec.Egress[0].ToFQDNs)
+	}
+
+	// no policy for the pods without labels
+	unknown := egress
+	unknown.SrcPodName = "unknown-1"
+	assert.Nil(t, buildPolicyGapPolicy(unknown, policyGapPods))
+}
+
+func TestUpdatePolicyGaps(t *testing.T) {
+	defer func(gaps map[string]*types.PolicyGap) { PolicyGaps = gaps }(PolicyGaps)
+	PolicyGaps = map[string]*types.PolicyGap{}
+
+	denied := types.KnoxNetworkLog{
+		SrcNamespace: "default",
+		SrcPodName:   "frontend-1",
+		DstNamespace: "default",
+		DstPodName:   "redis-1",
+		DstPort:      6379,
+		Protocol:     6,
+		Direction:    "EGRESS",
+		Verdict:      "DROPPED",
+		DropReason:   DropReasonPolicyDenied,
+		Total:        3,
+		StartTime:    100,
+		UpdatedTime:  150,
+	}
+
+	again := denied
+	again.Total = 0
+	again.StartTime, again.UpdatedTime = 90, 200
+
+	otherPort := denied
+	otherPort.DstPort = 6380
+	otherPort.Total = 1
+
+	// neither the forwarded flows nor the other drops are gaps
+	forwarded := denied
+	forwarded.Verdict = "FORWARDED"
+	forwarded.DropReason = ""
+	invalid := denied
+	invalid.DropReason = "INVALID_PACKET"
+
+	UpdatePolicyGaps("default", []types.KnoxNetworkLog{denied, again, otherPort, forwarded, invalid}, policyGapPods)
+
+	gaps := GetPolicyGaps("default", "default")
+	if assert.Len(t, gaps, 2) {
+		assert.Equal(t, "default/app=frontend", gaps[0].Workload)
+		assert.Equal(t, "default/app=redis", gaps[0].Peer)
+		assert.Equal(t, 6379, gaps[0].Port)
+		assert.Equal(t, 4, gaps[0].Count)
+		assert.Equal(t, int64(90), gaps[0].FirstSeen)
+		assert.Equal(t, int64(200), gaps[0].LastSeen)
+		assert.NotNil(t, gaps[0].SuggestedPolicy)
+		assert.Equal(t, 6380, gaps[1].Port)
+	}
+
+	assert.Empty(t, GetPolicyGaps("other", ""))
+	assert.Empty(t, GetPolicyGaps("", "monitoring"))
+
+	report := GetPolicyGapReport("default", "")
+	reported := []types.PolicyGap{}
+	assert.NoError(t, json.Unmarshal([]byte(report.Res), &reported))
+	assert.Len(t, reported, 2)
+	assert.Len(t, report.Ciliumpolicy, 2)
+}
+
+func TestExpirePolicyGaps(t *testing.T) {
+	defer func(gaps map[string]*types.PolicyGap) { PolicyGaps = gaps }(PolicyGaps)
+	defer func(maxAge int64, maxCount int) { PolicyGapMaxAge, PolicyGapMaxCount = maxAge, maxCount }(PolicyGapMaxAge, PolicyGapMaxCount)
+
+	PolicyGaps = map[string]*types.PolicyGap{
+		"old":    {Peer: "10.0.0.1", LastSeen: 100},
+		"recent": {Peer: "10.0.0.2", LastSeen: 950},
+		"latest": {Peer: "10.0.0.3", LastSeen: 1000},
+	}
+
+	// no limit by default
+	expirePolicyGaps(1000)
+	assert.Len(t, PolicyGaps, 3)
+
+	// the gaps not seen for the max age are dropped
+	PolicyGapMaxAge = 500
+	expirePolicyGaps(1000)
+	assert.Len(t, PolicyGaps, 2)
+	assert.NotContains(t, PolicyGaps, "old")
+
+	// the least recently seen are evicted beyond the max count
+	PolicyGapMaxCount = 1
+	expirePolicyGaps(1000)
+	assert.Len(t, PolicyGaps, 1)
+	assert.Contains(t, PolicyGaps, "latest")
+}
@@ -3,6 +3,7 @@ package observability
 import (
 	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/accuknox/auto-policy-discovery/src/common"
@@ -13,6 +14,59 @@ import (
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// ConvertTCPFlagsToString returns the names of the tcp flags set, comma separated
+func ConvertTCPFlagsToString(flags *flow.TCPFlags) string {
+	if flags == nil {
+		return ""
+	}
+
+	names := []string{}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"SYN", flags.SYN}, {"ACK", flags.ACK}, {"FIN", flags.FIN}, {"RST", flags.RST}, {"PSH", flags.PSH},
+		{"URG", flags.URG}, {"ECE", flags.ECE}, {"CWR", flags.CWR}, {"NS", flags.NS},
+	} {
+		if f.set {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ConvertStringToTCPFlags returns the tcp flags of their names, nil if none
+func ConvertStringToTCPFlags(str string) *flow.TCPFlags {
+	if str == "" {
+		return nil
+	}
+
+	flags := &flow.TCPFlags{}
+	for _, name := range strings.Split(str, ",") {
+		switch name {
+		case "SYN":
+			flags.SYN = true
+		case "ACK":
+			flags.ACK = true
+		case "FIN":
+			flags.FIN = true
+		case "RST":
+			flags.RST = true
+		case "PSH":
+			flags.PSH = true
+		case "URG":
+			flags.URG = true
+		case "ECE":
+			flags.ECE = true
+		case "CWR":
+			flags.CWR = true
+		case "NS":
+			flags.NS = true
+		}
+	}
+	return flags
+}
+
 func convertFlowLogToCiliumLog(flowLog *flow.Flow) (types.CiliumLog, error) {
 	ciliumLog := types.CiliumLog{}
 
@@ -128,6 +182,7 @@ func convertFlowLogToCiliumLog(flowLog *flow.Flow) (types.CiliumLog, error) {
 	ciliumLog.IpEncrypted = ip.Encrypted
 	ciliumLog.L4TCPSourcePort = l4TCP.SourcePort
 	ciliumLog.L4TCPDestinationPort = l4TCP.DestinationPort
+	ciliumLog.L4TCPFlags = ConvertTCPFlagsToString(l4TCP.Flags)
 	ciliumLog.L4UDPSourcePort = l4UDP.SourcePort
 	ciliumLog.L4UDPDestinationPort = l4UDP.DestinationPort
 	ciliumLog.L4ICMPv4Type = l4ICMPv4.Type
@@ -190,12 +245,48 @@ func ProcessNetworkLogs() {
 		}
 		res = append(res, netLog)
 	}
-	if err := libs.UpdateOrInsertCiliumLogs(CfgDB, res); err != nil {
+	if err := libs.UpdateOrInsertCiliumLogs(CfgDB, compactCiliumLogs(res)); err != nil {
 		log.Error().Msg(err.Error())
 	}
 	ObsMutex.Unlock()
 }
 
+// compactCiliumLogs collapses the logs differing only in their time into one log
+// counting them, so that a connection retried many times is a single row update.
+// The client port of the requests is dropped, it would make every connection unique.
+func compactCiliumLogs(ciliumLogs []types.CiliumLog) []types.CiliumLog {
+	res := []types.CiliumLog{}
+	index := map[types.CiliumLog]int{}
+
+	for _, ciliumLog := range ciliumLogs {
+		if !ciliumLog.IsReply {
+			ciliumLog.L4TCPSourcePort = 0
+			ciliumLog.L4UDPSourcePort = 0
+		}
+
+		key := ciliumLog
+		key.StartTime, key.UpdatedTime, key.Total = 0, 0, 0
+
+		i, ok := index[key]
+		if !ok {
+			ciliumLog.Total = 1
+			index[key] = len(res)
+			res = append(res, ciliumLog)
+			continue
+		}
+
+		res[i].Total++
+		if ciliumLog.StartTime < res[i].StartTime {
+			res[i].StartTime = ciliumLog.StartTime
+		}
+		if ciliumLog.UpdatedTime > res[i].UpdatedTime {
+			res[i].UpdatedTime = ciliumLog.UpdatedTime
+		}
+	}
+
+	return res
+}
+
 // ProcessCiliumFlow : Add cilium flow logs to global buffer
 func ProcessCiliumFlow(flowLog *flow.Flow) {
 	NetworkLogsMutex.Lock()
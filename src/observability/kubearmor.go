@@ -168,14 +168,13 @@ func aggregateProcFileData(data []types.SysObsProcFileData) []types.SysObsProcFi
 	res := []types.SysObsProcFileData{}
 
 	for _, locData := range data {
-		var destination string
+		destination := common.NormalizePath(locData.Destination)
 
 		for _, dir := range aggregatedDir {
-			if strings.HasPrefix(locData.Destination, dir) {
+			if strings.HasPrefix(destination, dir) {
 				destination = dir
 				break
 			}
-			destination = locData.Destination
 		}
 
 		locKey := types.SysObsProcFileMapKey{
@@ -80,6 +80,7 @@ func aggregateSummaryMap(summaryMap map[types.SystemSummary]types.SysSummaryTime
 	for sysSummary, summaryTimeCount := range fileSummarizerMap {
 		key := sysSummary.PodName + "_" + sysSummary.Source
 		files := aggPodFilePaths[key]
+		sysSummary.Destination = common.NormalizePath(sysSummary.Destination)
 		for _, path := range files {
 			if strings.HasPrefix(sysSummary.Destination, path.Path) && (len(sysSummary.Destination) == len(path.Path) || sysSummary.Destination[len(strings.TrimSuffix(path.Path, "/"))] == '/') {
 				sysSummary.Destination = path.Path
@@ -3,7 +3,6 @@ package plugin
 import (
 	"context"
 	"encoding/json"
-	"net"
 	"net/url"
 	"strconv"
 	"strings"
@@ -17,8 +16,9 @@ import (
 	"github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
-	"github.com/cilium/cilium/api/v1/flow"
 	cilium "github.com/cilium/cilium/api/v1/flow"
 	"github.com/cilium/cilium/api/v1/observer"
 	cu "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/utils"
@@ -59,7 +59,8 @@ var Verdict = map[string]int{
 // == Global Variables  == //
 // ======================= //
 
-var CiliumFlows []*cilium.Flow
+// CiliumFlows compacts the flows streamed from the hubble relay until the next discovery
+var CiliumFlows = NewNetworkLogCompactor()
 var CiliumFlowsMutex *sync.Mutex
 var CiliumFlowsFC []*types.KnoxNetworkLog
 var CiliumFlowsFCMutex *sync.Mutex
@@ -177,6 +178,16 @@ func ConvertCiliumFlowToKnoxNetworkLog(ciliumFlow *cilium.Flow) (types.KnoxNetwo
 	} else {
 		log.Action = "allow"
 	}
+	log.Verdict = ciliumFlow.Verdict.String()
+	if ciliumFlow.Verdict == cilium.Verdict_DROPPED {
+		log.DropReason = ciliumFlow.GetDropReasonDesc().String()
+	}
+
+	// set cluster, overridden by the relay the flow came from
+	log.ClusterName = config.GetCfgClusterName()
+
+	log.StartTime = ciliumFlow.GetTime().GetSeconds()
+	log.UpdatedTime = log.StartTime
 
 	// set EGRESS / INGRESS
 	log.Direction = ciliumFlow.GetTrafficDirection().String()
@@ -757,8 +768,8 @@ func ConvertKnoxPoliciesToCiliumPolicies(policies []types.KnoxNetworkPolicy) []t
 // == Cilium Hubble Relay == //
 // ========================= //
 
-func ConnectHubbleRelay(cfg types.ConfigCiliumHubble) *grpc.ClientConn {
-	addr := net.JoinHostPort(cfg.HubbleURL, cfg.HubblePort)
+func ConnectHubbleRelay(ep types.RelayEndpoint) *grpc.ClientConn {
+	addr := relayAddr(ep)
 
 	conn, err := grpc.Dial(addr, grpc.WithInsecure())
 	if err != nil {
@@ -770,34 +781,52 @@ func ConnectHubbleRelay(cfg types.ConfigCiliumHubble) *grpc.ClientConn {
 	return conn
 }
 
-func GetCiliumFlowsFromHubble(trigger int) []*cilium.Flow {
-	results := []*cilium.Flow{}
+// checkHubbleRelayHealth asks the relay for its status, the dial does not block
+// so this is where an unreachable relay shows up
+func checkHubbleRelayHealth(conn *grpc.ClientConn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	_, err := observer.NewObserverClient(conn).ServerStatus(ctx, &observer.ServerStatusRequest{})
+	return err
+}
+
+// GetCiliumFlowsFromHubble returns the compacted hubble flows once the number of flows
+// streamed reaches the trigger
+func GetCiliumFlowsFromHubble(trigger int) []*types.KnoxNetworkLog {
+	results := []*types.KnoxNetworkLog{}
 
 	CiliumFlowsMutex.Lock()
-	if len(CiliumFlows) == 0 {
+	if CiliumFlows.Total() == 0 {
 		log.Info().Msgf("Cilium hubble traffic flow not exist")
 		CiliumFlowsMutex.Unlock()
 		return results
 	}
 
-	if len(CiliumFlows) < trigger {
-		log.Info().Msgf("The number of cilium hubble traffic flow [%d] is less than trigger [%d]", len(CiliumFlows), trigger)
+	if CiliumFlows.Total() < trigger {
+		log.Info().Msgf("The number of cilium hubble traffic flow [%d] is less than trigger [%d]", CiliumFlows.Total(), trigger)
 		CiliumFlowsMutex.Unlock()
 		return results
 	}
 
-	results = CiliumFlows          // copy
-	CiliumFlows = []*cilium.Flow{} // reset
+	compacted := CiliumFlows
+	CiliumFlows = NewNetworkLogCompactor() // reset
 	CiliumFlowsMutex.Unlock()
 
-	fisrtDoc := results[0]
-	lastDoc := results[len(results)-1]
+	results = compacted.Records()
 
-	// id/time filter update
-	startTime := fisrtDoc.Time.Seconds
-	endTime := lastDoc.Time.Seconds
+	startTime, endTime := results[0].StartTime, results[0].UpdatedTime
+	for _, record := range results {
+		if record.StartTime < startTime {
+			startTime = record.StartTime
+		}
+		if record.UpdatedTime > endTime {
+			endTime = record.UpdatedTime
+		}
+	}
 
-	log.Info().Msgf("The total number of cilium hubble traffic flow: [%d] from %s ~ to %s", len(results),
+	log.Info().Msgf("The total number of cilium hubble traffic flow: [%d] compacted into [%d] from %s ~ to %s",
+		compacted.Total(), compacted.Len(),
 		time.Unix(startTime, 0).Format(libs.TimeFormSimple),
 		time.Unix(endTime, 0).Format(libs.TimeFormSimple))
 
@@ -805,49 +834,47 @@ func GetCiliumFlowsFromHubble(trigger int) []*cilium.Flow {
 }
 
 var HubbleRelayStarted = false
+var hubbleRelayStreams = newRelayStreams(&HubbleRelayStarted)
 
+// StartHubbleRelay starts streaming the flows of the relay groups not streamed yet,
+// one group per cluster with the fan-in, otherwise one group failing over between all the endpoints
 func StartHubbleRelay(StopChan chan struct{}, cfg types.ConfigCiliumHubble) {
-	if HubbleRelayStarted {
-		return
+	endpoints := getRelayEndpoints(cfg.HubbleURL, cfg.HubblePort, cfg.Endpoints)
+
+	for _, group := range groupRelayEndpoints(endpoints, cfg.FanIn) {
+		if !hubbleRelayStreams.start(group.Name) {
+			continue
+		}
+		go streamHubbleRelay(StopChan, group)
 	}
-	conn := ConnectHubbleRelay(cfg)
+}
+
+func streamHubbleRelay(StopChan chan struct{}, group relayGroup) {
+	conn, ep := connectRelayGroup(group, ConnectHubbleRelay, checkHubbleRelayHealth)
 	if conn == nil {
-		log.Error().Msg("ConnectHubbleRelay() failed")
+		log.Error().Msgf("ConnectHubbleRelay() failed for cluster [%s]", group.Name)
+		hubbleRelayStreams.stop(group.Name)
 		return
 	}
-	HubbleRelayStarted = true
 
+	done := make(chan struct{})
 	defer func() {
-		log.Info().Msg("hubble relay stream rcvr returning")
-		HubbleRelayStarted = false
+		log.Info().Msgf("hubble relay stream rcvr returning (%s)", relayAddr(ep))
+		close(done)
+		hubbleRelayStreams.stop(group.Name)
 		_ = conn.Close()
 	}()
 
+	go watchRelayHealth(done, conn, ep, checkHubbleRelayHealth)
+
 	client := observer.NewObserverClient(conn)
 
+	// select the flows on the relay, the local filtering still applies to the rest
+	whitelist, blacklist := GetHubbleFlowFilters(config.CurrentCfg.ConfigNetPolicy)
 	req := &observer.GetFlowsRequest{
-		Follow: true,
-		Whitelist: []*cilium.FlowFilter{
-			{
-				TcpFlags: []*flow.TCPFlags{
-					{SYN: true},
-				},
-			},
-			{
-				Protocol: []string{"udp"},
-				Reply:    []bool{false},
-			},
-			{
-				Protocol: []string{"icmp", "http", "dns"},
-			},
-		},
-		Blacklist: []*cilium.FlowFilter{
-			{
-				TcpFlags: []*flow.TCPFlags{
-					{ACK: true},
-				},
-			},
-		},
+		Follow:    true,
+		Whitelist: whitelist,
+		Blacklist: blacklist,
 	}
 
 	stream, err := client.GetFlows(context.Background(), req)
@@ -871,9 +898,13 @@ func StartHubbleRelay(StopChan chan struct{}, cfg types.ConfigCiliumHubble) {
 			case *observer.GetFlowsResponse_Flow:
 				flow := r.Flow
 
-				CiliumFlowsMutex.Lock()
-				CiliumFlows = append(CiliumFlows, flow)
-				CiliumFlowsMutex.Unlock()
+				// compact the flows as they come, the invalid ones are dropped right away
+				if knoxLog, valid := ConvertCiliumFlowToKnoxNetworkLog(flow); valid {
+					knoxLog.ClusterName = getRelayClusterName(ep.ClusterName)
+					CiliumFlowsMutex.Lock()
+					CiliumFlows.Add(knoxLog, flow.GetSource().GetLabels(), flow.GetDestination().GetLabels())
+					CiliumFlowsMutex.Unlock()
+				}
 
 				if config.GetCfgObservabilityEnable() {
 					obs.ProcessCiliumFlow(flow)
@@ -905,3 +936,138 @@ func GetCiliumFlowsFromFeedConsumer(trigger int) []*types.KnoxNetworkLog {
 
 	return results
 }
+
+// ===================== //
+// == Network Logs DB == //
+// ===================== //
+
+// ciliumLogsUpdatedTime is the last update of the network_logs rows already read, the rows of
+// its second may still be updated after the read, so they are read again
+var ciliumLogsUpdatedTime int64
+
+// ciliumLogsTotals are the totals of the network_logs rows already read
+var ciliumLogsTotals = map[types.CiliumLog]int64{}
+
+// GetCiliumFlowsFromDB returns the network logs of the network_logs table updated since the last call.
+// The rows are compacted, a row updated again is returned with the flows added since.
+func GetCiliumFlowsFromDB(cfg types.ConfigDB) []*types.KnoxNetworkLog {
+	results := []*types.KnoxNetworkLog{}
+
+	ciliumLogs, totals, err := libs.GetCiliumLogsUpdatedAfter(cfg, ciliumLogsUpdatedTime-1)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return results
+	}
+
+	for i, ciliumLog := range ciliumLogs {
+		if ciliumLog.UpdatedTime > ciliumLogsUpdatedTime {
+			ciliumLogsUpdatedTime = ciliumLog.UpdatedTime
+		}
+
+		key := ciliumLog
+		key.StartTime, key.UpdatedTime, key.Total = 0, 0, 0
+
+		total := int64(totals[i])
+		added := total - ciliumLogsTotals[key]
+		if added < 0 { // purged and inserted again
+			added = total
+		}
+		ciliumLogsTotals[key] = total
+		if added == 0 {
+			continue
+		}
+
+		ciliumLog.Total = added
+		if knoxLog, valid := ConvertCiliumLogToKnoxNetworkLog(ciliumLog); valid {
+			results = append(results, &knoxLog)
+		}
+	}
+
+	log.Info().Msgf("The total number of network logs from the db: [%d]", len(results))
+
+	return results
+}
+
+// ConvertCiliumLogToKnoxNetworkLog converts a network_logs row, along with its drop reason
+func ConvertCiliumLogToKnoxNetworkLog(ciliumLog types.CiliumLog) (types.KnoxNetworkLog, bool) {
+	ciliumFlow := &cilium.Flow{
+		Time:             &timestamppb.Timestamp{Seconds: ciliumLog.StartTime},
+		Verdict:          cilium.Verdict(cilium.Verdict_value[ciliumLog.Verdict]),
+		DropReasonDesc:   cilium.DropReason(cilium.DropReason_value[ciliumLog.DropReasonDesc]),
+		TrafficDirection: cilium.TrafficDirection(cilium.TrafficDirection_value[ciliumLog.TrafficDirection]),
+		IsReply:          &wrapperspb.BoolValue{Value: ciliumLog.IsReply},
+		NodeName:         ciliumLog.NodeName,
+		IP: &cilium.IP{
+			Source:      ciliumLog.IpSource,
+			Destination: ciliumLog.IpDestination,
+			IpVersion:   cilium.IPVersion(cilium.IPVersion_value[ciliumLog.IpVersion]),
+		},
+		Source: &cilium.Endpoint{
+			Namespace: ciliumLog.SourceNamespace,
+			PodName:   ciliumLog.SourcePodName,
+			Labels:    getCiliumLogLabels(ciliumLog.SourceLabels),
+		},
+		Destination: &cilium.Endpoint{
+			Namespace: ciliumLog.DestinationNamespace,
+			PodName:   ciliumLog.DestinationPodName,
+			Labels:    getCiliumLogLabels(ciliumLog.DestinationLabels),
+		},
+		L4: getCiliumLogL4(ciliumLog),
+	}
+
+	if ciliumLog.L7HttpMethod != "" || ciliumLog.L7HttpUrl != "" {
+		ciliumFlow.L7 = &cilium.Layer7{
+			Type: cilium.L7FlowType(cilium.L7FlowType_value[ciliumLog.L7Type]),
+			Record: &cilium.Layer7_Http{Http: &cilium.HTTP{
+				Code:     ciliumLog.L7HttpCode,
+				Method:   ciliumLog.L7HttpMethod,
+				Url:      ciliumLog.L7HttpUrl,
+				Protocol: ciliumLog.L7HttpProtocol,
+			}},
+		}
+	}
+
+	knoxLog, valid := ConvertCiliumFlowToKnoxNetworkLog(ciliumFlow)
+	if !valid {
+		return knoxLog, false
+	}
+
+	knoxLog.UpdatedTime = ciliumLog.UpdatedTime
+	knoxLog.Total = int(ciliumLog.Total)
+
+	return knoxLog, true
+}
+
+func getCiliumLogLabels(labels string) []string {
+	if labels == "" {
+		return nil
+	}
+	return strings.Split(labels, ",")
+}
+
+// getCiliumLogL4 tells the protocol of the row by its ports, the rows without ports are icmp
+func getCiliumLogL4(ciliumLog types.CiliumLog) *cilium.Layer4 {
+	switch {
+	case ciliumLog.L4TCPSourcePort != 0 || ciliumLog.L4TCPDestinationPort != 0:
+		return &cilium.Layer4{Protocol: &cilium.Layer4_TCP{TCP: &cilium.TCP{
+			SourcePort:      ciliumLog.L4TCPSourcePort,
+			DestinationPort: ciliumLog.L4TCPDestinationPort,
+			Flags:           obs.ConvertStringToTCPFlags(ciliumLog.L4TCPFlags),
+		}}}
+	case ciliumLog.L4UDPSourcePort != 0 || ciliumLog.L4UDPDestinationPort != 0:
+		return &cilium.Layer4{Protocol: &cilium.Layer4_UDP{UDP: &cilium.UDP{
+			SourcePort:      ciliumLog.L4UDPSourcePort,
+			DestinationPort: ciliumLog.L4UDPDestinationPort,
+		}}}
+	case ciliumLog.IpVersion == cilium.IPVersion_IPv6.String():
+		return &cilium.Layer4{Protocol: &cilium.Layer4_ICMPv6{ICMPv6: &cilium.ICMPv6{
+			Type: ciliumLog.L4ICMPv6Type,
+			Code: ciliumLog.L4ICMPv6Code,
+		}}}
+	default:
+		return &cilium.Layer4{Protocol: &cilium.Layer4_ICMPv4{ICMPv4: &cilium.ICMPv4{
+			Type: ciliumLog.L4ICMPv4Type,
+			Code: ciliumLog.L4ICMPv4Code,
+		}}}
+	}
+}
@@ -2,8 +2,11 @@ package plugin
 
 import (
 	"encoding/json"
+	"path/filepath"
 	"testing"
 
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/libs"
 	"github.com/accuknox/auto-policy-discovery/src/types"
 	flow "github.com/cilium/cilium/api/v1/flow"
 	"github.com/google/go-cmp/cmp"
@@ -78,10 +81,13 @@ func TestConvertCiliumFlowToKnoxLog(t *testing.T) {
 			"src_port": 6379,
 			"dst_port": 60416,
 			"direction": "INGRESS",
-			"action": "allow"
+			"action": "allow",
+			"verdict": "FORWARDED",
+			"start_time": 1605679254,
+			"updated_time": 1605679254
 		}
 	*/
-	logBytes := []byte("{\"src_namespace\":\"default\",\"src_pod_name\":\"redis-cart-74594bd569-gw2xb\",\"dst_reserved_labels\":[\"reserved:host\"],\"protocol\":6,\"src_ip\":\"10.0.1.31\",\"dst_ip\":\"10.0.1.144\",\"src_port\":6379,\"dst_port\":60416,\"direction\":\"INGRESS\",\"action\":\"allow\"}")
+	logBytes := []byte("{\"src_namespace\":\"default\",\"src_pod_name\":\"redis-cart-74594bd569-gw2xb\",\"dst_reserved_labels\":[\"reserved:host\"],\"protocol\":6,\"src_ip\":\"10.0.1.31\",\"dst_ip\":\"10.0.1.144\",\"src_port\":6379,\"dst_port\":60416,\"direction\":\"INGRESS\",\"action\":\"allow\",\"verdict\":\"FORWARDED\",\"start_time\":1605679254,\"updated_time\":1605679254}")
 	flow := &flow.Flow{}
 	json.Unmarshal(flowBytes, flow)
 
@@ -182,3 +188,116 @@ func TestConvertKnoxPolicyToCiliumPolicy(t *testing.T) {
 		t.Errorf("they should be equal %v %v", expected, actual)
 	}
 }
+
+func TestConvertCiliumLogToKnoxNetworkLog(t *testing.T) {
+	ciliumLog := types.CiliumLog{
+		Verdict:              "DROPPED",
+		DropReasonDesc:       "POLICY_DENIED",
+		TrafficDirection:     "EGRESS",
+		IpSource:             "10.0.0.1",
+		IpDestination:        "10.0.0.2",
+		IpVersion:            "IPv4",
+		L4TCPSourcePort:      40001,
+		L4TCPDestinationPort: 6379,
+		L4TCPFlags:           "SYN",
+		SourceNamespace:      "default",
+		SourcePodName:        "frontend-1",
+		SourceLabels:         "app=frontend",
+		DestinationLabels:    "reserved:world",
+		StartTime:            100,
+		UpdatedTime:          200,
+		Total:                3,
+	}
+
+	log, valid := ConvertCiliumLogToKnoxNetworkLog(ciliumLog)
+	if !valid {
+		t.Fatal("the network log is not valid")
+	}
+
+	expected := types.KnoxNetworkLog{
+		SrcNamespace:      "default",
+		SrcPodName:        "frontend-1",
+		DstReservedLabels: []string{"reserved:world"},
+		SrcIP:             "10.0.0.1",
+		DstIP:             "10.0.0.2",
+		Protocol:          6,
+		SrcPort:           40001,
+		DstPort:           6379,
+		SynFlag:           true,
+		Direction:         "EGRESS",
+		Action:            "deny",
+		Verdict:           "DROPPED",
+		DropReason:        "POLICY_DENIED",
+		StartTime:         100,
+		UpdatedTime:       200,
+		Total:             3,
+	}
+	if diff := cmp.Diff(expected, log); diff != "" {
+		t.Errorf("ConvertCiliumLogToKnoxNetworkLog() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetCiliumFlowsFromDB(t *testing.T) {
+	dir := t.TempDir()
+	prevObsDBName := config.CurrentCfg.ConfigObservability.DBName
+	defer func() { config.CurrentCfg.ConfigObservability.DBName = prevObsDBName }()
+	config.CurrentCfg.ConfigObservability.DBName = filepath.Join(dir, "observability.db")
+
+	defer func() { ciliumLogsUpdatedTime, ciliumLogsTotals = 0, map[types.CiliumLog]int64{} }()
+
+	cfg := types.ConfigDB{DBDriver: "sqlite3", SQLiteDBPath: filepath.Join(dir, "knox.db")}
+	store, err := libs.GetStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	newCiliumLog := func(dstPort uint32, total int64) types.CiliumLog {
+		return types.CiliumLog{
+			Verdict:              "FORWARDED",
+			TrafficDirection:     "EGRESS",
+			IpSource:             "10.0.0.1",
+			IpDestination:        "10.0.0.2",
+			IpVersion:            "IPv4",
+			L4TCPDestinationPort: dstPort,
+			L4TCPFlags:           "SYN",
+			SourceNamespace:      "default",
+			SourcePodName:        "frontend-1",
+			DestinationLabels:    "reserved:world",
+			StartTime:            100,
+			UpdatedTime:          100,
+			Total:                total,
+		}
+	}
+	totals := func(logs []*types.KnoxNetworkLog) map[int]int {
+		res := map[int]int{}
+		for _, log := range logs {
+			if !log.SynFlag {
+				t.Errorf("the tcp flags of the network log %v are lost", log)
+			}
+			res[log.DstPort] = log.Total
+		}
+		return res
+	}
+
+	if err := store.UpdateOrInsertCiliumLogs([]types.CiliumLog{newCiliumLog(80, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[int]int{80: 2}, totals(GetCiliumFlowsFromDB(cfg))); diff != "" {
+		t.Errorf("GetCiliumFlowsFromDB() mismatch (-want +got):\n%s", diff)
+	}
+
+	// the rows updated in the same second are read again, with the flows added since
+	if err := store.UpdateOrInsertCiliumLogs([]types.CiliumLog{newCiliumLog(80, 3), newCiliumLog(443, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[int]int{80: 3, 443: 1}, totals(GetCiliumFlowsFromDB(cfg))); diff != "" {
+		t.Errorf("GetCiliumFlowsFromDB() mismatch (-want +got):\n%s", diff)
+	}
+
+	if logs := GetCiliumFlowsFromDB(cfg); len(logs) != 0 {
+		t.Errorf("the network logs already read are read again: %v", logs)
+	}
+}
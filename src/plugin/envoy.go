@@ -0,0 +1,492 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/cluster"
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// ======================= //
+// == Global Variables  == //
+// ======================= //
+
+// EnvoyAccessLogs compacts the access logs received over OTLP/HTTP until the next discovery
+var EnvoyAccessLogs = NewNetworkLogCompactor()
+var EnvoyAccessLogsMutex = &sync.Mutex{}
+
+var envoyReceiverStarted bool
+var envoyReceiverMutex sync.Mutex
+
+// envoyMaxRequestSize bounds the OTLP/HTTP exports the receiver reads
+const envoyMaxRequestSize = 16 << 20
+
+// envoyPods resolve the access logs received, they are refreshed by the discovery cycles
+// rather than looked up for each export
+var envoyPods []types.Pod
+var envoyPodsLoaded bool
+var envoyPodsMutex sync.Mutex
+
+// lookupEnvoyPods is replaced by the tests
+var lookupEnvoyPods = getEnvoyPods
+
+// ========================= //
+// == Envoy Access Logs  == //
+// ========================= //
+
+// envoyAccessLog is an envoy access log record, as written by the istio JSON access log
+// format (start_time, method, path, upstream_cluster, downstream_remote_address, ...)
+type envoyAccessLog map[string]interface{}
+
+func (a envoyAccessLog) get(key string) string {
+	switch value := a[key].(type) {
+	case string:
+		if value == "-" {
+			return ""
+		}
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return ""
+}
+
+// otlpLogs is the part of an OTLP/HTTP JSON logs export holding the access logs
+type otlpLogs struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			LogRecords []struct {
+				TimeUnixNano string         `json:"timeUnixNano"`
+				Body         otlpAnyValue   `json:"body"`
+				Attributes   []otlpKeyValue `json:"attributes"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string      `json:"stringValue,omitempty"`
+	IntValue    *json.Number `json:"intValue,omitempty"`
+	DoubleValue *float64     `json:"doubleValue,omitempty"`
+	BoolValue   *bool        `json:"boolValue,omitempty"`
+	KvlistValue *struct {
+		Values []otlpKeyValue `json:"values"`
+	} `json:"kvlistValue,omitempty"`
+}
+
+func (v otlpAnyValue) value() interface{} {
+	if v.StringValue != nil {
+		return *v.StringValue
+	} else if v.IntValue != nil {
+		return *v.IntValue
+	} else if v.DoubleValue != nil {
+		return *v.DoubleValue
+	} else if v.BoolValue != nil {
+		return *v.BoolValue
+	}
+	return nil
+}
+
+func addOTLPAttributes(accessLog envoyAccessLog, attributes []otlpKeyValue) {
+	for _, attr := range attributes {
+		if value := attr.Value.value(); value != nil {
+			accessLog[attr.Key] = value
+		}
+	}
+}
+
+// parseEnvoyAccessLogs parses an envoy JSON access log, or an OTLP/HTTP JSON logs export
+// whose records hold the access log in the body (JSON string or key/value list) or attributes
+func parseEnvoyAccessLogs(record []byte) ([]envoyAccessLog, error) {
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+
+	accessLog := envoyAccessLog{}
+	if err := decoder.Decode(&accessLog); err != nil {
+		return nil, err
+	}
+	if _, ok := accessLog["resourceLogs"]; !ok {
+		return []envoyAccessLog{accessLog}, nil
+	}
+
+	export := otlpLogs{}
+	if err := json.Unmarshal(record, &export); err != nil {
+		return nil, err
+	}
+
+	accessLogs := []envoyAccessLog{}
+	for _, resourceLogs := range export.ResourceLogs {
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			for _, logRecord := range scopeLogs.LogRecords {
+				accessLog := envoyAccessLog{}
+				addOTLPAttributes(accessLog, resourceLogs.Resource.Attributes)
+
+				if logRecord.Body.StringValue != nil {
+					decoder := json.NewDecoder(strings.NewReader(*logRecord.Body.StringValue))
+					decoder.UseNumber()
+					_ = decoder.Decode(&accessLog) // text bodies, the fields are in the attributes
+				} else if logRecord.Body.KvlistValue != nil {
+					addOTLPAttributes(accessLog, logRecord.Body.KvlistValue.Values)
+				}
+				addOTLPAttributes(accessLog, logRecord.Attributes)
+
+				if accessLog.get("start_time") == "" && logRecord.TimeUnixNano != "" {
+					if nano, err := strconv.ParseInt(logRecord.TimeUnixNano, 10, 64); err == nil {
+						accessLog["start_time"] = time.Unix(0, nano).UTC().Format(time.RFC3339Nano)
+					}
+				}
+
+				accessLogs = append(accessLogs, accessLog)
+			}
+		}
+	}
+
+	return accessLogs, nil
+}
+
+// ================================== //
+// == Envoy Access Log Convertor  == //
+// ================================== //
+
+func splitEnvoyAddress(address string) (string, int) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0
+	}
+	portNum, _ := strconv.Atoi(port)
+	return host, portNum
+}
+
+// getUpstreamClusterNamespace returns the namespace of an istio upstream cluster,
+// e.g. default for outbound|9080||reviews.default.svc.cluster.local
+func getUpstreamClusterNamespace(upstreamCluster string) string {
+	parts := strings.Split(upstreamCluster, "|")
+	if len(parts) != 4 || !strings.HasSuffix(parts[3], ".svc.cluster.local") {
+		return ""
+	}
+
+	host := strings.Split(parts[3], ".")
+	if len(host) < 2 {
+		return ""
+	}
+	return host[1]
+}
+
+// envoyEndpoint resolves the pod of an access log address
+type envoyEndpoint struct {
+	namespace string
+	podName   string
+	labels    []string
+	reserved  []string
+}
+
+func getEnvoyEndpoint(ip string, pods []types.Pod) envoyEndpoint {
+	for _, pod := range pods {
+		if pod.PodIP == ip {
+			return envoyEndpoint{namespace: pod.Namespace, podName: pod.PodName, labels: pod.Labels}
+		}
+	}
+	return envoyEndpoint{reserved: []string{CiliumReserved + "world"}}
+}
+
+// convertEnvoyAccessLog converts an access log of a sidecar into a network log.
+// The inbound listeners log the requests to their pod (INGRESS), the outbound ones the requests
+// of their pod (EGRESS). The pods are resolved by ip, the other endpoints are reserved:world.
+func convertEnvoyAccessLog(accessLog envoyAccessLog, pods []types.Pod) (types.KnoxNetworkLog, []string, []string, bool) {
+	log := types.KnoxNetworkLog{
+		ClusterName:     accessLog.get("k8s.cluster.name"),
+		UpstreamCluster: accessLog.get("upstream_cluster"),
+		Protocol:        libs.IPProtocolTCP,
+		SynFlag:         true, // an access log is a request or a connection
+	}
+
+	srcIP, srcPort := splitEnvoyAddress(accessLog.get("downstream_remote_address"))
+	dstIP, dstPort := splitEnvoyAddress(accessLog.get("downstream_local_address"))
+
+	if strings.HasPrefix(log.UpstreamCluster, "inbound|") || strings.HasPrefix(log.UpstreamCluster, "InboundPassthroughCluster") {
+		log.Direction = "INGRESS"
+	} else {
+		log.Direction = "EGRESS"
+		// the selected upstream instead of the service ip, except for the passthrough traffic
+		if ip, port := splitEnvoyAddress(accessLog.get("upstream_host")); ip != "" && !strings.HasPrefix(log.UpstreamCluster, "Passthrough") {
+			dstIP, dstPort = ip, port
+		}
+	}
+	if srcIP == "" || dstIP == "" {
+		return log, nil, nil, false
+	}
+	if net.ParseIP(srcIP).IsLoopback() || net.ParseIP(dstIP).IsLoopback() {
+		return log, nil, nil, false
+	}
+
+	log.SrcIP, log.SrcPort = srcIP, srcPort
+	log.DstIP, log.DstPort = dstIP, dstPort
+
+	src := getEnvoyEndpoint(srcIP, pods)
+	log.SrcNamespace, log.SrcPodName, log.SrcReservedLabels = src.namespace, src.podName, src.reserved
+
+	dst := getEnvoyEndpoint(dstIP, pods)
+	log.DstNamespace, log.DstPodName, log.DstReservedLabels = dst.namespace, dst.podName, dst.reserved
+	if dst.podName == "" {
+		if namespace := getUpstreamClusterNamespace(log.UpstreamCluster); namespace != "" {
+			log.DstNamespace, log.DstReservedLabels = namespace, nil
+		}
+	}
+
+	// denied by the mesh (rbac, ext authz) or by the outbound traffic policy
+	flags := accessLog.get("response_flags")
+	if log.UpstreamCluster == "BlackHoleCluster" || strings.Contains(flags, "RBAC") || strings.Contains(flags, "UAEX") {
+		log.Action = "deny"
+		log.Verdict = "DROPPED"
+		log.DropReason = flags
+	} else {
+		log.Action = "allow"
+		log.Verdict = "FORWARDED"
+	}
+
+	if startTime, err := time.Parse(time.RFC3339Nano, accessLog.get("start_time")); err == nil {
+		log.StartTime = startTime.Unix()
+		log.UpdatedTime = log.StartTime
+	}
+
+	// get L7 HTTP
+	if method := accessLog.get("method"); method != "" {
+		u, err := url.Parse(accessLog.get("path"))
+		if err != nil || u.Path == "" {
+			return log, nil, nil, false
+		}
+		log.L7Protocol = libs.L7ProtocolHTTP
+		log.HTTPMethod, log.HTTPPath = method, u.Path
+	}
+
+	return log, src.labels, dst.labels, true
+}
+
+// addEnvoyAccessLogs converts and compacts the access logs of the record
+func addEnvoyAccessLogs(compactor *NetworkLogCompactor, record []byte, pods []types.Pod) error {
+	accessLogs, err := parseEnvoyAccessLogs(record)
+	if err != nil {
+		return err
+	}
+
+	for _, accessLog := range accessLogs {
+		if log, srcLabels, dstLabels, valid := convertEnvoyAccessLog(accessLog, pods); valid {
+			compactor.Add(log, srcLabels, dstLabels)
+		}
+	}
+
+	return nil
+}
+
+func getEnvoyPods() []types.Pod {
+	_, _, _, pods, err := cluster.GetAllClusterResources(config.GetCfgClusterName())
+	if err != nil {
+		log.Error().Msgf("Failed to get the pods resolving the envoy access logs: %s", err)
+	}
+	return pods
+}
+
+// refreshEnvoyPods looks the pods up again, once per discovery cycle
+func refreshEnvoyPods() []types.Pod {
+	pods := lookupEnvoyPods()
+
+	envoyPodsMutex.Lock()
+	defer envoyPodsMutex.Unlock()
+
+	envoyPods = pods
+	envoyPodsLoaded = true
+	return pods
+}
+
+// getCachedEnvoyPods returns the pods of the last discovery cycle, looked up on the first call
+func getCachedEnvoyPods() []types.Pod {
+	envoyPodsMutex.Lock()
+	pods, loaded := envoyPods, envoyPodsLoaded
+	envoyPodsMutex.Unlock()
+
+	if !loaded {
+		return refreshEnvoyPods()
+	}
+	return pods
+}
+
+// ========================== //
+// == Envoy Access Log File == //
+// ========================== //
+
+// GetNetworkLogsFromEnvoy returns the network logs of the access logs appended to the file,
+// or to the files of the directory, since the last call, and of the access logs received
+func GetNetworkLogsFromEnvoy(path string) []*types.KnoxNetworkLog {
+	EnvoyAccessLogsMutex.Lock()
+	compactor := EnvoyAccessLogs
+	EnvoyAccessLogs = NewNetworkLogCompactor() // reset
+	EnvoyAccessLogsMutex.Unlock()
+
+	pods := refreshEnvoyPods()
+	if path != "" {
+		err := readLogFile(path, func(record []byte) {
+			if err := addEnvoyAccessLogs(compactor, record, pods); err != nil {
+				log.Error().Msgf("Failed to parse the envoy access log [%s]: %s", record, err)
+			}
+		})
+		if err != nil {
+			log.Error().Msg(err.Error())
+		}
+	}
+
+	if compactor.Total() > 0 {
+		log.Info().Msgf("The number of envoy access logs: [%d], compacted into [%d]", compactor.Total(), compactor.Len())
+	}
+
+	return compactor.Records()
+}
+
+// ================================ //
+// == Envoy OTLP/HTTP Receiver  == //
+// ================================ //
+
+// envoyAccessLogHandler receives the OTLP/HTTP JSON logs exports, or the envoy access
+// logs themselves, one per line or in an array
+func envoyAccessLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.Contains(r.Header.Get("Content-Type"), "protobuf") {
+		http.Error(w, "only the JSON encoding is supported", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readEnvoyRequest(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, errEnvoyUnsupportedEncoding):
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		case errors.As(err, &maxBytesErr):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	pods := getCachedEnvoyPods()
+
+	EnvoyAccessLogsMutex.Lock()
+	_, _, _ = consumeRecords(bufio.NewReader(bytes.NewReader(body)), true, func(record []byte) {
+		if err := addEnvoyAccessLogs(EnvoyAccessLogs, record, pods); err != nil {
+			log.Error().Msgf("Failed to parse the envoy access log [%s]: %s", record, err)
+		}
+	})
+	EnvoyAccessLogsMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte("{}"))
+}
+
+// errEnvoyUnsupportedEncoding is returned for the exports neither plain nor gzipped
+var errEnvoyUnsupportedEncoding = errors.New("only the gzip content encoding is supported")
+
+// readEnvoyRequest reads the body of an export, gunzipped if need be; the compressed and
+// the uncompressed bodies are both bounded
+func readEnvoyRequest(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, envoyMaxRequestSize)
+
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+		return io.ReadAll(body)
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		content, err := io.ReadAll(io.LimitReader(gz, envoyMaxRequestSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > envoyMaxRequestSize {
+			return nil, &http.MaxBytesError{Limit: envoyMaxRequestSize}
+		}
+		return content, nil
+	default:
+		return nil, errEnvoyUnsupportedEncoding
+	}
+}
+
+// StartEnvoyAccessLogReceiver serves the OTLP/HTTP logs endpoint (/v1/logs) on the address,
+// until the stop channel is closed
+func StartEnvoyAccessLogReceiver(StopChan chan struct{}, addr string) {
+	if addr == "" {
+		return
+	}
+
+	envoyReceiverMutex.Lock()
+	defer envoyReceiverMutex.Unlock()
+
+	if envoyReceiverStarted {
+		return
+	}
+
+	// the address is bound first, a failed attempt leaves nothing behind to retry
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Error().Msgf("envoy access log receiver failed: %s", err)
+		return
+	}
+	envoyReceiverStarted = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/logs", envoyAccessLogHandler)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		log.Info().Msgf("envoy access log receiver listening on %s", addr)
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Msgf("envoy access log receiver failed: %s", err)
+		}
+
+		envoyReceiverMutex.Lock()
+		envoyReceiverStarted = false
+		envoyReceiverMutex.Unlock()
+	}()
+
+	go func() {
+		select {
+		case <-StopChan:
+			_ = server.Close()
+		case <-done:
+		}
+	}()
+}
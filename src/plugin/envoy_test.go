@@ -0,0 +1,218 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const testEnvoyOutboundLog = `{"start_time":"2023-05-01T10:00:00.000Z","method":"GET","path":"/reviews/1?x=1","protocol":"HTTP/1.1","response_code":200,"response_flags":"-","upstream_cluster":"outbound|9080||reviews.default.svc.cluster.local","upstream_host":"10.0.0.2:9080","downstream_local_address":"10.96.0.10:9080","downstream_remote_address":"10.0.0.1:41234"}`
+
+const testEnvoyInboundLog = `{"start_time":"2023-05-01T10:00:01.000Z","method":"GET","path":"/reviews/1","protocol":"HTTP/1.1","response_code":403,"response_flags":"-","upstream_cluster":"inbound|9080||","upstream_host":"10.0.0.2:9080","downstream_local_address":"10.0.0.2:9080","downstream_remote_address":"10.0.0.1:41234"}`
+
+var envoyTestPods = []types.Pod{
+	{Namespace: "default", PodName: "productpage-1", PodIP: "10.0.0.1", Labels: []string{"app=productpage"}},
+	{Namespace: "default", PodName: "reviews-1", PodIP: "10.0.0.2", Labels: []string{"app=reviews"}},
+}
+
+func parseTestEnvoyLog(t *testing.T, record string) envoyAccessLog {
+	accessLogs, err := parseEnvoyAccessLogs([]byte(record))
+	assert.NoError(t, err)
+	assert.Len(t, accessLogs, 1)
+	return accessLogs[0]
+}
+
+func TestConvertEnvoyAccessLog(t *testing.T) {
+	log, srcLabels, dstLabels, valid := convertEnvoyAccessLog(parseTestEnvoyLog(t, testEnvoyOutboundLog), envoyTestPods)
+	if assert.True(t, valid) {
+		assert.Equal(t, "EGRESS", log.Direction)
+		assert.Equal(t, "productpage-1", log.SrcPodName)
+		assert.Equal(t, "reviews-1", log.DstPodName)
+		assert.Equal(t, "default", log.DstNamespace)
+		assert.Equal(t, "10.0.0.2", log.DstIP)
+		assert.Equal(t, 9080, log.DstPort)
+		assert.Equal(t, "http", log.L7Protocol)
+		assert.Equal(t, "GET", log.HTTPMethod)
+		assert.Equal(t, "/reviews/1", log.HTTPPath)
+		assert.Equal(t, "outbound|9080||reviews.default.svc.cluster.local", log.UpstreamCluster)
+		assert.Equal(t, "FORWARDED", log.Verdict)
+		assert.Equal(t, int64(1682935200), log.StartTime)
+		assert.True(t, log.SynFlag)
+		assert.Equal(t, []string{"app=productpage"}, srcLabels)
+		assert.Equal(t, []string{"app=reviews"}, dstLabels)
+	}
+
+	log, _, _, valid = convertEnvoyAccessLog(parseTestEnvoyLog(t, testEnvoyInboundLog), envoyTestPods)
+	if assert.True(t, valid) {
+		assert.Equal(t, "INGRESS", log.Direction)
+		assert.Equal(t, "productpage-1", log.SrcPodName)
+		assert.Equal(t, "reviews-1", log.DstPodName)
+	}
+
+	// the upstream outside the pods, only the namespace of the service is known
+	log, _, _, valid = convertEnvoyAccessLog(parseTestEnvoyLog(t, testEnvoyOutboundLog), envoyTestPods[:1])
+	if assert.True(t, valid) {
+		assert.Equal(t, "", log.DstPodName)
+		assert.Equal(t, "default", log.DstNamespace)
+	}
+
+	// tcp proxy to the outside, blocked by the outbound traffic policy
+	tcp := `{"upstream_cluster":"BlackHoleCluster","downstream_local_address":"1.1.1.1:443","downstream_remote_address":"10.0.0.1:41234","response_flags":"-"}`
+	log, _, _, valid = convertEnvoyAccessLog(parseTestEnvoyLog(t, tcp), envoyTestPods)
+	if assert.True(t, valid) {
+		assert.Equal(t, "DROPPED", log.Verdict)
+		assert.Equal(t, "deny", log.Action)
+		assert.Equal(t, []string{"reserved:world"}, log.DstReservedLabels)
+		assert.Equal(t, 443, log.DstPort)
+		assert.Empty(t, log.L7Protocol)
+	}
+
+	// the requests between the sidecar and its application are not network traffic
+	local := `{"upstream_cluster":"inbound|9080||","downstream_local_address":"127.0.0.6:9080","downstream_remote_address":"10.0.0.1:41234"}`
+	_, _, _, valid = convertEnvoyAccessLog(parseTestEnvoyLog(t, local), envoyTestPods)
+	assert.False(t, valid)
+}
+
+func TestParseEnvoyOTLPLogs(t *testing.T) {
+	export := `{"resourceLogs":[{"resource":{"attributes":[{"key":"k8s.cluster.name","value":{"stringValue":"cluster-a"}}]},"scopeLogs":[{"logRecords":[` +
+		`{"timeUnixNano":"1682935200000000000","body":{"stringValue":` + jsonQuote(testEnvoyOutboundLog) + `}},` +
+		`{"timeUnixNano":"1682935201000000000","body":{"stringValue":"[2023-05-01T10:00:01.000Z] GET /reviews/1"},"attributes":[` +
+		`{"key":"upstream_cluster","value":{"stringValue":"inbound|9080||"}},` +
+		`{"key":"downstream_local_address","value":{"stringValue":"10.0.0.2:9080"}},` +
+		`{"key":"downstream_remote_address","value":{"stringValue":"10.0.0.1:41234"}},` +
+		`{"key":"response_code","value":{"intValue":"200"}}]}]}]}]}`
+
+	accessLogs, err := parseEnvoyAccessLogs([]byte(export))
+	assert.NoError(t, err)
+	if assert.Len(t, accessLogs, 2) {
+		assert.Equal(t, "cluster-a", accessLogs[0].get("k8s.cluster.name"))
+		assert.Equal(t, "GET", accessLogs[0].get("method"))
+		assert.Equal(t, "200", accessLogs[1].get("response_code"))
+		assert.Equal(t, "2023-05-01T10:00:01Z", accessLogs[1].get("start_time"))
+
+		log, _, _, valid := convertEnvoyAccessLog(accessLogs[1], envoyTestPods)
+		assert.True(t, valid)
+		assert.Equal(t, "cluster-a", log.ClusterName)
+		assert.Equal(t, "INGRESS", log.Direction)
+	}
+}
+
+func jsonQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func TestGetNetworkLogsFromEnvoy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	writeTestFile(t, path, testEnvoyOutboundLog+"\n"+testEnvoyOutboundLog+"\n"+testEnvoyInboundLog+"\n")
+
+	logs := GetNetworkLogsFromEnvoy(path)
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, 2, logs[0].Total)
+	}
+	assert.Len(t, GetNetworkLogsFromEnvoy(path), 0)
+}
+
+func TestEnvoyAccessLogHandler(t *testing.T) {
+	defer func(lookup func() []types.Pod) { lookupEnvoyPods = lookup }(lookupEnvoyPods)
+	lookups := 0
+	lookupEnvoyPods = func() []types.Pod {
+		lookups++
+		return nil
+	}
+	envoyPodsLoaded = false
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(testEnvoyOutboundLog+"\n"+testEnvoyInboundLog+"\n"))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	envoyAccessLogHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// the pods are looked up once, not for each export
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(""))
+	rec = httptest.NewRecorder()
+	envoyAccessLogHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, lookups)
+
+	// the exports are bounded
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(strings.Repeat(" ", envoyMaxRequestSize+1)))
+	rec = httptest.NewRecorder()
+	envoyAccessLogHandler(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	rec = httptest.NewRecorder()
+	envoyAccessLogHandler(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	// the gzipped exports are decoded, within the bound once uncompressed
+	gzipped := func(content string) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		_, _ = gz.Write([]byte(content))
+		_ = gz.Close()
+		return buf
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", gzipped(testEnvoyOutboundLog))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	envoyAccessLogHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", gzipped(strings.Repeat(" ", envoyMaxRequestSize+1)))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	envoyAccessLogHandler(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(testEnvoyOutboundLog))
+	req.Header.Set("Content-Encoding", "br")
+	rec = httptest.NewRecorder()
+	envoyAccessLogHandler(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	assert.Len(t, GetNetworkLogsFromEnvoy(""), 2)
+	assert.Len(t, GetNetworkLogsFromEnvoy(""), 0)
+
+	// each discovery cycle refreshes the pods
+	assert.Equal(t, 3, lookups)
+}
+
+func TestStartEnvoyAccessLogReceiver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := ln.Addr().String()
+
+	// the address in use is retried later, nothing is left started
+	stop := make(chan struct{})
+	StartEnvoyAccessLogReceiver(stop, addr)
+	envoyReceiverMutex.Lock()
+	assert.False(t, envoyReceiverStarted)
+	envoyReceiverMutex.Unlock()
+
+	assert.NoError(t, ln.Close())
+	StartEnvoyAccessLogReceiver(stop, addr)
+	envoyReceiverMutex.Lock()
+	assert.True(t, envoyReceiverStarted)
+	envoyReceiverMutex.Unlock()
+
+	close(stop)
+	assert.Eventually(t, func() bool {
+		envoyReceiverMutex.Lock()
+		defer envoyReceiverMutex.Unlock()
+		return !envoyReceiverStarted
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,160 @@
+package plugin
+
+import (
+	"strings"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/cilium/cilium/api/v1/flow"
+	"google.golang.org/protobuf/proto"
+)
+
+// hubbleProtocols maps the protocols of the log filters to the hubble protocol filter
+var hubbleProtocols = map[string]string{
+	"TCP":    "tcp",
+	"UDP":    "udp",
+	"ICMP":   "icmpv4",
+	"ICMPV6": "icmpv6",
+}
+
+// =========================== //
+// == Hubble Flow Filtering == //
+// =========================== //
+
+// getBaseFlowWhitelist selects the flows the discovery is interested in
+func getBaseFlowWhitelist() []*flow.FlowFilter {
+	return []*flow.FlowFilter{
+		{
+			TcpFlags: []*flow.TCPFlags{
+				{SYN: true},
+			},
+		},
+		{
+			Protocol: []string{"udp"},
+			Reply:    []bool{false},
+		},
+		{
+			Protocol: []string{"icmp", "http", "dns"},
+		},
+	}
+}
+
+func getBaseFlowBlacklist() []*flow.FlowFilter {
+	return []*flow.FlowFilter{
+		{
+			TcpFlags: []*flow.TCPFlags{
+				{ACK: true},
+			},
+		},
+	}
+}
+
+// GetHubbleFlowFilters translates the namespace, verdict and log filters of the configuration
+// into the whitelist and blacklist of the hubble GetFlowsRequest. The filters hubble cannot
+// express, such as the pod labels of the log filters, are only applied locally.
+func GetHubbleFlowFilters(netCfg types.ConfigNetworkPolicy) ([]*flow.FlowFilter, []*flow.FlowFilter) {
+	whitelist := getBaseFlowWhitelist()
+	blacklist := getBaseFlowBlacklist()
+
+	// verdicts
+	if verdicts := getHubbleVerdicts(netCfg.VerdictFilter); len(verdicts) > 0 {
+		for _, filter := range whitelist {
+			filter.Verdict = verdicts
+		}
+	}
+	if verdicts := getHubbleVerdicts(netCfg.VerdictNotFilter); len(verdicts) > 0 {
+		blacklist = append(blacklist, &flow.FlowFilter{Verdict: verdicts})
+	}
+
+	// namespaces, the flows from or to the selected namespaces are kept
+	if len(netCfg.NsFilter) > 0 {
+		pods := getHubbleNamespacePods(netCfg.NsFilter)
+
+		nsWhitelist := []*flow.FlowFilter{}
+		for _, filter := range whitelist {
+			from := proto.Clone(filter).(*flow.FlowFilter)
+			from.SourcePod = pods
+
+			to := proto.Clone(filter).(*flow.FlowFilter)
+			to.DestinationPod = pods
+
+			nsWhitelist = append(nsWhitelist, from, to)
+		}
+		whitelist = nsWhitelist
+	} else if len(netCfg.NsNotFilter) > 0 {
+		pods := getHubbleNamespacePods(netCfg.NsNotFilter)
+		blacklist = append(blacklist, &flow.FlowFilter{SourcePod: pods, DestinationPod: pods})
+	}
+
+	// ignored flows
+	for _, logFilter := range netCfg.NetLogFilters {
+		blacklist = append(blacklist, getHubbleLogFilters(logFilter)...)
+	}
+
+	return whitelist, blacklist
+}
+
+func getHubbleVerdicts(verdicts []string) []flow.Verdict {
+	results := []flow.Verdict{}
+	for _, verdict := range verdicts {
+		value, ok := flow.Verdict_value[strings.ToUpper(verdict)]
+		if !ok {
+			log.Warn().Msgf("Unknown verdict [%s] in the network verdict filter", verdict)
+			continue
+		}
+		results = append(results, flow.Verdict(value))
+	}
+	return results
+}
+
+// getHubbleNamespacePods returns the pod filters matching all the pods of the namespaces
+func getHubbleNamespacePods(namespaces []string) []string {
+	pods := []string{}
+	for _, namespace := range namespaces {
+		pods = append(pods, namespace+"/")
+	}
+	return pods
+}
+
+// getHubbleLogFilters translates a log filter into blacklist filters, the log filters
+// with pod labels are matched against the k8s pods, so they are not translated
+func getHubbleLogFilters(logFilter types.NetworkLogFilter) []*flow.FlowFilter {
+	if len(logFilter.SourceLabels) > 0 || len(logFilter.DestinationLabels) > 0 {
+		return nil
+	}
+
+	filter := &flow.FlowFilter{}
+	translated := false
+
+	if logFilter.SourceNamespace != "" {
+		filter.SourcePod = getHubbleNamespacePods([]string{logFilter.SourceNamespace})
+		translated = true
+	}
+	if logFilter.DestinationNamespace != "" {
+		filter.DestinationPod = getHubbleNamespacePods([]string{logFilter.DestinationNamespace})
+		translated = true
+	}
+	if logFilter.Protocol != "" {
+		protocol, ok := hubbleProtocols[strings.ToUpper(logFilter.Protocol)]
+		if !ok {
+			return nil
+		}
+		filter.Protocol = []string{protocol}
+		translated = true
+	}
+
+	if logFilter.PortNumber == "" {
+		if !translated {
+			return nil
+		}
+		return []*flow.FlowFilter{filter}
+	}
+
+	// the port matches either side of the flow
+	from := proto.Clone(filter).(*flow.FlowFilter)
+	from.SourcePort = []string{logFilter.PortNumber}
+
+	to := proto.Clone(filter).(*flow.FlowFilter)
+	to.DestinationPort = []string{logFilter.PortNumber}
+
+	return []*flow.FlowFilter{from, to}
+}
@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/cilium/cilium/api/v1/flow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHubbleFlowFilters(t *testing.T) {
+	// no filters configured
+	whitelist, blacklist := GetHubbleFlowFilters(types.ConfigNetworkPolicy{})
+	assert.Equal(t, getBaseFlowWhitelist(), whitelist)
+	assert.Equal(t, getBaseFlowBlacklist(), blacklist)
+
+	whitelist, blacklist = GetHubbleFlowFilters(types.ConfigNetworkPolicy{
+		NsFilter:         []string{"default", "prod"},
+		VerdictFilter:    []string{"forwarded", "DROPPED", "unknown"},
+		VerdictNotFilter: []string{"ERROR"},
+		NetLogFilters: []types.NetworkLogFilter{
+			{SourceNamespace: "default", Protocol: "udp", PortNumber: "53"},
+			{SourceLabels: []string{"app=nginx"}}, // local only
+			{Protocol: "sctp"},                    // local only
+		},
+	})
+
+	// each base filter matches the flows from or to the namespaces
+	if assert.Len(t, whitelist, 2*len(getBaseFlowWhitelist())) {
+		assert.Equal(t, []string{"default/", "prod/"}, whitelist[0].SourcePod)
+		assert.Empty(t, whitelist[0].DestinationPod)
+		assert.Equal(t, []string{"default/", "prod/"}, whitelist[1].DestinationPod)
+		assert.Empty(t, whitelist[1].SourcePod)
+		assert.Equal(t, whitelist[0].TcpFlags, whitelist[1].TcpFlags)
+		for _, filter := range whitelist {
+			assert.Equal(t, []flow.Verdict{flow.Verdict_FORWARDED, flow.Verdict_DROPPED}, filter.Verdict)
+		}
+	}
+
+	if assert.Len(t, blacklist, 4) {
+		assert.Equal(t, []flow.Verdict{flow.Verdict_ERROR}, blacklist[1].Verdict)
+
+		assert.Equal(t, []string{"default/"}, blacklist[2].SourcePod)
+		assert.Equal(t, []string{"udp"}, blacklist[2].Protocol)
+		assert.Equal(t, []string{"53"}, blacklist[2].SourcePort)
+		assert.Equal(t, []string{"53"}, blacklist[3].DestinationPort)
+		assert.Empty(t, blacklist[3].SourcePort)
+	}
+
+	// excluded namespaces drop the flows between them
+	_, blacklist = GetHubbleFlowFilters(types.ConfigNetworkPolicy{NsNotFilter: []string{"kube-system"}})
+	if assert.Len(t, blacklist, 2) {
+		assert.Equal(t, []string{"kube-system/"}, blacklist[1].SourcePod)
+		assert.Equal(t, []string{"kube-system/"}, blacklist[1].DestinationPod)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand"
 	"net"
 	"path/filepath"
 	"strconv"
@@ -65,7 +67,7 @@ func ConvertKnoxSystemPolicyToKubeArmorPolicy(knoxPolicies []types.KnoxSystemPol
 			}
 		}
 
-		if policy.Metadata["namespace"] == types.PolicyDiscoveryVMNamespace {
+		if policy.Kind == types.KindKubeArmorHostPolicy || policy.Metadata["namespace"] == types.PolicyDiscoveryVMNamespace {
 			kubePolicy.Kind = "KubeArmorHostPolicy"
 		}
 
@@ -318,8 +320,8 @@ func ConvertKubeArmorLogToKnoxSystemLog(relayLog *pb.Alert) (types.KnoxSystemLog
 // == KubeArmor Relay == //
 // ========================= //
 
-func ConnectKubeArmorRelay(cfg types.ConfigKubeArmorRelay) *grpc.ClientConn {
-	addr := net.JoinHostPort(cfg.KubeArmorRelayURL, cfg.KubeArmorRelayPort)
+func ConnectKubeArmorRelay(ep types.RelayEndpoint) *grpc.ClientConn {
+	addr := relayAddr(ep)
 
 	// Check for kubearmor-relay with 30s timeout
 	ctx, cf1 := context.WithTimeout(context.Background(), time.Second*30)
@@ -336,6 +338,23 @@ func ConnectKubeArmorRelay(cfg types.ConfigKubeArmorRelay) *grpc.ClientConn {
 	return conn
 }
 
+// checkKubeArmorRelayHealth expects the relay to echo the nonce of the health check
+func checkKubeArmorRelayHealth(conn *grpc.ClientConn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	nonce := rand.Int31()
+	reply, err := pb.NewLogServiceClient(conn).HealthCheck(ctx, &pb.NonceMessage{Nonce: nonce})
+	if err != nil {
+		return err
+	}
+	if reply.Retval != nonce {
+		return fmt.Errorf("health check replied %d, expected %d", reply.Retval, nonce)
+	}
+
+	return nil
+}
+
 func GetSystemAlertsFromKubeArmorRelay(trigger int) []*pb.Alert {
 	results := []*pb.Alert{}
 	KubeArmorRelayLogsMutex.Lock()
@@ -388,17 +407,42 @@ func ignoreLogFromRelayWithNamespace(nsFilter, nsNotFilter []string, namespace s
 	return false
 }
 
+var kubeArmorRelayStreams = newRelayStreams(&KubeArmorRelayStarted)
+
+// StartKubeArmorRelay starts streaming the logs and alerts of the relay groups not streamed yet,
+// one group per cluster with the fan-in, otherwise one group failing over between all the endpoints
 func StartKubeArmorRelay(StopChan chan struct{}, cfg types.ConfigKubeArmorRelay) {
-	if KubeArmorRelayStarted {
-		// log.Info().Msg("kubearmor relay already started")
-		return
+	endpoints := getRelayEndpoints(cfg.KubeArmorRelayURL, cfg.KubeArmorRelayPort, cfg.Endpoints)
+
+	for _, group := range groupRelayEndpoints(endpoints, cfg.FanIn) {
+		if !kubeArmorRelayStreams.start(group.Name) {
+			continue
+		}
+		go streamKubeArmorRelay(StopChan, group)
 	}
-	KubeArmorRelayStarted = true
-	conn := ConnectKubeArmorRelay(cfg)
+}
+
+func streamKubeArmorRelay(StopChan chan struct{}, group relayGroup) {
+	conn, ep := connectRelayGroup(group, ConnectKubeArmorRelay, checkKubeArmorRelayHealth)
 	if conn == nil {
-		log.Error().Msg("failed connecting to kubearmor relay")
+		log.Error().Msgf("failed connecting to kubearmor relay for cluster [%s]", group.Name)
+		kubeArmorRelayStreams.stop(group.Name)
 		return
 	}
+
+	// the logs and the alerts share the connection, the first stream to stop closes it
+	done := make(chan struct{})
+	var closeOnce sync.Once
+	closeRelay := func() {
+		closeOnce.Do(func() {
+			close(done)
+			kubeArmorRelayStreams.stop(group.Name)
+			_ = conn.Close()
+		})
+	}
+
+	go watchRelayHealth(done, conn, ep, checkKubeArmorRelayHealth)
+
 	client := pb.NewLogServiceClient(conn)
 	req := pb.RequestMessage{}
 	req.Filter = "all"
@@ -410,9 +454,8 @@ func StartKubeArmorRelay(StopChan chan struct{}, cfg types.ConfigKubeArmorRelay)
 	//Stream Logs
 	go func(client pb.LogServiceClient) {
 		defer func() {
-			log.Info().Msg("watchlogs returning")
-			KubeArmorRelayStarted = false
-			_ = conn.Close()
+			log.Info().Msgf("watchlogs returning (%s)", relayAddr(ep))
+			closeRelay()
 		}()
 		stream, err := client.WatchLogs(context.Background(), &req)
 		if err != nil {
@@ -476,6 +519,7 @@ func StartKubeArmorRelay(StopChan chan struct{}, cfg types.ConfigKubeArmorRelay)
 				}
 
 				kubearmorLog.Labels = libs.RemoveFieldFromLabel(kubearmorLog.Labels, types.LabelJobControllerUid)
+				tagAlertCluster(&kubearmorLog, ep.ClusterName)
 
 				KubeArmorRelayLogsMutex.Lock()
 				KubeArmorRelayLogs = append(KubeArmorRelayLogs, &kubearmorLog)
@@ -499,9 +543,8 @@ func StartKubeArmorRelay(StopChan chan struct{}, cfg types.ConfigKubeArmorRelay)
 	//Stream Alerts
 	go func() {
 		defer func() {
-			log.Info().Msg("watchalerts returning")
-			KubeArmorRelayStarted = false
-			_ = conn.Close()
+			log.Info().Msgf("watchalerts returning (%s)", relayAddr(ep))
+			closeRelay()
 		}()
 		stream, err := client.WatchAlerts(context.Background(), &req)
 		if err != nil {
@@ -533,6 +576,7 @@ func StartKubeArmorRelay(StopChan chan struct{}, cfg types.ConfigKubeArmorRelay)
 				}
 
 				res.Labels = libs.RemoveFieldFromLabel(res.Labels, types.LabelJobControllerUid)
+				tagAlertCluster(res, ep.ClusterName)
 
 				KubeArmorRelayLogsMutex.Lock()
 				KubeArmorRelayLogs = append(KubeArmorRelayLogs, res)
@@ -0,0 +1,355 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/cilium/cilium/api/v1/flow"
+	"github.com/cilium/cilium/api/v1/observer"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ======================== //
+// == Log File Ingestion == //
+// ======================== //
+
+// logFileReader reads the records appended to a log file, or to the files of a
+// directory, since the last read. Files may be gzip compressed.
+type logFileReader struct {
+	path     string
+	offsets  map[string]int64 // consumed bytes of each file, uncompressed
+	gzipDone map[string]int64 // size of the gzip files read to their end, not read again unless it changes
+}
+
+var logFileReaders = map[string]*logFileReader{}
+var logFileReadersMutex sync.Mutex
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// logFileMaxRecordSize is the size of the longest record of a log file
+const logFileMaxRecordSize = 16 << 20
+
+// readLogFile passes the records of the path appended since the last call to handle
+func readLogFile(path string, handle func(record []byte)) error {
+	logFileReadersMutex.Lock()
+	defer logFileReadersMutex.Unlock()
+
+	reader, ok := logFileReaders[path]
+	if !ok {
+		reader = &logFileReader{path: path, offsets: map[string]int64{}, gzipDone: map[string]int64{}}
+		logFileReaders[path] = reader
+	}
+
+	return reader.read(handle)
+}
+
+func (r *logFileReader) read(handle func(record []byte)) error {
+	files, err := r.listFiles()
+	if err != nil {
+		return err
+	}
+
+	listed := map[string]bool{}
+	for _, file := range files {
+		listed[file] = true
+		if err := r.readFile(file, handle); err != nil {
+			log.Error().Msgf("Failed to read the log file %s: %s", file, err)
+		}
+	}
+
+	// forget the files removed since the last read
+	for file := range r.offsets {
+		if !listed[file] {
+			delete(r.offsets, file)
+			delete(r.gzipDone, file)
+		}
+	}
+
+	return nil
+}
+
+// listFiles returns the file itself, or the files of the directory sorted by name
+func (r *logFileReader) listFiles() ([]string, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{r.path}, nil
+	}
+
+	entries, err := os.ReadDir(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		files = append(files, filepath.Join(r.path, entry.Name()))
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func (r *logFileReader) readFile(path string, handle func(record []byte)) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset := r.offsets[path]
+
+	magic := make([]byte, len(gzipMagic))
+	n, _ := io.ReadFull(f, magic)
+	compressed := n == len(gzipMagic) && bytes.Equal(magic, gzipMagic)
+
+	var content io.Reader
+	if compressed {
+		if size, ok := r.gzipDone[path]; ok && size == info.Size() {
+			return nil
+		}
+		delete(r.gzipDone, path)
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+
+		// compressed files cannot be seeked, skip what was already consumed
+		if _, err := io.CopyN(io.Discard, gz, offset); err != nil {
+			return nil
+		}
+		content = gz
+	} else {
+		if info.Size() < offset {
+			// truncated or replaced, read it again from the start
+			offset = 0
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		content = f
+	}
+
+	consumed, complete, err := consumeRecords(bufio.NewReader(content), offset == 0, handle)
+	r.offsets[path] = offset + consumed
+
+	// a partially written gzip stream ends with an unexpected EOF, the records
+	// read until then are consumed and the rest is read on the next call
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	if compressed && err == nil && complete {
+		r.gzipDone[path] = info.Size()
+	}
+	return nil
+}
+
+// consumeRecords passes the complete records of the content to handle and returns the consumed bytes,
+// and whether all of them were consumed. The content is either a JSON array, read once it is complete,
+// or one JSON record per line, where the last line is only consumed if it is already a valid record.
+func consumeRecords(content *bufio.Reader, fromStart bool, handle func(record []byte)) (int64, bool, error) {
+	var consumed int64
+
+	if fromStart {
+		// the leading spaces tell the arrays from the lines
+		for {
+			b, err := content.ReadByte()
+			if err != nil {
+				return 0, err == io.EOF, ignoreEOF(err)
+			}
+			if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+				if err := content.UnreadByte(); err != nil {
+					return 0, false, err
+				}
+				break
+			}
+			consumed++
+		}
+
+		if next, _ := content.Peek(1); len(next) == 1 && next[0] == '[' {
+			return consumeArray(content, consumed, handle)
+		}
+	}
+
+	complete := true
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64*1024), logFileMaxRecordSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if end := bytes.IndexByte(data, '\n'); end >= 0 {
+			return end + 1, data[:end+1], nil
+		}
+		if atEOF && len(data) > 0 {
+			if json.Valid(data) {
+				return len(data), data, nil
+			}
+			complete = false // not complete yet
+		}
+		return 0, nil, nil
+	})
+
+	for scanner.Scan() {
+		consumed += int64(len(scanner.Bytes()))
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			handle(line)
+		}
+	}
+
+	return consumed, complete, scanner.Err()
+}
+
+// consumeArray passes the records of a JSON array to handle once the array is complete
+func consumeArray(content *bufio.Reader, leading int64, handle func(record []byte)) (int64, bool, error) {
+	dec := json.NewDecoder(content)
+	if _, err := dec.Token(); err != nil {
+		return 0, false, ignoreEOF(err)
+	}
+
+	records := []json.RawMessage{}
+	for dec.More() {
+		var record json.RawMessage
+		if err := dec.Decode(&record); err != nil {
+			return 0, false, ignoreEOF(err) // not complete yet
+		}
+		records = append(records, record)
+	}
+	if _, err := dec.Token(); err != nil {
+		return 0, false, ignoreEOF(err)
+	}
+
+	for _, record := range records {
+		handle(record)
+	}
+	return leading + dec.InputOffset(), true, nil
+}
+
+// ignoreEOF drops the errors of the records not complete yet
+func ignoreEOF(err error) error {
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+// ================ //
+// == Flow Files == //
+// ================ //
+
+// GetCiliumFlowsFromFile returns the flows appended to the file, or to the files of the directory,
+// since the last call. It accepts 'hubble observe -o jsonpb' output and arrays of flows.
+func GetCiliumFlowsFromFile(path string) []*flow.Flow {
+	flows := []*flow.Flow{}
+
+	err := readLogFile(path, func(record []byte) {
+		flow, err := parseCiliumFlow(record)
+		if err != nil {
+			log.Error().Msgf("Failed to parse the flow [%s]: %s", record, err)
+			return
+		}
+		if flow != nil {
+			flows = append(flows, flow)
+		}
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
+	}
+
+	return flows
+}
+
+// parseCiliumFlow parses a hubble GetFlowsResponse or a single flow,
+// the responses not holding a flow return nil
+func parseCiliumFlow(record []byte) (*flow.Flow, error) {
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: true}
+
+	res := &observer.GetFlowsResponse{}
+	if err := unmarshaler.Unmarshal(record, res); err == nil {
+		if f := res.GetFlow(); f != nil {
+			if f.NodeName == "" {
+				f.NodeName = res.GetNodeName()
+			}
+			return f, nil
+		}
+		if res.GetNodeStatus() != nil || res.GetLostEvents() != nil {
+			return nil, nil
+		}
+	}
+
+	f := &flow.Flow{}
+	if err := unmarshaler.Unmarshal(record, f); err == nil {
+		return f, nil
+	}
+
+	// flows marshaled with encoding/json
+	f = &flow.Flow{}
+	if err := json.Unmarshal(record, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ====================== //
+// == System Log Files == //
+// ====================== //
+
+// GetSystemLogsFromFile returns the system logs appended to the file, or to the files of the directory,
+// since the last call. It accepts 'karmor log --json' output and arrays of logs.
+func GetSystemLogsFromFile(path string) []types.KnoxSystemLog {
+	systemLogs := []types.KnoxSystemLog{}
+
+	err := readLogFile(path, func(record []byte) {
+		alert, err := parseKubeArmorLog(record)
+		if err != nil {
+			log.Error().Msgf("Failed to parse the system log [%s]: %s", record, err)
+			return
+		}
+		if systemLog, err := ConvertKubeArmorLogToKnoxSystemLog(alert); err == nil {
+			systemLogs = append(systemLogs, systemLog)
+		}
+	})
+	if err != nil {
+		log.Error().Msg(err.Error())
+	}
+
+	return systemLogs
+}
+
+// parseKubeArmorLog parses a kubearmor log or alert
+func parseKubeArmorLog(record []byte) (*pb.Alert, error) {
+	alert := &pb.Alert{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(record, alert); err == nil {
+		return alert, nil
+	}
+
+	// logs marshaled with encoding/json
+	alert = &pb.Alert{}
+	if err := json.Unmarshal(record, alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
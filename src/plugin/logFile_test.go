@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testHubbleFlow = `{"flow":{"time":"2023-05-01T10:00:00Z","verdict":"FORWARDED","IP":{"source":"10.0.0.1","destination":"10.0.0.2","ipVersion":"IPv4"},"l4":{"TCP":{"source_port":41234,"destination_port":80}},"source":{"namespace":"default","labels":["k8s:app=client"],"pod_name":"client"},"destination":{"namespace":"default","labels":["k8s:app=server"],"pod_name":"server"},"Type":"L3_L4","traffic_direction":"EGRESS"},"node_name":"node-1","time":"2023-05-01T10:00:00Z"}`
+
+const testKubeArmorLog = `{"Timestamp":1682935200,"UpdatedTime":"2023-05-01T10:00:00.000000Z","ClusterName":"default","HostName":"node-1","NamespaceName":"default","PodName":"server","Labels":"app=server","ContainerID":"78c4b0cb165d","ContainerName":"server","HostPID":1234,"PPID":1,"PID":12,"Type":"ContainerLog","Source":"/bin/cat","Operation":"File","Resource":"/etc/hosts","Data":"syscall=SYS_OPENAT flags=O_RDONLY","Result":"Passed"}`
+
+func writeTestFile(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(content)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+}
+
+func TestGetCiliumFlowsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flows.json")
+	writeTestFile(t, path, testHubbleFlow+"\n"+`{"node_status":{"state_change":"NODE_CONNECTED","node_names":["node-1"]}}`+"\n")
+
+	flows := GetCiliumFlowsFromFile(path)
+	if assert.Len(t, flows, 1) {
+		assert.Equal(t, "node-1", flows[0].NodeName)
+		assert.Equal(t, "client", flows[0].Source.PodName)
+		assert.Equal(t, uint32(80), flows[0].L4.GetTCP().DestinationPort)
+	}
+
+	// partially written records are read once they are complete
+	writeTestFile(t, path, testHubbleFlow[:50])
+	assert.Len(t, GetCiliumFlowsFromFile(path), 0)
+
+	writeTestFile(t, path, testHubbleFlow[50:]+"\n")
+	assert.Len(t, GetCiliumFlowsFromFile(path), 1)
+	assert.Len(t, GetCiliumFlowsFromFile(path), 0)
+}
+
+func TestGetCiliumFlowsFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	f, err := os.Create(filepath.Join(dir, "a.json.gz"))
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(testHubbleFlow + "\n" + testHubbleFlow + "\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, f.Close())
+
+	assert.Len(t, GetCiliumFlowsFromFile(dir), 2)
+
+	// the gzip file read to its end is not decompressed again
+	reader := logFileReaders[dir]
+	if assert.NotNil(t, reader) {
+		assert.Contains(t, reader.gzipDone, filepath.Join(dir, "a.json.gz"))
+	}
+
+	// new files of the directory are tailed
+	writeTestFile(t, filepath.Join(dir, "b.json"), testHubbleFlow+"\n")
+	assert.Len(t, GetCiliumFlowsFromFile(dir), 1)
+	assert.Len(t, GetCiliumFlowsFromFile(dir), 0)
+
+	// the removed files are forgotten
+	assert.NoError(t, os.Remove(filepath.Join(dir, "a.json.gz")))
+	assert.Len(t, GetCiliumFlowsFromFile(dir), 0)
+	assert.NotContains(t, reader.offsets, filepath.Join(dir, "a.json.gz"))
+	assert.NotContains(t, reader.gzipDone, filepath.Join(dir, "a.json.gz"))
+	assert.Contains(t, reader.offsets, filepath.Join(dir, "b.json"))
+}
+
+func TestGetCiliumFlowsFromArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flows.json")
+
+	// the array is read once it is complete
+	writeTestFile(t, path, "  [\n"+testHubbleFlow+",\n")
+	assert.Len(t, GetCiliumFlowsFromFile(path), 0)
+
+	writeTestFile(t, path, testHubbleFlow+"\n]\n")
+	assert.Len(t, GetCiliumFlowsFromFile(path), 2)
+	assert.Len(t, GetCiliumFlowsFromFile(path), 0)
+}
+
+func TestGetSystemLogsFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// karmor log --json output
+	writeTestFile(t, filepath.Join(dir, "karmor.json"), testKubeArmorLog+"\n"+testKubeArmorLog+"\n")
+
+	// array of logs
+	writeTestFile(t, filepath.Join(dir, "logs.json"), "[\n"+testKubeArmorLog+"\n]\n")
+
+	logs := GetSystemLogsFromFile(dir)
+	if assert.Len(t, logs, 3) {
+		assert.Equal(t, "/bin/cat", logs[0].Source)
+		assert.Equal(t, "/etc/hosts", logs[0].Resource)
+		assert.Equal(t, "server", logs[0].PodName)
+		assert.True(t, logs[0].ReadOnly)
+	}
+	assert.Len(t, GetSystemLogsFromFile(dir), 0)
+}
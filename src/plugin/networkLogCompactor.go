@@ -0,0 +1,158 @@
+package plugin
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// ============================ //
+// == Network Log Compaction == //
+// ============================ //
+
+// networkLogKey is the identity of the network logs a compacted record stands for.
+// The ephemeral parts of a flow, such as the client port and the pod names of
+// the labeled endpoints, are left out so that retries and long-lived connections
+// collapse into one record.
+type networkLogKey struct {
+	ClusterName string
+	Direction   string
+	Verdict     string
+	DropReason  string
+	Action      string
+	IsReply     bool
+	SynFlag     bool
+
+	SrcNamespace string
+	Src          string // labels, or pod name / ip of the endpoints without labels
+	DstNamespace string
+	Dst          string
+
+	Protocol int
+	Port     int // destination port, source port for the replies
+	ICMPType int
+
+	L7 string // http method and path, or dns response and ips
+
+	UpstreamCluster string
+}
+
+// getEndpointKey identifies an endpoint by its labels, the pod name or ip are kept
+// for the endpoints without labels which cannot be told apart otherwise
+func getEndpointKey(labels []string, podName, ip string) string {
+	if len(labels) > 0 {
+		sorted := append([]string{}, labels...)
+		sort.Strings(sorted)
+		return strings.Join(sorted, ",")
+	}
+	if podName != "" {
+		return podName
+	}
+	return ip
+}
+
+func getNetworkLogKey(log types.KnoxNetworkLog, srcLabels, dstLabels []string) networkLogKey {
+	key := networkLogKey{
+		ClusterName:  log.ClusterName,
+		Direction:    log.Direction,
+		Verdict:      log.Verdict,
+		DropReason:   log.DropReason,
+		Action:       log.Action,
+		IsReply:      log.IsReply,
+		SynFlag:      log.SynFlag,
+		SrcNamespace: log.SrcNamespace,
+		Src:          getEndpointKey(srcLabels, log.SrcPodName, log.SrcIP),
+		DstNamespace: log.DstNamespace,
+		Dst:          getEndpointKey(dstLabels, log.DstPodName, log.DstIP),
+		Protocol:     log.Protocol,
+		Port:         log.DstPort,
+		ICMPType:     log.ICMPType,
+
+		UpstreamCluster: log.UpstreamCluster,
+	}
+
+	if log.IsReply {
+		key.Port = log.SrcPort
+	}
+
+	// the destinations outside the cluster are told apart by their ip (toCIDRs, toFQDNs)
+	if log.DstPodName == "" {
+		key.Dst = key.Dst + "|" + log.DstIP
+	}
+
+	switch log.L7Protocol {
+	case "":
+	case libs.L7ProtocolDNS:
+		ips := append([]string{}, log.DNSResIPs...)
+		sort.Strings(ips)
+		key.L7 = log.L7Protocol + "|" + log.DNSRes + "|" + strings.Join(ips, ",")
+	default:
+		key.L7 = log.L7Protocol + "|" + log.HTTPMethod + "|" + log.HTTPPath
+	}
+
+	return key
+}
+
+// NetworkLogCompactor collapses the network logs of the same tuple into a single record
+// holding the number of logs and the time of the first and the last one. It is not safe
+// for concurrent use.
+type NetworkLogCompactor struct {
+	records map[networkLogKey]*types.KnoxNetworkLog
+	order   []*types.KnoxNetworkLog
+	total   int
+}
+
+func NewNetworkLogCompactor() *NetworkLogCompactor {
+	return &NetworkLogCompactor{
+		records: map[networkLogKey]*types.KnoxNetworkLog{},
+		order:   []*types.KnoxNetworkLog{},
+	}
+}
+
+// Add compacts the log into the record of its tuple, srcLabels and dstLabels
+// are the labels of the endpoints if the source of the log knows them
+func (c *NetworkLogCompactor) Add(log types.KnoxNetworkLog, srcLabels, dstLabels []string) {
+	count := log.Total
+	if count <= 0 {
+		count = 1
+	}
+	c.total += count
+
+	key := getNetworkLogKey(log, srcLabels, dstLabels)
+	record, ok := c.records[key]
+	if !ok {
+		log.Total = count
+		c.records[key] = &log
+		c.order = append(c.order, &log)
+		return
+	}
+
+	record.Total += count
+	if log.StartTime != 0 && (record.StartTime == 0 || log.StartTime < record.StartTime) {
+		record.StartTime = log.StartTime
+	}
+	if log.UpdatedTime >= record.UpdatedTime {
+		record.UpdatedTime = log.UpdatedTime
+
+		// the pods of the labeled endpoints are replaced over time, the record keeps
+		// the latest ones so that their labels can still be resolved
+		record.SrcPodName, record.SrcIP = log.SrcPodName, log.SrcIP
+		record.DstPodName, record.DstIP = log.DstPodName, log.DstIP
+	}
+}
+
+// Total returns the number of logs added, Len the number of records they compacted into
+func (c *NetworkLogCompactor) Total() int {
+	return c.total
+}
+
+func (c *NetworkLogCompactor) Len() int {
+	return len(c.order)
+}
+
+// Records returns the compacted records in the order of their first log
+func (c *NetworkLogCompactor) Records() []*types.KnoxNetworkLog {
+	return c.order
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkLogCompactor(t *testing.T) {
+	srcLabels := []string{"k8s:app=frontend", "k8s:io.kubernetes.pod.namespace=default"}
+	dstLabels := []string{"k8s:app=redis", "k8s:io.kubernetes.pod.namespace=default"}
+
+	request := types.KnoxNetworkLog{
+		SrcNamespace: "default",
+		SrcPodName:   "frontend-1",
+		SrcIP:        "10.0.0.1",
+		SrcPort:      40001,
+		DstNamespace: "default",
+		DstPodName:   "redis-1",
+		DstIP:        "10.0.0.2",
+		DstPort:      6379,
+		Protocol:     6,
+		SynFlag:      true,
+		Direction:    "EGRESS",
+		Action:       "allow",
+		Verdict:      "FORWARDED",
+		StartTime:    100,
+		UpdatedTime:  100,
+	}
+
+	compactor := NewNetworkLogCompactor()
+	compactor.Add(request, srcLabels, dstLabels)
+
+	// another client port, another replica of the same workload
+	retry := request
+	retry.SrcPodName = "frontend-2"
+	retry.SrcIP = "10.0.0.3"
+	retry.SrcPort = 40002
+	retry.StartTime, retry.UpdatedTime = 90, 90
+	compactor.Add(retry, []string{"k8s:io.kubernetes.pod.namespace=default", "k8s:app=frontend"}, dstLabels)
+
+	later := request
+	later.StartTime, later.UpdatedTime = 200, 200
+	compactor.Add(later, srcLabels, dstLabels)
+
+	// another port, another verdict
+	otherPort := request
+	otherPort.DstPort = 6380
+	compactor.Add(otherPort, srcLabels, dstLabels)
+
+	dropped := request
+	dropped.Verdict = "DROPPED"
+	dropped.Action = "deny"
+	compactor.Add(dropped, srcLabels, dstLabels)
+
+	assert.Equal(t, 5, compactor.Total())
+	if assert.Equal(t, 3, compactor.Len()) {
+		records := compactor.Records()
+		assert.Equal(t, "frontend-1", records[0].SrcPodName)
+		assert.Equal(t, 3, records[0].Total)
+		assert.Equal(t, int64(90), records[0].StartTime)
+		assert.Equal(t, int64(200), records[0].UpdatedTime)
+		assert.Equal(t, 6380, records[1].DstPort)
+		assert.Equal(t, 1, records[1].Total)
+		assert.Equal(t, "DROPPED", records[2].Verdict)
+	}
+
+	// the first pod is replaced, the record keeps the live one
+	replaced := request
+	replaced.SrcPodName = "frontend-3"
+	replaced.SrcIP = "10.0.0.4"
+	replaced.StartTime, replaced.UpdatedTime = 300, 300
+	compactor.Add(replaced, srcLabels, dstLabels)
+
+	record := compactor.Records()[0]
+	assert.Equal(t, 4, record.Total)
+	assert.Equal(t, "frontend-3", record.SrcPodName)
+	assert.Equal(t, "10.0.0.4", record.SrcIP)
+	assert.Equal(t, int64(90), record.StartTime)
+}
+
+func TestNetworkLogCompactorWithoutLabels(t *testing.T) {
+	world := types.KnoxNetworkLog{
+		SrcNamespace: "default",
+		SrcPodName:   "frontend-1",
+		DstIP:        "1.1.1.1",
+		DstPort:      443,
+		Protocol:     6,
+		Direction:    "EGRESS",
+		Total:        10,
+	}
+
+	compactor := NewNetworkLogCompactor()
+	compactor.Add(world, nil, []string{"reserved:world"})
+
+	// the external destinations are kept apart by ip
+	other := world
+	other.DstIP = "8.8.8.8"
+	compactor.Add(other, nil, []string{"reserved:world"})
+
+	// without labels, the pods are kept apart
+	otherPod := world
+	otherPod.SrcPodName = "frontend-2"
+	compactor.Add(otherPod, nil, []string{"reserved:world"})
+
+	// dns responses are kept apart by the resolved ips
+	dns := world
+	dns.L7Protocol = "dns"
+	dns.DNSRes = "example.com"
+	dns.DNSResIPs = []string{"1.1.1.1", "2.2.2.2"}
+	compactor.Add(dns, nil, []string{"reserved:world"})
+	dns.DNSResIPs = []string{"2.2.2.2", "1.1.1.1"}
+	compactor.Add(dns, nil, []string{"reserved:world"})
+
+	assert.Equal(t, 50, compactor.Total())
+	if assert.Equal(t, 4, compactor.Len()) {
+		assert.Equal(t, 20, compactor.Records()[3].Total)
+	}
+}
@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	"google.golang.org/grpc"
+)
+
+// RelayRetryInterval is the wait between two rounds over the endpoints of a relay group
+var RelayRetryInterval = 2 * time.Second
+
+// RelayHealthCheckInterval is the interval of the health check of a streaming relay
+var RelayHealthCheckInterval = 30 * time.Second
+
+// ===================== //
+// == Relay Endpoints == //
+// ===================== //
+
+// relayGroup is the set of relay endpoints a single stream fails over between
+type relayGroup struct {
+	Name      string // cluster name, empty if the group holds all the endpoints
+	Endpoints []types.RelayEndpoint
+}
+
+func relayAddr(ep types.RelayEndpoint) string {
+	return net.JoinHostPort(ep.URL, ep.Port)
+}
+
+// getRelayEndpoints returns the configured endpoints, or the single relay url/port
+// for the configurations without endpoints, whose logs are not tagged with a cluster
+func getRelayEndpoints(url, port string, endpoints []types.RelayEndpoint) []types.RelayEndpoint {
+	results := []types.RelayEndpoint{}
+
+	for _, ep := range endpoints {
+		if ep.Port == "" {
+			ep.Port = port
+		}
+		results = append(results, ep)
+	}
+
+	if len(results) == 0 && url != "" {
+		results = append(results, types.RelayEndpoint{URL: url, Port: port})
+	}
+
+	return results
+}
+
+// groupRelayEndpoints groups the endpoints by cluster to stream each cluster concurrently,
+// without the fan-in all the endpoints are the failover candidates of one stream.
+// The logs of every cluster are tagged with the cluster of their relay, but under the
+// k8sclient cluster info mode their workloads are still resolved against the k8s resources
+// of the cluster of the engine only.
+func groupRelayEndpoints(endpoints []types.RelayEndpoint, fanIn bool) []relayGroup {
+	groups := []relayGroup{}
+
+	if !fanIn {
+		if len(endpoints) > 0 {
+			groups = append(groups, relayGroup{Endpoints: endpoints})
+		}
+		return groups
+	}
+
+	index := map[string]int{}
+	for _, ep := range endpoints {
+		i, ok := index[ep.ClusterName]
+		if !ok {
+			i = len(groups)
+			index[ep.ClusterName] = i
+			groups = append(groups, relayGroup{Name: ep.ClusterName})
+		}
+		groups[i].Endpoints = append(groups[i].Endpoints, ep)
+	}
+
+	return groups
+}
+
+// connectRelayGroup dials the endpoints of the group in order and returns the first one
+// passing the health check. The endpoints are tried from the first one on each call,
+// so a stream moves back to the primary relay as soon as it is healthy again.
+func connectRelayGroup(group relayGroup, dial func(types.RelayEndpoint) *grpc.ClientConn,
+	healthCheck func(*grpc.ClientConn) error) (*grpc.ClientConn, types.RelayEndpoint) {
+	for try := 0; try < types.Maxtries; try++ {
+		if try > 0 {
+			time.Sleep(RelayRetryInterval)
+		}
+
+		for _, ep := range group.Endpoints {
+			conn := dial(ep)
+			if conn == nil {
+				continue
+			}
+
+			if err := healthCheck(conn); err != nil {
+				log.Warn().Msgf("relay %s (cluster [%s]) failed the health check: %s", relayAddr(ep), ep.ClusterName, err.Error())
+				_ = conn.Close()
+				continue
+			}
+
+			return conn, ep
+		}
+	}
+
+	return nil, types.RelayEndpoint{}
+}
+
+// watchRelayHealth closes the connection once the relay fails the health check,
+// which stops its streams and lets the next start fail over to another endpoint
+func watchRelayHealth(done chan struct{}, conn *grpc.ClientConn, ep types.RelayEndpoint,
+	healthCheck func(*grpc.ClientConn) error) {
+	ticker := time.NewTicker(RelayHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case <-ticker.C:
+			if err := healthCheck(conn); err != nil {
+				log.Warn().Msgf("relay %s (cluster [%s]) became unhealthy: %s", relayAddr(ep), ep.ClusterName, err.Error())
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// =================== //
+// == Relay Streams == //
+// =================== //
+
+// relayStreams keeps track of the relay groups being streamed
+type relayStreams struct {
+	mutex   sync.Mutex
+	running map[string]bool
+	started *bool // set while any group is streamed
+}
+
+func newRelayStreams(started *bool) *relayStreams {
+	return &relayStreams{
+		running: map[string]bool{},
+		started: started,
+	}
+}
+
+// start marks the group as streamed, false if it already is
+func (r *relayStreams) start(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.running[name] {
+		return false
+	}
+	r.running[name] = true
+	*r.started = true
+
+	return true
+}
+
+func (r *relayStreams) stop(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.running, name)
+	*r.started = len(r.running) > 0
+}
+
+// ===================== //
+// == Cluster Tagging == //
+// ===================== //
+
+// getRelayClusterName returns the cluster of the relay endpoint, or else the cluster of the engine
+func getRelayClusterName(clusterName string) string {
+	if clusterName != "" {
+		return clusterName
+	}
+	return config.GetCfgClusterName()
+}
+
+// tagAlertCluster replaces the cluster name the kubearmor relay reports, which is
+// not unique across clusters, with the cluster of the relay endpoint
+func tagAlertCluster(alert *pb.Alert, clusterName string) {
+	if clusterName == "" {
+		return
+	}
+	alert.ClusterName = clusterName
+}
@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	cilium "github.com/cilium/cilium/api/v1/flow"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+)
+
+func TestGetRelayEndpoints(t *testing.T) {
+	// the single relay of the legacy configuration
+	assert.Equal(t, []types.RelayEndpoint{{URL: "localhost", Port: "4245"}},
+		getRelayEndpoints("localhost", "4245", nil))
+	assert.Empty(t, getRelayEndpoints("", "4245", nil))
+
+	// the endpoints replace the url, the port is the default of the endpoints
+	endpoints := getRelayEndpoints("localhost", "4245", []types.RelayEndpoint{
+		{ClusterName: "a", URL: "relay-a"},
+		{ClusterName: "b", URL: "relay-b", Port: "80"},
+	})
+	assert.Equal(t, []types.RelayEndpoint{
+		{ClusterName: "a", URL: "relay-a", Port: "4245"},
+		{ClusterName: "b", URL: "relay-b", Port: "80"},
+	}, endpoints)
+}
+
+func TestGroupRelayEndpoints(t *testing.T) {
+	endpoints := []types.RelayEndpoint{
+		{ClusterName: "a", URL: "relay-a1"},
+		{ClusterName: "b", URL: "relay-b1"},
+		{ClusterName: "a", URL: "relay-a2"},
+	}
+
+	// failover between all the endpoints
+	groups := groupRelayEndpoints(endpoints, false)
+	assert.Equal(t, []relayGroup{{Endpoints: endpoints}}, groups)
+	assert.Empty(t, groupRelayEndpoints(nil, false))
+
+	// fan-in, the endpoints of a cluster keep their order
+	groups = groupRelayEndpoints(endpoints, true)
+	assert.Equal(t, []relayGroup{
+		{Name: "a", Endpoints: []types.RelayEndpoint{endpoints[0], endpoints[2]}},
+		{Name: "b", Endpoints: []types.RelayEndpoint{endpoints[1]}},
+	}, groups)
+}
+
+func TestConnectRelayGroup(t *testing.T) {
+	defer func(interval time.Duration) { RelayRetryInterval = interval }(RelayRetryInterval)
+	RelayRetryInterval = 0
+
+	group := relayGroup{
+		Name: "a",
+		Endpoints: []types.RelayEndpoint{
+			{ClusterName: "a", URL: "down", Port: "1"},
+			{ClusterName: "a", URL: "unhealthy", Port: "1"},
+			{ClusterName: "a", URL: "healthy", Port: "1"},
+		},
+	}
+
+	conns := map[string]*grpc.ClientConn{}
+	dial := func(ep types.RelayEndpoint) *grpc.ClientConn {
+		if ep.URL == "down" {
+			return nil
+		}
+		conn, err := grpc.Dial(relayAddr(ep), grpc.WithInsecure())
+		assert.NoError(t, err)
+		conns[ep.URL] = conn
+		return conn
+	}
+	healthCheck := func(conn *grpc.ClientConn) error {
+		if conn == conns["unhealthy"] {
+			return errors.New("unhealthy")
+		}
+		return nil
+	}
+
+	conn, ep := connectRelayGroup(group, dial, healthCheck)
+	if assert.NotNil(t, conn) {
+		assert.Equal(t, "healthy", ep.URL)
+		_ = conn.Close()
+	}
+
+	// no endpoint left
+	conn, _ = connectRelayGroup(relayGroup{Endpoints: group.Endpoints[:1]}, dial, healthCheck)
+	assert.Nil(t, conn)
+}
+
+func TestRelayStreams(t *testing.T) {
+	started := false
+	streams := newRelayStreams(&started)
+
+	assert.True(t, streams.start("a"))
+	assert.False(t, streams.start("a"))
+	assert.True(t, streams.start("b"))
+	assert.True(t, started)
+
+	streams.stop("a")
+	assert.True(t, started)
+	assert.True(t, streams.start("a"))
+
+	streams.stop("a")
+	streams.stop("b")
+	assert.False(t, started)
+}
+
+func TestClusterTagging(t *testing.T) {
+	// untagged relays fall back to the cluster of the engine
+	config.CurrentCfg.ClusterName = "engine"
+	defer func() { config.CurrentCfg.ClusterName = "" }()
+	assert.Equal(t, "a", getRelayClusterName("a"))
+	assert.Equal(t, "engine", getRelayClusterName(""))
+
+	flow := &cilium.Flow{
+		NodeName:    "default/node-1",
+		Verdict:     cilium.Verdict_FORWARDED,
+		IP:          &cilium.IP{Source: "10.0.0.1", Destination: "10.0.0.2"},
+		Source:      &cilium.Endpoint{Namespace: "ns", PodName: "src"},
+		Destination: &cilium.Endpoint{Namespace: "ns", PodName: "dst"},
+		L4:          &cilium.Layer4{Protocol: &cilium.Layer4_TCP{TCP: &cilium.TCP{DestinationPort: 80}}},
+	}
+	if log, valid := ConvertCiliumFlowToKnoxNetworkLog(flow); assert.True(t, valid) {
+		assert.Equal(t, "engine", log.ClusterName)
+	}
+
+	alert := &pb.Alert{ClusterName: "default"}
+	tagAlertCluster(alert, "")
+	assert.Equal(t, "default", alert.ClusterName)
+	tagAlertCluster(alert, "a")
+	assert.Equal(t, "a", alert.ClusterName)
+}
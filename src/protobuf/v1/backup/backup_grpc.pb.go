@@ -0,0 +1,207 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.10
+// source: v1/backup/backup.proto
+
+package backup
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Backup_Export_FullMethodName = "/v1.backup.Backup/Export"
+	Backup_Import_FullMethodName = "/v1.backup.Backup/Import"
+)
+
+// BackupClient is the client API for Backup service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BackupClient interface {
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Backup_ExportClient, error)
+	Import(ctx context.Context, opts ...grpc.CallOption) (Backup_ImportClient, error)
+}
+
+type backupClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBackupClient(cc grpc.ClientConnInterface) BackupClient {
+	return &backupClient{cc}
+}
+
+func (c *backupClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Backup_ExportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Backup_ServiceDesc.Streams[0], Backup_Export_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &backupExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Backup_ExportClient interface {
+	Recv() (*ArchiveChunk, error)
+	grpc.ClientStream
+}
+
+type backupExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *backupExportClient) Recv() (*ArchiveChunk, error) {
+	m := new(ArchiveChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *backupClient) Import(ctx context.Context, opts ...grpc.CallOption) (Backup_ImportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Backup_ServiceDesc.Streams[1], Backup_Import_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &backupImportClient{stream}
+	return x, nil
+}
+
+type Backup_ImportClient interface {
+	Send(*ImportRequest) error
+	CloseAndRecv() (*ImportResponse, error)
+	grpc.ClientStream
+}
+
+type backupImportClient struct {
+	grpc.ClientStream
+}
+
+func (x *backupImportClient) Send(m *ImportRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *backupImportClient) CloseAndRecv() (*ImportResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BackupServer is the server API for Backup service.
+// All implementations must embed UnimplementedBackupServer
+// for forward compatibility
+type BackupServer interface {
+	Export(*ExportRequest, Backup_ExportServer) error
+	Import(Backup_ImportServer) error
+	mustEmbedUnimplementedBackupServer()
+}
+
+// UnimplementedBackupServer must be embedded to have forward compatible implementations.
+type UnimplementedBackupServer struct {
+}
+
+func (UnimplementedBackupServer) Export(*ExportRequest, Backup_ExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedBackupServer) Import(Backup_ImportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (UnimplementedBackupServer) mustEmbedUnimplementedBackupServer() {}
+
+// UnsafeBackupServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BackupServer will
+// result in compilation errors.
+type UnsafeBackupServer interface {
+	mustEmbedUnimplementedBackupServer()
+}
+
+func RegisterBackupServer(s grpc.ServiceRegistrar, srv BackupServer) {
+	s.RegisterService(&Backup_ServiceDesc, srv)
+}
+
+func _Backup_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BackupServer).Export(m, &backupExportServer{stream})
+}
+
+type Backup_ExportServer interface {
+	Send(*ArchiveChunk) error
+	grpc.ServerStream
+}
+
+type backupExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *backupExportServer) Send(m *ArchiveChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Backup_Import_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BackupServer).Import(&backupImportServer{stream})
+}
+
+type Backup_ImportServer interface {
+	SendAndClose(*ImportResponse) error
+	Recv() (*ImportRequest, error)
+	grpc.ServerStream
+}
+
+type backupImportServer struct {
+	grpc.ServerStream
+}
+
+func (x *backupImportServer) SendAndClose(m *ImportResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *backupImportServer) Recv() (*ImportRequest, error) {
+	m := new(ImportRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Backup_ServiceDesc is the grpc.ServiceDesc for Backup service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Backup_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "v1.backup.Backup",
+	HandlerType: (*BackupServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Export",
+			Handler:       _Backup_Export_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Import",
+			Handler:       _Backup_Import_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "v1/backup/backup.proto",
+}
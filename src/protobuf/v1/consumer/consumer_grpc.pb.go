@@ -19,9 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Consumer_GetConsumerStatus_FullMethodName = "/v1.consumer.Consumer/GetConsumerStatus"
-	Consumer_Start_FullMethodName             = "/v1.consumer.Consumer/Start"
-	Consumer_Stop_FullMethodName              = "/v1.consumer.Consumer/Stop"
+	Consumer_GetConsumerStatus_FullMethodName  = "/v1.consumer.Consumer/GetConsumerStatus"
+	Consumer_Start_FullMethodName              = "/v1.consumer.Consumer/Start"
+	Consumer_Stop_FullMethodName               = "/v1.consumer.Consumer/Stop"
+	Consumer_GetConsumerMetrics_FullMethodName = "/v1.consumer.Consumer/GetConsumerMetrics"
+	Consumer_ListDeadLetters_FullMethodName    = "/v1.consumer.Consumer/ListDeadLetters"
+	Consumer_ReplayDeadLetters_FullMethodName  = "/v1.consumer.Consumer/ReplayDeadLetters"
 )
 
 // ConsumerClient is the client API for Consumer service.
@@ -31,6 +34,9 @@ type ConsumerClient interface {
 	GetConsumerStatus(ctx context.Context, in *ConsumerRequest, opts ...grpc.CallOption) (*ConsumerResponse, error)
 	Start(ctx context.Context, in *ConsumerRequest, opts ...grpc.CallOption) (*ConsumerResponse, error)
 	Stop(ctx context.Context, in *ConsumerRequest, opts ...grpc.CallOption) (*ConsumerResponse, error)
+	GetConsumerMetrics(ctx context.Context, in *ConsumerRequest, opts ...grpc.CallOption) (*ConsumerMetricsResponse, error)
+	ListDeadLetters(ctx context.Context, in *DeadLetterRequest, opts ...grpc.CallOption) (*DeadLetterResponse, error)
+	ReplayDeadLetters(ctx context.Context, in *DeadLetterRequest, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error)
 }
 
 type consumerClient struct {
@@ -68,6 +74,33 @@ func (c *consumerClient) Stop(ctx context.Context, in *ConsumerRequest, opts ...
 	return out, nil
 }
 
+func (c *consumerClient) GetConsumerMetrics(ctx context.Context, in *ConsumerRequest, opts ...grpc.CallOption) (*ConsumerMetricsResponse, error) {
+	out := new(ConsumerMetricsResponse)
+	err := c.cc.Invoke(ctx, Consumer_GetConsumerMetrics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consumerClient) ListDeadLetters(ctx context.Context, in *DeadLetterRequest, opts ...grpc.CallOption) (*DeadLetterResponse, error) {
+	out := new(DeadLetterResponse)
+	err := c.cc.Invoke(ctx, Consumer_ListDeadLetters_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consumerClient) ReplayDeadLetters(ctx context.Context, in *DeadLetterRequest, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error) {
+	out := new(ReplayDeadLettersResponse)
+	err := c.cc.Invoke(ctx, Consumer_ReplayDeadLetters_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ConsumerServer is the server API for Consumer service.
 // All implementations must embed UnimplementedConsumerServer
 // for forward compatibility
@@ -75,6 +108,9 @@ type ConsumerServer interface {
 	GetConsumerStatus(context.Context, *ConsumerRequest) (*ConsumerResponse, error)
 	Start(context.Context, *ConsumerRequest) (*ConsumerResponse, error)
 	Stop(context.Context, *ConsumerRequest) (*ConsumerResponse, error)
+	GetConsumerMetrics(context.Context, *ConsumerRequest) (*ConsumerMetricsResponse, error)
+	ListDeadLetters(context.Context, *DeadLetterRequest) (*DeadLetterResponse, error)
+	ReplayDeadLetters(context.Context, *DeadLetterRequest) (*ReplayDeadLettersResponse, error)
 	mustEmbedUnimplementedConsumerServer()
 }
 
@@ -91,6 +127,15 @@ func (UnimplementedConsumerServer) Start(context.Context, *ConsumerRequest) (*Co
 func (UnimplementedConsumerServer) Stop(context.Context, *ConsumerRequest) (*ConsumerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
 }
+func (UnimplementedConsumerServer) GetConsumerMetrics(context.Context, *ConsumerRequest) (*ConsumerMetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConsumerMetrics not implemented")
+}
+func (UnimplementedConsumerServer) ListDeadLetters(context.Context, *DeadLetterRequest) (*DeadLetterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeadLetters not implemented")
+}
+func (UnimplementedConsumerServer) ReplayDeadLetters(context.Context, *DeadLetterRequest) (*ReplayDeadLettersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayDeadLetters not implemented")
+}
 func (UnimplementedConsumerServer) mustEmbedUnimplementedConsumerServer() {}
 
 // UnsafeConsumerServer may be embedded to opt out of forward compatibility for this service.
@@ -158,6 +203,60 @@ func _Consumer_Stop_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Consumer_GetConsumerMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConsumerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsumerServer).GetConsumerMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Consumer_GetConsumerMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsumerServer).GetConsumerMetrics(ctx, req.(*ConsumerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Consumer_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsumerServer).ListDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Consumer_ListDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsumerServer).ListDeadLetters(ctx, req.(*DeadLetterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Consumer_ReplayDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsumerServer).ReplayDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Consumer_ReplayDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsumerServer).ReplayDeadLetters(ctx, req.(*DeadLetterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Consumer_ServiceDesc is the grpc.ServiceDesc for Consumer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -177,6 +276,18 @@ var Consumer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Stop",
 			Handler:    _Consumer_Stop_Handler,
 		},
+		{
+			MethodName: "GetConsumerMetrics",
+			Handler:    _Consumer_GetConsumerMetrics_Handler,
+		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _Consumer_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "ReplayDeadLetters",
+			Handler:    _Consumer_ReplayDeadLetters_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "v1/consumer/consumer.proto",
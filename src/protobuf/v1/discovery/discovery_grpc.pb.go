@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Discovery_GetPolicy_FullMethodName = "/v1.discovery.Discovery/GetPolicy"
+	Discovery_GetPolicy_FullMethodName  = "/v1.discovery.Discovery/GetPolicy"
+	Discovery_GetAnomaly_FullMethodName = "/v1.discovery.Discovery/GetAnomaly"
 )
 
 // DiscoveryClient is the client API for Discovery service.
@@ -27,6 +28,7 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type DiscoveryClient interface {
 	GetPolicy(ctx context.Context, in *GetPolicyRequest, opts ...grpc.CallOption) (Discovery_GetPolicyClient, error)
+	GetAnomaly(ctx context.Context, in *GetAnomalyRequest, opts ...grpc.CallOption) (Discovery_GetAnomalyClient, error)
 }
 
 type discoveryClient struct {
@@ -69,11 +71,44 @@ func (x *discoveryGetPolicyClient) Recv() (*GetPolicyResponse, error) {
 	return m, nil
 }
 
+func (c *discoveryClient) GetAnomaly(ctx context.Context, in *GetAnomalyRequest, opts ...grpc.CallOption) (Discovery_GetAnomalyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Discovery_ServiceDesc.Streams[1], Discovery_GetAnomaly_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &discoveryGetAnomalyClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Discovery_GetAnomalyClient interface {
+	Recv() (*GetAnomalyResponse, error)
+	grpc.ClientStream
+}
+
+type discoveryGetAnomalyClient struct {
+	grpc.ClientStream
+}
+
+func (x *discoveryGetAnomalyClient) Recv() (*GetAnomalyResponse, error) {
+	m := new(GetAnomalyResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // DiscoveryServer is the server API for Discovery service.
 // All implementations must embed UnimplementedDiscoveryServer
 // for forward compatibility
 type DiscoveryServer interface {
 	GetPolicy(*GetPolicyRequest, Discovery_GetPolicyServer) error
+	GetAnomaly(*GetAnomalyRequest, Discovery_GetAnomalyServer) error
 	mustEmbedUnimplementedDiscoveryServer()
 }
 
@@ -84,6 +119,9 @@ type UnimplementedDiscoveryServer struct {
 func (UnimplementedDiscoveryServer) GetPolicy(*GetPolicyRequest, Discovery_GetPolicyServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetPolicy not implemented")
 }
+func (UnimplementedDiscoveryServer) GetAnomaly(*GetAnomalyRequest, Discovery_GetAnomalyServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetAnomaly not implemented")
+}
 func (UnimplementedDiscoveryServer) mustEmbedUnimplementedDiscoveryServer() {}
 
 // UnsafeDiscoveryServer may be embedded to opt out of forward compatibility for this service.
@@ -118,6 +156,27 @@ func (x *discoveryGetPolicyServer) Send(m *GetPolicyResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Discovery_GetAnomaly_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetAnomalyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DiscoveryServer).GetAnomaly(m, &discoveryGetAnomalyServer{stream})
+}
+
+type Discovery_GetAnomalyServer interface {
+	Send(*GetAnomalyResponse) error
+	grpc.ServerStream
+}
+
+type discoveryGetAnomalyServer struct {
+	grpc.ServerStream
+}
+
+func (x *discoveryGetAnomalyServer) Send(m *GetAnomalyResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Discovery_ServiceDesc is the grpc.ServiceDesc for Discovery service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -131,6 +190,11 @@ var Discovery_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Discovery_GetPolicy_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetAnomaly",
+			Handler:       _Discovery_GetAnomaly_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "v1/discovery/discovery.proto",
 }
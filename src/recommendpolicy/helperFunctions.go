@@ -364,7 +364,7 @@ func addPolicyRule(policy *types.KnoxSystemPolicy, r *types.KnoxSystemSpec) {
 
 }
 
-func initDeploymentWatcher() {
+func initDeploymentWatcher(stopChan chan struct{}) {
 	clientset := cluster.ConnectK8sClient()
 
 	if clientset == nil {
@@ -379,6 +379,12 @@ func initDeploymentWatcher() {
 	}
 	defer watcher.Stop()
 
+	// the watcher is stopped with the cron job
+	go func() {
+		<-stopChan
+		watcher.Stop()
+	}()
+
 	for event := range watcher.ResultChan() {
 		found := false
 		var index int
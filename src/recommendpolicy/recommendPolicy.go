@@ -97,6 +97,9 @@ func StopRecommendWorker() {
 
 // StartRecommendCronJob starts the recommendation cronjob
 func StartRecommendCronJob() {
+	// a stopped worker can be started again, e.g., by the next elected leader
+	RecommendStopChan = make(chan struct{})
+
 	// init cron job
 	RecommendCronJob = cron.New()
 	err := RecommendCronJob.AddFunc(cfg.GetCfgRecCronJobTime(), RecommendPolicyMain) // time interval
@@ -106,7 +109,7 @@ func StartRecommendCronJob() {
 	}
 	RecommendCronJob.Start()
 
-	go initDeploymentWatcher()
+	go initDeploymentWatcher(RecommendStopChan)
 
 }
 
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"strings"
@@ -12,8 +13,11 @@ import (
 
 	"github.com/accuknox/auto-policy-discovery/src/admissioncontrollerpolicy"
 	analyzer "github.com/accuknox/auto-policy-discovery/src/analyzer"
+	"github.com/accuknox/auto-policy-discovery/src/backup"
+	"github.com/accuknox/auto-policy-discovery/src/cluster"
 	core "github.com/accuknox/auto-policy-discovery/src/config"
 	fc "github.com/accuknox/auto-policy-discovery/src/feedconsumer"
+	"github.com/accuknox/auto-policy-discovery/src/leader"
 	logger "github.com/accuknox/auto-policy-discovery/src/logging"
 	network "github.com/accuknox/auto-policy-discovery/src/networkpolicy"
 	obs "github.com/accuknox/auto-policy-discovery/src/observability"
@@ -23,6 +27,7 @@ import (
 	"github.com/accuknox/auto-policy-discovery/src/insight"
 	"github.com/accuknox/auto-policy-discovery/src/libs"
 	apb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/analyzer"
+	bpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/backup"
 	fpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/consumer"
 	dpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/discovery"
 	ipb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/insight"
@@ -33,10 +38,12 @@ import (
 	"github.com/accuknox/auto-policy-discovery/src/types"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 var log *zerolog.Logger
@@ -69,6 +76,12 @@ func (s *workerServer) Start(ctx context.Context, in *wpb.WorkerRequest) (*wpb.W
 	}
 
 	if in.GetPolicytype() != "" {
+		// the workers only run on the elected replica
+		if elector != nil && !elector.IsLeader() {
+			response += "Not the leader, the policy discovery runs on " + elector.Leader()
+			return &wpb.WorkerResponse{Res: response}, nil
+		}
+
 		if in.GetPolicytype() == "network" {
 			network.StartNetworkWorker()
 		} else if in.GetPolicytype() == "system" {
@@ -113,21 +126,35 @@ func (s *workerServer) GetWorkerStatus(ctx context.Context, in *wpb.WorkerReques
 func (s *workerServer) Convert(ctx context.Context, in *wpb.WorkerRequest) (*wpb.WorkerResponse, error) {
 
 	policyType := in.GetPolicytype()
+	page := types.PageRequest{Size: int(in.GetPagesize()), Token: in.GetPagetoken()}
 	if strings.Contains(policyType, "NetworkPolicy") {
 		log.Info().Msg("Convert network policy called")
 		network.InitNetPolicyDiscoveryConfiguration()
 		network.WriteNetworkPoliciesToFile(in.GetClustername(), in.GetNamespace())
-		return network.GetNetPolicy(in.Clustername, in.Namespace, policyType), nil
+		return network.GetNetPolicy(in.Clustername, in.Namespace, policyType, page), nil
 	} else if policyType == "KubearmorSecurityPolicy" {
 		log.Info().Msg("Convert system policy called")
+		// the system policies are built from the workload process file sets, they are not paged
+		if page.Size != 0 || page.Token != "" {
+			return &wpb.WorkerResponse{Res: "paging is not supported for " + policyType}, nil
+		}
 		system.InitSysPolicyDiscoveryConfiguration()
 		system.WriteSystemPoliciesToFile(in.GetNamespace(), in.GetClustername(), in.GetLabels(), in.GetFromsource(), in.GetIncludenetwork())
 		return system.GetSysPolicy(in.Namespace, in.Clustername, in.Labels, in.Fromsource, in.Includenetwork), nil
+	} else if policyType == types.KindKubeArmorHostPolicy {
+		log.Info().Msg("Convert host policy called")
+		system.InitSysPolicyDiscoveryConfiguration()
+		return system.GetHostSysPolicy(in.Clustername, in.Labels, in.Fromsource), nil
+	} else if policyType == types.PolicyTypePolicyGap {
+		log.Info().Msg("Policy gap report called")
+		return network.GetPolicyGapReport(in.GetClustername(), in.GetNamespace()), nil
 	} else if policyType == types.PolicyTypeAdmissionController || policyType == types.PolicyTypeAdmissionControllerGeneric {
 		log.Info().Msg("Convert admission controller policy called")
 		admissioncontrollerpolicy.InitAdmissionControllerPolicyDiscoveryConfiguration()
-		policies := admissioncontrollerpolicy.GetAdmissionControllerPolicy(in.Namespace, in.Clustername, in.Labels, policyType)
-		return admissioncontrollerpolicy.ConvertPoliciesToWorkerResponse(policies), nil
+		policies, next := admissioncontrollerpolicy.GetAdmissionControllerPolicy(in.Namespace, in.Clustername, in.Labels, policyType, page)
+		response := admissioncontrollerpolicy.ConvertPoliciesToWorkerResponse(policies)
+		response.NextPageToken = next
+		return response, nil
 	} else {
 		log.Error().Msgf("unsupported policy type - %s", policyType)
 	}
@@ -149,17 +176,13 @@ func (ds *discoveryServer) GetPolicy(req *dpb.GetPolicyRequest, srv dpb.Discover
 		return errors.New("invalid request")
 	}
 
-	var yamlFromDB []types.PolicyYaml
 	if consumer.IsTypeSystem() {
-		yamlFromDB = append(yamlFromDB, system.GetPolicyYamlFromDB(consumer)...)
+		if err := sendPolicyYamlsFromDB(srv, consumer, system.GetPolicyYamlFromDB); err != nil {
+			return err
+		}
 	}
 	if consumer.IsTypeNetwork() {
-		yamlFromDB = append(yamlFromDB, network.GetPolicyYamlFromDB(consumer)...)
-	}
-
-	for i := range yamlFromDB {
-		err := libs.SendPolicyYamlInGrpcStream(srv, &yamlFromDB[i])
-		if err != nil {
+		if err := sendPolicyYamlsFromDB(srv, consumer, network.GetPolicyYamlFromDB); err != nil {
 			return err
 		}
 	}
@@ -184,6 +207,51 @@ func (ds *discoveryServer) GetPolicy(req *dpb.GetPolicyRequest, srv dpb.Discover
 	return libs.RelayPolicyEventToGrpcStream(srv, consumer)
 }
 
+// sendPolicyYamlsFromDB streams the policies of the consumer stored in the DB, a page at a time
+func sendPolicyYamlsFromDB(srv dpb.Discovery_GetPolicyServer, consumer *libs.PolicyConsumer,
+	getPage func(*libs.PolicyConsumer, types.PageRequest) ([]types.PolicyYaml, string, error)) error {
+	page := types.PageRequest{Size: libs.DefaultPageSize}
+	for {
+		yamlFromDB, next, err := getPage(consumer, page)
+		if err != nil {
+			return err
+		}
+
+		for i := range yamlFromDB {
+			if err := libs.SendPolicyYamlInGrpcStream(srv, &yamlFromDB[i]); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		page.Token = next
+	}
+}
+
+func (ds *discoveryServer) GetAnomaly(req *dpb.GetAnomalyRequest, srv dpb.Discovery_GetAnomalyServer) error {
+	consumer := libs.NewAnomalyConsumer(req)
+
+	anomalies := system.GetAnomaliesFromDB(consumer)
+	for i := range anomalies {
+		err := libs.SendAnomalyInGrpcStream(srv, &anomalies[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	if !req.GetFollow() {
+		return nil
+	}
+
+	system.AnomalyStore.AddConsumer(consumer)
+	defer system.AnomalyStore.RemoveConsumer(consumer)
+
+	// consume anomaly events
+	return libs.RelayAnomalyEventToGrpcStream(srv, consumer)
+}
+
 // ====================== //
 // == Consumer Service == //
 // ====================== //
@@ -194,6 +262,10 @@ type consumerServer struct {
 
 func (s *consumerServer) Start(ctx context.Context, in *fpb.ConsumerRequest) (*fpb.ConsumerResponse, error) {
 	log.Info().Msg("Start consumer called")
+	// the consumer only runs on the elected replica
+	if elector != nil && !elector.IsLeader() {
+		return &fpb.ConsumerResponse{Res: "Not the leader, the consumer runs on " + elector.Leader()}, nil
+	}
 	fc.ConsumerMutex.Lock()
 	fc.StartConsumer()
 	fc.ConsumerMutex.Unlock()
@@ -213,6 +285,74 @@ func (s *consumerServer) GetWorkerStatus(ctx context.Context, in *fpb.ConsumerRe
 	return &fpb.ConsumerResponse{Res: fc.Status}, nil
 }
 
+func (s *consumerServer) GetConsumerMetrics(ctx context.Context, in *fpb.ConsumerRequest) (*fpb.ConsumerMetricsResponse, error) {
+	metrics := fc.GetMetrics()
+	return &fpb.ConsumerMetricsResponse{
+		NetworkLogsBuffered: metrics.NetworkLogsBuffered,
+		SystemLogsBuffered:  metrics.SystemLogsBuffered,
+		NetworkLogsQueued:   metrics.NetworkLogsQueued,
+		SystemLogsQueued:    metrics.SystemLogsQueued,
+		QueueCapacity:       metrics.QueueCapacity,
+		PausedConsumers:     metrics.PausedConsumers,
+		NetworkLogsFlushed:  metrics.NetworkLogsFlushed,
+		SystemLogsFlushed:   metrics.SystemLogsFlushed,
+		BackpressurePauses:  metrics.BackpressurePauses,
+		RedeliveredEvents:   metrics.RedeliveredEvents,
+		DeadLetters:         metrics.DeadLetters,
+	}, nil
+}
+
+func (s *consumerServer) ListDeadLetters(ctx context.Context, in *fpb.DeadLetterRequest) (*fpb.DeadLetterResponse, error) {
+	deadLetters, err := fc.ListDeadLetters(getDeadLetterFilter(in))
+	if err != nil {
+		log.Error().Msgf("Failed to list the dead letters: %s", err)
+		return nil, err
+	}
+	return &fpb.DeadLetterResponse{DeadLetters: convertDeadLetters(deadLetters)}, nil
+}
+
+func (s *consumerServer) ReplayDeadLetters(ctx context.Context, in *fpb.DeadLetterRequest) (*fpb.ReplayDeadLettersResponse, error) {
+	log.Info().Msg("Replay dead letters called")
+	if elector != nil && !elector.IsLeader() {
+		return nil, status.Error(codes.FailedPrecondition, "Not the leader, the dead letters are replayed on "+elector.Leader())
+	}
+	replayed, failed, err := fc.ReplayDeadLetters(getDeadLetterFilter(in))
+	if err != nil {
+		log.Error().Msgf("Failed to replay the dead letters: %s", err)
+		return nil, err
+	}
+	return &fpb.ReplayDeadLettersResponse{
+		Replayed: int64(replayed),
+		Failed:   convertDeadLetters(failed),
+	}, nil
+}
+
+func getDeadLetterFilter(in *fpb.DeadLetterRequest) types.DeadLetterFilter {
+	return types.DeadLetterFilter{
+		Topic: in.GetTopic(),
+		IDs:   in.GetId(),
+		Limit: int(in.GetLimit()),
+	}
+}
+
+func convertDeadLetters(deadLetters []types.DeadLetter) []*fpb.DeadLetter {
+	res := make([]*fpb.DeadLetter, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		res = append(res, &fpb.DeadLetter{
+			Id:          deadLetter.ID,
+			Driver:      deadLetter.Driver,
+			Topic:       deadLetter.Topic,
+			Partition:   deadLetter.Partition,
+			Offset:      deadLetter.Offset,
+			MessageId:   deadLetter.MessageID,
+			Payload:     deadLetter.Payload,
+			Reason:      deadLetter.Reason,
+			CreatedTime: deadLetter.CreatedTime,
+		})
+	}
+	return res
+}
+
 // ====================== //
 // == Analyzer Service == //
 // ====================== //
@@ -302,6 +442,93 @@ func (ps *publisherServer) GetSummary(req *ppb.SummaryRequest, srv ppb.Publisher
 	return obs.SysSummary.RelaySummaryEventToGrpcStream(srv, consumer)
 }
 
+// ============ //
+// == Backup == //
+// ============ //
+
+// archiveChunkSize is the size of the archive chunks sent by an export
+const archiveChunkSize = 64 * 1024
+
+type backupServer struct {
+	bpb.UnimplementedBackupServer
+}
+
+// archiveChunkWriter sends the archive written by an export as chunks
+type archiveChunkWriter struct {
+	srv bpb.Backup_ExportServer
+}
+
+func (w archiveChunkWriter) Write(p []byte) (int, error) {
+	// the buffer of p is reused once written, a sent message is not to be modified
+	data := append([]byte(nil), p...)
+	if err := w.srv.Send(&bpb.ArchiveChunk{Data: data}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (bs *backupServer) Export(req *bpb.ExportRequest, srv bpb.Backup_ExportServer) error {
+	w := bufio.NewWriterSize(archiveChunkWriter{srv: srv}, archiveChunkSize)
+
+	manifest, err := backup.Export(w, core.GetCfgDB())
+	if err != nil {
+		log.Error().Msgf("failed to export the discovery state: %v", err)
+		return err
+	}
+	log.Info().Msgf("exported the discovery state: %v", manifest.Counts)
+
+	return w.Flush()
+}
+
+// archiveChunkReader reads the archive of the chunks received by an import
+type archiveChunkReader struct {
+	srv bpb.Backup_ImportServer
+	buf []byte
+}
+
+func (r *archiveChunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.srv.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = req.GetData()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (bs *backupServer) Import(srv bpb.Backup_ImportServer) error {
+	// the workers of the leader would rediscover the state being replaced on another replica
+	if elector != nil && !elector.IsLeader() {
+		return status.Error(codes.FailedPrecondition, "Not the leader, the discovery state is imported on "+elector.Leader())
+	}
+
+	first, err := srv.Recv()
+	if err != nil {
+		return err
+	}
+	r := &archiveChunkReader{srv: srv, buf: first.GetData()}
+
+	manifest, err := backup.Import(r, core.GetCfgDB(), first.GetReplace())
+	if err != nil {
+		log.Error().Msgf("failed to import the discovery state: %v, imported: %v", err, manifest.Counts)
+		switch {
+		case errors.Is(err, backup.ErrInvalidArchive):
+			return status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, backup.ErrArchiveTooNew):
+			return status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	log.Info().Msgf("imported the discovery state: %v", manifest.Counts)
+
+	return srv.SendAndClose(&bpb.ImportResponse{Res: "ok", Counts: manifest.Counts})
+}
+
 func StartGrpcServer() *grpc.Server {
 	var s *grpc.Server
 	if viper.GetBool("server.tls.enable") {
@@ -342,6 +569,7 @@ func AddServers(s *grpc.Server) *grpc.Server {
 	observabilityServer := &observabilityServer{}
 	discoveryServer := &discoveryServer{}
 	publisherServer := &publisherServer{}
+	backupServer := &backupServer{}
 
 	// register gRPC servers
 	wpb.RegisterWorkerServer(s, workerServer)
@@ -351,29 +579,69 @@ func AddServers(s *grpc.Server) *grpc.Server {
 	opb.RegisterObservabilityServer(s, observabilityServer)
 	dpb.RegisterDiscoveryServer(s, discoveryServer)
 	ppb.RegisterPublisherServer(s, publisherServer)
+	bpb.RegisterBackupServer(s, backupServer)
 
-	if core.GetCurrentCfg().ConfigClusterMgmt.ClusterInfoFrom != "k8sclient" {
-		// start consumer automatically
-		fc.ConsumerMutex.Lock()
-		fc.StartConsumer()
-		fc.ConsumerMutex.Unlock()
-	}
-
-	// start net worker automatically
-	network.StartNetworkWorker()
-
-	// start sys worker automatically
-	system.StartSystemWorker()
+	// start the consumer and the discovery workers automatically, on the leader only if elected
+	startDiscoveryWorkers()
 
 	// start observability
 	obs.InitObservability()
 
-	//start recommendation
-	recommend.StartRecommendWorker()
-
 	return s
 }
 
+// elector is the leader election of the discovery workers, nil if disabled
+var elector *leader.Elector
+
+func startDiscoveryWorkers() {
+	workers := leader.Workers{
+		Start: func() {
+			if core.GetCurrentCfg().ConfigClusterMgmt.ClusterInfoFrom != "k8sclient" {
+				// start consumer automatically
+				fc.ConsumerMutex.Lock()
+				fc.StartConsumer()
+				fc.ConsumerMutex.Unlock()
+			}
+			network.StartNetworkWorker()
+			system.StartSystemWorker()
+			recommend.StartRecommendWorker()
+			libs.InitPurgeOldDBEntries()
+		},
+		Stop: func() {
+			network.StopNetworkWorker()
+			system.StopSystemWorker()
+			recommend.StopRecommendWorker()
+			libs.StopPurgeOldDBEntries()
+			fc.ConsumerMutex.Lock()
+			fc.StopConsumer()
+			fc.ConsumerMutex.Unlock()
+		},
+	}
+
+	leCfg := core.GetCfgLeaderElection()
+	if !leCfg.Enable {
+		workers.Start()
+		return
+	}
+
+	var err error
+	if client := cluster.ConnectK8sClient(); client != nil {
+		elector, err = leader.NewElector(client, leCfg, workers)
+	} else {
+		err = leader.ErrNoK8sClient
+	}
+	if err != nil {
+		log.Error().Msgf("failed to start the leader election, the discovery workers are not started: %v", err)
+		return
+	}
+
+	go func() {
+		if err := elector.Run(context.Background()); err != nil {
+			log.Error().Msgf("leader election stopped: %v", err)
+		}
+	}()
+}
+
 func GetTLSCredentails() credentials.TransportCredentials {
 	certFile := viper.GetString("server.tls.cert")
 	keyFile := viper.GetString("server.tls.key")
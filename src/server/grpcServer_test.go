@@ -1,11 +1,86 @@
 package server
 
 import (
+	"context"
 	"testing"
 
+	core "github.com/accuknox/auto-policy-discovery/src/config"
+	"github.com/accuknox/auto-policy-discovery/src/leader"
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	bpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/backup"
+	fpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/consumer"
+	wpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/worker"
+	"github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestGetNewServer(t *testing.T) {
 	assert.NotNil(t, AddServers(AddLicenseServer(StartGrpcServer())))
 }
+
+func TestConvertSystemPolicyRejectsPaging(t *testing.T) {
+	s := &workerServer{}
+
+	res, err := s.Convert(context.Background(), &wpb.WorkerRequest{Policytype: "KubearmorSecurityPolicy", Pagesize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "paging is not supported for KubearmorSecurityPolicy", res.Res)
+
+	res, err = s.Convert(context.Background(), &wpb.WorkerRequest{Policytype: "KubearmorSecurityPolicy", Pagetoken: "MTA"})
+	assert.NoError(t, err)
+	assert.Equal(t, "paging is not supported for KubearmorSecurityPolicy", res.Res)
+}
+
+func TestStartDiscoveryWorkersSchedulesPurge(t *testing.T) {
+	prevCfg := core.CurrentCfg
+	defer func() { core.CurrentCfg = prevCfg }()
+	defer libs.StopPurgeOldDBEntries()
+
+	// without the leader election the replica runs the workers and the purge
+	core.CurrentCfg.ConfigPurgeOldDBEntries = types.ConfigPurgeOldDBEntries{Enable: true, CronJobTimeInterval: "@every 1h"}
+	startDiscoveryWorkers()
+	if assert.NotNil(t, libs.PurgeDBCronJob) {
+		assert.Len(t, libs.PurgeDBCronJob.Entries(), 1)
+	}
+}
+
+// importStream is the stream of an import whose archive is never read
+type importStream struct {
+	bpb.Backup_ImportServer
+	res *bpb.ImportResponse
+}
+
+func (s *importStream) SendAndClose(res *bpb.ImportResponse) error {
+	s.res = res
+	return nil
+}
+
+func TestNonLeaderRejectsImport(t *testing.T) {
+	defer func(e *leader.Elector) { elector = e }(elector)
+
+	var err error
+	elector, err = leader.NewElector(fake.NewSimpleClientset(), types.ConfigLeaderElection{
+		LeaseName: "discovery-engine", Namespace: "accuknox-agents", Identity: "replica-2",
+		LeaseDuration: 3, RenewDeadline: 2, RetryPeriod: 1,
+	}, leader.Workers{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the replica is not elected, the import is refused before the archive is read
+	srv := &importStream{}
+	err = (&backupServer{}).Import(srv)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "Not the leader")
+	assert.Nil(t, srv.res)
+
+	res, err := (&consumerServer{}).Start(context.Background(), &fpb.ConsumerRequest{})
+	assert.NoError(t, err)
+	assert.Contains(t, res.Res, "Not the leader")
+
+	// the dead letters stay in the store until the leader replays them
+	_, err = (&consumerServer{}).ReplayDeadLetters(context.Background(), &fpb.DeadLetterRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
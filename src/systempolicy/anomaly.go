@@ -0,0 +1,200 @@
+package systempolicy
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// DefaultStabilisationPeriod is used when the configured period is missing or invalid
+const DefaultStabilisationPeriod = 24 * time.Hour
+
+// severityRule matches a path prefix (ending with '/'), an absolute path, or a binary name
+type severityRule struct {
+	pattern  string
+	severity string
+}
+
+// severityRules are evaluated in order, the first match wins
+var severityRules = []severityRule{
+	// credentials and secrets
+	{"/etc/shadow", types.AnomalySeverityCritical},
+	{"/etc/gshadow", types.AnomalySeverityCritical},
+	{"/etc/sudoers", types.AnomalySeverityCritical},
+	{"/etc/sudoers.d/", types.AnomalySeverityCritical},
+	{"/root/.ssh/", types.AnomalySeverityCritical},
+	{"/var/run/secrets/kubernetes.io/", types.AnomalySeverityCritical},
+	{"/run/secrets/kubernetes.io/", types.AnomalySeverityCritical},
+
+	// sensitive files
+	{"/etc/passwd", types.AnomalySeverityHigh},
+	{"/etc/group", types.AnomalySeverityHigh},
+	{"/etc/ssh/", types.AnomalySeverityHigh},
+
+	// shells
+	{"sh", types.AnomalySeverityHigh},
+	{"bash", types.AnomalySeverityHigh},
+	{"dash", types.AnomalySeverityHigh},
+	{"ash", types.AnomalySeverityHigh},
+	{"zsh", types.AnomalySeverityHigh},
+	{"ksh", types.AnomalySeverityHigh},
+	{"csh", types.AnomalySeverityHigh},
+	{"tcsh", types.AnomalySeverityHigh},
+
+	// package managers
+	{"apt", types.AnomalySeverityHigh},
+	{"apt-get", types.AnomalySeverityHigh},
+	{"dpkg", types.AnomalySeverityHigh},
+	{"yum", types.AnomalySeverityHigh},
+	{"dnf", types.AnomalySeverityHigh},
+	{"rpm", types.AnomalySeverityHigh},
+	{"apk", types.AnomalySeverityHigh},
+	{"pip", types.AnomalySeverityHigh},
+	{"pip3", types.AnomalySeverityHigh},
+	{"npm", types.AnomalySeverityHigh},
+	{"gem", types.AnomalySeverityHigh},
+
+	// download and remote access tools
+	{"curl", types.AnomalySeverityHigh},
+	{"wget", types.AnomalySeverityHigh},
+	{"nc", types.AnomalySeverityHigh},
+	{"ncat", types.AnomalySeverityHigh},
+
+	// system binaries and configuration
+	{"/etc/", types.AnomalySeverityMedium},
+	{"/bin/", types.AnomalySeverityMedium},
+	{"/sbin/", types.AnomalySeverityMedium},
+	{"/usr/bin/", types.AnomalySeverityMedium},
+	{"/usr/sbin/", types.AnomalySeverityMedium},
+	{"/lib/", types.AnomalySeverityMedium},
+	{"/usr/lib/", types.AnomalySeverityMedium},
+	{"/boot/", types.AnomalySeverityMedium},
+}
+
+// ======================= //
+// == Anomaly Detection == //
+// ======================= //
+
+func getStabilisationPeriod(period string) time.Duration {
+	if period == "" {
+		return DefaultStabilisationPeriod
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d < 0 {
+		log.Error().Msgf("invalid stabilisation period [%s], using %s", period, DefaultStabilisationPeriod)
+		return DefaultStabilisationPeriod
+	}
+
+	return d
+}
+
+func matchSeverityRule(resource string, pattern string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(resource, pattern)
+	}
+	if strings.HasPrefix(pattern, "/") {
+		return resource == pattern
+	}
+	return filepath.Base(resource) == pattern
+}
+
+// getAnomalySeverity returns the severity based on the sensitivity of the path
+func getAnomalySeverity(resource string) string {
+	for _, rule := range severityRules {
+		if matchSeverityRule(resource, rule.pattern) {
+			return rule.severity
+		}
+	}
+	return types.AnomalySeverityLow
+}
+
+// isCoveredByFileSet checks if the resource is already allowed by the learnt fileset
+func isCoveredByFileSet(resource string, fs []string) bool {
+	for _, allowed := range fs {
+		if resource == allowed {
+			return true
+		}
+		// aggregated directories cover all the paths below
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(resource, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStableProfile checks if the WPFS entry was learnt for longer than the stabilisation period
+func isStableProfile(wpfs types.WorkloadProcessFileSet) bool {
+	createdTime, err := libs.GetWorkloadProcessFileSetCreatedTime(CfgDB, wpfs)
+	if err != nil {
+		log.Error().Msgf("failed to get wpfs created time wpfs=%+v err=%s", wpfs, err.Error())
+		return false
+	}
+
+	return time.Now().Unix()-createdTime >= int64(StabilisationPeriod.Seconds())
+}
+
+// getAnomalies returns the resources not covered by the stable profile
+func getAnomalies(wpfs types.WorkloadProcessFileSet, fs []string, profile []string) []types.SystemAnomaly {
+	counts := map[string]int32{}
+	resources := []string{}
+
+	for _, resource := range fs {
+		if isCoveredByFileSet(resource, profile) {
+			continue
+		}
+		if _, ok := counts[resource]; !ok {
+			resources = append(resources, resource)
+		}
+		counts[resource]++
+	}
+
+	now := time.Now().Unix()
+	anomalies := []types.SystemAnomaly{}
+	for _, resource := range resources {
+		anomalies = append(anomalies, types.SystemAnomaly{
+			ClusterName:   wpfs.ClusterName,
+			Namespace:     wpfs.Namespace,
+			ContainerName: wpfs.ContainerName,
+			Labels:        wpfs.Labels,
+			FromSource:    wpfs.FromSource,
+			Operation:     wpfs.SetType,
+			Resource:      resource,
+			Severity:      getAnomalySeverity(resource),
+			Count:         counts[resource],
+			FirstSeen:     now,
+			LastSeen:      now,
+		})
+	}
+
+	return anomalies
+}
+
+// raiseAnomalies stores the anomalies in DB and publishes the new ones
+func raiseAnomalies(anomalies []types.SystemAnomaly) {
+	for i := range anomalies {
+		isNew, err := libs.UpsertSystemAnomaly(CfgDB, anomalies[i])
+		if err != nil {
+			log.Error().Msgf("failed to store anomaly=%+v err=%s", anomalies[i], err.Error())
+			continue
+		}
+
+		if isNew {
+			log.Info().Msgf("%s anomaly detected ns=%s labels=%s %s=%s", anomalies[i].Severity,
+				anomalies[i].Namespace, anomalies[i].Labels, anomalies[i].Operation, anomalies[i].Resource)
+			AnomalyStore.Publish(&anomalies[i])
+		}
+	}
+}
+
+func GetAnomaliesFromDB(consumer *libs.AnomalyConsumer) []types.SystemAnomaly {
+	anomalies, err := libs.GetSystemAnomalies(CfgDB, consumer.Filter)
+	if err != nil {
+		log.Error().Msgf("fetching anomalies from DB failed err=%v", err.Error())
+		return nil
+	}
+	return libs.FilterAnomalies(anomalies, consumer.Filter)
+}
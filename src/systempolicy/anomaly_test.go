@@ -0,0 +1,64 @@
+package systempolicy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAnomalySeverity(t *testing.T) {
+	assert.Equal(t, types.AnomalySeverityCritical, getAnomalySeverity("/etc/shadow"))
+	assert.Equal(t, types.AnomalySeverityCritical, getAnomalySeverity("/var/run/secrets/kubernetes.io/serviceaccount/token"))
+	assert.Equal(t, types.AnomalySeverityHigh, getAnomalySeverity("/bin/bash"))
+	assert.Equal(t, types.AnomalySeverityHigh, getAnomalySeverity("/usr/bin/apt-get"))
+	assert.Equal(t, types.AnomalySeverityMedium, getAnomalySeverity("/etc/hosts"))
+	assert.Equal(t, types.AnomalySeverityMedium, getAnomalySeverity("/usr/bin/find"))
+	assert.Equal(t, types.AnomalySeverityLow, getAnomalySeverity("/app/data/cache.db"))
+}
+
+func TestGetAnomalies(t *testing.T) {
+	wpfs := types.WorkloadProcessFileSet{
+		ClusterName: "default",
+		Namespace:   "default",
+		Labels:      "app=nginx",
+		SetType:     SYS_OP_PROCESS,
+	}
+	profile := []string{"/usr/sbin/nginx", "/usr/lib/"}
+	fs := []string{"/usr/sbin/nginx", "/usr/lib/x86_64/libc.so", "/bin/sh", "/bin/sh", "/usr/bin/curl"}
+
+	anomalies := getAnomalies(wpfs, fs, profile)
+
+	assert.Len(t, anomalies, 2)
+	assert.Equal(t, "/bin/sh", anomalies[0].Resource)
+	assert.Equal(t, int32(2), anomalies[0].Count)
+	assert.Equal(t, types.AnomalySeverityHigh, anomalies[0].Severity)
+	assert.Equal(t, "/usr/bin/curl", anomalies[1].Resource)
+	assert.Equal(t, SYS_OP_PROCESS, anomalies[1].Operation)
+}
+
+func TestGetStabilisationPeriod(t *testing.T) {
+	assert.Equal(t, DefaultStabilisationPeriod, getStabilisationPeriod(""))
+	assert.Equal(t, DefaultStabilisationPeriod, getStabilisationPeriod("invalid"))
+	assert.Equal(t, 90*time.Minute, getStabilisationPeriod("1h30m0s"))
+}
+
+func TestFilterAnomalies(t *testing.T) {
+	anomalies := []types.SystemAnomaly{
+		{ClusterName: "default", Namespace: "default", Labels: "app=nginx", Resource: "/bin/sh", Severity: types.AnomalySeverityHigh},
+		{ClusterName: "default", Namespace: "default", Labels: "app=nginx", Resource: "/tmp/x", Severity: types.AnomalySeverityLow},
+		{ClusterName: "default", Namespace: "kube-system", Labels: "app=dns", Resource: "/etc/shadow", Severity: types.AnomalySeverityCritical},
+	}
+
+	filtered := libs.FilterAnomalies(anomalies, types.AnomalyFilter{Severity: types.AnomalySeverityHigh})
+	assert.Len(t, filtered, 2)
+
+	filtered = libs.FilterAnomalies(anomalies, types.AnomalyFilter{Labels: types.LabelMap{"app": "nginx"}})
+	assert.Len(t, filtered, 2)
+
+	filtered = libs.FilterAnomalies(anomalies, types.AnomalyFilter{Namespace: "default", Severity: types.AnomalySeverityHigh})
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "/bin/sh", filtered[0].Resource)
+}
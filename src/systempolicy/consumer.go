@@ -8,20 +8,27 @@ import (
 )
 
 var PolicyStore libs.PolicyStore
+var AnomalyStore libs.AnomalyStore
 
 func init() {
 	PolicyStore = libs.PolicyStore{
 		Consumers: make(map[*libs.PolicyConsumer]struct{}),
 		Mutex:     sync.Mutex{},
 	}
+	AnomalyStore = libs.AnomalyStore{
+		Consumers: make(map[*libs.AnomalyConsumer]struct{}),
+		Mutex:     sync.Mutex{},
+	}
 }
 
-func GetPolicyYamlFromDB(consumer *libs.PolicyConsumer) []types.PolicyYaml {
-	// TODO: Use policy filters for system policies
-	policyYamls, err := libs.GetPolicyYamls(CfgDB, types.PolicyTypeSystem, types.PolicyFilter{})
+// GetPolicyYamlFromDB returns a page of the system policies of the consumer stored in the DB
+// and the token of the next page, the cluster and the namespace are filtered by the DB
+func GetPolicyYamlFromDB(consumer *libs.PolicyConsumer, page types.PageRequest) ([]types.PolicyYaml, string, error) {
+	filter := types.PolicyFilter{Cluster: consumer.Filter.Cluster, Namespace: consumer.Filter.Namespace}
+	policyYamls, next, err := libs.GetPolicyYamlsPage(CfgDB, types.PolicyTypeSystem, filter, page)
 	if err != nil {
 		log.Error().Msgf("fetching policy yaml from DB failed err=%v", err.Error())
-		return nil
+		return nil, "", err
 	}
-	return libs.FilterPolicyYamls(policyYamls, consumer)
+	return libs.FilterPolicyYamls(policyYamls, consumer), next, nil
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/accuknox/auto-policy-discovery/src/libs"
 	types "github.com/accuknox/auto-policy-discovery/src/types"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 // ==================== //
@@ -65,99 +66,358 @@ func GeneratePolicyName(policyNamesMap map[string]bool, policy types.KnoxSystemP
 	return policy
 }
 
-// ======================= //
-// == Process Operation == //
-// ======================= //
+// ================= //
+// == Subsumption == //
+// ================= //
 
-func UpdateProcessOperation(newPolicy types.KnoxSystemPolicy, existingPolicies []types.KnoxSystemPolicy) (types.KnoxSystemPolicy, bool) {
-	latestPolicies := GetLatestPolicy(existingPolicies, newPolicy)
-	if len(latestPolicies) == 0 {
-		return newPolicy, false
+func dirPath(dir string) string {
+	if !strings.HasSuffix(dir, "/") {
+		return dir + "/"
 	}
+	return dir
+}
 
-	latestPolicy := latestPolicies[0]
-	src := ""
-	if val, ok := latestPolicy.Metadata["fromSource"]; ok {
-		src = val
+// fromSourceCovers checks if the source is included in the outer source
+func fromSourceCovers(outer, src types.KnoxFromSource) bool {
+	if outer == src {
+		return true
+	}
+	if outer.Dir == "" {
+		return false
+	}
+	outerDir := dirPath(outer.Dir)
+	if src.Path != "" {
+		return strings.HasPrefix(src.Path, outerDir)
+	}
+	return strings.HasPrefix(dirPath(src.Dir), outerDir)
+}
+
+// fromSourceListCovers checks if every source of the inner rule is allowed by the outer rule,
+// an empty fromSource list allows any source
+func fromSourceListCovers(outer, inner []types.KnoxFromSource) bool {
+	if len(outer) == 0 {
+		return true
+	}
+	if len(inner) == 0 {
+		return false
 	}
 
-	// step 1: latest matchDirectories -> new matchDirectories
-	for _, latestMatchDir := range latestPolicy.Spec.Process.MatchDirectories {
-		included := false
-		for _, newMatchDir := range newPolicy.Spec.Process.MatchDirectories {
-			if latestMatchDir.Dir == newMatchDir.Dir {
-				included = true
+	for _, src := range inner {
+		covered := false
+		for _, o := range outer {
+			if fromSourceCovers(o, src) {
+				covered = true
 				break
 			}
 		}
+		if !covered {
+			return false
+		}
+	}
+
+	return true
+}
 
-		if !included {
-			newPolicy.Spec.Process.MatchDirectories = append(newPolicy.Spec.Process.MatchDirectories, latestMatchDir)
+// collapseFromSource removes the duplicated sources and the ones covered by a source directory
+func collapseFromSource(srcs []types.KnoxFromSource) []types.KnoxFromSource {
+	if len(srcs) == 0 {
+		return nil
+	}
+
+	result := []types.KnoxFromSource{}
+	for i, src := range srcs {
+		subsumed := false
+		for j, other := range srcs {
+			if i == j {
+				continue
+			}
+			// keep the first one of the duplicated sources
+			if (other == src && j < i) || (other != src && fromSourceCovers(other, src)) {
+				subsumed = true
+				break
+			}
+		}
+		if !subsumed {
+			result = append(result, src)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Path != result[j].Path {
+			return result[i].Path < result[j].Path
 		}
+		return result[i].Dir < result[j].Dir
+	})
+
+	return result
+}
+
+// unionFromSource merges two fromSource lists, an empty list allows any source
+func unionFromSource(a, b []types.KnoxFromSource) []types.KnoxFromSource {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	return collapseFromSource(append(append([]types.KnoxFromSource{}, a...), b...))
+}
+
+// dirCoversPath checks if the path is matched by the directory rule
+func dirCoversPath(dir types.KnoxMatchDirectories, path string) bool {
+	d := dirPath(dir.Dir)
+	if len(path) <= len(d) || !strings.HasPrefix(path, d) {
+		return false
 	}
+	if dir.Recursive {
+		return true
+	}
+	// non recursive directories only match the files directly under them
+	return !strings.Contains(path[len(d):], "/")
+}
 
-	// step 2: get the matchDirectories
-	dirs := []string{}
-	for _, newMatchDir := range newPolicy.Spec.Process.MatchDirectories {
-		if !libs.ContainsElement(dirs, newMatchDir.Dir) {
-			dirs = append(dirs, newMatchDir.Dir)
+func flagsCover(outerReadOnly, outerOwnerOnly, innerReadOnly, innerOwnerOnly bool) bool {
+	return (!outerReadOnly || innerReadOnly) && (!outerOwnerOnly || innerOwnerOnly)
+}
+
+func dirSubsumesPath(dir types.KnoxMatchDirectories, path types.KnoxMatchPaths) bool {
+	return dirCoversPath(dir, path.Path) &&
+		flagsCover(dir.ReadOnly, dir.OwnerOnly, path.ReadOnly, path.OwnerOnly) &&
+		fromSourceListCovers(dir.FromSource, path.FromSource)
+}
+
+func dirSubsumesDir(outer, inner types.KnoxMatchDirectories) bool {
+	outerDir, innerDir := dirPath(outer.Dir), dirPath(inner.Dir)
+	if outerDir == innerDir {
+		if inner.Recursive && !outer.Recursive {
+			return false
 		}
+	} else if !outer.Recursive || !strings.HasPrefix(innerDir, outerDir) {
+		return false
 	}
 
-	// step 3: get the matchPaths
-	paths := []string{}
-	for _, newMatchPath := range newPolicy.Spec.Process.MatchPaths {
-		if !libs.ContainsElement(dirs, newMatchPath.Path) {
-			paths = append(dirs, newMatchPath.Path)
+	return flagsCover(outer.ReadOnly, outer.OwnerOnly, inner.ReadOnly, inner.OwnerOnly) &&
+		fromSourceListCovers(outer.FromSource, inner.FromSource)
+}
+
+// mergeKnoxSys merges the file/process rules, aggregates the paths and drops the
+// paths/directories subsumed by a recursive or parent directory rule
+func mergeKnoxSys(sys types.KnoxSys, others ...types.KnoxSys) types.KnoxSys {
+	dirs := map[string]types.KnoxMatchDirectories{}
+	paths := map[string]types.KnoxMatchPaths{}
+
+	addDir := func(dir types.KnoxMatchDirectories) {
+		dir.Dir = dirPath(dir.Dir)
+		if exist, ok := dirs[dir.Dir]; ok {
+			dir.Recursive = dir.Recursive || exist.Recursive
+			dir.ReadOnly = dir.ReadOnly && exist.ReadOnly
+			dir.OwnerOnly = dir.OwnerOnly && exist.OwnerOnly
+			dir.FromSource = unionFromSource(dir.FromSource, exist.FromSource)
 		}
+		dir.FromSource = collapseFromSource(dir.FromSource)
+		dirs[dir.Dir] = dir
 	}
 
-	mergedSysPaths := common.MergeAndAggregatePaths(dirs, paths)
+	addPath := func(path types.KnoxMatchPaths) {
+		if exist, ok := paths[path.Path]; ok {
+			path.ReadOnly = path.ReadOnly && exist.ReadOnly
+			path.OwnerOnly = path.OwnerOnly && exist.OwnerOnly
+			path.FromSource = unionFromSource(path.FromSource, exist.FromSource)
+		}
+		path.FromSource = collapseFromSource(path.FromSource)
+		paths[path.Path] = path
+	}
 
-	// step 4: init and updated proecss spec
-	newPolicy.Spec.Process = types.KnoxSys{} // init
-	for _, pathSpec := range mergedSysPaths {
-		if pathSpec.IsDir {
-			matchDirs := types.KnoxMatchDirectories{
-				Dir: pathSpec.Path,
-			}
+	for _, s := range append([]types.KnoxSys{sys}, others...) {
+		for _, dir := range s.MatchDirectories {
+			addDir(dir)
+		}
+		for _, path := range s.MatchPaths {
+			addPath(path)
+		}
+	}
 
-			if src != "" {
-				matchDirs.FromSource = []types.KnoxFromSource{
-					{
-						Path: src,
-					},
-				}
+	// step 1: aggregate the paths, too many paths in a directory are collapsed to a directory rule
+	pathList := []string{}
+	for path := range paths {
+		pathList = append(pathList, path)
+	}
+	for _, aggregated := range common.MergeAndAggregatePaths(nil, pathList) {
+		if !aggregated.IsDir {
+			continue
+		}
 
+		collapsed := types.KnoxMatchDirectories{Dir: dirPath(aggregated.Path), ReadOnly: true, OwnerOnly: true}
+		first := true
+		for _, path := range paths {
+			if !strings.HasPrefix(path.Path, collapsed.Dir) {
+				continue
 			}
-
-			if len(newPolicy.Spec.Process.MatchDirectories) == 0 {
-				newPolicy.Spec.Process.MatchDirectories = []types.KnoxMatchDirectories{matchDirs}
+			if strings.Contains(path.Path[len(collapsed.Dir):], "/") {
+				collapsed.Recursive = true
+			}
+			collapsed.ReadOnly = collapsed.ReadOnly && path.ReadOnly
+			collapsed.OwnerOnly = collapsed.OwnerOnly && path.OwnerOnly
+			if first {
+				collapsed.FromSource = path.FromSource
+				first = false
 			} else {
-				newPolicy.Spec.Process.MatchDirectories = append(newPolicy.Spec.Process.MatchDirectories, matchDirs)
+				collapsed.FromSource = unionFromSource(collapsed.FromSource, path.FromSource)
 			}
-		} else {
-			matchPaths := types.KnoxMatchPaths{
-				Path: pathSpec.Path,
+		}
+		addDir(collapsed)
+	}
+
+	// step 2: drop the rules subsumed by a directory rule
+	merged := types.KnoxSys{}
+	for _, dir := range dirs {
+		subsumed := false
+		for _, outer := range dirs {
+			if outer.Dir != dir.Dir && dirSubsumesDir(outer, dir) {
+				subsumed = true
+				break
+			}
+		}
+		if !subsumed {
+			merged.MatchDirectories = append(merged.MatchDirectories, dir)
+		}
+	}
+	for _, path := range paths {
+		subsumed := false
+		for _, dir := range merged.MatchDirectories {
+			if dirSubsumesPath(dir, path) {
+				subsumed = true
+				break
 			}
+		}
+		if !subsumed {
+			merged.MatchPaths = append(merged.MatchPaths, path)
+		}
+	}
 
-			if src != "" {
-				matchPaths.FromSource = []types.KnoxFromSource{
-					{
-						Path: src,
-					},
-				}
+	sort.Slice(merged.MatchDirectories, func(i, j int) bool {
+		return merged.MatchDirectories[i].Dir < merged.MatchDirectories[j].Dir
+	})
+	sort.Slice(merged.MatchPaths, func(i, j int) bool {
+		return merged.MatchPaths[i].Path < merged.MatchPaths[j].Path
+	})
+
+	return merged
+}
+
+// knoxSysSubsumes checks if all the rules of the inner spec are allowed by the outer spec
+func knoxSysSubsumes(outer, inner types.KnoxSys) bool {
+	for _, dir := range inner.MatchDirectories {
+		covered := false
+		for _, o := range outer.MatchDirectories {
+			if dirSubsumesDir(o, dir) {
+				covered = true
+				break
 			}
+		}
+		if !covered {
+			return false
+		}
+	}
 
-			if len(newPolicy.Spec.Process.MatchPaths) == 0 {
-				newPolicy.Spec.Process.MatchPaths = []types.KnoxMatchPaths{matchPaths}
-			} else {
-				newPolicy.Spec.Process.MatchPaths = append(newPolicy.Spec.Process.MatchPaths, matchPaths)
+	for _, path := range inner.MatchPaths {
+		covered := false
+		for _, o := range outer.MatchPaths {
+			if o.Path == path.Path &&
+				flagsCover(o.ReadOnly, o.OwnerOnly, path.ReadOnly, path.OwnerOnly) &&
+				fromSourceListCovers(o.FromSource, path.FromSource) {
+				covered = true
+				break
+			}
+		}
+		for _, o := range outer.MatchDirectories {
+			if covered {
+				break
 			}
+			covered = dirSubsumesPath(o, path)
+		}
+		if !covered {
+			return false
 		}
 	}
 
-	// step 5: update latest -> outdated
+	return true
+}
+
+func networkRuleSubsumes(outer, inner types.NetworkRule) bool {
+	for _, proto := range inner.MatchProtocols {
+		covered := false
+		for _, o := range outer.MatchProtocols {
+			if o.Protocol == proto.Protocol && fromSourceListCovers(o.FromSource, proto.FromSource) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSubsumedPolicy checks if the new policy is already allowed by a latest policy of the same workload
+func IsSubsumedPolicy(existingPolicies []types.KnoxSystemPolicy, newPolicy types.KnoxSystemPolicy) bool {
+	for _, exist := range existingPolicies {
+		if exist.Metadata["clusterName"] == newPolicy.Metadata["clusterName"] &&
+			exist.Metadata["namespace"] == newPolicy.Metadata["namespace"] &&
+			exist.Metadata["type"] == newPolicy.Metadata["type"] &&
+			exist.Metadata["status"] == "latest" &&
+			cmp.Equal(exist.Spec.Selector, newPolicy.Spec.Selector) &&
+			cmp.Equal(exist.Spec.NodeSelector, newPolicy.Spec.NodeSelector, cmpopts.EquateEmpty()) &&
+			exist.Spec.Action == newPolicy.Spec.Action &&
+			knoxSysSubsumes(exist.Spec.Process, newPolicy.Spec.Process) &&
+			knoxSysSubsumes(exist.Spec.File, newPolicy.Spec.File) &&
+			networkRuleSubsumes(exist.Spec.Network, newPolicy.Spec.Network) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withFromSource sets the fromSource of the rules having no source
+func withFromSource(sys types.KnoxSys, src string) types.KnoxSys {
+	if src == "" {
+		return sys
+	}
+
+	fromSource := []types.KnoxFromSource{{Path: src}}
+	result := types.KnoxSys{}
+	for _, dir := range sys.MatchDirectories {
+		if len(dir.FromSource) == 0 {
+			dir.FromSource = fromSource
+		}
+		result.MatchDirectories = append(result.MatchDirectories, dir)
+	}
+	for _, path := range sys.MatchPaths {
+		if len(path.FromSource) == 0 {
+			path.FromSource = fromSource
+		}
+		result.MatchPaths = append(result.MatchPaths, path)
+	}
+	return result
+}
+
+// ======================= //
+// == Process Operation == //
+// ======================= //
+
+func UpdateProcessOperation(newPolicy types.KnoxSystemPolicy, existingPolicies []types.KnoxSystemPolicy) (types.KnoxSystemPolicy, bool) {
+	latestPolicies := GetLatestPolicy(existingPolicies, newPolicy)
+	if len(latestPolicies) == 0 {
+		return newPolicy, false
+	}
+
+	latestPolicy := latestPolicies[0]
+	src := latestPolicy.Metadata["fromSource"]
+
+	// merge latest matchPaths/matchDirectories -> new ones, drop the subsumed rules
+	newPolicy.Spec.Process = mergeKnoxSys(withFromSource(newPolicy.Spec.Process, src),
+		withFromSource(latestPolicy.Spec.Process, src))
+
+	// update latest -> outdated
 	libs.UpdateOutdatedSystemPolicy(config.GetCfgDB(), latestPolicy.Metadata["name"], newPolicy.Metadata["name"])
 
 	return newPolicy, true
@@ -202,6 +462,12 @@ func GetLatestPolicy(existingPolicies []types.KnoxSystemPolicy, policy types.Kno
 				continue
 			}
 
+			// the host policies select their nodes by the node selector, a node is not merged into another one
+			if !includeSelectorLabels(policy.Spec.NodeSelector.MatchLabels, exist.Spec.NodeSelector.MatchLabels) ||
+				!includeSelectorLabels(exist.Spec.NodeSelector.MatchLabels, policy.Spec.NodeSelector.MatchLabels) {
+				continue
+			}
+
 			latestPolicies = append(latestPolicies, exist)
 		}
 	}
@@ -216,87 +482,13 @@ func UpdateFileOperation(newPolicy types.KnoxSystemPolicy, existingPolicies []ty
 	}
 
 	latestPolicy := latestPolicies[0]
-	src := ""
-	if val, ok := latestPolicy.Metadata["fromSource"]; ok {
-		src = val
-	}
-
-	// step 1: latest matchDirectories -> new matchDirectories
-	for _, latestMatchDir := range latestPolicy.Spec.File.MatchDirectories {
-		included := false
-		for _, newMatchDir := range newPolicy.Spec.File.MatchDirectories {
-			if latestMatchDir.Dir == newMatchDir.Dir {
-				included = true
-				break
-			}
-		}
-
-		if !included {
-			newPolicy.Spec.File.MatchDirectories = append(newPolicy.Spec.File.MatchDirectories, latestMatchDir)
-		}
-	}
-
-	// step 2: get the matchDirectories
-	dirs := []string{}
-	for _, newMatchDir := range newPolicy.Spec.File.MatchDirectories {
-		if !libs.ContainsElement(dirs, newMatchDir.Dir) {
-			dirs = append(dirs, newMatchDir.Dir)
-		}
-	}
-
-	// step 3: get the matchPaths
-	paths := []string{}
-	for _, newMatchPath := range newPolicy.Spec.File.MatchPaths {
-		if !libs.ContainsElement(dirs, newMatchPath.Path) {
-			paths = append(dirs, newMatchPath.Path)
-		}
-	}
-
-	mergedSysPaths := common.MergeAndAggregatePaths(dirs, paths)
-
-	// step 4: init and updated file spec
-	newPolicy.Spec.File = types.KnoxSys{} // init
-	for _, pathSpec := range mergedSysPaths {
-		if pathSpec.IsDir {
-			matchDirs := types.KnoxMatchDirectories{
-				Dir: pathSpec.Path,
-			}
-
-			if src != "" {
-				matchDirs.FromSource = []types.KnoxFromSource{
-					{
-						Path: src,
-					},
-				}
-			}
-
-			if len(newPolicy.Spec.File.MatchDirectories) == 0 {
-				newPolicy.Spec.File.MatchDirectories = []types.KnoxMatchDirectories{matchDirs}
-			} else {
-				newPolicy.Spec.File.MatchDirectories = append(newPolicy.Spec.File.MatchDirectories, matchDirs)
-			}
-		} else {
-			matchPaths := types.KnoxMatchPaths{
-				Path: pathSpec.Path,
-			}
-
-			if src != "" {
-				matchPaths.FromSource = []types.KnoxFromSource{
-					{
-						Path: src,
-					},
-				}
-			}
+	src := latestPolicy.Metadata["fromSource"]
 
-			if len(newPolicy.Spec.File.MatchPaths) == 0 {
-				newPolicy.Spec.File.MatchPaths = []types.KnoxMatchPaths{matchPaths}
-			} else {
-				newPolicy.Spec.File.MatchPaths = append(newPolicy.Spec.File.MatchPaths, matchPaths)
-			}
-		}
-	}
+	// merge latest matchPaths/matchDirectories -> new ones, drop the subsumed rules
+	newPolicy.Spec.File = mergeKnoxSys(withFromSource(newPolicy.Spec.File, src),
+		withFromSource(latestPolicy.Spec.File, src))
 
-	// step 5: update latest -> outdated
+	// update latest -> outdated
 	libs.UpdateOutdatedSystemPolicy(config.GetCfgDB(), latestPolicy.Metadata["name"], newPolicy.Metadata["name"])
 
 	return newPolicy, true
@@ -317,8 +509,12 @@ func UpdateDuplicatedPolicy(existingPolicies []types.KnoxSystemPolicy, discovere
 
 	// enumerate discovered network policy
 	for _, policy := range discoveredPolicies {
-		// step 1: compare the total network policy spec
-		if IsExistingPolicy(existingPolicies, policy) {
+		// step 0: drop the redundant rules and collapse the fromSource lists
+		policy.Spec.Process = mergeKnoxSys(policy.Spec.Process)
+		policy.Spec.File = mergeKnoxSys(policy.Spec.File)
+
+		// step 1: compare the total network policy spec, skip it if it is allowed by a latest policy
+		if IsExistingPolicy(existingPolicies, policy) || IsSubsumedPolicy(existingPolicies, policy) {
 			continue
 		}
 
@@ -54,3 +54,133 @@ func TestGeneratePolicyName(t *testing.T) {
 
 	assert.Equal(t, updated.Metadata["clusterName"], "testcluster")
 }
+
+// ================= //
+// == Subsumption == //
+// ================= //
+
+func TestMergeKnoxSys_RecursiveDirSubsumesPaths(t *testing.T) {
+	latest := types.KnoxSys{
+		MatchPaths: []types.KnoxMatchPaths{
+			{Path: "/usr/lib/libc.so"},
+			{Path: "/usr/lib/x86_64/libssl.so"},
+			{Path: "/etc/hosts"},
+		},
+	}
+	learnt := types.KnoxSys{
+		MatchDirectories: []types.KnoxMatchDirectories{
+			{Dir: "/usr/lib/", Recursive: true},
+			{Dir: "/usr/lib/python3/"},
+		},
+	}
+
+	merged := mergeKnoxSys(learnt, latest)
+
+	assert.Equal(t, []types.KnoxMatchDirectories{{Dir: "/usr/lib/", Recursive: true}}, merged.MatchDirectories)
+	assert.Equal(t, []types.KnoxMatchPaths{{Path: "/etc/hosts"}}, merged.MatchPaths)
+}
+
+func TestMergeKnoxSys_NonRecursiveDir(t *testing.T) {
+	sys := types.KnoxSys{
+		MatchDirectories: []types.KnoxMatchDirectories{{Dir: "/usr/lib/"}},
+		MatchPaths: []types.KnoxMatchPaths{
+			{Path: "/usr/lib/libc.so"},
+			{Path: "/usr/lib/x86_64/libssl.so"},
+		},
+	}
+
+	merged := mergeKnoxSys(sys)
+
+	assert.Len(t, merged.MatchDirectories, 1)
+	assert.Equal(t, []types.KnoxMatchPaths{{Path: "/usr/lib/x86_64/libssl.so"}}, merged.MatchPaths)
+}
+
+func TestMergeKnoxSys_FromSource(t *testing.T) {
+	bash := types.KnoxFromSource{Path: "/bin/bash"}
+	python := types.KnoxFromSource{Path: "/usr/bin/python3"}
+
+	sys := types.KnoxSys{
+		MatchDirectories: []types.KnoxMatchDirectories{
+			{Dir: "/etc/", Recursive: true, FromSource: []types.KnoxFromSource{bash}},
+		},
+		MatchPaths: []types.KnoxMatchPaths{
+			// different source, not subsumed
+			{Path: "/etc/passwd", FromSource: []types.KnoxFromSource{python}},
+			// same source, subsumed
+			{Path: "/etc/hosts", FromSource: []types.KnoxFromSource{bash}},
+			// equivalent fromSource lists are collapsed
+			{Path: "/app/a", FromSource: []types.KnoxFromSource{python, bash}},
+			{Path: "/app/a", FromSource: []types.KnoxFromSource{bash, python, bash}},
+			// a source directory covers the source paths
+			{Path: "/app/b", FromSource: []types.KnoxFromSource{python, {Dir: "/usr/bin/"}}},
+		},
+	}
+
+	merged := mergeKnoxSys(sys)
+
+	assert.Equal(t, []types.KnoxMatchPaths{
+		{Path: "/app/a", FromSource: []types.KnoxFromSource{bash, python}},
+		{Path: "/app/b", FromSource: []types.KnoxFromSource{{Dir: "/usr/bin/"}}},
+		{Path: "/etc/passwd", FromSource: []types.KnoxFromSource{python}},
+	}, merged.MatchPaths)
+}
+
+func TestMergeKnoxSys_ReadOnly(t *testing.T) {
+	sys := types.KnoxSys{
+		MatchDirectories: []types.KnoxMatchDirectories{{Dir: "/data/", Recursive: true, ReadOnly: true}},
+		MatchPaths:       []types.KnoxMatchPaths{{Path: "/data/db"}},
+	}
+
+	// a read-only directory does not allow writing the path
+	merged := mergeKnoxSys(sys)
+	assert.Len(t, merged.MatchPaths, 1)
+}
+
+func TestIsSubsumedPolicy(t *testing.T) {
+	exist := types.KnoxSystemPolicy{
+		Metadata: map[string]string{"namespace": "default", "type": SYS_OP_FILE, "status": "latest"},
+		Spec: types.KnoxSystemSpec{
+			Selector: types.Selector{MatchLabels: map[string]string{"app": "test"}},
+			File: types.KnoxSys{
+				MatchDirectories: []types.KnoxMatchDirectories{{Dir: "/usr/lib/", Recursive: true}},
+			},
+		},
+	}
+
+	newPolicy := types.KnoxSystemPolicy{
+		Metadata: map[string]string{"namespace": "default", "type": SYS_OP_FILE},
+		Spec: types.KnoxSystemSpec{
+			Selector: types.Selector{MatchLabels: map[string]string{"app": "test"}},
+			File: types.KnoxSys{
+				MatchPaths: []types.KnoxMatchPaths{{Path: "/usr/lib/libc.so"}},
+			},
+		},
+	}
+	assert.True(t, IsSubsumedPolicy([]types.KnoxSystemPolicy{exist}, newPolicy))
+
+	newPolicy.Spec.File.MatchPaths = append(newPolicy.Spec.File.MatchPaths, types.KnoxMatchPaths{Path: "/etc/hosts"})
+	assert.False(t, IsSubsumedPolicy([]types.KnoxSystemPolicy{exist}, newPolicy))
+}
+
+func TestIsSubsumedPolicy_HostNodes(t *testing.T) {
+	hostPolicy := func(node string) types.KnoxSystemPolicy {
+		return types.KnoxSystemPolicy{
+			Kind:     types.KindKubeArmorHostPolicy,
+			Metadata: map[string]string{"namespace": "", "type": SYS_OP_FILE, "status": "latest"},
+			Spec: types.KnoxSystemSpec{
+				NodeSelector: types.Selector{MatchLabels: map[string]string{"kubernetes.io/hostname": node}},
+				File: types.KnoxSys{
+					MatchPaths: []types.KnoxMatchPaths{{Path: "/etc/hosts"}},
+				},
+			},
+		}
+	}
+	nodeA, nodeB := hostPolicy("node-a"), hostPolicy("node-b")
+
+	// the policy of a node neither subsumes nor is merged into the policy of another node
+	assert.False(t, IsSubsumedPolicy([]types.KnoxSystemPolicy{nodeA}, nodeB))
+	assert.Empty(t, GetLatestPolicy([]types.KnoxSystemPolicy{nodeA}, nodeB))
+
+	assert.True(t, IsSubsumedPolicy([]types.KnoxSystemPolicy{nodeA}, hostPolicy("node-a")))
+	assert.Len(t, GetLatestPolicy([]types.KnoxSystemPolicy{nodeA}, hostPolicy("node-a")), 1)
+}
@@ -0,0 +1,176 @@
+package systempolicy
+
+import (
+	"strings"
+
+	"github.com/clarketm/json"
+
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	"github.com/accuknox/auto-policy-discovery/src/plugin"
+	wpb "github.com/accuknox/auto-policy-discovery/src/protobuf/v1/worker"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// LabelNodeHostname is the well-known node label used when no grouping label matches
+const LabelNodeHostname = "kubernetes.io/hostname"
+
+// ============================ //
+// == Host Policy Discovery  == //
+// ============================ //
+
+// getHostLabels returns the node labels used to group the hosts.
+// Nodes sharing the same values for the configured label keys share a policy.
+func getHostLabels(node types.Node, labelKeys []string) []string {
+	if len(labelKeys) == 0 {
+		labelKeys = []string{LabelNodeHostname}
+	}
+
+	labels := []string{}
+	for _, label := range node.Labels {
+		k := strings.Split(label, "=")[0]
+		if libs.ContainsElement(labelKeys, k) {
+			labels = append(labels, label)
+		}
+	}
+
+	// never generate an empty nodeSelector, it would select every node
+	if len(labels) == 0 {
+		labels = append(labels, LabelNodeHostname+"="+node.NodeName)
+	}
+
+	return labels
+}
+
+// splitHostLogs separates the host logs of the k8s nodes from the other system logs
+func splitHostLogs(logs []types.KnoxSystemLog, nodes []types.Node) ([]types.KnoxSystemLog, []types.KnoxSystemLog) {
+	hostLogs := []types.KnoxSystemLog{}
+	otherLogs := []types.KnoxSystemLog{}
+
+	nodeNames := map[string]bool{}
+	for _, node := range nodes {
+		nodeNames[node.NodeName] = true
+	}
+
+	for _, log := range logs {
+		// kubearmor host logs are mapped to the VM namespace, the ones
+		// coming from a known k8s node are treated as host logs
+		if log.Namespace == types.PolicyDiscoveryVMNamespace && nodeNames[log.HostName] {
+			hostLogs = append(hostLogs, log)
+		} else {
+			otherLogs = append(otherLogs, log)
+		}
+	}
+
+	return hostLogs, otherLogs
+}
+
+// GenFileSetForAllHostsInCluster Generate process specific fileset across all the hosts in a cluster
+func GenFileSetForAllHostsInCluster(clusterName string, nodes []types.Node, settype string, slogs []types.KnoxSystemLog) bool {
+	res := types.ResourceSetMap{} // key: WorkloadProcess - val: Accesss File Set
+	isNetworkOp := settype == SYS_OP_NETWORK
+
+	nodeLabels := map[string]string{}
+	for _, node := range nodes {
+		nodeLabels[node.NodeName] = strings.Join(getHostLabels(node, HostNodeLabelKeys), ",")
+	}
+
+	var resource []string
+	for _, slog := range slogs {
+		labels, ok := nodeLabels[slog.HostName]
+		if !ok {
+			log.Error().Msgf("could not get node labels for hostname=%s", slog.HostName)
+			continue
+		}
+
+		wpfs := types.WorkloadProcessFileSet{
+			ClusterName: clusterName,
+			Namespace:   types.PolicyDiscoveryHostNamespace,
+			Labels:      labels,
+			FromSource:  slog.Source,
+			SetType:     settype,
+		}
+
+		if isNetworkOp {
+			resource = cleanResource(settype, slog.ResourceOrigin)
+		} else {
+			resource = cleanResource(settype, slog.Resource)
+		}
+		if len(resource) == 0 {
+			continue
+		}
+		res[wpfs] = append(res[wpfs], resource...)
+	}
+
+	return updateWorkloadProcessFileSets(res, isNetworkOp)
+}
+
+// discoverHostPolicies learns the host behaviour and updates the host policies
+func discoverHostPolicies(clusterName string, nodes []types.Node, hostLogs []types.KnoxSystemLog) {
+	if len(hostLogs) == 0 {
+		return
+	}
+
+	log.Info().Msgf("host policy discovery cluster [%s] len(hostLogs):%d", clusterName, len(hostLogs))
+
+	isWpfsDbUpdated := false
+	if SystemPolicyTypes&SYS_OP_FILE_INT > 0 {
+		fileOpLogs := getOperationLogs(SYS_OP_FILE, hostLogs)
+		isWpfsDbUpdated = GenFileSetForAllHostsInCluster(clusterName, nodes, SYS_OP_FILE, fileOpLogs) || isWpfsDbUpdated
+	}
+
+	if SystemPolicyTypes&SYS_OP_PROCESS_INT > 0 {
+		procOpLogs := getOperationLogs(SYS_OP_PROCESS, hostLogs)
+		isWpfsDbUpdated = GenFileSetForAllHostsInCluster(clusterName, nodes, SYS_OP_PROCESS, procOpLogs) || isWpfsDbUpdated
+	}
+
+	if SystemPolicyTypes&SYS_OP_NETWORK_INT > 0 {
+		netOpLogs := getOperationLogs(SYS_OP_NETWORK, hostLogs)
+		isWpfsDbUpdated = GenFileSetForAllHostsInCluster(clusterName, nodes, SYS_OP_NETWORK, netOpLogs) || isWpfsDbUpdated
+	}
+
+	if !isWpfsDbUpdated {
+		return
+	}
+
+	hostPolicies := populateKnoxSysPolicyFromWPFSDb(types.PolicyDiscoveryHostNamespace, clusterName, "", "")
+	if len(hostPolicies) > 0 {
+		UpdateSysPolicies(hostPolicies)
+		log.Info().Msgf("host policy discovery done for [%s], [%d] policies discovered", clusterName, len(hostPolicies))
+	}
+}
+
+func extractHostSystemPolicies(clustername, labels, fromsource string) []types.KubeArmorPolicy {
+	sysPols := populateKnoxSysPolicyFromWPFSDb(types.PolicyDiscoveryHostNamespace, clustername, labels, fromsource)
+	policies := plugin.ConvertKnoxSystemPolicyToKubeArmorPolicy(sysPols)
+
+	var result []types.KubeArmorPolicy
+	for _, pol := range policies {
+		if pol.Kind == types.KindKubeArmorHostPolicy {
+			result = append(result, pol)
+		}
+	}
+	return result
+}
+
+// GetHostSysPolicy returns the discovered KubeArmorHostPolicies
+func GetHostSysPolicy(clustername, labels, fromsource string) *wpb.WorkerResponse {
+	var response wpb.WorkerResponse
+
+	kubearmorHostPolicies := extractHostSystemPolicies(clustername, labels, fromsource)
+	for i := range kubearmorHostPolicies {
+		kubearmorpolicy := wpb.Policy{}
+
+		val, err := json.Marshal(&kubearmorHostPolicies[i])
+		if err != nil {
+			log.Error().Msgf("kubearmorHostPolicy json marshal failed err=%v", err.Error())
+		}
+		kubearmorpolicy.Data = val
+
+		response.Kubearmorpolicy = append(response.Kubearmorpolicy, &kubearmorpolicy)
+	}
+
+	response.Res = "OK"
+	response.Ciliumpolicy = nil
+
+	return &response
+}
@@ -0,0 +1,74 @@
+package systempolicy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/accuknox/auto-policy-discovery/src/plugin"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestNodes() []types.Node {
+	return []types.Node{
+		{
+			NodeName: "node-1",
+			Labels:   []string{"kubernetes.io/hostname=node-1", "kubernetes.io/os=linux", "node-role=worker"},
+		},
+		{
+			NodeName: "node-2",
+			Labels:   []string{"kubernetes.io/hostname=node-2", "kubernetes.io/os=linux", "node-role=worker"},
+		},
+	}
+}
+
+func TestGetHostLabels(t *testing.T) {
+	nodes := getTestNodes()
+
+	// default: group per hostname
+	assert.Equal(t, []string{"kubernetes.io/hostname=node-1"}, getHostLabels(nodes[0], nil))
+
+	// group by role, both nodes share the same labels
+	assert.Equal(t, []string{"node-role=worker"}, getHostLabels(nodes[0], []string{"node-role"}))
+	assert.Equal(t, getHostLabels(nodes[0], []string{"node-role"}), getHostLabels(nodes[1], []string{"node-role"}))
+
+	// unknown label keys fallback to the hostname
+	assert.Equal(t, []string{"kubernetes.io/hostname=node-2"}, getHostLabels(nodes[1], []string{"unknown"}))
+}
+
+func TestSplitHostLogs(t *testing.T) {
+	logs := []types.KnoxSystemLog{
+		{Namespace: types.PolicyDiscoveryVMNamespace, PodName: types.PolicyDiscoveryVMPodName, HostName: "node-1", Resource: "/usr/bin/kubelet"},
+		{Namespace: types.PolicyDiscoveryVMNamespace, PodName: types.PolicyDiscoveryVMPodName, HostName: "vm-1", Resource: "/usr/bin/sshd"},
+		{Namespace: "default", PodName: "nginx", HostName: "node-2", Resource: "/usr/sbin/nginx"},
+	}
+
+	hostLogs, otherLogs := splitHostLogs(logs, getTestNodes())
+
+	assert.Len(t, hostLogs, 1)
+	assert.Equal(t, "/usr/bin/kubelet", hostLogs[0].Resource)
+	assert.Len(t, otherLogs, 2)
+}
+
+func TestConvertWPFSToKnoxSysPolicy_Host(t *testing.T) {
+	wpfs := types.WorkloadProcessFileSet{
+		ClusterName: "default",
+		Namespace:   types.PolicyDiscoveryHostNamespace,
+		Labels:      "node-role=worker",
+		SetType:     SYS_OP_PROCESS,
+	}
+	wpfsSet := types.ResourceSetMap{wpfs: []string{"/usr/bin/kubelet"}}
+
+	policies := ConvertWPFSToKnoxSysPolicy(wpfsSet, types.PolicyNameMap{})
+
+	assert.Len(t, policies, 1)
+	assert.Equal(t, types.KindKubeArmorHostPolicy, policies[0].Kind)
+	assert.True(t, strings.HasPrefix(policies[0].Metadata["name"], "autopol-host-"))
+	assert.Equal(t, map[string]string{"node-role": "worker"}, policies[0].Spec.NodeSelector.MatchLabels)
+	assert.Empty(t, policies[0].Spec.Selector.MatchLabels)
+
+	kubearmorPolicies := plugin.ConvertKnoxSystemPolicyToKubeArmorPolicy(policies)
+	assert.Len(t, kubearmorPolicies, 1)
+	assert.Equal(t, types.KindKubeArmorHostPolicy, kubearmorPolicies[0].Kind)
+	assert.Empty(t, kubearmorPolicies[0].Metadata.Namespace)
+}
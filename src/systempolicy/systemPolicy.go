@@ -2,9 +2,6 @@ package systempolicy
 
 import (
 	"errors"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
@@ -65,6 +62,15 @@ const (
 	SOURCE_ALL = "/ALL" // for fromSource 'off'
 )
 
+const (
+	INIT_CONTAINER_MERGE    = "merge"    // learn init containers with the app containers
+	INIT_CONTAINER_SEPARATE = "separate" // learn init containers in their own container scoped policy
+	INIT_CONTAINER_IGNORE   = "ignore"   // drop the init container logs
+
+	JOB_MODE_POD      = "pod"      // learn job pods by their labels
+	JOB_MODE_TEMPLATE = "template" // learn cronjob pods per job template across runs
+)
+
 // ====================== //
 // == Global Variables == //
 // ====================== //
@@ -92,72 +98,22 @@ var SystemLogFilters []types.SystemLogFilter
 var ProcessFromSource bool
 var FileFromSource bool
 
+var HostPolicy bool
+var HostNodeLabelKeys []string
+
+var ContainerPolicy bool
+var InitContainerMode string
+var JobMode string
+
+var AnomalyDetection bool
+var StabilisationPeriod time.Duration
+
 // init Function
 func init() {
 	SystemWorkerStatus = STATUS_IDLE
 	SystemStopChan = make(chan struct{})
 }
 
-// ====================== //
-// == Internal Testing == //
-// ====================== //
-
-func ReplaceMultiubuntuPodName(logs []types.KnoxSystemLog, pods []types.Pod) {
-	var pod1Name, pod2Name, pod3Name, pod4Name, pod5Name string
-
-	for _, pod := range pods {
-		if strings.Contains(pod.PodName, "ubuntu-1-deployment") {
-			pod1Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-2-deployment") {
-			pod2Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-3-deployment") {
-			pod3Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-4-deployment") {
-			pod4Name = pod.PodName
-		}
-
-		if strings.Contains(pod.PodName, "ubuntu-5-deployment") {
-			pod5Name = pod.PodName
-		}
-	}
-
-	for i, log := range logs {
-		if strings.Contains(log.PodName, "ubuntu-1-deployment") {
-			logs[i].PodName = pod1Name
-		}
-
-		///
-
-		if strings.Contains(log.PodName, "ubuntu-2-deployment") {
-			logs[i].PodName = pod2Name
-		}
-
-		///
-
-		if strings.Contains(log.PodName, "ubuntu-3-deployment") {
-			logs[i].PodName = pod3Name
-		}
-
-		///
-
-		if strings.Contains(log.PodName, "ubuntu-4-deployment") {
-			logs[i].PodName = pod4Name
-		}
-
-		///
-
-		if strings.Contains(log.PodName, "ubuntu-5-deployment") {
-			logs[i].PodName = pod5Name
-		}
-	}
-}
-
 // ========================== //
 // == Inner Structure Type == //
 // ========================== //
@@ -173,49 +129,15 @@ type SysLogKey struct {
 // ================ //
 
 func getSystemLogs() map[types.KnoxSystemLog]bool {
-	systemLogs := []types.KnoxSystemLog{}
-
 	if SystemLogFrom == "file" {
-		// =============================== //
-		// == File (.json) for testing  == //
-		// =============================== //
-
-		jsonLogs := []map[string]interface{}{}
-		log.Info().Msg("Get system logs from the json file : " + SystemLogFile)
+		// ============================ //
+		// == File (karmor captures) == //
+		// ============================ //
+		log.Info().Msg("Get system logs from the file : " + SystemLogFile)
 
-		// Opens jsonFile
-		logFile, err := os.Open(filepath.Clean(SystemLogFile))
-		if err != nil {
-			log.Error().Msg(err.Error())
-			if err := logFile.Close(); err != nil {
-				log.Error().Msg(err.Error())
-			}
-			return nil
-		}
-
-		byteValue, err := ioutil.ReadAll(logFile)
-		if err != nil {
-			log.Error().Msg(err.Error())
-		}
-
-		if err := json.Unmarshal(byteValue, &jsonLogs); err != nil {
-			log.Error().Msg(err.Error())
-			return nil
-		}
-
-		// raw json --> knoxSystemLog
-		if CfgDB.DBDriver == "mysql" {
-			systemLogs = plugin.ConvertMySQLKubeArmorLogsToKnoxSystemLogs(jsonLogs)
-		} else if CfgDB.DBDriver == "sqlite3" {
-			systemLogs = plugin.ConvertSQLiteKubeArmorLogsToKnoxSystemLogs(jsonLogs)
-		}
-
-		// replace the pod names in prepared-logs with the working pod names
-		pods := cluster.GetPodsFromK8sClient()
-		ReplaceMultiubuntuPodName(systemLogs, pods)
-
-		if err := logFile.Close(); err != nil {
-			log.Error().Msg(err.Error())
+		// get the logs appended to the file (or directory) since the last run
+		for _, systemLog := range plugin.GetSystemLogsFromFile(SystemLogFile) {
+			SystemLogMap[systemLog] = true
 		}
 	} else if SystemLogFrom == "kubearmor" {
 		// ================================ //
@@ -281,6 +203,14 @@ func WriteSystemPoliciesToFile_Ext(namespace, clustername, labels, fromsource st
 		fname := "kubearmor_policies_" + pol.Metadata.Namespace + "_" + locSrc
 		libs.WriteKubeArmorPolicyToYamlFile(fname, []types.KubeArmorPolicy{pol})
 	}
+
+	if namespace == "" || namespace == types.PolicyDiscoveryHostNamespace {
+		kubearmorHostPolicies := extractHostSystemPolicies(clustername, labels, fromsource)
+		for _, pol := range kubearmorHostPolicies {
+			fname := "kubearmor_host_policies_" + pol.Metadata.Name
+			libs.WriteKubeArmorPolicyToYamlFile(fname, []types.KubeArmorPolicy{pol})
+		}
+	}
 }
 
 func WriteSystemPoliciesToFile(namespace, clustername, labels, fromsource string, includeNetwork bool) {
@@ -296,6 +226,10 @@ func GetSysPolicy(namespace, clustername, labels, fromsource string, includeNetw
 
 	kubearmorK8SPolicies := extractK8SSystemPolicies(namespace, clustername, labels, fromsource, includeNetwork)
 	kubearmorVMPolicies, _ := extractVMSystemPolicies(types.PolicyDiscoveryVMNamespace, clustername, labels, fromsource)
+	kubearmorHostPolicies := []types.KubeArmorPolicy{}
+	if namespace == "" || namespace == types.PolicyDiscoveryHostNamespace {
+		kubearmorHostPolicies = extractHostSystemPolicies(clustername, labels, fromsource)
+	}
 
 	var response wpb.WorkerResponse
 
@@ -325,6 +259,19 @@ func GetSysPolicy(namespace, clustername, labels, fromsource string, includeNetw
 		response.Kubearmorpolicy = append(response.Kubearmorpolicy, &kubearmorpolicy)
 	}
 
+	// system policy for k8s hosts
+	for i := range kubearmorHostPolicies {
+		kubearmorpolicy := wpb.Policy{}
+
+		val, err := json.Marshal(&kubearmorHostPolicies[i])
+		if err != nil {
+			log.Error().Msgf("kubearmorHostPolicy json marshal failed err=%v", err.Error())
+		}
+		kubearmorpolicy.Data = val
+
+		response.Kubearmorpolicy = append(response.Kubearmorpolicy, &kubearmorpolicy)
+	}
+
 	response.Res = "OK"
 	response.Ciliumpolicy = nil
 
@@ -337,6 +284,10 @@ func extractK8SSystemPolicies(namespace, clustername, labels, fromsource string,
 
 	var result []types.KubeArmorPolicy
 	for _, pol := range policies {
+		if pol.Kind == types.KindKubeArmorHostPolicy {
+			continue
+		}
+
 		if pol.Metadata.Namespace != types.PolicyDiscoveryVMNamespace {
 			if !includeNetwork {
 				pol.Spec.Network = types.NetworkRule{}
@@ -770,7 +721,11 @@ func mergeFromSource(pols []types.KnoxSystemPolicy) []types.KnoxSystemPolicy {
 func mergeSysPolicies(pols []types.KnoxSystemPolicy) []types.KnoxSystemPolicy {
 	var results []types.KnoxSystemPolicy
 	for _, pol := range pols {
-		pol.Metadata["name"] = "autopol-system-" +
+		namePrefix := "autopol-system-"
+		if pol.Kind == types.KindKubeArmorHostPolicy {
+			namePrefix = "autopol-host-"
+		}
+		pol.Metadata["name"] = namePrefix +
 			strconv.FormatUint(uint64(common.HashInt(pol.Metadata["labels"]+pol.Metadata["namespace"]+pol.Metadata["clustername"])), 10)
 		i := checkIfMetadataMatches(pol, results)
 		if i < 0 {
@@ -866,12 +821,21 @@ func ConvertWPFSToKnoxSysPolicy(wpfsSet types.ResourceSetMap, pnMap types.Policy
 		policy.Metadata["labels"] = wpfs.Labels
 		policy.Metadata["name"] = pnMap[wpfs]
 
+		selector := policy.Spec.Selector.MatchLabels
+		if wpfs.Namespace == types.PolicyDiscoveryHostNamespace {
+			// host policies select the nodes instead of the pods
+			policy.Kind = types.KindKubeArmorHostPolicy
+			policy.Spec.Selector = types.Selector{}
+			policy.Spec.NodeSelector.MatchLabels = map[string]string{}
+			selector = policy.Spec.NodeSelector.MatchLabels
+		}
+
 		if wpfs.Labels != "" {
 			labels := strings.Split(wpfs.Labels, ",")
 			for _, label := range labels {
 				k := strings.Split(label, "=")[0]
 				v := strings.Split(label, "=")[1]
-				policy.Spec.Selector.MatchLabels[k] = v
+				selector[k] = v
 			}
 		}
 
@@ -1072,6 +1036,16 @@ func InitSysPolicyDiscoveryConfiguration() {
 
 	ProcessFromSource = cfg.GetCfgSystemProcFromSource()
 	FileFromSource = cfg.GetCfgSystemFileFromSource()
+
+	HostPolicy = cfg.GetCfgSystemHostPolicy()
+	HostNodeLabelKeys = cfg.GetCfgSystemHostNodeLabelKeys()
+
+	ContainerPolicy = cfg.GetCfgSystemContainerPolicy()
+	InitContainerMode = cfg.GetCfgSystemInitContainerMode()
+	JobMode = cfg.GetCfgSystemJobMode()
+
+	AnomalyDetection = cfg.GetCfgSystemAnomalyDetection()
+	StabilisationPeriod = getStabilisationPeriod(cfg.GetCfgSystemStabilisationPeriod())
 }
 
 func PopulateSystemPoliciesFromSystemLogs(sysLogMap map[types.KnoxSystemLog]bool) []types.KnoxSystemPolicy {
@@ -1100,6 +1074,19 @@ func PopulateSystemPoliciesFromSystemLogs(sysLogMap map[types.KnoxSystemLog]bool
 		// filter system logs from configuration
 		cfgFilteredLogs := FilterSystemLogsByConfig(sysLogs, pods)
 
+		// exclude the init container behaviour from the learnt policies
+		if InitContainerMode == INIT_CONTAINER_IGNORE {
+			cfgFilteredLogs = filterInitContainerLogs(cfgFilteredLogs, pods)
+		}
+
+		// discover host policies from the host logs of k8s nodes
+		if HostPolicy {
+			nodes := cluster.GetNodes(clusterName)
+			var hostLogs []types.KnoxSystemLog
+			hostLogs, cfgFilteredLogs = splitHostLogs(cfgFilteredLogs, nodes)
+			discoverHostPolicies(clusterName, nodes, hostLogs)
+		}
+
 		// iterate sys log key := [namespace + pod_name]
 		nsPodLogs := clusteringSystemLogsByNamespacePod(cfgFilteredLogs)
 
@@ -1297,7 +1284,6 @@ func GenFileSetForAllPodsInCluster(clusterName string, pods []types.Pod, settype
 	res := types.ResourceSetMap{} // key: WorkloadProcess - val: Accesss File Set
 	wpfs := types.WorkloadProcessFileSet{}
 	isNetworkOp := false
-	status := false
 	if settype == SYS_OP_NETWORK {
 		isNetworkOp = true // for network logs, need full ResourceOrigin to do regexp matching in getProtocolType()
 	}
@@ -1308,16 +1294,13 @@ func GenFileSetForAllPodsInCluster(clusterName string, pods []types.Pod, settype
 		wpfs.Namespace = slog.Namespace
 		wpfs.FromSource = slog.Source
 		wpfs.SetType = settype
-		labels, err := GetPodLabels(slog.ClusterName, slog.PodName, slog.Namespace, pods)
+		pod, err := getPodInstance(SysLogKey{Namespace: slog.Namespace, PodName: slog.PodName}, pods)
 		if err != nil {
 			log.Error().Msgf("could not get pod labels for podname=%s ns=%s", slog.PodName, slog.Namespace)
 			continue
 		}
 
-		if slog.Namespace == types.PolicyDiscoveryContainerNamespace {
-			labels = append(labels, "kubearmor.io/container.name="+slog.ContainerName)
-		}
-
+		labels := getWorkloadLabels(pod, slog.ContainerName)
 		wpfs.Labels = strings.Join(labels[:], ",")
 
 		if isNetworkOp {
@@ -1331,6 +1314,13 @@ func GenFileSetForAllPodsInCluster(clusterName string, pods []types.Pod, settype
 		res[wpfs] = append(res[wpfs], resource...)
 	}
 
+	return updateWorkloadProcessFileSets(res, isNetworkOp)
+}
+
+// updateWorkloadProcessFileSets merges the resource sets with the WPFS entries in DB
+func updateWorkloadProcessFileSets(res types.ResourceSetMap, isNetworkOp bool) bool {
+	status := false
+
 	var mergedfs []string
 	for wpfs, fs := range res {
 		out, _, err := libs.GetWorkloadProcessFileSet(CfgDB, wpfs)
@@ -1342,6 +1332,11 @@ func GenFileSetForAllPodsInCluster(clusterName string, pods []types.Pod, settype
 		if len(out[wpfs]) == 0 {
 			dbEntry = false
 		}
+		if dbEntry && AnomalyDetection && isStableProfile(wpfs) {
+			// stable profiles are not updated anymore, deviations are raised as anomalies
+			raiseAnomalies(getAnomalies(wpfs, fs, out[wpfs]))
+			continue
+		}
 		mergedfs = removeDuplicates(append(fs, out[wpfs]...))
 		if !isNetworkOp {
 			// Path aggregation makes sense for file, process operations only
@@ -1377,13 +1372,8 @@ func GenFileSetForAllPodsInCluster(clusterName string, pods []types.Pod, settype
 		} else {
 			if !reflect.DeepEqual(mergedfs, out[wpfs]) {
 				log.Info().Msgf("updating wpfs db entry for wpfs=%+v", wpfs)
-				if CfgDB.DBDriver == "mysql" {
-					err = libs.UpdateWorkloadProcessFileSetMySQL(CfgDB, wpfs, mergedfs)
-					status = true
-				} else if CfgDB.DBDriver == "sqlite3" {
-					err = libs.UpdateWorkloadProcessFileSetSQLite(CfgDB, wpfs, mergedfs)
-					status = true
-				}
+				err = libs.UpdateWorkloadProcessFileSet(CfgDB, wpfs, mergedfs)
+				status = true
 			}
 		}
 		if err != nil {
@@ -1414,6 +1404,11 @@ func InsertSysPoliciesYamlToDB(policies []types.KnoxSystemPolicy) {
 			continue
 		}
 
+		labels := kubearmorPolicy.Spec.Selector.MatchLabels
+		if kubearmorPolicy.Kind == types.KindKubeArmorHostPolicy {
+			labels = kubearmorPolicy.Spec.NodeSelector.MatchLabels
+		}
+
 		policyYaml := types.PolicyYaml{
 			Type:        types.PolicyTypeSystem,
 			Kind:        kubearmorPolicy.Kind,
@@ -1422,7 +1417,7 @@ func InsertSysPoliciesYamlToDB(policies []types.KnoxSystemPolicy) {
 			Cluster:     cfg.GetCfgClusterName(),
 			WorkspaceId: cfg.GetCfgWorkspaceId(),
 			ClusterId:   cfg.GetCfgClusterId(),
-			Labels:      kubearmorPolicy.Spec.Selector.MatchLabels,
+			Labels:      labels,
 			Yaml:        yamlBytes,
 		}
 		res = append(res, policyYaml)
@@ -1466,9 +1461,14 @@ func DiscoverSystemPolicyMain() {
 // ==================================== //
 
 func StartSystemLogRcvr() {
+	stopChan := SystemStopChan
+
 	for {
-		if !plugin.KubeArmorRelayStarted {
-			if cfg.GetCfgSystemLogFrom() == "kubearmor" {
+		if cfg.GetCfgSystemLogFrom() == "kubearmor" {
+			relayCfg := cfg.GetCfgKubeArmor()
+
+			// without endpoints configured, look up the relay of the local cluster
+			if len(relayCfg.Endpoints) == 0 && !plugin.KubeArmorRelayStarted {
 				url := cluster.GetKubearmorRelayURL()
 				if url == "" {
 					log.Error().Msg("kubearmor-relay url not found, retrying...")
@@ -1480,24 +1480,30 @@ func StartSystemLogRcvr() {
 						}
 					}
 				}
-				if url == "" {
-					url = cfg.CurrentCfg.ConfigKubeArmorRelay.KubeArmorRelayURL
+				if url != "" {
+					relayCfg.KubeArmorRelayURL = url
 				}
-				plugin.StartKubeArmorRelay(SystemStopChan, types.ConfigKubeArmorRelay{
-					KubeArmorRelayURL:  url,
-					KubeArmorRelayPort: cfg.CurrentCfg.ConfigKubeArmorRelay.KubeArmorRelayPort,
-				})
-			} else if cfg.GetCfgSystemLogFrom() == "feed-consumer" {
-				fc.ConsumerMutex.Lock()
-				fc.StartConsumer()
-				fc.ConsumerMutex.Unlock()
 			}
-			time.Sleep(time.Second * 2)
+
+			plugin.StartKubeArmorRelay(stopChan, relayCfg)
+		} else if cfg.GetCfgSystemLogFrom() == "feed-consumer" {
+			fc.ConsumerMutex.Lock()
+			fc.StartConsumer()
+			fc.ConsumerMutex.Unlock()
+		}
+
+		// the receiver is stopped with its worker
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(time.Second * 2):
 		}
 	}
 }
 
 func StartSystemCronJob() {
+	// a stopped worker can be started again, e.g., by the next elected leader
+	SystemStopChan = make(chan struct{})
 	go StartSystemLogRcvr()
 
 	// init cron job
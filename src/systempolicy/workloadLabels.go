@@ -0,0 +1,80 @@
+package systempolicy
+
+import (
+	"strings"
+
+	"github.com/accuknox/auto-policy-discovery/src/libs"
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+)
+
+// jobRunLabelKeys are the labels changing on every run of a cronjob
+var jobRunLabelKeys = []string{
+	types.LabelJobName,
+	types.LabelBatchJobName,
+	types.LabelBatchControllerUid,
+	types.LabelJobControllerUid,
+}
+
+// ============================= //
+// == Workload Label Handling == //
+// ============================= //
+
+func isInitContainer(pod types.Pod, containerName string) bool {
+	return libs.ContainsElement(pod.InitContainers, containerName)
+}
+
+// filterInitContainerLogs drops the system logs generated by init containers
+func filterInitContainerLogs(logs []types.KnoxSystemLog, pods []types.Pod) []types.KnoxSystemLog {
+	filteredLogs := []types.KnoxSystemLog{}
+
+	for _, log := range logs {
+		pod, err := getPodInstance(SysLogKey{Namespace: log.Namespace, PodName: log.PodName}, pods)
+		if err == nil && isInitContainer(pod, log.ContainerName) {
+			continue
+		}
+		filteredLogs = append(filteredLogs, log)
+	}
+
+	return filteredLogs
+}
+
+// getJobTemplateLabels strips the per run labels of the cronjob pods so that
+// the pods of all the runs share the same workload labels
+func getJobTemplateLabels(pod types.Pod) []string {
+	labels := []string{}
+	for _, label := range pod.Labels {
+		k := strings.Split(label, "=")[0]
+		if libs.ContainsElement(jobRunLabelKeys, k) {
+			continue
+		}
+		labels = append(labels, label)
+	}
+
+	// an empty selector would match every pod in the namespace,
+	// keep the labels of the run in that case
+	if len(labels) == 0 {
+		log.Warn().Msgf("cronjob %s/%s has no template labels, learning per run", pod.Namespace, pod.OwnerName)
+		return pod.Labels
+	}
+
+	return labels
+}
+
+// getWorkloadLabels returns the labels identifying the workload the container belongs to
+func getWorkloadLabels(pod types.Pod, containerName string) []string {
+	labels := append([]string{}, pod.Labels...)
+
+	if JobMode == JOB_MODE_TEMPLATE && pod.OwnerKind == "CronJob" {
+		labels = getJobTemplateLabels(pod)
+	}
+
+	scopeToContainer := ContainerPolicy ||
+		pod.Namespace == types.PolicyDiscoveryContainerNamespace ||
+		(InitContainerMode == INIT_CONTAINER_SEPARATE && isInitContainer(pod, containerName))
+
+	if scopeToContainer {
+		labels = append(labels, types.LabelKubeArmorContainerName+"="+containerName)
+	}
+
+	return labels
+}
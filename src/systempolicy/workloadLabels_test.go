@@ -0,0 +1,77 @@
+package systempolicy
+
+import (
+	"testing"
+
+	types "github.com/accuknox/auto-policy-discovery/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestCronJobPods() []types.Pod {
+	return []types.Pod{
+		{
+			Namespace:      "default",
+			PodName:        "backup-28012340-abcde",
+			Labels:         []string{"app=backup", "job-name=backup-28012340"},
+			InitContainers: []string{"fetch-config"},
+			OwnerKind:      "CronJob",
+			OwnerName:      "backup",
+		},
+		{
+			Namespace:      "default",
+			PodName:        "backup-28012350-fghij",
+			Labels:         []string{"app=backup", "job-name=backup-28012350"},
+			InitContainers: []string{"fetch-config"},
+			OwnerKind:      "CronJob",
+			OwnerName:      "backup",
+		},
+	}
+}
+
+func TestGetWorkloadLabels_JobTemplate(t *testing.T) {
+	defer func() { JobMode = "" }()
+	pods := getTestCronJobPods()
+
+	// default: every run is learnt separately
+	assert.NotEqual(t, getWorkloadLabels(pods[0], "backup"), getWorkloadLabels(pods[1], "backup"))
+
+	JobMode = JOB_MODE_TEMPLATE
+	assert.Equal(t, []string{"app=backup"}, getWorkloadLabels(pods[0], "backup"))
+	assert.Equal(t, getWorkloadLabels(pods[0], "backup"), getWorkloadLabels(pods[1], "backup"))
+
+	// no template labels, keep the labels of the run
+	pod := pods[0]
+	pod.Labels = []string{"job-name=backup-28012340"}
+	assert.Equal(t, []string{"job-name=backup-28012340"}, getWorkloadLabels(pod, "backup"))
+}
+
+func TestGetWorkloadLabels_Containers(t *testing.T) {
+	defer func() {
+		InitContainerMode = ""
+		ContainerPolicy = false
+	}()
+	pod := getTestCronJobPods()[0]
+
+	// default: init containers are merged with the app containers
+	assert.Equal(t, getWorkloadLabels(pod, "backup"), getWorkloadLabels(pod, "fetch-config"))
+
+	InitContainerMode = INIT_CONTAINER_SEPARATE
+	assert.Equal(t, pod.Labels, getWorkloadLabels(pod, "backup"))
+	assert.Contains(t, getWorkloadLabels(pod, "fetch-config"), types.LabelKubeArmorContainerName+"=fetch-config")
+
+	ContainerPolicy = true
+	assert.Contains(t, getWorkloadLabels(pod, "backup"), types.LabelKubeArmorContainerName+"=backup")
+}
+
+func TestFilterInitContainerLogs(t *testing.T) {
+	pods := getTestCronJobPods()
+	logs := []types.KnoxSystemLog{
+		{Namespace: "default", PodName: pods[0].PodName, ContainerName: "fetch-config", Resource: "/usr/bin/curl"},
+		{Namespace: "default", PodName: pods[0].PodName, ContainerName: "backup", Resource: "/usr/bin/tar"},
+	}
+
+	filteredLogs := filterInitContainerLogs(logs, pods)
+
+	assert.Len(t, filteredLogs, 1)
+	assert.Equal(t, "/usr/bin/tar", filteredLogs[0].Resource)
+}
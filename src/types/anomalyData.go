@@ -0,0 +1,36 @@
+package types
+
+// Severity of the anomalies
+const (
+	AnomalySeverityLow      = "low"
+	AnomalySeverityMedium   = "medium"
+	AnomalySeverityHigh     = "high"
+	AnomalySeverityCritical = "critical"
+)
+
+// ==================== //
+// == System Anomaly == //
+// ==================== //
+
+// SystemAnomaly is a process/file/network access deviating from a stable WPFS profile
+type SystemAnomaly struct {
+	ClusterName   string `json:"cluster_name" bson:"cluster_name"`
+	Namespace     string `json:"namespace" bson:"namespace"`
+	ContainerName string `json:"container_name" bson:"container_name"`
+	Labels        string `json:"labels" bson:"labels"`
+	FromSource    string `json:"from_source" bson:"from_source"`
+	Operation     string `json:"operation" bson:"operation"`
+	Resource      string `json:"resource" bson:"resource"`
+	Severity      string `json:"severity" bson:"severity"`
+	Count         int32  `json:"count" bson:"count"`
+	FirstSeen     int64  `json:"first_seen" bson:"first_seen"`
+	LastSeen      int64  `json:"last_seen" bson:"last_seen"`
+}
+
+// AnomalyFilter is used for GetAnomaly RPC in Discovery Service.
+type AnomalyFilter struct {
+	Cluster   string
+	Namespace string
+	Labels    LabelMap
+	Severity  string // minimum severity
+}
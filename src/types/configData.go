@@ -8,16 +8,57 @@ type ConfigDB struct {
 	DBPass       string `json:"db_pass,omitempty" bson:"db_pass,omitempty"`
 	DBName       string `json:"db_name,omitempty" bson:"db_name,omitempty"`
 	SQLiteDBPath string `json:"sqlite_db_path,omitempty" bson:"sqlite_db_path,omitempty"`
+
+	// the pool of the long-lived handle of the database, ConnMaxLifetime in seconds
+	MaxOpenConns    int `json:"max_open_conns,omitempty" bson:"max_open_conns,omitempty"`
+	MaxIdleConns    int `json:"max_idle_conns,omitempty" bson:"max_idle_conns,omitempty"`
+	ConnMaxLifetime int `json:"conn_max_lifetime,omitempty" bson:"conn_max_lifetime,omitempty"`
+
+	// the store of the raw network and system logs, "sql" or "bolt"; the bolt store keeps the
+	// logs in the segment files of LogStorePath, each of LogSegmentDuration seconds
+	LogStore           string `json:"log_store,omitempty" bson:"log_store,omitempty"`
+	LogStorePath       string `json:"log_store_path,omitempty" bson:"log_store_path,omitempty"`
+	LogSegmentDuration int64  `json:"log_segment_duration,omitempty" bson:"log_segment_duration,omitempty"`
+
+	// the encryption at rest of the sensitive columns of SQLite
+	Encryption ConfigEncryption `json:"encryption,omitempty" bson:"encryption,omitempty"`
+}
+
+// ConfigEncryption is the key ring of the encryption at rest, the keys are the files of KeyDir,
+// as a mounted Secret, or else the data of the Secret "namespace/name". ActiveKey encrypts,
+// the other keys only decrypt the values until they are re-encrypted with ActiveKey
+type ConfigEncryption struct {
+	Enable    bool   `json:"enable,omitempty" bson:"enable,omitempty"`
+	KeyDir    string `json:"key_dir,omitempty" bson:"key_dir,omitempty"`
+	Secret    string `json:"secret,omitempty" bson:"secret,omitempty"`
+	ActiveKey string `json:"active_key,omitempty" bson:"active_key,omitempty"`
+}
+
+// RelayEndpoint is a hubble or kubearmor relay address and the cluster it serves
+type RelayEndpoint struct {
+	ClusterName string `json:"cluster_name,omitempty" bson:"cluster_name,omitempty"`
+	URL         string `json:"url,omitempty" bson:"url,omitempty"`
+	Port        string `json:"port,omitempty" bson:"port,omitempty"`
 }
 
 type ConfigCiliumHubble struct {
 	HubbleURL  string `json:"hubble_url,omitempty" bson:"hubble_url,omitempty"`
 	HubblePort string `json:"hubble_port,omitempty" bson:"hubble_port,omitempty"`
+
+	// Endpoints, if set, replace HubbleURL/HubblePort. Endpoints of the same
+	// cluster fail over in order; with FanIn every cluster is streamed concurrently
+	Endpoints []RelayEndpoint `json:"endpoints,omitempty" bson:"endpoints,omitempty"`
+	FanIn     bool            `json:"fan_in,omitempty" bson:"fan_in,omitempty"`
 }
 
 type ConfigKubeArmorRelay struct {
 	KubeArmorRelayURL  string `json:"kubearmor_url,omitempty" bson:"kubearmor_url,omitempty"`
 	KubeArmorRelayPort string `json:"kubearmor_port,omitempty" bson:"kubearmor_port,omitempty"`
+
+	// Endpoints, if set, replace KubeArmorRelayURL/KubeArmorRelayPort. Endpoints of the
+	// same cluster fail over in order; with FanIn every cluster is streamed concurrently
+	Endpoints []RelayEndpoint `json:"endpoints,omitempty" bson:"endpoints,omitempty"`
+	FanIn     bool            `json:"fan_in,omitempty" bson:"fan_in,omitempty"`
 }
 
 type NetworkLogFilter struct {
@@ -38,12 +79,19 @@ type ConfigNetworkPolicy struct {
 	NetworkLogLimit  int
 	NetworkLogFrom   string `json:"network_log_from,omitempty" bson:"network_log_from,omitempty"`
 	NetworkLogFile   string `json:"network_log_file,omitempty" bson:"network_log_file,omitempty"`
+	EnvoyReceiver    string `json:"envoy_receiver,omitempty" bson:"envoy_receiver,omitempty"`
 	NetworkPolicyTo  string `json:"network_policy_to,omitempty" bson:"network_policy_to,omitempty"`
 	NetworkPolicyDir string `json:"network_policy_dir,omitempty" bson:"network_policy_dir,omitempty"`
 
+	PolicyGapMaxAge   int `json:"policy_gap_max_age,omitempty" bson:"policy_gap_max_age,omitempty"`
+	PolicyGapMaxCount int `json:"policy_gap_max_count,omitempty" bson:"policy_gap_max_count,omitempty"`
+
 	NsFilter    []string `json:"network_policy_ns_filter,omitempty" bson:"network_policy_ns_filter,omitempty"`
 	NsNotFilter []string `json:"network_policy_ns_not_filter,omitempty" bson:"network_policy_ns_not_filter,omitempty"`
 
+	VerdictFilter    []string `json:"network_policy_verdict_filter,omitempty" bson:"network_policy_verdict_filter,omitempty"`
+	VerdictNotFilter []string `json:"network_policy_verdict_not_filter,omitempty" bson:"network_policy_verdict_not_filter,omitempty"`
+
 	NetPolicyTypes     int `json:"network_policy_types,omitempty" bson:"network_policy_types,omitempty"`
 	NetPolicyRuleTypes int `json:"network_policy_rule_types,omitempty" bson:"network_policy_rule_types,omitempty"`
 	NetPolicyCIDRBits  int `json:"network_policy_cidrbits,omitempty" bson:"network_policy_cidrbits,omitempty"`
@@ -89,6 +137,34 @@ type ConfigSystemPolicy struct {
 
 	ProcessFromSource bool `json:"system_policy_proc_fromsource,omitempty" bson:"system_policy_proc_fromsource,omitempty"`
 	FileFromSource    bool `json:"system_policy_file_fromsource,omitempty" bson:"system_policy_file_fromsource,omitempty"`
+
+	HostPolicy        bool     `json:"system_host_policy,omitempty" bson:"system_host_policy,omitempty"`
+	HostNodeLabelKeys []string `json:"system_host_node_label_keys,omitempty" bson:"system_host_node_label_keys,omitempty"`
+
+	ContainerPolicy   bool   `json:"system_container_policy,omitempty" bson:"system_container_policy,omitempty"`
+	InitContainerMode string `json:"system_init_container_mode,omitempty" bson:"system_init_container_mode,omitempty"`
+	JobMode           string `json:"system_job_mode,omitempty" bson:"system_job_mode,omitempty"`
+
+	AnomalyDetection    bool   `json:"system_anomaly_detection,omitempty" bson:"system_anomaly_detection,omitempty"`
+	StabilisationPeriod string `json:"system_stabilisation_period,omitempty" bson:"system_stabilisation_period,omitempty"`
+}
+
+type PathPrefixThreshold struct {
+	Prefix    string `json:"prefix,omitempty" bson:"prefix,omitempty"`
+	Threshold int    `json:"threshold,omitempty" bson:"threshold,omitempty"`
+}
+
+type PathNormalizer struct {
+	Pattern     string `json:"pattern,omitempty" bson:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty" bson:"replacement,omitempty"`
+}
+
+type ConfigPathAggregation struct {
+	Threshold        int                   `json:"threshold,omitempty" bson:"threshold,omitempty"`
+	PrefixThresholds []PathPrefixThreshold `json:"prefix_thresholds,omitempty" bson:"prefix_thresholds,omitempty"`
+	NeverCollapse    []string              `json:"never_collapse,omitempty" bson:"never_collapse,omitempty"`
+	AlwaysCollapse   []string              `json:"always_collapse,omitempty" bson:"always_collapse,omitempty"`
+	Normalizers      []PathNormalizer      `json:"normalizers,omitempty" bson:"normalizers,omitempty"`
 }
 
 type ConfigAdmissionControllerPolicy struct {
@@ -119,9 +195,36 @@ type SummaryJobsConfig struct {
 }
 
 type ConfigPurgeOldDBEntries struct {
-	Enable              bool     `json:"enable,omitempty" bson:"enable,omitempty"`
-	CronJobTimeInterval string   `json:"cronjob_time_interval,omitempty" bson:"cronjob_time_interval,omitempty"`
-	DBName              []string `json:"db_name,omitempty" bson:"db_name,omitempty"`
+	Enable              bool                       `json:"enable,omitempty" bson:"enable,omitempty"`
+	CronJobTimeInterval string                     `json:"cronjob_time_interval,omitempty" bson:"cronjob_time_interval,omitempty"`
+	DBName              []string                   `json:"db_name,omitempty" bson:"db_name,omitempty"`
+	Retention           map[string]ConfigRetention `json:"retention,omitempty" bson:"retention,omitempty"` // by table name
+	Downsampling        ConfigDownsampling         `json:"downsampling,omitempty" bson:"downsampling,omitempty"`
+}
+
+// ConfigRetention is the retention policy of a table, zero keeps the rows
+type ConfigRetention struct {
+	MaxAge  int64 `json:"max_age,omitempty" bson:"max_age,omitempty"` // sec
+	MaxRows int64 `json:"max_rows,omitempty" bson:"max_rows,omitempty"`
+}
+
+// ConfigDownsampling rolls the old summaries up into hourly and the old hourly rollups into daily ones
+type ConfigDownsampling struct {
+	Enable      bool  `json:"enable,omitempty" bson:"enable,omitempty"`
+	HourlyAfter int64 `json:"hourly_after,omitempty" bson:"hourly_after,omitempty"` // sec
+	DailyAfter  int64 `json:"daily_after,omitempty" bson:"daily_after,omitempty"`   // sec
+}
+
+// ConfigLeaderElection elects the replica running the discovery workers with the Lease
+// Namespace/LeaseName, the other replicas only serve the gRPC APIs
+type ConfigLeaderElection struct {
+	Enable        bool   `json:"enable,omitempty" bson:"enable,omitempty"`
+	LeaseName     string `json:"lease_name,omitempty" bson:"lease_name,omitempty"`
+	Namespace     string `json:"namespace,omitempty" bson:"namespace,omitempty"`
+	Identity      string `json:"identity,omitempty" bson:"identity,omitempty"`             // default: hostname
+	LeaseDuration int64  `json:"lease_duration,omitempty" bson:"lease_duration,omitempty"` // sec
+	RenewDeadline int64  `json:"renew_deadline,omitempty" bson:"renew_deadline,omitempty"` // sec
+	RetryPeriod   int64  `json:"retry_period,omitempty" bson:"retry_period,omitempty"`     // sec
 }
 
 type ConfigRecommendPolicy struct {
@@ -152,5 +255,7 @@ type Configuration struct {
 	ConfigObservability             ConfigObservability             `json:"config_observability,omitempty" bson:"config_observability,omitempty"`
 	ConfigPurgeOldDBEntries         ConfigPurgeOldDBEntries         `json:"config_purge_old_db_entries,omitempty" bson:"config_purge_old_db_entries,omitempty"`
 	ConfigRecommendPolicy           ConfigRecommendPolicy           `json:"config_recommend_policy,omitempty" bson:"config_recommend_policy,omitempty"`
+	ConfigPathAggregation           ConfigPathAggregation           `json:"config_path_aggregation,omitempty" bson:"config_path_aggregation,omitempty"`
+	ConfigLeaderElection            ConfigLeaderElection            `json:"config_leader_election,omitempty" bson:"config_leader_election,omitempty"`
 	ConfigAutoDepolyDiscoveredPolicy bool `json:"config_dsp,omitempty" bson:"config_dsp,omitempty"`
 }
@@ -7,6 +7,9 @@ const (
 	PolicyDiscoveryVMNamespace = "accuknox-vm-namespace"
 	PolicyDiscoveryVMPodName   = "accuknox-vm-podname"
 
+	// KubeArmor k8s host (node)
+	PolicyDiscoveryHostNamespace = "accuknox-host-namespace"
+
 	// KubeArmor container
 	PolicyDiscoveryContainerNamespace = "container_namespace"
 	PolicyDiscoveryContainerPodName   = "container_podname"
@@ -36,6 +39,7 @@ const (
 	PolicyTypeNetwork                    = "network"
 	PolicyTypeAdmissionController        = "admission-controller"
 	PolicyTypeAdmissionControllerGeneric = "admission-controller-generic"
+	PolicyTypePolicyGap                  = "policy-gap"
 
 	// Hardening policy
 	HardeningPolicy = "harden"
@@ -66,6 +70,14 @@ const (
 	// Hence ignoring the same from labels if exist
 	LabelJobControllerUid = "controller-uid"
 
+	// Labels set by the job controller for every run of a Job/CronJob
+	LabelJobName            = "job-name"
+	LabelBatchJobName       = "batch.kubernetes.io/job-name"
+	LabelBatchControllerUid = "batch.kubernetes.io/controller-uid"
+
+	// KubeArmor label used to select a container within a pod
+	LabelKubeArmorContainerName = "kubearmor.io/container.name"
+
 	// Constants to match with operations when aggregating summary data
 	FileOperation = "File"
 	NetworkOperation = "Network"
@@ -100,3 +100,27 @@ type WorkloadProcessFileSet struct {
 
 type PolicyNameMap map[WorkloadProcessFileSet]string
 type ResourceSetMap map[WorkloadProcessFileSet][]string
+
+// ================= //
+// == Dead Letter == //
+// ================= //
+
+// DeadLetter is a feed message which could not be parsed, kept for a later replay
+type DeadLetter struct {
+	ID          int64  `json:"id" bson:"id"`
+	Driver      string `json:"driver" bson:"driver"`
+	Topic       string `json:"topic" bson:"topic"`
+	Partition   int32  `json:"partition" bson:"partition"`
+	Offset      int64  `json:"offset" bson:"offset"`
+	MessageID   string `json:"message_id,omitempty" bson:"message_id"`
+	Payload     []byte `json:"payload" bson:"payload"`
+	Reason      string `json:"reason" bson:"reason"`
+	CreatedTime int64  `json:"created_time" bson:"created_time"`
+}
+
+// DeadLetterFilter selects the dead letters to list or replay
+type DeadLetterFilter struct {
+	Topic string
+	IDs   []int64
+	Limit int
+}
@@ -42,6 +42,16 @@ type Pod struct {
 	PodName   string   `json:"pod_name" bson:"pod_name"`
 	Labels    []string `json:"labels" bson:"labels"`
 	PodIP     string   `json:"pod_ip" bson:"pod_ip"`
+
+	InitContainers []string `json:"init_containers,omitempty" bson:"init_containers,omitempty"`
+	OwnerKind      string   `json:"owner_kind,omitempty" bson:"owner_kind,omitempty"`
+	OwnerName      string   `json:"owner_name,omitempty" bson:"owner_name,omitempty"`
+}
+
+// Node Structure
+type Node struct {
+	NodeName string   `json:"node_name" bson:"node_name"`
+	Labels   []string `json:"labels" bson:"labels"`
 }
 
 // Deployment Structure
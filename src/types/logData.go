@@ -36,9 +36,19 @@ type KnoxNetworkLog struct {
 	HTTPMethod string `json:"http_method,omitempty" bson:"http_method"` // for L7 http
 	HTTPPath   string `json:"http_path,omitempty" bson:"http_path"`     // for L7 http
 
+	UpstreamCluster string `json:"upstream_cluster,omitempty" bson:"upstream_cluster"` // for service mesh access logs
+
 	Direction string `json:"direction,omitempty" bson:"direction"` // ingress or egress
 
-	Action string `json:"action,omitempty" bson:"action"`
+	Action  string `json:"action,omitempty" bson:"action"`
+	Verdict string `json:"verdict,omitempty" bson:"verdict"` // FORWARDED, DROPPED, ...
+
+	DropReason string `json:"drop_reason,omitempty" bson:"drop_reason"` // POLICY_DENIED, ... for the dropped flows
+
+	// set when the log is compacted: the number of flows and their first/last time
+	Total       int   `json:"total,omitempty" bson:"total"`
+	StartTime   int64 `json:"start_time,omitempty" bson:"start_time"`
+	UpdatedTime int64 `json:"updated_time,omitempty" bson:"updated_time"`
 }
 
 // KnoxSystemLog Structure
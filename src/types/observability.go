@@ -29,6 +29,7 @@ type CiliumLog struct {
 	IpEncrypted                 bool   `json:"ip_encrypted,omitempty"`
 	L4TCPSourcePort             uint32 `json:"l4_tcp_source_port,omitempty"`
 	L4TCPDestinationPort        uint32 `json:"l4_tcp_destination_port,omitempty"`
+	L4TCPFlags                  string `json:"l4_tcp_flags,omitempty"`
 	L4UDPSourcePort             uint32 `json:"l4_udp_source_port,omitempty"`
 	L4UDPDestinationPort        uint32 `json:"l4_udp_destination_port,omitempty"`
 	L4ICMPv4Type                uint32 `json:"l4_icmpv4_type,omitempty"`
@@ -134,6 +135,15 @@ type SystemSummary struct {
 	Workload       Workload `json:"Workload,omitempty"`
 }
 
+// SystemSummaryRollup is the count of the summaries of a workload in the hourly or the daily
+// bucket of its time, the summary keeps no pod
+type SystemSummaryRollup struct {
+	Granularity string        `json:"Granularity"`
+	BucketTime  int64         `json:"BucketTime"`
+	Summary     SystemSummary `json:"Summary"`
+	Count       int64         `json:"Count"`
+}
+
 type SysSummaryTimeCount struct {
 	Count       int32 `json:"Count,omitempty"`
 	UpdatedTime int64 `json:"UpdatedTime,omitempty"`
@@ -281,7 +281,8 @@ type KnoxSystemSpec struct {
 	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
 	Message  string   `json:"message,omitempty" yaml:"message,omitempty"`
 
-	Selector Selector `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Selector     Selector `json:"selector,omitempty" yaml:"selector,omitempty"`
+	NodeSelector Selector `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
 
 	Process KnoxSys     `json:"process,omitempty" yaml:"process,omitempty"`
 	File    KnoxSys     `json:"file,omitempty" yaml:"file,omitempty"`
@@ -322,9 +323,27 @@ type KubeArmorPolicy struct {
 type PolicyFilter struct {
 	Cluster   string
 	Namespace string
+	Kind      string
 	Labels    LabelMap
 }
 
+// NetworkPolicyFilter selects the network policies of a query, the empty fields match any
+type NetworkPolicyFilter struct {
+	Cluster   string
+	Namespace string
+	Status    string
+	Type      string
+	Rule      string
+	Selector  map[string]string // the exact matchLabels of the selector of the policies
+}
+
+// PageRequest selects a page of the results of a query in the order they were stored,
+// a zero Size returns all the results after the token
+type PageRequest struct {
+	Size  int
+	Token string // the next page token of the previous page, empty for the first page
+}
+
 // PolicyYaml stores a policy in YAML format along with its metadata
 type PolicyYaml struct {
 	Type        string   `json:"type,omitempty"`
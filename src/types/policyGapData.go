@@ -0,0 +1,26 @@
+package types
+
+// ================ //
+// == Policy Gap == //
+// ================ //
+
+// PolicyGap is a connection dropped by the network policies in place, along with
+// the policy the workload would need to allow it
+type PolicyGap struct {
+	ClusterName string `json:"cluster_name" bson:"cluster_name"`
+	Namespace   string `json:"namespace" bson:"namespace"`
+	Workload    string `json:"workload" bson:"workload"` // labels of the workload, or its pod name
+	Direction   string `json:"direction" bson:"direction"`
+	Peer        string `json:"peer" bson:"peer"` // labels of the peer, entity, fqdn or ip
+	Protocol    string `json:"protocol" bson:"protocol"`
+	Port        int    `json:"port,omitempty" bson:"port"`
+	L7          string `json:"l7,omitempty" bson:"l7"`
+	DropReason  string `json:"drop_reason" bson:"drop_reason"`
+	Count       int    `json:"count" bson:"count"`
+	FirstSeen   int64  `json:"first_seen" bson:"first_seen"`
+	LastSeen    int64  `json:"last_seen" bson:"last_seen"`
+
+	// SuggestedPolicy is the minimal policy allowing the connection, named after the
+	// discovered policy of the workload it would be merged into
+	SuggestedPolicy *CiliumNetworkPolicy `json:"suggested_policy,omitempty" bson:"suggested_policy,omitempty"`
+}